@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"medods-test-task/config"
+	"medods-test-task/internal/db/postgres"
+	"medods-test-task/internal/models"
+	"medods-test-task/internal/repository"
+	"medods-test-task/internal/service"
+	"medods-test-task/pkg/logger"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joho/godotenv"
+	"go.uber.org/zap"
+)
+
+const serviceName = "medods_oauth_clients"
+
+const usage = `usage: oauth-clients <command> [flags]
+
+commands:
+  create         register a client, run "oauth-clients create -h" for flags
+  list           list registered clients
+  rotate-secret  issue a new secret, usage: oauth-clients rotate-secret <id>
+  delete         delete a client and end its sessions, usage: oauth-clients delete <id>
+`
+
+type clientService interface {
+	CreateClient(ctx context.Context, params models.OAuthClientParams) (*models.OAuthClient, error)
+	ListClients(ctx context.Context) ([]models.OAuthClient, error)
+	RotateSecret(ctx context.Context, clientID string) (*models.OAuthClient, error)
+	DeleteClient(ctx context.Context, clientID string) error
+}
+
+// listFlag collects a flag that may be repeated or hold comma-separated
+// values.
+type listFlag []string
+
+func (f *listFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *listFlag) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*f = append(*f, item)
+		}
+	}
+
+	return nil
+}
+
+// oauth-clients manages the oauth client registry without going through the
+// admin api, for example to register the first clients of a new deployment.
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	logs, err := logger.New(serviceName)
+	if err != nil {
+		panic(err)
+	}
+
+	ctx := logger.SetToCtx(context.Background(), logs)
+
+	if err := godotenv.Load(".env"); err != nil {
+		logs.Fatal(ctx, "Error loading .env file", zap.Error(err))
+	}
+
+	cfg := config.NewSettings()
+
+	db := postgres.New(ctx, &cfg.Postgres)
+	defer db.Close()
+
+	clients := service.NewClientService(repository.NewClientRepo(db))
+
+	switch os.Args[1] {
+	case "create":
+		err = create(ctx, clients, os.Args[2:])
+	case "list":
+		err = list(ctx, clients)
+	case "rotate-secret":
+		err = rotateSecret(ctx, clients, os.Args[2:])
+	case "delete":
+		err = remove(ctx, clients, os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+
+		db.Close()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "oauth-clients %s: %v\n", os.Args[1], err)
+
+		db.Close()
+		os.Exit(1)
+	}
+}
+
+func create(ctx context.Context, clients clientService, args []string) error {
+	var (
+		params                   models.OAuthClientParams
+		redirectURIs, grantTypes listFlag
+		scopes                   listFlag
+		accessTTL, refreshTTL    time.Duration
+		publicKeyPath            string
+	)
+
+	flags := flag.NewFlagSet("create", flag.ExitOnError)
+	flags.StringVar(&params.ID, "id", "", "client id, generated when empty")
+	flags.StringVar(&params.Name, "name", "", "name shown on the consent page")
+	flags.BoolVar(&params.Confidential, "confidential", false, "issue a client secret")
+	flags.StringVar(&publicKeyPath, "public-key-file", "", "PEM encoded public key for private_key_jwt")
+	flags.Var(&redirectURIs, "redirect-uri", "allowed redirect uri, may be repeated")
+	flags.Var(&grantTypes, "grant-type", "allowed grant type, may be repeated (default authorization_code,refresh_token)")
+	flags.Var(&scopes, "scope", "scope the client may request, may be repeated")
+	flags.DurationVar(&accessTTL, "access-ttl", 0, "access token ttl, the server default when 0")
+	flags.DurationVar(&refreshTTL, "refresh-ttl", 0, "refresh token ttl, the server default when 0")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if publicKeyPath != "" {
+		key, err := os.ReadFile(publicKeyPath)
+		if err != nil {
+			return err
+		}
+
+		params.PublicKey = string(key)
+	}
+
+	params.RedirectURIs = redirectURIs
+	params.GrantTypes = grantTypes
+	params.Scopes = scopes
+	params.AccessTokenTTL = accessTTL
+	params.RefreshTokenTTL = refreshTTL
+
+	client, err := clients.CreateClient(ctx, params)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("client_id: %s\n", client.ID)
+
+	if client.Secret != "" {
+		fmt.Printf("client_secret: %s\n", client.Secret)
+		fmt.Println("the secret is shown only once, store it now")
+	}
+
+	return nil
+}
+
+func list(ctx context.Context, clients clientService) error {
+	all, err := clients.ListClients(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tTYPE\tGRANT TYPES\tREDIRECT URIS\tSCOPES")
+
+	for _, client := range all {
+		kind := "public"
+		if client.IsConfidential() {
+			kind = "confidential"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", client.ID, client.Name, kind,
+			strings.Join(client.GrantTypes, ","), strings.Join(client.RedirectURIs, ","), strings.Join(client.Scopes, ","))
+	}
+
+	return w.Flush()
+}
+
+func rotateSecret(ctx context.Context, clients clientService, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected a client id")
+	}
+
+	client, err := clients.RotateSecret(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("client_secret: %s\n", client.Secret)
+	fmt.Println("the secret is shown only once, store it now")
+
+	return nil
+}
+
+func remove(ctx context.Context, clients clientService, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected a client id")
+	}
+
+	if err := clients.DeleteClient(ctx, args[0]); err != nil {
+		return err
+	}
+
+	fmt.Printf("client %s deleted\n", args[0])
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"medods-test-task/config"
+	"medods-test-task/internal/db/postgres"
+	"medods-test-task/internal/repository"
+	"medods-test-task/internal/service"
+	"medods-test-task/pkg/logger"
+	"os"
+
+	"github.com/joho/godotenv"
+	"go.uber.org/zap"
+)
+
+const serviceName = "medods_verify_audit"
+
+// verify-audit walks the auth_events hash chain and its daily checkpoints and
+// exits with a non-zero code when a record was altered or deleted.
+func main() {
+	logs, err := logger.New(serviceName)
+	if err != nil {
+		panic(err)
+	}
+
+	ctx := logger.SetToCtx(context.Background(), logs)
+
+	if err := godotenv.Load(".env"); err != nil {
+		logs.Fatal(ctx, "Error loading .env file", zap.Error(err))
+	}
+
+	cfg := config.NewSettings()
+
+	db := postgres.New(ctx, &cfg.Postgres)
+	defer db.Close()
+
+	audit := service.NewAuditService(repository.NewAuditRepo(db), logs, cfg.AuthJWT.Secret)
+
+	res, err := audit.Verify(ctx)
+	if err != nil {
+		logs.Fatal(ctx, "failed to verify audit log", zap.Error(err))
+	}
+
+	if res.Broken() {
+		fmt.Printf("audit chain is broken at event #%d: %s\n", res.BrokenAt, res.Reason)
+		fmt.Printf("%d events and %d checkpoints verified before the broken link\n", res.Events, res.Checkpoints)
+
+		db.Close()
+		os.Exit(1)
+	}
+
+	fmt.Printf("audit chain is intact: %d events and %d checkpoints verified\n", res.Events, res.Checkpoints)
+}
@@ -1,15 +1,28 @@
 package config
 
 import (
+	"errors"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
 )
 
+const (
+	ModeDevelopment = "development"
+	ModeProduction  = "production"
+)
+
+type AppConfig struct {
+	Mode string
+}
+
 type AuthJWT struct {
 	Secret          string
 	AccessTokenTTL  time.Duration
 	RefreshTokenTTL time.Duration
+	RevokeTokenTTL  time.Duration
 }
 
 type PostgresConfig struct {
@@ -26,8 +39,18 @@ type ServerConfig struct {
 }
 
 type EmailConfig struct {
-	IPWarningSubject  string
-	IPWarningTemplate string
+	TemplatesDir  string
+	DefaultLocale string
+	PublicURL     string
+	GeoIPDatabase string
+	// ChangeTTL is how long the link confirming a new address stays valid.
+	ChangeTTL time.Duration
+	// DisposableDomainsPath points to a file of extra disposable domains added
+	// to the bundled list.
+	DisposableDomainsPath string
+	// Canonicalize merges provider-specific spellings of an address, such as
+	// Gmail dots and plus tags, when looking for duplicate users.
+	Canonicalize bool
 }
 
 type SMTPConfig struct {
@@ -36,7 +59,30 @@ type SMTPConfig struct {
 	Port     int
 	Password string
 	Domain   string
+
+	PoolSize    int
+	QueueSize   int
+	IdleTimeout time.Duration
+
+	DKIMSelector       string
+	DKIMPrivateKeyPath string
+
+	// Relays lists "host:port" addresses in priority order. When empty, Host
+	// and Port are the only relay.
+	Relays           []string
+	BreakerThreshold int
+	BreakerTimeout   time.Duration
+}
+
+type MailConfig struct {
+	Transport string
+	Dir       string
+}
+
+type SMSConfig struct {
+	Provider string
 }
+
 type HttpConfig struct {
 	Host               string
 	Port               string
@@ -45,23 +91,171 @@ type HttpConfig struct {
 	MaxHeaderMegabytes int
 }
 
+type RateLimit struct {
+	Limit  int
+	Period time.Duration
+}
+
+type RouteRateLimitConfig struct {
+	PerIP   RateLimit
+	PerUser RateLimit
+	Global  RateLimit
+}
+
+type RateLimitConfig struct {
+	Backend     string
+	Login       RouteRateLimitConfig
+	Refresh     RouteRateLimitConfig
+	EmailChange RouteRateLimitConfig
+}
+
+type BruteForceConfig struct {
+	BackoffAfter int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	LockAfter    int
+	LockDuration time.Duration
+	Window       time.Duration
+}
+
+type WebhookConfig struct {
+	MaxAttempts  int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	Timeout      time.Duration
+	PollInterval time.Duration
+	BatchSize    int
+}
+
+type OutboxConfig struct {
+	MaxAttempts  int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	Lease        time.Duration
+	PollInterval time.Duration
+	BatchSize    int
+}
+
+type ThrottleConfig struct {
+	// DedupWindow drops repeats of the same notification to a user. Zero
+	// disables deduplication.
+	DedupWindow time.Duration
+	// HourlyCap limits emails per recipient per hour. Zero disables the cap.
+	HourlyCap      int
+	DigestInterval time.Duration
+}
+
+type PasswordConfig struct {
+	MinLength   int
+	MaxLength   int
+	BannedWords []string
+	// BreachCorpusPath points to a sorted file of breached SHA-1 hashes. The
+	// breach check is skipped when it is empty.
+	BreachCorpusPath string
+	BreachThreshold  int
+}
+
+type OAuthConfig struct {
+	// Issuer is the public url of the server, client assertions must name it
+	// or its token endpoint as the audience.
+	Issuer         string
+	CodeTTL        time.Duration
+	ClientTokenTTL time.Duration
+	// DeviceCodeTTL is how long a device code waits for the user, devices
+	// poll the token endpoint at most once per DevicePollInterval.
+	DeviceCodeTTL      time.Duration
+	DevicePollInterval time.Duration
+	// ExchangeAudiences are the services tokens can be exchanged for, the
+	// exchanged tokens live at most ExchangeTokenTTL.
+	ExchangeAudiences []string
+	ExchangeTokenTTL  time.Duration
+	// LegacyClients maps client ids to their redirect uris. It is read from
+	// the deprecated OAUTH_CLIENTS, the clients are registered at startup.
+	LegacyClients map[string][]string
+}
+
+// RolesConfig maps each role to the scopes its users may be granted. Roles
+// missing from the map cannot be assigned.
+type RolesConfig struct {
+	Scopes map[string][]string
+}
+
+// AllScopes returns the scopes granted by any role, sorted.
+func (cfg *RolesConfig) AllScopes() []string {
+	var scopes []string
+
+	for _, list := range cfg.Scopes {
+		for _, scope := range list {
+			if !slices.Contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+
+	slices.Sort(scopes)
+
+	return scopes
+}
+
+// exampleAdminToken was shipped in .env, so it is publicly known and must not
+// guard a deployment.
+const exampleAdminToken = "admin-secret"
+
+var ErrExampleAdminToken = errors.New("ADMIN_API_TOKEN must not be the example token " + exampleAdminToken)
+
+// AdminConfig holds the static bearer token of the operator endpoints. They
+// are not served when Token is empty.
+type AdminConfig struct {
+	Token string
+}
+
+// Enabled reports whether the operator endpoints are served.
+func (cfg *AdminConfig) Enabled() bool {
+	return cfg.Token != ""
+}
+
+// Validate rejects the publicly known example token.
+func (cfg *AdminConfig) Validate() error {
+	if cfg.Token == exampleAdminToken {
+		return ErrExampleAdminToken
+	}
+
+	return nil
+}
+
 type Config struct {
-	AuthJWT  AuthJWT
-	Postgres PostgresConfig
-	HTTP     HttpConfig
-	Server   ServerConfig
-	SMTP     SMTPConfig
-	Email    EmailConfig
+	App        AppConfig
+	AuthJWT    AuthJWT
+	Postgres   PostgresConfig
+	HTTP       HttpConfig
+	Server     ServerConfig
+	SMTP       SMTPConfig
+	Mail       MailConfig
+	SMS        SMSConfig
+	Email      EmailConfig
+	RateLimit  RateLimitConfig
+	BruteForce BruteForceConfig
+	Webhook    WebhookConfig
+	Outbox     OutboxConfig
+	Throttle   ThrottleConfig
+	Password   PasswordConfig
+	OAuth      OAuthConfig
+	Roles      RolesConfig
+	Admin      AdminConfig
 }
 
 func NewSettings() *Config {
 	viper.AutomaticEnv()
 
 	return &Config{
+		App: AppConfig{
+			Mode: viper.GetString("APP_MODE"),
+		},
 		AuthJWT: AuthJWT{
 			Secret:          viper.GetString("JWT_SECRET"),
 			AccessTokenTTL:  viper.GetDuration("ACCESS_TOKEN_TTL"),
 			RefreshTokenTTL: viper.GetDuration("REFRESH_TOKEN_TTL"),
+			RevokeTokenTTL:  viper.GetDuration("REVOKE_TOKEN_TTL"),
 		},
 		Postgres: PostgresConfig{
 			Host:     viper.GetString("DB_HOST"),
@@ -87,12 +281,155 @@ func NewSettings() *Config {
 			Port:     viper.GetInt("SMTP_PORT"),
 			Password: viper.GetString("SMTP_PASSWORD"),
 			Domain:   viper.GetString("DOMAIN"),
+
+			PoolSize:    viper.GetInt("SMTP_POOL_SIZE"),
+			QueueSize:   viper.GetInt("SMTP_QUEUE_SIZE"),
+			IdleTimeout: viper.GetDuration("SMTP_IDLE_TIMEOUT"),
+
+			DKIMSelector:       viper.GetString("DKIM_SELECTOR"),
+			DKIMPrivateKeyPath: viper.GetString("DKIM_PRIVATE_KEY_PATH"),
+
+			Relays:           splitList(viper.GetString("SMTP_RELAYS")),
+			BreakerThreshold: viper.GetInt("SMTP_BREAKER_THRESHOLD"),
+			BreakerTimeout:   viper.GetDuration("SMTP_BREAKER_TIMEOUT"),
+		},
+		Mail: MailConfig{
+			Transport: viper.GetString("MAIL_TRANSPORT"),
+			Dir:       viper.GetString("MAIL_DIR"),
+		},
+		SMS: SMSConfig{
+			Provider: viper.GetString("SMS_PROVIDER"),
 		},
 		Email: EmailConfig{
-			IPWarningSubject:  viper.GetString("IP_WARNING_SUBJECT"),
-			IPWarningTemplate: viper.GetString("IP_WARNING_TEMPLATE"),
+			TemplatesDir:  viper.GetString("EMAIL_TEMPLATES_DIR"),
+			DefaultLocale: viper.GetString("EMAIL_DEFAULT_LOCALE"),
+			PublicURL:     viper.GetString("PUBLIC_URL"),
+			GeoIPDatabase: viper.GetString("GEOIP_DATABASE"),
+			ChangeTTL:     viper.GetDuration("EMAIL_CHANGE_TTL"),
+
+			DisposableDomainsPath: viper.GetString("EMAIL_DISPOSABLE_DOMAINS_PATH"),
+			Canonicalize:          viper.GetBool("EMAIL_CANONICALIZE"),
 		},
+		RateLimit: RateLimitConfig{
+			Backend:     viper.GetString("RATE_LIMIT_BACKEND"),
+			Login:       routeRateLimit("LOGIN"),
+			Refresh:     routeRateLimit("REFRESH"),
+			EmailChange: routeRateLimit("EMAIL_CHANGE"),
+		},
+		BruteForce: BruteForceConfig{
+			BackoffAfter: viper.GetInt("BRUTE_FORCE_BACKOFF_AFTER"),
+			BaseDelay:    viper.GetDuration("BRUTE_FORCE_BASE_DELAY"),
+			MaxDelay:     viper.GetDuration("BRUTE_FORCE_MAX_DELAY"),
+			LockAfter:    viper.GetInt("BRUTE_FORCE_LOCK_AFTER"),
+			LockDuration: viper.GetDuration("BRUTE_FORCE_LOCK_DURATION"),
+			Window:       viper.GetDuration("BRUTE_FORCE_WINDOW"),
+		},
+		Webhook: WebhookConfig{
+			MaxAttempts:  viper.GetInt("WEBHOOK_MAX_ATTEMPTS"),
+			BaseDelay:    viper.GetDuration("WEBHOOK_BASE_DELAY"),
+			MaxDelay:     viper.GetDuration("WEBHOOK_MAX_DELAY"),
+			Timeout:      viper.GetDuration("WEBHOOK_TIMEOUT"),
+			PollInterval: viper.GetDuration("WEBHOOK_POLL_INTERVAL"),
+			BatchSize:    viper.GetInt("WEBHOOK_BATCH_SIZE"),
+		},
+		Outbox: OutboxConfig{
+			MaxAttempts:  viper.GetInt("EMAIL_OUTBOX_MAX_ATTEMPTS"),
+			BaseDelay:    viper.GetDuration("EMAIL_OUTBOX_BASE_DELAY"),
+			MaxDelay:     viper.GetDuration("EMAIL_OUTBOX_MAX_DELAY"),
+			Lease:        viper.GetDuration("EMAIL_OUTBOX_LEASE"),
+			PollInterval: viper.GetDuration("EMAIL_OUTBOX_POLL_INTERVAL"),
+			BatchSize:    viper.GetInt("EMAIL_OUTBOX_BATCH_SIZE"),
+		},
+		Throttle: ThrottleConfig{
+			DedupWindow:    viper.GetDuration("EMAIL_DEDUP_WINDOW"),
+			HourlyCap:      viper.GetInt("EMAIL_HOURLY_CAP"),
+			DigestInterval: viper.GetDuration("EMAIL_DIGEST_INTERVAL"),
+		},
+		Password: PasswordConfig{
+			MinLength:        viper.GetInt("PASSWORD_MIN_LENGTH"),
+			MaxLength:        viper.GetInt("PASSWORD_MAX_LENGTH"),
+			BannedWords:      splitList(viper.GetString("PASSWORD_BANNED_WORDS")),
+			BreachCorpusPath: viper.GetString("PASSWORD_BREACH_CORPUS_PATH"),
+			BreachThreshold:  viper.GetInt("PASSWORD_BREACH_THRESHOLD"),
+		},
+		OAuth: OAuthConfig{
+			Issuer:             viper.GetString("PUBLIC_URL"),
+			CodeTTL:            viper.GetDuration("OAUTH_CODE_TTL"),
+			ClientTokenTTL:     viper.GetDuration("OAUTH_CLIENT_TOKEN_TTL"),
+			DeviceCodeTTL:      viper.GetDuration("OAUTH_DEVICE_CODE_TTL"),
+			DevicePollInterval: viper.GetDuration("OAUTH_DEVICE_POLL_INTERVAL"),
+			ExchangeAudiences:  splitList(viper.GetString("OAUTH_EXCHANGE_AUDIENCES")),
+			ExchangeTokenTTL:   viper.GetDuration("OAUTH_EXCHANGE_TOKEN_TTL"),
+			LegacyClients:      splitPairs(viper.GetString("OAUTH_CLIENTS")),
+		},
+		Roles: RolesConfig{
+			Scopes: roleScopes(viper.GetString("ROLE_SCOPES")),
+		},
+		Admin: AdminConfig{
+			Token: viper.GetString("ADMIN_API_TOKEN"),
+		},
+	}
+}
+
+func routeRateLimit(route string) RouteRateLimitConfig {
+	period := viper.GetDuration(route + "_RATE_LIMIT_PERIOD")
+
+	return RouteRateLimitConfig{
+		PerIP:   RateLimit{Limit: viper.GetInt(route + "_RATE_LIMIT_IP"), Period: period},
+		PerUser: RateLimit{Limit: viper.GetInt(route + "_RATE_LIMIT_USER"), Period: period},
+		Global:  RateLimit{Limit: viper.GetInt(route + "_RATE_LIMIT_GLOBAL"), Period: period},
+	}
+}
+
+// splitList splits a comma-separated value, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// splitPairs parses a comma-separated list of key=value pairs. A key may be
+// repeated to collect several values.
+func splitPairs(value string) map[string][]string {
+	pairs := make(map[string][]string)
+
+	for _, item := range splitList(value) {
+		key, val, ok := strings.Cut(item, "=")
+		if !ok {
+			continue
+		}
+
+		key = strings.TrimSpace(key)
+		pairs[key] = append(pairs[key], strings.TrimSpace(val))
 	}
+
+	return pairs
+}
+
+// roleScopes parses comma-separated "role=scope scope" entries.
+func roleScopes(value string) map[string][]string {
+	scopes := make(map[string][]string)
+
+	for _, item := range splitList(value) {
+		role, list, _ := strings.Cut(item, "=")
+		if role = strings.TrimSpace(role); role != "" {
+			scopes[role] = strings.Fields(list)
+		}
+	}
+
+	return scopes
+}
+
+// IsDevelopment reports whether development-only features may be enabled.
+// Any mode other than development is treated as production.
+func (cfg *Config) IsDevelopment() bool {
+	return cfg.App.Mode == ModeDevelopment
 }
 
 func (cfg *Config) GetAuthJWTSecret() string {
@@ -106,3 +443,7 @@ func (cfg *Config) GetAccessTokenExpiration() time.Duration {
 func (cfg *Config) GetRefreshTokenExpiration() time.Duration {
 	return time.Duration(cfg.AuthJWT.RefreshTokenTTL)
 }
+
+func (cfg *Config) GetRevokeTokenExpiration() time.Duration {
+	return cfg.AuthJWT.RevokeTokenTTL
+}
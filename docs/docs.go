@@ -15,9 +15,52 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/auth/login": {
+        "/admin/clients": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Lists registered oauth clients",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "ListClients",
+                "responses": {
+                    "200": {
+                        "description": "Registered clients",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/internal_transport_http.ClientResponse"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "An unexpected error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    }
+                }
+            },
             "post": {
-                "description": "Authenticates a user and generates access and refresh tokens",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Registers an oauth client. The secret of a confidential client is returned only once.",
                 "consumes": [
                     "application/json"
                 ],
@@ -25,27 +68,41 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "auth"
+                    "admin"
                 ],
-                "summary": "Login",
+                "summary": "CreateClient",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "User GUID",
-                        "name": "user_id",
-                        "in": "query",
-                        "required": true
+                        "description": "Client settings",
+                        "name": "client",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.CreateClientRequest"
+                        }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "access_token \u0026 refresh_token",
+                    "201": {
+                        "description": "Registered client with its secret",
                         "schema": {
-                            "$ref": "#/definitions/internal_transport_http.TokenResponse"
+                            "$ref": "#/definitions/internal_transport_http.ClientResponse"
                         }
                     },
                     "400": {
-                        "description": "User id is empty",
+                        "description": "Invalid client settings",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Client id is taken",
                         "schema": {
                             "$ref": "#/definitions/internal_transport_http.ErrorResponse"
                         }
@@ -59,9 +116,64 @@ const docTemplate = `{
                 }
             }
         },
-        "/auth/refresh": {
-            "post": {
-                "description": "Refreshes token pair",
+        "/admin/clients/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns an oauth client",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "GetClient",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Client id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Client",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ClientResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Client was not found",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "An unexpected error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Replaces the settings of an oauth client. The secret and the sessions of the client are kept.",
                 "consumes": [
                     "application/json"
                 ],
@@ -69,29 +181,36 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "auth"
+                    "admin"
                 ],
-                "summary": "RefreshToken",
+                "summary": "UpdateClient",
                 "parameters": [
                     {
-                        "description": "Refresh Token",
-                        "name": "token",
+                        "type": "string",
+                        "description": "Client id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Client settings",
+                        "name": "client",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/internal_transport_http.RefreshTokenRequest"
+                            "$ref": "#/definitions/internal_transport_http.UpdateClientRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "access_token \u0026 refresh_token",
+                        "description": "Updated client",
                         "schema": {
-                            "$ref": "#/definitions/internal_transport_http.TokenResponse"
+                            "$ref": "#/definitions/internal_transport_http.ClientResponse"
                         }
                     },
                     "400": {
-                        "description": "Token is invalid",
+                        "description": "Invalid client settings",
                         "schema": {
                             "$ref": "#/definitions/internal_transport_http.ErrorResponse"
                         }
@@ -102,8 +221,8 @@ const docTemplate = `{
                             "$ref": "#/definitions/internal_transport_http.ErrorResponse"
                         }
                     },
-                    "403": {
-                        "description": "Session is invalid",
+                    "404": {
+                        "description": "Client was not found",
                         "schema": {
                             "$ref": "#/definitions/internal_transport_http.ErrorResponse"
                         }
@@ -115,36 +234,1930 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        }
-    },
-    "definitions": {
-        "internal_transport_http.ErrorResponse": {
-            "type": "object",
-            "properties": {
-                "error": {
-                    "description": "Error message",
-                    "type": "string"
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Deletes an oauth client and ends its sessions",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "DeleteClient",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Client id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Deleted"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Client was not found",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "An unexpected error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    }
                 }
             }
         },
-        "internal_transport_http.RefreshTokenRequest": {
-            "type": "object",
-            "properties": {
-                "refresh_token": {
-                    "type": "string"
+        "/admin/clients/{id}/secret": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Issues a new secret for an oauth client, the old secret stops working at once. A public client becomes confidential.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "RotateClientSecret",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Client id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Client with its new secret",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ClientResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Client was not found",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "An unexpected error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    }
                 }
             }
         },
-        "internal_transport_http.TokenResponse": {
-            "type": "object",
-            "properties": {
-                "access_token": {
-                    "description": "Access token",
-                    "type": "string"
-                },
-                "refresh_token": {
-                    "description": "Refresh token",
+        "/admin/users/{id}/api-keys": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Lists the api keys of a user, revoked ones included, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "ListUserAPIKeys",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Keys",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/internal_transport_http.APIKeyResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "User id must be a valid UUID",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "An unexpected error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Creates an api key of a user, such as a machine user. The key is returned only once.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "CreateUserAPIKey",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Key settings",
+                        "name": "key",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.CreateAPIKeyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created key",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.APIKeyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid key settings",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "User was not found",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "An unexpected error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{id}/api-keys/{keyID}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Revokes an api key of a user, it stops working at once",
+                "tags": [
+                    "admin"
+                ],
+                "summary": "RevokeUserAPIKey",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key id",
+                        "name": "keyID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Key revoked"
+                    },
+                    "400": {
+                        "description": "User id must be a valid UUID",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "API key was not found",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "An unexpected error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{id}/roles": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Replaces the roles of a user. The new roles are put into access tokens issued from now on, existing sessions get them on their next refresh.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "SetUserRoles",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Roles",
+                        "name": "roles",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.SetUserRolesRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "All roles of the user",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.UserRolesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Unknown role",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "User was not found",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "An unexpected error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/webhooks": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Lists registered webhook endpoints",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "ListWebhooks",
+                "responses": {
+                    "200": {
+                        "description": "Registered endpoints",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/internal_transport_http.WebhookEndpointResponse"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "An unexpected error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Registers an endpoint that receives signed security events. The signing secret is returned only once.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "RegisterWebhook",
+                "parameters": [
+                    {
+                        "description": "Endpoint url and events, all events when empty",
+                        "name": "endpoint",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.RegisterWebhookRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Registered endpoint with its secret",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.WebhookEndpointResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid url or unknown event",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "An unexpected error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/webhooks/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Deletes a webhook endpoint together with its pending deliveries",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "DeleteWebhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Endpoint id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Deleted"
+                    },
+                    "400": {
+                        "description": "Endpoint id must be a valid UUID",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Endpoint was not found",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "An unexpected error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/email/confirm": {
+            "get": {
+                "description": "Opens the confirmation link sent to the new address. It only asks the user to confirm, so that mail scanners following the link do not change the email.",
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "ConfirmEmailChangePage",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Token from the confirmation email",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Confirmation page",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Link is invalid",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Confirms the link sent to the new address and changes the email of the user. The link works only once.",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "ConfirmEmailChange",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Token from the confirmation email",
+                        "name": "token",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Email changed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Link is invalid",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Email is already used",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "Link has expired",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "An unexpected error occurred",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/login": {
+            "post": {
+                "description": "Authenticates a user and generates access and refresh tokens. Deprecated: clients should use the authorization code flow at /oauth/authorize.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Login",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User GUID (xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx)",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Space separated scopes of the user role, all of them when empty. Elevated roles are granted only through /oauth/authorize.",
+                        "name": "scope",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "access_token \u0026 refresh_token",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.TokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "User id must be a valid UUID",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "423": {
+                        "description": "Account is temporarily locked",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "An unexpected error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/refresh": {
+            "post": {
+                "description": "Refreshes token pair. Sessions created by an oauth client can be refreshed only by that client. Deprecated for oauth clients, which get a Deprecation header and should use grant_type=refresh_token at /oauth/token.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "RefreshToken",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "description": "Refresh Token",
+                        "name": "token",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.RefreshTokenRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "access_token \u0026 refresh_token",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.TokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Token is invalid",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Client credentials are wrong",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Refresh token was issued to another client",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "423": {
+                        "description": "Account is temporarily locked",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "An unexpected error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/revoke": {
+            "get": {
+                "description": "Opens the \"This wasn't me\" link from a security alert email. It only asks the user to confirm, so that mail scanners following the link do not end the sessions.",
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "RevokeSessionsPage",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Signed token from the alert email",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Confirmation page",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Link is invalid",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Confirms the \"This wasn't me\" link from a security alert email and ends every session of the user. The link works only once.",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "RevokeSessions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Signed token from the alert email",
+                        "name": "token",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Sessions revoked",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Link is invalid",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "Link has expired or was already used",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "An unexpected error occurred",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/me/api-keys": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Lists the api keys of the current user, revoked ones included, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "me"
+                ],
+                "summary": "ListAPIKeys",
+                "responses": {
+                    "200": {
+                        "description": "Keys",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/internal_transport_http.APIKeyResponse"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Token of the legacy login or an api key",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "An unexpected error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Creates an api key of the current user for scripts and machine users. The key gets no more roles and scopes than the access token it is created with. The key is returned only once. It is sent as the bearer token or in the X-API-Key header and cannot manage credentials.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "me"
+                ],
+                "summary": "CreateAPIKey",
+                "parameters": [
+                    {
+                        "description": "Key settings",
+                        "name": "key",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.CreateAPIKeyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created key",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.APIKeyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid key settings",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Token of the legacy login or an api key",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "An unexpected error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/me/api-keys/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Revokes an api key of the current user, it stops working at once",
+                "tags": [
+                    "me"
+                ],
+                "summary": "RevokeAPIKey",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Key id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Key revoked"
+                    },
+                    "400": {
+                        "description": "Key id must be a valid UUID",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Token of the legacy login or an api key",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "API key was not found",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "An unexpected error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/me/devices": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Approves or denies a device that signs in with the device authorization grant. Show the user what GET /me/devices/{userCode} returns before they approve. The device gets its tokens on its next poll of /oauth/token.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "me"
+                ],
+                "summary": "ConfirmDevice",
+                "parameters": [
+                    {
+                        "description": "User code and decision",
+                        "name": "device",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ConfirmDeviceRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Decision recorded"
+                    },
+                    "400": {
+                        "description": "Invalid or expired code",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Token of the legacy login or an api key",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "An unexpected error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/me/devices/{userCode}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Shows the client and scope a device waiting for the user code asks for, to be shown to the user before they approve it with POST /me/devices.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "me"
+                ],
+                "summary": "GetDeviceRequest",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Code shown on the device",
+                        "name": "userCode",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Client and scope",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.DeviceRequestResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Token of the legacy login or an api key",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Invalid or expired code",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "An unexpected error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/me/email": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Starts changing the email of the current user. The new address gets a confirmation link and the current one a notice; the email changes only after the link is confirmed. The response is the same when the address belongs to another account, which then gets no link.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "me"
+                ],
+                "summary": "ChangeEmail",
+                "parameters": [
+                    {
+                        "description": "New email",
+                        "name": "email",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ChangeEmailRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Confirmation sent",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.MessageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid or disposable email",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Token of the legacy login or an api key",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "User was not found",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "An unexpected error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/me/notifications": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns the channels of every security notification of the current user",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "me"
+                ],
+                "summary": "GetNotificationSettings",
+                "responses": {
+                    "200": {
+                        "description": "Notification settings",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.NotificationSettingsResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Token lacks the profile scope",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "User was not found",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "An unexpected error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Chooses the channels of security notifications. Events are new_device, ip_change, password_change and session_revoked; channels are email, webhook and sms. The webhook signing secret is returned only when the url changes. A new phone is texted a code and can be chosen for sms once the code is confirmed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "me"
+                ],
+                "summary": "UpdateNotificationSettings",
+                "parameters": [
+                    {
+                        "description": "Changed settings",
+                        "name": "settings",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.UpdateNotificationSettingsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Notification settings",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.NotificationSettingsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid settings",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Token lacks the profile scope",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "User was not found",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "A phone code was sent recently",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "An unexpected error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/me/notifications/phone": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Confirms the new phone with the code texted to it. The phone then replaces the current one and can be chosen for the sms channel.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "me"
+                ],
+                "summary": "ConfirmPhone",
+                "parameters": [
+                    {
+                        "description": "Texted code",
+                        "name": "code",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ConfirmPhoneRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Phone confirmed"
+                    },
+                    "400": {
+                        "description": "Invalid or expired code",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Token lacks the profile scope",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "An unexpected error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/me/password": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Sets or changes the password of the current user. The password is checked against the password policy and a local corpus of breached passwords; a rejected password names the failed rule.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "me"
+                ],
+                "summary": "SetPassword",
+                "parameters": [
+                    {
+                        "description": "Passwords",
+                        "name": "password",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.SetPasswordRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Password changed",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.MessageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Password does not meet the policy",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.PasswordErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Current password is wrong, or a token of the legacy login or an api key",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "User was not found",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "423": {
+                        "description": "Account is temporarily locked",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many failed attempts",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "An unexpected error occurred",
+                        "schema": {
+                            "$ref": "#/definitions/internal_transport_http.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "internal_transport_http.APIKeyResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "description": "Absent when the key does not expire",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "Key id",
+                    "type": "string"
+                },
+                "key": {
+                    "description": "The key, returned only on creation",
+                    "type": "string"
+                },
+                "last_used_at": {
+                    "type": "string"
+                },
+                "last_used_ip": {
+                    "description": "Ip of the last use",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Name given by the owner",
+                    "type": "string"
+                },
+                "prefix": {
+                    "description": "Start of the key, identifies it without revealing it",
+                    "type": "string"
+                },
+                "revoked_at": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "description": "Scopes granted to the key",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "internal_transport_http.ChangeEmailRequest": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_transport_http.ClientResponse": {
+            "type": "object",
+            "properties": {
+                "access_token_ttl": {
+                    "description": "Access token ttl in seconds, 0 for the server default",
+                    "type": "integer"
+                },
+                "confidential": {
+                    "description": "Whether the client authenticates with a secret or a key",
+                    "type": "boolean"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "grant_types": {
+                    "description": "Allowed grant types",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "description": "Client id",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Name shown on the consent page",
+                    "type": "string"
+                },
+                "public_key": {
+                    "description": "PEM encoded key verifying private_key_jwt assertions",
+                    "type": "string"
+                },
+                "redirect_uris": {
+                    "description": "Allowed redirect uris",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "refresh_token_ttl": {
+                    "description": "Refresh token ttl in seconds, 0 for the server default",
+                    "type": "integer"
+                },
+                "scopes": {
+                    "description": "Scopes the client may request",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "secret": {
+                    "description": "Client secret, returned only on creation and rotation",
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_transport_http.ConfirmDeviceRequest": {
+            "type": "object",
+            "properties": {
+                "approve": {
+                    "description": "False denies the device",
+                    "type": "boolean"
+                },
+                "user_code": {
+                    "description": "Code shown on the device",
+                    "type": "string"
+                }
+            }
+        },
+        "internal_transport_http.ConfirmPhoneRequest": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "Code texted to the new phone",
+                    "type": "string"
+                }
+            }
+        },
+        "internal_transport_http.CreateAPIKeyRequest": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "description": "The key never expires when absent",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Shown in the list of keys",
+                    "type": "string"
+                },
+                "scopes": {
+                    "description": "Scopes granted to the user by their roles and to the access token",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "internal_transport_http.CreateClientRequest": {
+            "type": "object",
+            "properties": {
+                "access_token_ttl": {
+                    "description": "Seconds, the server default when 0",
+                    "type": "integer"
+                },
+                "confidential": {
+                    "description": "Confidential clients get a secret, public clients without a public key rely on PKCE",
+                    "type": "boolean"
+                },
+                "grant_types": {
+                    "description": "authorization_code and refresh_token when empty",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "description": "Generated when empty",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "public_key": {
+                    "description": "PEM encoded RSA or EC key for private_key_jwt",
+                    "type": "string"
+                },
+                "redirect_uris": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "refresh_token_ttl": {
+                    "description": "Seconds, the server default when 0",
+                    "type": "integer"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "internal_transport_http.DeviceRequestResponse": {
+            "type": "object",
+            "properties": {
+                "client_id": {
+                    "description": "Client of the device",
+                    "type": "string"
+                },
+                "client_name": {
+                    "type": "string"
+                },
+                "scope": {
+                    "description": "Scopes the device gets when approved",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "internal_transport_http.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Error message",
+                    "type": "string"
+                }
+            }
+        },
+        "internal_transport_http.MessageResponse": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "description": "Human readable result",
+                    "type": "string"
+                }
+            }
+        },
+        "internal_transport_http.NotificationPreferenceRequest": {
+            "type": "object",
+            "properties": {
+                "channels": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "event": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_transport_http.NotificationPreferenceResponse": {
+            "type": "object",
+            "properties": {
+                "channels": {
+                    "description": "Channels, empty when the event is off",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "event": {
+                    "description": "Event name",
+                    "type": "string"
+                }
+            }
+        },
+        "internal_transport_http.NotificationSettingsResponse": {
+            "type": "object",
+            "properties": {
+                "pending_phone": {
+                    "description": "New phone waiting for its code, returned only when the phone changes",
+                    "type": "string"
+                },
+                "phone": {
+                    "description": "Phone for the sms channel",
+                    "type": "string"
+                },
+                "preferences": {
+                    "description": "Channels of every event",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/internal_transport_http.NotificationPreferenceResponse"
+                    }
+                },
+                "webhook_secret": {
+                    "description": "Signing secret, returned only when the webhook url changes",
+                    "type": "string"
+                },
+                "webhook_url": {
+                    "description": "Url for the webhook channel",
+                    "type": "string"
+                }
+            }
+        },
+        "internal_transport_http.PasswordErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Error message",
+                    "type": "string"
+                },
+                "rule": {
+                    "description": "Failed rule: min_length, max_length, banned_word, matches_email or breached",
+                    "type": "string"
+                }
+            }
+        },
+        "internal_transport_http.RefreshTokenRequest": {
+            "type": "object",
+            "properties": {
+                "client_id": {
+                    "description": "Required for sessions created by an oauth client",
+                    "type": "string"
+                },
+                "client_secret": {
+                    "description": "Required for sessions of a confidential client",
+                    "type": "string"
+                },
+                "refresh_token": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_transport_http.RegisterWebhookRequest": {
+            "type": "object",
+            "properties": {
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_transport_http.SetPasswordRequest": {
+            "type": "object",
+            "properties": {
+                "current_password": {
+                    "description": "Required when the user already has a password",
+                    "type": "string"
+                },
+                "new_password": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_transport_http.SetUserRolesRequest": {
+            "type": "object",
+            "properties": {
+                "roles": {
+                    "description": "Assigned roles, the user role is implied",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "internal_transport_http.TokenResponse": {
+            "type": "object",
+            "properties": {
+                "access_token": {
+                    "description": "Access token",
+                    "type": "string"
+                },
+                "expires_in": {
+                    "description": "Access token lifetime in seconds",
+                    "type": "integer"
+                },
+                "issued_token_type": {
+                    "description": "Type of the issued token, set only for token exchange",
+                    "type": "string"
+                },
+                "refresh_expires_in": {
+                    "description": "Refresh token lifetime in seconds",
+                    "type": "integer"
+                },
+                "refresh_token": {
+                    "description": "Refresh token, not issued for the client credentials grant",
+                    "type": "string"
+                },
+                "scope": {
+                    "description": "Granted scopes separated by spaces",
+                    "type": "string"
+                },
+                "token_type": {
+                    "description": "Always Bearer",
+                    "type": "string"
+                }
+            }
+        },
+        "internal_transport_http.UpdateClientRequest": {
+            "type": "object",
+            "properties": {
+                "access_token_ttl": {
+                    "description": "Seconds, the server default when 0",
+                    "type": "integer"
+                },
+                "grant_types": {
+                    "description": "authorization_code and refresh_token when empty",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "public_key": {
+                    "description": "PEM encoded RSA or EC key for private_key_jwt",
+                    "type": "string"
+                },
+                "redirect_uris": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "refresh_token_ttl": {
+                    "description": "Seconds, the server default when 0",
+                    "type": "integer"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "internal_transport_http.UpdateNotificationSettingsRequest": {
+            "type": "object",
+            "properties": {
+                "phone": {
+                    "description": "E.164 phone for the sms channel, empty string removes it. A new phone\ngets a code and is used once the code is confirmed.",
+                    "type": "string"
+                },
+                "preferences": {
+                    "description": "Events to change, other events keep their channels",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/internal_transport_http.NotificationPreferenceRequest"
+                    }
+                },
+                "webhook_url": {
+                    "description": "Url for the webhook channel, empty string removes it",
+                    "type": "string"
+                }
+            }
+        },
+        "internal_transport_http.UserRolesResponse": {
+            "type": "object",
+            "properties": {
+                "roles": {
+                    "description": "Roles of the user including the implied user role",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "user_id": {
+                    "description": "User id",
+                    "type": "string"
+                }
+            }
+        },
+        "internal_transport_http.WebhookEndpointResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "events": {
+                    "description": "Subscribed events",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "description": "Endpoint id",
+                    "type": "string"
+                },
+                "secret": {
+                    "description": "Signing secret, returned only on registration",
+                    "type": "string"
+                },
+                "url": {
+                    "description": "Url receiving the events",
                     "type": "string"
                 }
             }
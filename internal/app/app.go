@@ -2,16 +2,20 @@ package app
 
 import (
 	"context"
+	"io"
 	"medods-test-task/config"
 	"medods-test-task/internal/repository"
 	"medods-test-task/internal/server"
 	"medods-test-task/internal/service"
 	"medods-test-task/internal/transport/http"
 	"medods-test-task/internal/transport/http/routes"
-	"medods-test-task/pkg/email/smtp"
+	"medods-test-task/pkg/email/catcher"
+	"medods-test-task/pkg/geoip"
 	"medods-test-task/pkg/logger"
 	"medods-test-task/pkg/migrator"
+	"medods-test-task/pkg/ratelimit"
 	"medods-test-task/pkg/utils"
+	"medods-test-task/pkg/webhook"
 	"os"
 	"os/signal"
 	"syscall"
@@ -27,6 +31,11 @@ import (
 const (
 	serviceName     = "medods_auth_service"
 	shutdownTimeout = 5 * time.Second
+
+	auditCheckpointInterval = time.Hour
+
+	rateLimitBackendPostgres = "postgres"
+	rateLimitSweepInterval   = 10 * time.Minute
 )
 
 func Run() {
@@ -47,6 +56,14 @@ func Run() {
 		logs.Fatal(ctx, "Error config load", zap.Error(err))
 	}
 
+	if err := cfg.Admin.Validate(); err != nil {
+		logs.Fatal(ctx, "Insecure admin api token", zap.Error(err))
+	}
+
+	if !cfg.Admin.Enabled() {
+		logs.Warn(ctx, "admin api disabled, set ADMIN_API_TOKEN to enable it")
+	}
+
 	db := postgres.New(ctx, &cfg.Postgres)
 
 	err = migrator.Start(cfg)
@@ -54,21 +71,118 @@ func Run() {
 		logs.Fatal(ctx, "failed to migrate", zap.Error(err))
 	}
 
-	sender, err := smtp.NewSMTPSender(cfg.SMTP.Mail, cfg.SMTP.Password, cfg.SMTP.Host, cfg.SMTP.Domain, cfg.SMTP.Port)
+	sender, err := newMailSender(cfg, logs)
+	if err != nil {
+		logs.Fatal(ctx, "failed to create mail sender", zap.Error(err))
+	}
+
+	renderer, err := newEmailRenderer(&cfg.Email)
+	if err != nil {
+		logs.Fatal(ctx, "failed to load email templates", zap.Error(err))
+	}
+
+	emailPolicy, err := newEmailPolicy(&cfg.Email)
+	if err != nil {
+		logs.Fatal(ctx, "failed to load disposable email domains", zap.Error(err))
+	}
+
+	passwordPolicy, breachCorpus, err := newPasswordPolicy(&cfg.Password)
+	if err != nil {
+		logs.Fatal(ctx, "failed to open breached password corpus", zap.Error(err))
+	}
+
+	smsProvider, err := newSMSProvider(cfg, logs)
 	if err != nil {
-		logs.Fatal(ctx, "failed to create smtp sender", zap.Error(err))
+		logs.Fatal(ctx, "failed to create sms provider", zap.Error(err))
+	}
+
+	var geo *geoip.Locator
+	if cfg.Email.GeoIPDatabase != "" {
+		geo, err = geoip.Load(cfg.Email.GeoIPDatabase)
+		if err != nil {
+			logs.Fatal(ctx, "failed to load geoip database", zap.Error(err))
+		}
 	}
 
 	tokenMananger := utils.NewManager(cfg)
 	authRepo := repository.NewAuthRepo(db)
-	emailService := service.NewEmailService(sender, logs, &cfg.Email)
-	service := service.NewAuthService(authRepo, tokenMananger, emailService)
+	emailService := service.NewEmailService(repository.NewOutboxRepo(db), sender, renderer, geo, db, logs, &cfg.Email, &cfg.Outbox, &cfg.Throttle)
+	guard := service.NewBruteForceGuard(authRepo, emailService, logs, &cfg.BruteForce)
+	auditLog := service.NewAuditService(repository.NewAuditRepo(db), logs, cfg.AuthJWT.Secret)
+	webhooks := service.NewWebhookService(repository.NewWebhookRepo(db), webhook.NewSender(cfg.Webhook.Timeout), webhook.NewPublicSender(cfg.Webhook.Timeout), logs, &cfg.Webhook)
+	smsService := service.NewSMSService(repository.NewSMSOutboxRepo(db), smsProvider, logs, &cfg.Email, &cfg.Outbox)
+	notifier := service.NewNotifier(repository.NewNotificationRepo(db), authRepo, emailService, smsService, webhooks, db, logs)
+	accounts := service.NewAccountService(authRepo, emailService, emailPolicy, passwordPolicy, notifier, tokenMananger, guard, db, &cfg.Email)
+	clients := service.NewClientService(repository.NewClientRepo(db))
+	roles := service.NewRoleService(authRepo, &cfg.Roles)
+
+	if len(cfg.OAuth.LegacyClients) > 0 {
+		imported, err := clients.ImportClients(ctx, cfg.OAuth.LegacyClients, cfg.Roles.AllScopes())
+		if err != nil {
+			logs.Fatal(ctx, "failed to import oauth clients", zap.Error(err))
+		}
 
-	handler := http.NewAppController(service, logs)
+		logs.Warn(ctx, "OAUTH_CLIENTS is deprecated, manage clients with /v1/admin/clients or the oauth-clients cli",
+			zap.Strings("imported", imported))
+	}
+	authService := service.NewAuthService(authRepo, tokenMananger, clients, roles, notifier, guard, auditLog, webhooks, db)
+	apiKeys := service.NewAPIKeyService(authRepo, roles, logs)
+	oauth := service.NewOAuthService(repository.NewOAuthRepo(db), clients, authRepo, emailPolicy, authService, tokenMananger, roles, guard, auditLog, &cfg.OAuth)
+
+	handler := http.NewAppController(authService, webhooks, notifier, accounts, clients, roles, oauth, apiKeys, logs)
+
+	var (
+		limiter        ratelimit.Limiter = ratelimit.NewMemoryLimiter()
+		rateLimitsRepo *repository.RateLimit
+	)
+	if cfg.RateLimit.Backend == rateLimitBackendPostgres {
+		rateLimitsRepo = repository.NewRateLimitRepo(db)
+		limiter = rateLimitsRepo
+	}
 
 	app := gin.New()
 
-	routes.RegistrationRoutes(app, tokenMananger, handler)
+	routes.RegistrationRoutes(app, cfg, tokenMananger, apiKeys, limiter, logs, handler)
+	routes.RegistrationOAuthRoutes(app, cfg, tokenMananger, limiter, logs, http.NewOAuthController(oauth, logs))
+
+	mailHealth, _ := sender.(http.MailHealth)
+	routes.RegistrationHealthRoutes(app, cfg, http.NewHealthController(db, mailHealth, logs))
+
+	if mailbox, ok := sender.(*catcher.Catcher); ok && cfg.IsDevelopment() {
+		logs.Warn(ctx, "development mail catcher enabled at /dev/mail")
+		routes.RegistrationDevMailRoutes(app, http.NewDevMailController(mailbox))
+	}
+
+	workersCtx, stopWorkers := context.WithCancel(ctx)
+	defer stopWorkers()
+
+	go auditLog.RunCheckpoints(workersCtx, auditCheckpointInterval)
+	go emailService.RunDigests(workersCtx)
+
+	if rateLimitsRepo != nil {
+		go sweepRateLimits(workersCtx, rateLimitsRepo, logs)
+	}
+
+	outboxDone := make(chan struct{})
+	go func() {
+		defer close(outboxDone)
+
+		emailService.Run(workersCtx)
+	}()
+
+	smsDone := make(chan struct{})
+	go func() {
+		defer close(smsDone)
+
+		smsService.Run(workersCtx)
+	}()
+
+	webhooksDone := make(chan struct{})
+	go func() {
+		defer close(webhooksDone)
+
+		webhooks.Run(workersCtx)
+	}()
 
 	// HTTP server
 	srv := server.NewServer(cfg, app)
@@ -84,6 +198,8 @@ func Run() {
 
 	<-c
 
+	stopWorkers()
+
 	ctx, shutdown := context.WithTimeout(ctx, shutdownTimeout)
 	defer shutdown()
 
@@ -91,6 +207,41 @@ func Run() {
 		logs.Error(ctx, "failed shutting down the server", zap.Error(err))
 	}
 
+	// Sends already claimed are finished rather than cut off, so the workers
+	// get as long as a claim lasts before the database is closed under them.
+	drainCtx, drained := context.WithTimeout(context.WithoutCancel(ctx), drainTimeout(cfg))
+	defer drained()
+
+	select {
+	case <-outboxDone:
+	case <-drainCtx.Done():
+		logs.Error(ctx, "email outbox was not drained before shutdown timeout")
+	}
+
+	select {
+	case <-smsDone:
+	case <-drainCtx.Done():
+		logs.Error(ctx, "sms outbox was not drained before shutdown timeout")
+	}
+
+	select {
+	case <-webhooksDone:
+	case <-drainCtx.Done():
+		logs.Error(ctx, "webhook deliveries were not drained before shutdown timeout")
+	}
+
+	if closer, ok := sender.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			logs.Error(ctx, "failed to close mail sender", zap.Error(err))
+		}
+	}
+
+	if breachCorpus != nil {
+		if err := breachCorpus.Close(); err != nil {
+			logs.Error(ctx, "failed to close breached password corpus", zap.Error(err))
+		}
+	}
+
 	if err := db.Close(); err != nil {
 		logs.Error(ctx, "failed to close database connection", zap.Error(err))
 	}
@@ -101,3 +252,28 @@ func Run() {
 		logs.Error(ctx, "failed to sync logger", zap.Error(err))
 	}
 }
+
+// sweepRateLimits drops the refilled buckets of the postgres rate limiter,
+// which would otherwise keep a row for every ip and user ever seen.
+func sweepRateLimits(ctx context.Context, repo *repository.RateLimit, logs logger.Logger) {
+	ticker := time.NewTicker(rateLimitSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := repo.DeleteFullRateLimits(ctx); err != nil && ctx.Err() == nil {
+			logs.Error(ctx, "failed to sweep rate limits", zap.Error(err))
+		}
+	}
+}
+
+// drainTimeout is how long shutdown waits for the outbox and webhook workers:
+// the longest lease they hold on a claimed batch.
+func drainTimeout(cfg *config.Config) time.Duration {
+	return max(cfg.Outbox.Lease, 2*cfg.Webhook.Timeout, shutdownTimeout)
+}
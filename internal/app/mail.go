@@ -0,0 +1,151 @@
+package app
+
+import (
+	"fmt"
+	"io/fs"
+	"medods-test-task/config"
+	"medods-test-task/internal/models"
+	"medods-test-task/internal/service"
+	"medods-test-task/pkg/circuitbreaker"
+	"medods-test-task/pkg/email"
+	"medods-test-task/pkg/email/catcher"
+	"medods-test-task/pkg/email/dkim"
+	"medods-test-task/pkg/email/failover"
+	"medods-test-task/pkg/email/logsink"
+	"medods-test-task/pkg/email/maildir"
+	"medods-test-task/pkg/email/nop"
+	"medods-test-task/pkg/email/render"
+	"medods-test-task/pkg/email/smtp"
+	"medods-test-task/pkg/logger"
+	"medods-test-task/templates"
+	"net"
+	"os"
+	"strconv"
+)
+
+const (
+	mailTransportSMTP = "smtp"
+	mailTransportFile = "file"
+	mailTransportLog  = "log"
+	mailTransportNop  = "noop"
+
+	mailTransportCatcher = "catcher"
+	mailCatcherCapacity  = 500
+)
+
+// newEmailRenderer loads templates from EMAIL_TEMPLATES_DIR, falling back to
+// the ones embedded in the binary.
+func newEmailRenderer(cfg *config.EmailConfig) (*render.Renderer, error) {
+	var fsys fs.FS = templates.FS
+	if cfg.TemplatesDir != "" {
+		fsys = os.DirFS(cfg.TemplatesDir)
+	}
+
+	return render.New(fsys, cfg.DefaultLocale)
+}
+
+// newEmailPolicy blocks the bundled disposable domains plus the ones listed in
+// EMAIL_DISPOSABLE_DOMAINS_PATH.
+func newEmailPolicy(cfg *config.EmailConfig) (*email.Policy, error) {
+	blocklist := email.DefaultBlocklist()
+	if cfg.DisposableDomainsPath != "" {
+		if err := blocklist.LoadFile(cfg.DisposableDomainsPath); err != nil {
+			return nil, err
+		}
+	}
+
+	return email.NewPolicy(blocklist, cfg.Canonicalize), nil
+}
+
+// newMailSender builds the transport selected by MAIL_TRANSPORT. SMTP is used
+// when the transport is not set.
+func newMailSender(cfg *config.Config, logs logger.Logger) (service.SMTPSender, error) {
+	switch cfg.Mail.Transport {
+	case mailTransportSMTP, "":
+		signer, err := newDKIMSigner(&cfg.SMTP)
+		if err != nil {
+			return nil, err
+		}
+
+		return newSMTPFailover(&cfg.SMTP, signer)
+	case mailTransportFile:
+		return maildir.NewSender(cfg.Mail.Dir, cfg.SMTP.Mail, cfg.SMTP.Domain)
+	case mailTransportLog:
+		return logsink.NewSender(logs), nil
+	case mailTransportNop:
+		return nop.NewSender(), nil
+	case mailTransportCatcher:
+		if !cfg.IsDevelopment() {
+			return nil, models.ErrDevOnlyTransport
+		}
+
+		return catcher.New(cfg.SMTP.Mail, cfg.SMTP.Domain, mailCatcherCapacity), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", models.ErrUnknownTransport, cfg.Mail.Transport)
+	}
+}
+
+// newSMTPFailover opens a pooled sender per relay from SMTP_RELAYS, falling
+// back to SMTP_HOST and SMTP_PORT, and fails over between them in order.
+func newSMTPFailover(cfg *config.SMTPConfig, signer smtp.Signer) (*failover.Sender, error) {
+	addrs := cfg.Relays
+	if len(addrs) == 0 {
+		addrs = []string{net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))}
+	}
+
+	pool := smtp.PoolConfig{
+		Size:        cfg.PoolSize,
+		QueueSize:   cfg.QueueSize,
+		IdleTimeout: cfg.IdleTimeout,
+	}
+
+	relays := make([]failover.Relay, 0, len(addrs))
+	for _, addr := range addrs {
+		host, port, err := splitRelayAddr(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		sender, err := smtp.NewSMTPSender(cfg.Mail, cfg.Password, host, cfg.Domain, port, pool, signer)
+		if err != nil {
+			return nil, err
+		}
+
+		relays = append(relays, failover.Relay{
+			Name:      addr,
+			Transport: sender,
+			Breaker:   circuitbreaker.New(cfg.BreakerThreshold, cfg.BreakerTimeout),
+		})
+	}
+
+	return failover.New(relays...), nil
+}
+
+func splitRelayAddr(addr string) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid smtp relay %q: %w", addr, err)
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid smtp relay port %q: %w", addr, err)
+	}
+
+	return host, port, nil
+}
+
+// newDKIMSigner returns nil when no DKIM key is configured, so messages are
+// sent unsigned.
+func newDKIMSigner(cfg *config.SMTPConfig) (smtp.Signer, error) {
+	if cfg.DKIMPrivateKeyPath == "" {
+		return nil, nil
+	}
+
+	key, err := dkim.LoadPrivateKey(cfg.DKIMPrivateKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return dkim.NewSigner(cfg.Domain, cfg.DKIMSelector, key), nil
+}
@@ -0,0 +1,31 @@
+package app
+
+import (
+	"medods-test-task/config"
+	"medods-test-task/pkg/password"
+)
+
+// newPasswordPolicy builds the policy from the PASSWORD_* settings. The
+// breach corpus is opened only when PASSWORD_BREACH_CORPUS_PATH is set and
+// must be closed by the caller.
+func newPasswordPolicy(cfg *config.PasswordConfig) (*password.Policy, *password.Corpus, error) {
+	policy := &password.Policy{
+		MinLength:       cfg.MinLength,
+		MaxLength:       cfg.MaxLength,
+		BannedWords:     cfg.BannedWords,
+		BreachThreshold: cfg.BreachThreshold,
+	}
+
+	if cfg.BreachCorpusPath == "" {
+		return policy, nil, nil
+	}
+
+	corpus, err := password.OpenCorpus(cfg.BreachCorpusPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	policy.Breaches = corpus
+
+	return policy, corpus, nil
+}
@@ -0,0 +1,33 @@
+package app
+
+import (
+	"fmt"
+	"medods-test-task/config"
+	"medods-test-task/internal/models"
+	"medods-test-task/internal/service"
+	"medods-test-task/pkg/logger"
+	"medods-test-task/pkg/sms/fake"
+	"medods-test-task/pkg/sms/logsink"
+)
+
+const (
+	smsProviderLog  = "log"
+	smsProviderFake = "fake"
+)
+
+// newSMSProvider builds the provider selected by SMS_PROVIDER. Messages are
+// only logged when the provider is not set.
+func newSMSProvider(cfg *config.Config, logs logger.Logger) (service.SMSProvider, error) {
+	switch cfg.SMS.Provider {
+	case smsProviderLog, "":
+		return logsink.NewProvider(logs), nil
+	case smsProviderFake:
+		if !cfg.IsDevelopment() {
+			return nil, models.ErrDevOnlyTransport
+		}
+
+		return fake.NewProvider(), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", models.ErrUnknownSMSProvider, cfg.SMS.Provider)
+	}
+}
@@ -1,6 +1,9 @@
 package models
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 var (
 	ErrUserNotFound           = errors.New("user with such id was not found")
@@ -9,11 +12,130 @@ var (
 	ErrSessionNotFound        = errors.New("session was not found")
 	ErrInvalidSession         = errors.New("refresh session is invalid")
 	ErrTokenExpired           = errors.New("token is expired")
+	ErrTokenUsed              = errors.New("token was already used")
 	ErrInvalidToken           = errors.New("token is invalid")
 	ErrMismatchedHashAndToken = errors.New("token does not match with the hash")
+	ErrTooManyAttempts        = errors.New("too many failed attempts")
+	ErrAccountLocked          = errors.New("account is temporarily locked")
+	ErrAuthEventNotFound      = errors.New("auth event was not found")
+
+	ErrEmailTaken          = errors.New("email is already used by another user")
+	ErrEmailUnchanged      = errors.New("new email is the same as the current one")
+	ErrEmailChangeNotFound = errors.New("email change was not found")
+
+	ErrPasswordTooShort     = errors.New("password is too short")
+	ErrPasswordTooLong      = errors.New("password is too long")
+	ErrPasswordBannedWord   = errors.New("password contains a banned word")
+	ErrPasswordMatchesEmail = errors.New("password should not match the email")
+	ErrPasswordBreached     = errors.New("password appeared in a data breach")
+	ErrPasswordRequired     = errors.New("current password is required")
+	ErrWrongPassword        = errors.New("current password is wrong")
+
+	ErrInvalidCredentials      = errors.New("invalid email or password")
+	ErrInvalidClient           = errors.New("oauth client is unknown or its credentials are wrong")
+	ErrInvalidRedirectURI      = errors.New("redirect uri is not registered for the client")
+	ErrUnsupportedResponseType = errors.New("unsupported response type")
+	ErrInvalidCodeChallenge    = errors.New("code challenge should be an S256 challenge")
+	ErrInvalidGrant            = errors.New("authorization code is invalid, expired or was issued to another client")
+	ErrUnsupportedGrantType    = errors.New("unsupported grant type")
+	ErrClientMismatch          = errors.New("refresh token was issued to another client")
+	ErrUnauthorizedClient      = errors.New("client is not allowed to use this grant type")
+
+	ErrOAuthClientNotFound   = errors.New("oauth client was not found")
+	ErrOAuthClientExists     = errors.New("oauth client with such id already exists")
+	ErrInvalidClientID       = errors.New("client id should be 3 to 64 lowercase letters, digits, dots, dashes or underscores")
+	ErrEmptyClientName       = errors.New("client name is empty")
+	ErrInvalidClientURI      = errors.New("redirect uri should be an absolute uri without a fragment")
+	ErrMissingRedirectURI    = errors.New("clients using the authorization code grant need a redirect uri")
+	ErrUnknownGrantType      = errors.New("unknown grant type")
+	ErrInvalidScope          = errors.New("scope should be a non-empty token without spaces or quotes")
+	ErrInvalidClientTokenTTL = errors.New("token ttl should not be negative")
+	ErrInvalidClientKey      = errors.New("public key should be a PEM encoded RSA or EC public key")
+	ErrAssertionReplayed     = errors.New("client assertion was already used")
+	ErrScopeNotAllowed       = errors.New("requested scope is not allowed for the client")
+	ErrScopeNotGranted       = errors.New("requested scope exceeds the scope granted to the session")
+	ErrPublicClientGrant     = errors.New("public clients cannot use the client credentials or token exchange grant")
+
+	ErrInvalidUserCode      = errors.New("user code is invalid or has expired")
+	ErrUserCodeTaken        = errors.New("user code is already taken")
+	ErrAuthorizationPending = errors.New("the user has not approved the device yet")
+	ErrSlowDown             = errors.New("the device polls too often")
+	ErrAccessDenied         = errors.New("the user denied the device")
+	ErrExpiredToken         = errors.New("device code has expired")
+
+	ErrUnsupportedTokenType   = errors.New("only access tokens can be exchanged")
+	ErrInvalidTarget          = errors.New("audience is not allowed for token exchange")
+	ErrImpersonationForbidden = errors.New("only admins can impersonate users")
+	ErrUnknownSubject         = errors.New("requested subject is unknown")
+
+	ErrUnknownRole = errors.New("unknown role")
+
+	ErrAPIKeyNotFound      = errors.New("api key was not found")
+	ErrInvalidAPIKey       = errors.New("api key is invalid or was revoked")
+	ErrAPIKeyExpired       = errors.New("api key has expired")
+	ErrInvalidAPIKeyName   = errors.New("api key name should be 1 to 100 characters")
+	ErrInvalidAPIKeyScope  = errors.New("api key scopes should be non-empty and granted to the user")
+	ErrInvalidAPIKeyExpiry = errors.New("api key expiry should be in the future")
+
+	ErrWebhookEndpointNotFound = errors.New("webhook endpoint was not found")
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery was not found")
+	ErrInvalidWebhookURL       = errors.New("webhook url should be an absolute http or https url")
+	ErrNonPublicWebhookURL     = errors.New("webhook url should point to a public address")
+	ErrUnknownWebhookEvent     = errors.New("unknown webhook event")
+
+	ErrUnknownNotificationEvent   = errors.New("unknown notification event")
+	ErrUnknownNotificationChannel = errors.New("unknown notification channel")
+	ErrChannelNotConfigured       = errors.New("notification channel is not configured")
+	ErrInvalidPhone               = errors.New("phone should be in E.164 format")
+	ErrInvalidPhoneCode           = errors.New("phone code is invalid or has expired")
+	ErrPhoneCodeTooSoon           = errors.New("phone code was sent recently, try again later")
+	ErrPhoneVerificationNotFound  = errors.New("phone verification was not found")
+	ErrUnknownSMSProvider         = errors.New("unknown sms provider")
 
 	ErrSMTPEmptyTo        = errors.New("empty to address")
 	ErrSMTPEmptyMail      = errors.New("empty subject or body")
 	ErrSMTPInvalidToEmail = errors.New("invalid to email")
+	ErrSMTPQueueFull      = errors.New("smtp send queue is full")
+	ErrSMTPClosed         = errors.New("smtp sender is closed")
+	ErrNoRelayAvailable   = errors.New("no smtp relay available")
+	ErrSMTPRecipient      = errors.New("recipient was rejected by the smtp relay")
 	ErrEmailFormat        = errors.New("wrong email format")
+	ErrDisposableEmail    = errors.New("disposable email addresses are not allowed")
+	ErrUnknownTransport   = errors.New("unknown mail transport")
+	ErrDevOnlyTransport   = errors.New("mail transport is available only in development mode")
 )
+
+type RetryError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *RetryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+const (
+	PasswordRuleMinLength    = "min_length"
+	PasswordRuleMaxLength    = "max_length"
+	PasswordRuleBannedWord   = "banned_word"
+	PasswordRuleMatchesEmail = "matches_email"
+	PasswordRuleBreached     = "breached"
+)
+
+// PasswordPolicyError names the password rule that was not met.
+type PasswordPolicyError struct {
+	Err  error
+	Rule string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PasswordPolicyError) Unwrap() error {
+	return e.Err
+}
@@ -1,14 +1,21 @@
 package models
 
 import (
+	"encoding/json"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type RefreshSession struct {
-	ID        uint
-	UserID    uuid.UUID
+	ID     uint
+	UserID uuid.UUID
+	// ClientID is the oauth client that created the session, empty for
+	// sessions from the legacy login.
+	ClientID string
+	// Scopes granted to the session, access tokens get them or a subset.
+	Scopes    []string
 	IP        string
 	Token     string
 	CreatedAt time.Time
@@ -16,6 +23,529 @@ type RefreshSession struct {
 }
 
 type User struct {
-	ID    uuid.UUID
-	Email string
+	ID     uuid.UUID
+	Email  string
+	Phone  string
+	Locale string
+	// Roles assigned by admins, every user also has RoleUser.
+	Roles []string
+}
+
+const (
+	// RoleUser is the role every user has without it being assigned.
+	RoleUser = "user"
+	// RoleAdmin lets users impersonate other users.
+	RoleAdmin = "admin"
+)
+
+// ScopeProfile lets a token read and change the profile and notification
+// settings of its user.
+const ScopeProfile = "profile"
+
+// EmailChange is an email address waiting to be confirmed by its owner. Only
+// the hash of the confirmation token is stored.
+type EmailChange struct {
+	UserID            uuid.UUID
+	NewEmail          string
+	NewEmailCanonical string
+	TokenHash         string
+	ExpiresAt         time.Time
+	CreatedAt         time.Time
+}
+
+// SecurityAlert describes a suspicious refresh for the IP warning email.
+type SecurityAlert struct {
+	OldIP       string
+	NewIP       string
+	UserAgent   string
+	OccurredAt  time.Time
+	RevokeToken string
+}
+
+type AuthFailure struct {
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   time.Time
+}
+
+const (
+	AuthEventLogin      = "login"
+	AuthEventRefresh    = "refresh"
+	AuthEventRotation   = "rotation"
+	AuthEventIPMismatch = "ip_mismatch"
+	AuthEventRevocation = "revocation"
+	// AuthEventImpersonation is recorded for the impersonated user with the
+	// admin as the actor.
+	AuthEventImpersonation = "impersonation"
+
+	AuthOutcomeSuccess = "success"
+	AuthOutcomeFailure = "failure"
+
+	AuthReasonReplaced        = "replaced"
+	AuthReasonRotated         = "rotated"
+	AuthReasonIPMismatch      = "ip_mismatch"
+	AuthReasonTokenExpired    = "token_expired"
+	AuthReasonInvalidToken    = "invalid_token"
+	AuthReasonTokenMismatch   = "token_mismatch"
+	AuthReasonSessionNotFound = "session_not_found"
+	AuthReasonInvalidUserID   = "invalid_user_id"
+	AuthReasonAccountLocked   = "account_locked"
+	AuthReasonTooManyAttempts = "too_many_attempts"
+	AuthReasonInternalError   = "internal_error"
+	AuthReasonReportedByUser  = "reported_by_user"
+	AuthReasonClientMismatch  = "client_mismatch"
+	AuthReasonInvalidClient   = "invalid_client"
+	AuthReasonForbidden       = "forbidden"
+	AuthReasonInvalidScope    = "invalid_scope"
+)
+
+type AuthEvent struct {
+	ID      uint
+	Type    string
+	Outcome string
+	Reason  string
+	UserID  uuid.UUID
+	// ActorID is the user acting on behalf of UserID, if any.
+	ActorID   uuid.UUID
+	SessionID uint
+	IP        string
+	UserAgent string
+	RequestID string
+	CreatedAt time.Time
+	PrevHash  string
+	Hash      string
+}
+
+// Canonical returns the representation of the event covered by its hash.
+func (e AuthEvent) Canonical() []byte {
+	// The actor is left out when there is none, so that events recorded
+	// before actors were added keep their hashes.
+	var actorID string
+	if e.ActorID != uuid.Nil {
+		actorID = e.ActorID.String()
+	}
+
+	data, _ := json.Marshal(struct {
+		Type      string    `json:"type"`
+		Outcome   string    `json:"outcome"`
+		Reason    string    `json:"reason"`
+		UserID    uuid.UUID `json:"user_id"`
+		ActorID   string    `json:"actor_id,omitempty"`
+		SessionID uint      `json:"session_id"`
+		IP        string    `json:"ip"`
+		UserAgent string    `json:"user_agent"`
+		RequestID string    `json:"request_id"`
+		CreatedAt string    `json:"created_at"`
+	}{
+		Type:      e.Type,
+		Outcome:   e.Outcome,
+		Reason:    e.Reason,
+		UserID:    e.UserID,
+		ActorID:   actorID,
+		SessionID: e.SessionID,
+		IP:        e.IP,
+		UserAgent: e.UserAgent,
+		RequestID: e.RequestID,
+		CreatedAt: e.CreatedAt.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano),
+	})
+
+	return data
+}
+
+type AuditCheckpoint struct {
+	Day         time.Time
+	LastEventID uint
+	Hash        string
+	Signature   string
+	CreatedAt   time.Time
+}
+
+type AuditVerification struct {
+	Events      int
+	Checkpoints int
+	BrokenAt    uint
+	Reason      string
+}
+
+func (v *AuditVerification) Broken() bool {
+	return v.Reason != ""
+}
+
+const (
+	WebhookEventSessionCreated     = "session.created"
+	WebhookEventSessionRevoked     = "session.revoked"
+	WebhookEventIPMismatch         = "ip_mismatch"
+	WebhookEventTokenReuseDetected = "token_reuse_detected"
+)
+
+var WebhookEvents = []string{
+	WebhookEventSessionCreated,
+	WebhookEventSessionRevoked,
+	WebhookEventIPMismatch,
+	WebhookEventTokenReuseDetected,
+}
+
+type WebhookEndpoint struct {
+	ID uuid.UUID
+	// UserID is set for endpoints a user registered for their own
+	// notifications. Such endpoints never receive operator events.
+	UserID    uuid.UUID
+	URL       string
+	Secret    string
+	Events    []string
+	CreatedAt time.Time
+}
+
+type WebhookDelivery struct {
+	ID         uint
+	EndpointID uuid.UUID
+	// UserID is set for deliveries to an endpoint a user registered, whose
+	// url is not trusted.
+	UserID        uuid.UUID
+	URL           string
+	Secret        string
+	EventType     string
+	Payload       []byte
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	CreatedAt     time.Time
+}
+
+type OutboxEmail struct {
+	ID            uint
+	UserID        uuid.UUID
+	Kind          string
+	To            string
+	Subject       string
+	Body          string
+	Text          string
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	CreatedAt     time.Time
+}
+
+const (
+	EmailSuppressedDuplicate   = "duplicate"
+	EmailSuppressedRateLimited = "rate_limited"
+)
+
+// EmailSuppression records a notification that was not sent because of
+// deduplication or the per-recipient cap. Suppressions are summarized in a
+// digest email.
+type EmailSuppression struct {
+	ID        uint
+	UserID    uuid.UUID
+	To        string
+	Locale    string
+	Kind      string
+	Reason    string
+	CreatedAt time.Time
+}
+
+const (
+	NotificationNewDevice      = "new_device"
+	NotificationIPChange       = "ip_change"
+	NotificationPasswordChange = "password_change"
+	NotificationSessionRevoked = "session_revoked"
+
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+	ChannelSMS     = "sms"
+)
+
+var NotificationEvents = []string{
+	NotificationNewDevice,
+	NotificationIPChange,
+	NotificationPasswordChange,
+	NotificationSessionRevoked,
+}
+
+var NotificationChannels = []string{
+	ChannelEmail,
+	ChannelWebhook,
+	ChannelSMS,
+}
+
+// DefaultNotificationChannels apply to events the user has not configured.
+var DefaultNotificationChannels = []string{ChannelEmail}
+
+// Notification is a security event sent to the user on the channels they
+// chose for it.
+type Notification struct {
+	Event string
+	User  *User
+	Alert SecurityAlert
+}
+
+type NotificationPreference struct {
+	Event    string
+	Channels []string
+}
+
+type NotificationSettings struct {
+	Preferences []NotificationPreference
+	Phone       string
+	// PendingPhone waits for the code texted to it, it is returned only when
+	// the phone is changed.
+	PendingPhone string
+	WebhookURL   string
+	// WebhookSecret is returned only when the webhook url is changed.
+	WebhookSecret string
+}
+
+// PhoneVerification holds the code texted to a phone before it receives
+// notifications.
+type PhoneVerification struct {
+	ID        uint
+	UserID    uuid.UUID
+	Phone     string
+	CodeHash  string
+	Attempts  int
+	ExpiresAt time.Time
+	UsedAt    time.Time
+	CreatedAt time.Time
+}
+
+// NotificationSettingsUpdate changes only the fields that are set.
+type NotificationSettingsUpdate struct {
+	Preferences []NotificationPreference
+	Phone       *string
+	WebhookURL  *string
+}
+
+type OutboxSMS struct {
+	ID            uint
+	UserID        uuid.UUID
+	Kind          string
+	To            string
+	Body          string
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	CreatedAt     time.Time
+}
+
+const (
+	CodeChallengeS256 = "S256"
+
+	ClientAssertionTypeJWT = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+)
+
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+	GrantTypeClientCredentials = "client_credentials"
+	GrantTypeDeviceCode        = "urn:ietf:params:oauth:grant-type:device_code"
+	GrantTypeTokenExchange     = "urn:ietf:params:oauth:grant-type:token-exchange"
+)
+
+const (
+	TokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+	TokenTypeJWT         = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+// GrantTypes lists the grant types a client can be allowed to use.
+var GrantTypes = []string{
+	GrantTypeAuthorizationCode,
+	GrantTypeRefreshToken,
+	GrantTypeClientCredentials,
+	GrantTypeDeviceCode,
+	GrantTypeTokenExchange,
+}
+
+// OAuthClient is an application allowed to request tokens on behalf of users.
+type OAuthClient struct {
+	ID   string
+	Name string
+	// SecretHash is set for clients using client_secret_basic or
+	// client_secret_post.
+	SecretHash string
+	// PublicKey is a PEM encoded RSA or EC key verifying the assertions of
+	// clients using private_key_jwt.
+	PublicKey    string
+	RedirectURIs []string
+	GrantTypes   []string
+	Scopes       []string
+	// AccessTokenTTL and RefreshTokenTTL override the server defaults when
+	// they are not zero.
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	// Secret is set only when the client is created or its secret is rotated.
+	Secret string
+}
+
+// IsConfidential reports whether the client authenticates. Public clients
+// have neither a secret nor a key and rely on PKCE.
+func (c *OAuthClient) IsConfidential() bool {
+	return c.SecretHash != "" || c.PublicKey != ""
+}
+
+func (c *OAuthClient) AllowsGrant(grantType string) bool {
+	return slices.Contains(c.GrantTypes, grantType)
+}
+
+// ClientCredentials identify the client calling a token endpoint. Secret is
+// set for client_secret_basic and client_secret_post, Assertion for
+// private_key_jwt and neither for public clients.
+type ClientCredentials struct {
+	ID        string
+	Secret    string
+	Assertion string
+}
+
+// ClientAssertion is a verified private_key_jwt assertion. Its id may be
+// used only once before it expires.
+type ClientAssertion struct {
+	ID        string
+	ExpiresAt time.Time
+}
+
+// TokenSet is the result of a token request. RefreshToken is empty for
+// grants that do not issue one, IssuedTokenType is set only for token
+// exchange.
+type TokenSet struct {
+	AccessToken     string
+	RefreshToken    string
+	AccessTTL       time.Duration
+	RefreshTTL      time.Duration
+	Scope           []string
+	IssuedTokenType string
+}
+
+// OAuthClientParams are the client settings managed by admins. ID and
+// Confidential are used only when the client is created.
+type OAuthClientParams struct {
+	ID              string
+	Name            string
+	Confidential    bool
+	PublicKey       string
+	RedirectURIs    []string
+	GrantTypes      []string
+	Scopes          []string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+}
+
+// AuthorizeRequest holds the parameters of an authorization code request.
+type AuthorizeRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+// AuthorizationCode is issued after the user signs in and approves the
+// client. Only the hash of the code is stored.
+type AuthorizationCode struct {
+	CodeHash      string
+	ClientID      string
+	UserID        uuid.UUID
+	RedirectURI   string
+	Scope         string
+	CodeChallenge string
+	ExpiresAt     time.Time
+	CreatedAt     time.Time
+}
+
+const (
+	DeviceCodePending  = "pending"
+	DeviceCodeApproved = "approved"
+	DeviceCodeDenied   = "denied"
+)
+
+// DeviceCode is a device authorization request of a client that cannot open
+// a browser. The user approves it on another device by entering the user
+// code. Only the hash of the device code is stored.
+type DeviceCode struct {
+	DeviceCodeHash string
+	UserCode       string
+	ClientID       string
+	Scope          string
+	Status         string
+	// UserID is set once the user approves or denies the request.
+	UserID       uuid.UUID
+	PollInterval time.Duration
+	// LastPolledAt is the previous poll of the device, nil before the first.
+	LastPolledAt *time.Time
+	ExpiresAt    time.Time
+	CreatedAt    time.Time
+}
+
+// DeviceRequest is what the user approves for a device: the client waiting
+// for the user code and the scopes it asked for.
+type DeviceRequest struct {
+	Client *OAuthClient
+	Scope  []string
+}
+
+// DeviceAuthorization is returned to the device that requested a device
+// code, it shows the user code and verification uri to the user.
+type DeviceAuthorization struct {
+	DeviceCode              string
+	UserCode                string
+	VerificationURI         string
+	VerificationURIComplete string
+	ExpiresIn               time.Duration
+	Interval                time.Duration
+}
+
+// TokenExchangeRequest holds the parameters of an RFC 8693 token exchange.
+// RequestedSubject is the id of the user an admin impersonates.
+type TokenExchangeRequest struct {
+	SubjectToken       string
+	SubjectTokenType   string
+	RequestedSubject   string
+	RequestedTokenType string
+	Audience           string
+	Scope              string
+}
+
+// APIKey is a long-lived credential a user gives to a script or another
+// machine user instead of their password. Only the hash of the key is
+// stored, Prefix is its start that identifies it in listings.
+type APIKey struct {
+	ID      uuid.UUID
+	UserID  uuid.UUID
+	Name    string
+	Prefix  string
+	KeyHash string
+	Scopes  []string
+	// Roles caps the roles of requests made with the key to those of the
+	// token it was created with. It is nil for keys created by an operator,
+	// which act with every role of the owner.
+	Roles []string
+	// ExpiresAt, LastUsedAt and RevokedAt are zero when the key never
+	// expires, was not used yet and was not revoked.
+	ExpiresAt  time.Time
+	LastUsedAt time.Time
+	LastUsedIP string
+	RevokedAt  time.Time
+	CreatedAt  time.Time
+	// Key is set only when the key is created.
+	Key string
+}
+
+// APIKeyParams holds the settings of a new api key. A zero ExpiresAt creates
+// a key that does not expire. Limit is set when the user creates the key
+// with an access token, which the key must not outgrow.
+type APIKeyParams struct {
+	Name      string
+	Scopes    []string
+	ExpiresAt time.Time
+	Limit     *APIKeyLimit
+}
+
+// APIKeyLimit holds the roles and scopes of the access token a key is
+// created with.
+type APIKeyLimit struct {
+	Roles  []string
+	Scopes []string
 }
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"medods-test-task/internal/models"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// apiKeyTouchInterval limits how often the last use of a busy key is
+// written.
+const apiKeyTouchInterval = time.Minute
+
+var apiKeyColumns = []string{
+	"id", "userId", "name", "keyPrefix", "keyHash", "scopes", "roles",
+	"expiresAt", "lastUsedAt", "lastUsedIP", "revokedAt", "createdAt",
+}
+
+func (r *Auth) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	_, err := sq.
+		Insert("apiKeys").
+		Columns("id", "userId", "name", "keyPrefix", "keyHash", "scopes", "roles", "expiresAt", "createdAt").
+		Values(key.ID, key.UserID, key.Name, key.Prefix, key.KeyHash, pq.Array(key.Scopes), pq.Array(key.Roles), nullTime(key.ExpiresAt), key.CreatedAt).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		ExecContext(ctx)
+
+	return err
+}
+
+// ListAPIKeys returns the keys of the user, revoked ones included, newest
+// first.
+func (r *Auth) ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]models.APIKey, error) {
+	rows, err := sq.
+		Select(apiKeyColumns...).
+		From("apiKeys").
+		Where(sq.Eq{"userId": userID}).
+		OrderBy("createdAt DESC").
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, *key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (r *Auth) GetAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	row := sq.
+		Select(apiKeyColumns...).
+		From("apiKeys").
+		Where(sq.Eq{"keyHash": keyHash}).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		QueryRowContext(ctx)
+
+	key, err := scanAPIKey(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrAPIKeyNotFound
+		}
+
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// RevokeAPIKey revokes the key of the user. Revoking a revoked key keeps the
+// time of the first revocation.
+func (r *Auth) RevokeAPIKey(ctx context.Context, userID, keyID uuid.UUID, revokedAt time.Time) error {
+	res, err := sq.
+		Update("apiKeys").
+		Set("revokedAt", sq.Expr("COALESCE(revokedAt, ?)", revokedAt)).
+		Where(sq.Eq{"id": keyID, "userId": userID}).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return models.ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+// TouchAPIKey records the last use of the key. It is written at most once per
+// apiKeyTouchInterval, so that a key used by a busy script does not update
+// its row on every request.
+func (r *Auth) TouchAPIKey(ctx context.Context, keyID uuid.UUID, usedAt time.Time, IPAddress string) error {
+	_, err := sq.
+		Update("apiKeys").
+		Set("lastUsedAt", usedAt).
+		Set("lastUsedIP", IPAddress).
+		Where(sq.Eq{"id": keyID}).
+		Where(sq.Or{
+			sq.Eq{"lastUsedAt": nil},
+			sq.Lt{"lastUsedAt": usedAt.Add(-apiKeyTouchInterval)},
+			sq.NotEq{"lastUsedIP": IPAddress},
+		}).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		ExecContext(ctx)
+
+	return err
+}
+
+func scanAPIKey(row sq.RowScanner) (*models.APIKey, error) {
+	var (
+		key        models.APIKey
+		expiresAt  sql.NullTime
+		lastUsedAt sql.NullTime
+		lastUsedIP sql.NullString
+		revokedAt  sql.NullTime
+	)
+
+	err := row.Scan(
+		&key.ID,
+		&key.UserID,
+		&key.Name,
+		&key.Prefix,
+		&key.KeyHash,
+		pq.Array(&key.Scopes),
+		pq.Array(&key.Roles),
+		&expiresAt,
+		&lastUsedAt,
+		&lastUsedIP,
+		&revokedAt,
+		&key.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	key.ExpiresAt = expiresAt.Time
+	key.LastUsedAt = lastUsedAt.Time
+	key.LastUsedIP = lastUsedIP.String
+	key.RevokedAt = revokedAt.Time
+
+	return &key, nil
+}
@@ -0,0 +1,246 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"medods-test-task/internal/db/postgres"
+	"medods-test-task/internal/models"
+	"medods-test-task/pkg/hashchain"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+)
+
+// auditChainLock serializes appends to the audit hash chain across instances.
+const auditChainLock = 7_028_029
+
+var authEventColumns = []string{"id", "type", "outcome", "reason", "userId", "actorId", "sessionId", "ip", "userAgent", "requestId", "createdAt", "prevHash", "hash"}
+
+type Audit struct {
+	db postgres.DB
+}
+
+func NewAuditRepo(db postgres.DB) *Audit {
+	return &Audit{
+		db: db,
+	}
+}
+
+func (r *Audit) CreateAuthEvent(ctx context.Context, event *models.AuthEvent) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", auditChainLock); err != nil {
+		return err
+	}
+
+	row := sq.
+		Select("hash").
+		From("auth_events").
+		OrderBy("id DESC").
+		Limit(1).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(tx).
+		QueryRowContext(ctx)
+
+	var prevHash string
+	if err := row.Scan(&prevHash); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	event.CreatedAt = event.CreatedAt.Truncate(time.Microsecond)
+	event.PrevHash = prevHash
+	event.Hash = hashchain.Link(prevHash, event.Canonical())
+
+	userID := uuid.NullUUID{UUID: event.UserID, Valid: event.UserID != uuid.Nil}
+	actorID := uuid.NullUUID{UUID: event.ActorID, Valid: event.ActorID != uuid.Nil}
+	sessionID := sql.NullInt64{Int64: int64(event.SessionID), Valid: event.SessionID != 0}
+
+	row = sq.
+		Insert("auth_events").
+		Columns("type", "outcome", "reason", "userId", "actorId", "sessionId", "ip", "userAgent", "requestId", "createdAt", "prevHash", "hash").
+		Values(event.Type, event.Outcome, event.Reason, userID, actorID, sessionID, event.IP, event.UserAgent, event.RequestID, event.CreatedAt, event.PrevHash, event.Hash).
+		Suffix("RETURNING id").
+		PlaceholderFormat(sq.Dollar).
+		RunWith(tx).
+		QueryRowContext(ctx)
+
+	if err := row.Scan(&event.ID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *Audit) ListAuthEvents(ctx context.Context, afterID uint, limit int) ([]models.AuthEvent, error) {
+	rows, err := sq.
+		Select(authEventColumns...).
+		From("auth_events").
+		Where(sq.Gt{"id": afterID}).
+		OrderBy("id").
+		Limit(uint64(limit)).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.AuthEvent
+
+	for rows.Next() {
+		event, err := scanAuthEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, *event)
+	}
+
+	return events, rows.Err()
+}
+
+func (r *Audit) GetLastAuthEventBefore(ctx context.Context, before time.Time) (*models.AuthEvent, error) {
+	row := sq.
+		Select(authEventColumns...).
+		From("auth_events").
+		Where(sq.Lt{"createdAt": before}).
+		Where(sq.NotEq{"hash": ""}).
+		OrderBy("id DESC").
+		Limit(1).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db).
+		QueryRowContext(ctx)
+
+	event, err := scanAuthEvent(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrAuthEventNotFound
+		}
+
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// ListUncheckpointedDays returns the UTC days before the given time that have
+// chained events but no checkpoint yet.
+func (r *Audit) ListUncheckpointedDays(ctx context.Context, before time.Time) ([]time.Time, error) {
+	rows, err := sq.
+		Select("DISTINCT (e.createdAt AT TIME ZONE 'UTC')::date AS day").
+		From("auth_events e").
+		LeftJoin("audit_checkpoints c ON c.day = (e.createdAt AT TIME ZONE 'UTC')::date").
+		Where(sq.Lt{"e.createdAt": before}).
+		Where(sq.NotEq{"e.hash": ""}).
+		Where(sq.Eq{"c.day": nil}).
+		OrderBy("day").
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var days []time.Time
+
+	for rows.Next() {
+		var day time.Time
+		if err := rows.Scan(&day); err != nil {
+			return nil, err
+		}
+
+		days = append(days, day)
+	}
+
+	return days, rows.Err()
+}
+
+func (r *Audit) CreateAuditCheckpoint(ctx context.Context, checkpoint *models.AuditCheckpoint) error {
+	_, err := sq.
+		Insert("audit_checkpoints").
+		Columns("day", "lastEventId", "hash", "signature", "createdAt").
+		Values(checkpoint.Day, checkpoint.LastEventID, checkpoint.Hash, checkpoint.Signature, checkpoint.CreatedAt).
+		Suffix("ON CONFLICT (day) DO NOTHING").
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db).
+		ExecContext(ctx)
+
+	return err
+}
+
+func (r *Audit) ListAuditCheckpoints(ctx context.Context) ([]models.AuditCheckpoint, error) {
+	rows, err := sq.
+		Select("day", "lastEventId", "hash", "signature", "createdAt").
+		From("audit_checkpoints").
+		OrderBy("day").
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checkpoints []models.AuditCheckpoint
+
+	for rows.Next() {
+		var checkpoint models.AuditCheckpoint
+
+		err := rows.Scan(
+			&checkpoint.Day,
+			&checkpoint.LastEventID,
+			&checkpoint.Hash,
+			&checkpoint.Signature,
+			&checkpoint.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		checkpoints = append(checkpoints, checkpoint)
+	}
+
+	return checkpoints, rows.Err()
+}
+
+func scanAuthEvent(row sq.RowScanner) (*models.AuthEvent, error) {
+	var (
+		event     models.AuthEvent
+		userID    uuid.NullUUID
+		actorID   uuid.NullUUID
+		sessionID sql.NullInt64
+	)
+
+	err := row.Scan(
+		&event.ID,
+		&event.Type,
+		&event.Outcome,
+		&event.Reason,
+		&userID,
+		&actorID,
+		&sessionID,
+		&event.IP,
+		&event.UserAgent,
+		&event.RequestID,
+		&event.CreatedAt,
+		&event.PrevHash,
+		&event.Hash,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	event.UserID = userID.UUID
+	event.ActorID = actorID.UUID
+	event.SessionID = uint(sessionID.Int64)
+
+	return &event, nil
+}
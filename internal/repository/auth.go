@@ -6,9 +6,11 @@ import (
 	"errors"
 	"medods-test-task/internal/db/postgres"
 	"medods-test-task/internal/models"
+	"time"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 type Auth struct {
@@ -22,14 +24,21 @@ func NewAuthRepo(db postgres.DB) *Auth {
 }
 
 func (r *Auth) CreateSession(ctx context.Context, session *models.RefreshSession) error {
-	_, err := sq.
+	scopes := session.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	row := sq.
 		Insert("refreshSessions").
-		Columns("userId", "ip", "refreshToken", "expiresAt", "createdAt").
-		Values(session.UserID, session.IP, session.Token, session.ExpiresAt, session.CreatedAt).
+		Columns("userId", "clientID", "scopes", "ip", "refreshToken", "expiresAt", "createdAt").
+		Values(session.UserID, nullString(session.ClientID), pq.Array(scopes), session.IP, session.Token, session.ExpiresAt, session.CreatedAt).
+		Suffix("RETURNING id").
 		PlaceholderFormat(sq.Dollar).
-		RunWith(r.db).
-		Exec()
-	if err != nil {
+		RunWith(r.db.Runner(ctx)).
+		QueryRowContext(ctx)
+
+	if err := row.Scan(&session.ID); err != nil {
 		return err
 	}
 
@@ -41,8 +50,8 @@ func (r *Auth) DeleteSessionByUserID(ctx context.Context, userID uuid.UUID) erro
 		Delete("refreshSessions").
 		Where(sq.Eq{"userId": userID}).
 		PlaceholderFormat(sq.Dollar).
-		RunWith(r.db).
-		Exec()
+		RunWith(r.db.Runner(ctx)).
+		ExecContext(ctx)
 
 	if err != nil {
 		return err
@@ -61,18 +70,23 @@ func (r *Auth) DeleteSessionByUserID(ctx context.Context, userID uuid.UUID) erro
 
 func (r *Auth) GetSessionByUserID(ctx context.Context, userID uuid.UUID) (*models.RefreshSession, error) {
 	row := sq.
-		Select("id", "userId", "ip", "refreshToken", "expiresAt", "createdAt").
+		Select("id", "userId", "clientID", "scopes", "ip", "refreshToken", "expiresAt", "createdAt").
 		From("refreshSessions").
 		Where(sq.Eq{"userId": userID}).
 		PlaceholderFormat(sq.Dollar).
-		RunWith(r.db).
-		QueryRow()
+		RunWith(r.db.Runner(ctx)).
+		QueryRowContext(ctx)
 
-	var session models.RefreshSession
+	var (
+		session  models.RefreshSession
+		clientID sql.NullString
+	)
 
 	err := row.Scan(
 		&session.ID,
 		&session.UserID,
+		&clientID,
+		pq.Array(&session.Scopes),
 		&session.IP,
 		&session.Token,
 		&session.ExpiresAt,
@@ -86,54 +100,304 @@ func (r *Auth) GetSessionByUserID(ctx context.Context, userID uuid.UUID) (*model
 		return nil, err
 	}
 
+	session.ClientID = clientID.String
+
 	return &session, nil
 }
 
 func (r *Auth) GetUserByID(ctx context.Context, userID uuid.UUID) (*models.User, error) {
 	row := sq.
-		Select("id").
+		Select("id", "email", "phone", "locale", "roles").
 		From("users").
 		Where(sq.Eq{"id": userID}).
 		PlaceholderFormat(sq.Dollar).
-		RunWith(r.db).
-		QueryRow()
+		RunWith(r.db.Runner(ctx)).
+		QueryRowContext(ctx)
 
-	var user models.User
+	return scanUser(row)
+}
 
-	err := row.Scan(
-		&user.ID,
-	)
+func (r *Auth) CreateUser(ctx context.Context, user *models.User) error {
+	res, err := sq.
+		Insert("users").
+		Columns("id", "email", "phone", "locale").
+		Values(user.ID, nullString(user.Email), nullString(user.Phone), nullString(user.Locale)).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		ExecContext(ctx)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, models.ErrUserNotFound
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return models.ErrSessionNotFound
+	}
+
+	return nil
+}
+
+// GetUserByEmail finds the user by the canonical form of the address.
+func (r *Auth) GetUserByEmail(ctx context.Context, canonicalEmail string) (*models.User, error) {
+	row := sq.
+		Select("id", "email", "phone", "locale", "roles").
+		From("users").
+		Where(sq.Eq{"emailCanonical": canonicalEmail}).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		QueryRowContext(ctx)
+
+	return scanUser(row)
+}
+
+// UpdateUserEmail sets the address of the user. It fails with
+// models.ErrEmailTaken when another user already has the canonical address.
+func (r *Auth) UpdateUserEmail(ctx context.Context, userID uuid.UUID, email, canonicalEmail string) error {
+	res, err := sq.
+		Update("users").
+		Set("email", email).
+		Set("emailCanonical", canonicalEmail).
+		Where(sq.Eq{"id": userID}).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		ExecContext(ctx)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return models.ErrEmailTaken
 		}
 
-		return nil, err
+		return err
 	}
 
-	return &user, nil
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return models.ErrUserNotFound
+	}
+
+	return nil
 }
 
-func (r *Auth) CreateUser(ctx context.Context, user *models.User) error {
+// SetUserRoles replaces the roles assigned to the user.
+func (r *Auth) SetUserRoles(ctx context.Context, userID uuid.UUID, roles []string) error {
 	res, err := sq.
-		Insert("users").
-		Columns("id").
-		Values(user.ID).
+		Update("users").
+		Set("roles", pq.Array(roles)).
+		Where(sq.Eq{"id": userID}).
 		PlaceholderFormat(sq.Dollar).
-		RunWith(r.db).
-		Exec()
+		RunWith(r.db.Runner(ctx)).
+		ExecContext(ctx)
 	if err != nil {
 		return err
 	}
 
-	rows, err := res.RowsAffected()
+	rowsAffected, err := res.RowsAffected()
 	if err != nil {
 		return err
 	}
+	if rowsAffected == 0 {
+		return models.ErrUserNotFound
+	}
 
-	if rows == 0 {
-		return models.ErrSessionNotFound
+	return nil
+}
+
+// GetUserPasswordHash returns an empty hash when the user has not set a
+// password yet.
+func (r *Auth) GetUserPasswordHash(ctx context.Context, userID uuid.UUID) (string, error) {
+	var hash sql.NullString
+
+	err := sq.
+		Select("passwordHash").
+		From("users").
+		Where(sq.Eq{"id": userID}).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		QueryRowContext(ctx).
+		Scan(&hash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", models.ErrUserNotFound
+		}
+
+		return "", err
+	}
+
+	return hash.String, nil
+}
+
+func (r *Auth) UpdateUserPassword(ctx context.Context, userID uuid.UUID, hash string) error {
+	res, err := sq.
+		Update("users").
+		Set("passwordHash", hash).
+		Set("passwordChangedAt", sq.Expr("now()")).
+		Where(sq.Eq{"id": userID}).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return models.ErrUserNotFound
 	}
 
 	return nil
 }
+
+// CreateEmailChange stores a pending change, replacing the previous one of
+// the user.
+func (r *Auth) CreateEmailChange(ctx context.Context, change *models.EmailChange) error {
+	_, err := sq.
+		Insert("emailChanges").
+		Columns("userID", "newEmail", "newEmailCanonical", "tokenHash", "expiresAt", "createdAt").
+		Values(change.UserID, change.NewEmail, change.NewEmailCanonical, change.TokenHash, change.ExpiresAt, change.CreatedAt).
+		Suffix("ON CONFLICT (userID) DO UPDATE SET newEmail = EXCLUDED.newEmail, newEmailCanonical = EXCLUDED.newEmailCanonical, tokenHash = EXCLUDED.tokenHash, expiresAt = EXCLUDED.expiresAt, createdAt = EXCLUDED.createdAt").
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		ExecContext(ctx)
+
+	return err
+}
+
+// ClaimEmailChange removes the pending change with the token hash and
+// returns it, so that a confirmation link works only once.
+func (r *Auth) ClaimEmailChange(ctx context.Context, tokenHash string) (*models.EmailChange, error) {
+	row := sq.
+		Delete("emailChanges").
+		Where(sq.Eq{"tokenHash": tokenHash}).
+		Suffix("RETURNING userID, newEmail, newEmailCanonical, tokenHash, expiresAt, createdAt").
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		QueryRowContext(ctx)
+
+	var change models.EmailChange
+
+	err := row.Scan(
+		&change.UserID,
+		&change.NewEmail,
+		&change.NewEmailCanonical,
+		&change.TokenHash,
+		&change.ExpiresAt,
+		&change.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrEmailChangeNotFound
+		}
+
+		return nil, err
+	}
+
+	return &change, nil
+}
+
+func (r *Auth) DeleteEmailChange(ctx context.Context, userID uuid.UUID) error {
+	_, err := sq.
+		Delete("emailChanges").
+		Where(sq.Eq{"userID": userID}).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		ExecContext(ctx)
+
+	return err
+}
+
+// ConsumeRevokeToken marks the revoke token as used. A token can be used
+// only once.
+func (r *Auth) ConsumeRevokeToken(ctx context.Context, userID uuid.UUID, tokenHash string) error {
+	_, err := sq.
+		Insert("usedRevokeTokens").
+		Columns("tokenHash", "userId").
+		Values(tokenHash, userID).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		ExecContext(ctx)
+	if isUniqueViolation(err) {
+		return models.ErrTokenUsed
+	}
+
+	return err
+}
+
+// TouchUserDevice remembers the device and reports whether it is new for a
+// user who already signed in from other devices. The first device of a user
+// is not reported.
+func (r *Auth) TouchUserDevice(ctx context.Context, userID uuid.UUID, fingerprint string) (bool, error) {
+	var (
+		inserted bool
+		known    int
+	)
+
+	// Subqueries in RETURNING see the table as it was before the insert.
+	err := sq.
+		Insert("userDevices").
+		Columns("userID", "fingerprint").
+		Values(userID, fingerprint).
+		Suffix("ON CONFLICT (userID, fingerprint) DO UPDATE SET lastSeenAt = now()").
+		Suffix("RETURNING (xmax = 0), (SELECT COUNT(*) FROM userDevices WHERE userID = ?)", userID).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		QueryRowContext(ctx).
+		Scan(&inserted, &known)
+	if err != nil {
+		return false, err
+	}
+
+	return inserted && known > 0, nil
+}
+
+func scanUser(row sq.RowScanner) (*models.User, error) {
+	var (
+		user   models.User
+		email  sql.NullString
+		phone  sql.NullString
+		locale sql.NullString
+	)
+
+	err := row.Scan(
+		&user.ID,
+		&email,
+		&phone,
+		&locale,
+		pq.Array(&user.Roles),
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrUserNotFound
+		}
+
+		return nil, err
+	}
+
+	user.Email = email.String
+	user.Phone = phone.String
+	user.Locale = locale.String
+
+	return &user, nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"medods-test-task/internal/db/postgres"
+	"medods-test-task/internal/models"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+)
+
+var clientColumns = []string{
+	"id", "name", "secretHash", "publicKey", "redirectURIs", "grantTypes", "scopes",
+	"accessTokenTTL", "refreshTokenTTL", "createdAt", "updatedAt",
+}
+
+type Clients struct {
+	db postgres.DB
+}
+
+func NewClientRepo(db postgres.DB) *Clients {
+	return &Clients{
+		db: db,
+	}
+}
+
+func (r *Clients) CreateClient(ctx context.Context, client *models.OAuthClient) error {
+	_, err := sq.
+		Insert("clients").
+		Columns(clientColumns...).
+		Values(
+			client.ID,
+			client.Name,
+			nullString(client.SecretHash),
+			nullString(client.PublicKey),
+			pq.Array(client.RedirectURIs),
+			pq.Array(client.GrantTypes),
+			pq.Array(client.Scopes),
+			int64(client.AccessTokenTTL/time.Second),
+			int64(client.RefreshTokenTTL/time.Second),
+			client.CreatedAt,
+			client.UpdatedAt,
+		).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		ExecContext(ctx)
+	if isUniqueViolation(err) {
+		return models.ErrOAuthClientExists
+	}
+
+	return err
+}
+
+func (r *Clients) GetClient(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	row := sq.
+		Select(clientColumns...).
+		From("clients").
+		Where(sq.Eq{"id": clientID}).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		QueryRowContext(ctx)
+
+	client, err := scanClient(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrOAuthClientNotFound
+		}
+
+		return nil, err
+	}
+
+	return client, nil
+}
+
+func (r *Clients) ListClients(ctx context.Context) ([]models.OAuthClient, error) {
+	rows, err := sq.
+		Select(clientColumns...).
+		From("clients").
+		OrderBy("createdAt").
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clients []models.OAuthClient
+
+	for rows.Next() {
+		client, err := scanClient(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		clients = append(clients, *client)
+	}
+
+	return clients, rows.Err()
+}
+
+// UpdateClient saves the settings of the client. The secret is changed only
+// by UpdateClientSecret.
+func (r *Clients) UpdateClient(ctx context.Context, client *models.OAuthClient) error {
+	res, err := sq.
+		Update("clients").
+		Set("name", client.Name).
+		Set("publicKey", nullString(client.PublicKey)).
+		Set("redirectURIs", pq.Array(client.RedirectURIs)).
+		Set("grantTypes", pq.Array(client.GrantTypes)).
+		Set("scopes", pq.Array(client.Scopes)).
+		Set("accessTokenTTL", int64(client.AccessTokenTTL/time.Second)).
+		Set("refreshTokenTTL", int64(client.RefreshTokenTTL/time.Second)).
+		Set("updatedAt", client.UpdatedAt).
+		Where(sq.Eq{"id": client.ID}).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	return clientAffected(res)
+}
+
+func (r *Clients) UpdateClientSecret(ctx context.Context, clientID, secretHash string) error {
+	res, err := sq.
+		Update("clients").
+		Set("secretHash", secretHash).
+		Set("updatedAt", time.Now()).
+		Where(sq.Eq{"id": clientID}).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	return clientAffected(res)
+}
+
+// DeleteClient also removes the sessions and authorization codes of the
+// client.
+func (r *Clients) DeleteClient(ctx context.Context, clientID string) error {
+	res, err := sq.
+		Delete("clients").
+		Where(sq.Eq{"id": clientID}).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	return clientAffected(res)
+}
+
+func clientAffected(res sql.Result) error {
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return models.ErrOAuthClientNotFound
+	}
+
+	return nil
+}
+
+func scanClient(row sq.RowScanner) (*models.OAuthClient, error) {
+	var (
+		client          models.OAuthClient
+		secretHash      sql.NullString
+		publicKey       sql.NullString
+		accessTokenTTL  int64
+		refreshTokenTTL int64
+	)
+
+	err := row.Scan(
+		&client.ID,
+		&client.Name,
+		&secretHash,
+		&publicKey,
+		pq.Array(&client.RedirectURIs),
+		pq.Array(&client.GrantTypes),
+		pq.Array(&client.Scopes),
+		&accessTokenTTL,
+		&refreshTokenTTL,
+		&client.CreatedAt,
+		&client.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	client.SecretHash = secretHash.String
+	client.PublicKey = publicKey.String
+	client.AccessTokenTTL = time.Duration(accessTokenTTL) * time.Second
+	client.RefreshTokenTTL = time.Duration(refreshTokenTTL) * time.Second
+
+	return &client, nil
+}
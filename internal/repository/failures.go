@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"medods-test-task/internal/models"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+func (r *Auth) GetAuthFailures(ctx context.Context, keys []string) ([]models.AuthFailure, error) {
+	rows, err := sq.
+		Select("key", "failures", "lastFailureAt", "lockedUntil").
+		From("authFailures").
+		Where(sq.Eq{"key": keys}).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var failures []models.AuthFailure
+
+	for rows.Next() {
+		var (
+			failure     models.AuthFailure
+			lockedUntil sql.NullTime
+		)
+
+		err := rows.Scan(
+			&failure.Key,
+			&failure.Failures,
+			&failure.LastFailureAt,
+			&lockedUntil,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		failure.LockedUntil = lockedUntil.Time
+		failures = append(failures, failure)
+	}
+
+	return failures, rows.Err()
+}
+
+// RegisterAuthFailure increments the failure counter for the key. Counters
+// that have not been touched for longer than window start over.
+func (r *Auth) RegisterAuthFailure(ctx context.Context, key string, window time.Duration) (*models.AuthFailure, error) {
+	row := sq.
+		Insert("authFailures").
+		Columns("key", "failures", "lastFailureAt").
+		Values(key, 1, sq.Expr("now()")).
+		Suffix(`ON CONFLICT (key) DO UPDATE SET
+			failures = CASE
+				WHEN authFailures.lastFailureAt < now() - make_interval(secs => ?) THEN 1
+				ELSE authFailures.failures + 1
+			END,
+			lastFailureAt = now()`, window.Seconds()).
+		Suffix("RETURNING key, failures, lastFailureAt, lockedUntil").
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db).
+		QueryRowContext(ctx)
+
+	var (
+		failure     models.AuthFailure
+		lockedUntil sql.NullTime
+	)
+
+	err := row.Scan(
+		&failure.Key,
+		&failure.Failures,
+		&failure.LastFailureAt,
+		&lockedUntil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	failure.LockedUntil = lockedUntil.Time
+
+	return &failure, nil
+}
+
+func (r *Auth) LockAuthFailure(ctx context.Context, key string, until time.Time) error {
+	_, err := sq.
+		Update("authFailures").
+		Set("failures", 0).
+		Set("lockedUntil", until).
+		Where(sq.Eq{"key": key}).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db).
+		ExecContext(ctx)
+
+	return err
+}
+
+func (r *Auth) ResetAuthFailures(ctx context.Context, keys []string) error {
+	_, err := sq.
+		Delete("authFailures").
+		Where(sq.Eq{"key": keys}).
+		Where(sq.Or{sq.Eq{"lockedUntil": nil}, sq.Expr("lockedUntil < now()")}).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db).
+		ExecContext(ctx)
+
+	return err
+}
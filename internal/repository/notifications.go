@@ -0,0 +1,214 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"medods-test-task/internal/db/postgres"
+	"medods-test-task/internal/models"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type Notifications struct {
+	db postgres.DB
+}
+
+func NewNotificationRepo(db postgres.DB) *Notifications {
+	return &Notifications{
+		db: db,
+	}
+}
+
+func (r *Notifications) GetNotificationPreferences(ctx context.Context, userID uuid.UUID) ([]models.NotificationPreference, error) {
+	rows, err := sq.
+		Select("event", "channels").
+		From("notificationPreferences").
+		Where(sq.Eq{"userID": userID}).
+		OrderBy("event").
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var preferences []models.NotificationPreference
+
+	for rows.Next() {
+		var preference models.NotificationPreference
+
+		if err := rows.Scan(&preference.Event, pq.Array(&preference.Channels)); err != nil {
+			return nil, err
+		}
+
+		preferences = append(preferences, preference)
+	}
+
+	return preferences, rows.Err()
+}
+
+// SetNotificationPreferences stores the channels of every given event,
+// leaving other events untouched.
+func (r *Notifications) SetNotificationPreferences(ctx context.Context, userID uuid.UUID, preferences []models.NotificationPreference) error {
+	if len(preferences) == 0 {
+		return nil
+	}
+
+	query := sq.
+		Insert("notificationPreferences").
+		Columns("userID", "event", "channels")
+
+	for _, preference := range preferences {
+		query = query.Values(userID, preference.Event, pq.Array(preference.Channels))
+	}
+
+	_, err := query.
+		Suffix("ON CONFLICT (userID, event) DO UPDATE SET channels = EXCLUDED.channels, updatedAt = now()").
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		ExecContext(ctx)
+
+	return err
+}
+
+func (r *Notifications) UpdateUserPhone(ctx context.Context, userID uuid.UUID, phone string) error {
+	res, err := sq.
+		Update("users").
+		Set("phone", phone).
+		Where(sq.Eq{"id": userID}).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return models.ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (r *Notifications) CreatePhoneVerification(ctx context.Context, verification *models.PhoneVerification) error {
+	row := sq.
+		Insert("phoneVerifications").
+		Columns("userID", "phone", "codeHash", "expiresAt", "createdAt").
+		Values(verification.UserID, verification.Phone, verification.CodeHash, verification.ExpiresAt, verification.CreatedAt).
+		Suffix("RETURNING id").
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		QueryRowContext(ctx)
+
+	return row.Scan(&verification.ID)
+}
+
+// GetPhoneVerification returns the latest code sent to the user, used or not.
+func (r *Notifications) GetPhoneVerification(ctx context.Context, userID uuid.UUID) (*models.PhoneVerification, error) {
+	var (
+		verification models.PhoneVerification
+		usedAt       sql.NullTime
+	)
+
+	err := sq.
+		Select("id", "userID", "phone", "codeHash", "attempts", "expiresAt", "usedAt", "createdAt").
+		From("phoneVerifications").
+		Where(sq.Eq{"userID": userID}).
+		OrderBy("createdAt DESC", "id DESC").
+		Limit(1).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		QueryRowContext(ctx).
+		Scan(
+			&verification.ID,
+			&verification.UserID,
+			&verification.Phone,
+			&verification.CodeHash,
+			&verification.Attempts,
+			&verification.ExpiresAt,
+			&usedAt,
+			&verification.CreatedAt,
+		)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrPhoneVerificationNotFound
+		}
+
+		return nil, err
+	}
+
+	verification.UsedAt = usedAt.Time
+
+	return &verification, nil
+}
+
+func (r *Notifications) CountPhoneVerifications(ctx context.Context, userID uuid.UUID, since time.Time) (int, error) {
+	var count int
+
+	err := sq.
+		Select("COUNT(*)").
+		From("phoneVerifications").
+		Where(sq.Eq{"userID": userID}).
+		Where(sq.GtOrEq{"createdAt": since}).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		QueryRowContext(ctx).
+		Scan(&count)
+
+	return count, err
+}
+
+// RecordPhoneCodeAttempt counts a try of the code and returns the number of
+// tries so far. It is a single update, so that parallel guesses are all
+// counted.
+func (r *Notifications) RecordPhoneCodeAttempt(ctx context.Context, id uint) (int, error) {
+	var attempts int
+
+	err := sq.
+		Update("phoneVerifications").
+		Set("attempts", sq.Expr("attempts + 1")).
+		Where(sq.Eq{"id": id}).
+		Suffix("RETURNING attempts").
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		QueryRowContext(ctx).
+		Scan(&attempts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, models.ErrPhoneVerificationNotFound
+	}
+
+	return attempts, err
+}
+
+// UsePhoneVerification marks the code used. A code can be used only once.
+func (r *Notifications) UsePhoneVerification(ctx context.Context, id uint, usedAt time.Time) error {
+	res, err := sq.
+		Update("phoneVerifications").
+		Set("usedAt", usedAt).
+		Where(sq.Eq{"id": id, "usedAt": nil}).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return models.ErrPhoneVerificationNotFound
+	}
+
+	return nil
+}
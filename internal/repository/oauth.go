@@ -0,0 +1,286 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"medods-test-task/internal/db/postgres"
+	"medods-test-task/internal/models"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+)
+
+var deviceCodeColumns = []string{
+	"deviceCodeHash", "userCode", "clientID", "scope", "status", "userID", "pollInterval", "lastPolledAt", "expiresAt", "createdAt",
+}
+
+type OAuth struct {
+	db postgres.DB
+}
+
+func NewOAuthRepo(db postgres.DB) *OAuth {
+	return &OAuth{
+		db: db,
+	}
+}
+
+// CreateAuthorizationCode stores the code and drops expired ones, so the
+// table only holds codes that can still be exchanged.
+func (r *OAuth) CreateAuthorizationCode(ctx context.Context, code *models.AuthorizationCode) error {
+	_, err := sq.
+		Delete("authorizationCodes").
+		Where("expiresAt < now()").
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = sq.
+		Insert("authorizationCodes").
+		Columns("codeHash", "clientID", "userID", "redirectURI", "scope", "codeChallenge", "expiresAt", "createdAt").
+		Values(code.CodeHash, code.ClientID, code.UserID, code.RedirectURI, code.Scope, code.CodeChallenge, code.ExpiresAt, code.CreatedAt).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		ExecContext(ctx)
+
+	return err
+}
+
+// ClaimAuthorizationCode removes the code and returns it, so that a code can
+// be exchanged only once.
+func (r *OAuth) ClaimAuthorizationCode(ctx context.Context, codeHash string) (*models.AuthorizationCode, error) {
+	row := sq.
+		Delete("authorizationCodes").
+		Where(sq.Eq{"codeHash": codeHash}).
+		Suffix("RETURNING codeHash, clientID, userID, redirectURI, scope, codeChallenge, expiresAt, createdAt").
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		QueryRowContext(ctx)
+
+	var code models.AuthorizationCode
+
+	err := row.Scan(
+		&code.CodeHash,
+		&code.ClientID,
+		&code.UserID,
+		&code.RedirectURI,
+		&code.Scope,
+		&code.CodeChallenge,
+		&code.ExpiresAt,
+		&code.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrInvalidGrant
+		}
+
+		return nil, err
+	}
+
+	return &code, nil
+}
+
+// UseClientAssertion records the id of a private_key_jwt assertion, so that
+// it cannot be replayed before it expires.
+func (r *OAuth) UseClientAssertion(ctx context.Context, clientID string, assertion models.ClientAssertion) error {
+	_, err := sq.
+		Delete("clientAssertions").
+		Where("expiresAt < now()").
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = sq.
+		Insert("clientAssertions").
+		Columns("clientID", "jti", "expiresAt").
+		Values(clientID, assertion.ID, assertion.ExpiresAt).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		ExecContext(ctx)
+	if isUniqueViolation(err) {
+		return models.ErrAssertionReplayed
+	}
+
+	return err
+}
+
+// CreateDeviceCode stores the device code and drops expired ones. A user code
+// that is already taken gives models.ErrUserCodeTaken.
+func (r *OAuth) CreateDeviceCode(ctx context.Context, code *models.DeviceCode) error {
+	_, err := sq.
+		Delete("deviceCodes").
+		Where("expiresAt < now()").
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = sq.
+		Insert("deviceCodes").
+		Columns("deviceCodeHash", "userCode", "clientID", "scope", "status", "pollInterval", "expiresAt", "createdAt").
+		Values(code.DeviceCodeHash, code.UserCode, code.ClientID, code.Scope, code.Status, int64(code.PollInterval/time.Second), code.ExpiresAt, code.CreatedAt).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		ExecContext(ctx)
+	if isUniqueViolation(err) {
+		return models.ErrUserCodeTaken
+	}
+
+	return err
+}
+
+// GetDeviceCodeByUserCode returns a device code that still waits for the user.
+func (r *OAuth) GetDeviceCodeByUserCode(ctx context.Context, userCode string) (*models.DeviceCode, error) {
+	row := sq.
+		Select(deviceCodeColumns...).
+		From("deviceCodes").
+		Where(sq.Eq{"userCode": userCode, "status": models.DeviceCodePending}).
+		Where("expiresAt > now()").
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		QueryRowContext(ctx)
+
+	code, err := scanDeviceCode(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.ErrInvalidUserCode
+	}
+
+	return code, err
+}
+
+// DecideDeviceCode records the decision of the user on a device code that
+// still waits for one.
+func (r *OAuth) DecideDeviceCode(ctx context.Context, userCode string, userID uuid.UUID, status string) error {
+	res, err := sq.
+		Update("deviceCodes").
+		Set("status", status).
+		Set("userID", userID).
+		Where(sq.Eq{"userCode": userCode, "status": models.DeviceCodePending}).
+		Where("expiresAt > now()").
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return models.ErrInvalidUserCode
+	}
+
+	return nil
+}
+
+// PollDeviceCode records a poll of the device and returns the code with the
+// time of the previous poll.
+func (r *OAuth) PollDeviceCode(ctx context.Context, deviceCodeHash string, polledAt time.Time) (*models.DeviceCode, error) {
+	prev := sq.
+		Select("deviceCodeHash", "lastPolledAt").
+		From("deviceCodes").
+		Where(sq.Eq{"deviceCodeHash": deviceCodeHash}).
+		Suffix("FOR UPDATE")
+
+	row := sq.
+		Update("deviceCodes AS d").
+		Set("lastPolledAt", polledAt).
+		FromSelect(prev, "prev").
+		Where("d.deviceCodeHash = prev.deviceCodeHash").
+		Suffix("RETURNING d.deviceCodeHash, d.userCode, d.clientID, d.scope, d.status, d.userID, d.pollInterval, prev.lastPolledAt, d.expiresAt, d.createdAt").
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		QueryRowContext(ctx)
+
+	code, err := scanDeviceCode(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.ErrInvalidGrant
+	}
+
+	return code, err
+}
+
+// SetDevicePollInterval changes how often the device may poll.
+func (r *OAuth) SetDevicePollInterval(ctx context.Context, deviceCodeHash string, interval time.Duration) error {
+	_, err := sq.
+		Update("deviceCodes").
+		Set("pollInterval", int64(interval/time.Second)).
+		Where(sq.Eq{"deviceCodeHash": deviceCodeHash}).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		ExecContext(ctx)
+
+	return err
+}
+
+// DeleteDeviceCode removes a device code once the device got its answer. It
+// gives models.ErrInvalidGrant when the code was already removed, so that an
+// approval is redeemed only once.
+func (r *OAuth) DeleteDeviceCode(ctx context.Context, deviceCodeHash string) error {
+	res, err := sq.
+		Delete("deviceCodes").
+		Where(sq.Eq{"deviceCodeHash": deviceCodeHash}).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return models.ErrInvalidGrant
+	}
+
+	return nil
+}
+
+func scanDeviceCode(row sq.RowScanner) (*models.DeviceCode, error) {
+	var (
+		code         models.DeviceCode
+		userID       uuid.NullUUID
+		pollInterval int64
+		lastPolledAt sql.NullTime
+	)
+
+	err := row.Scan(
+		&code.DeviceCodeHash,
+		&code.UserCode,
+		&code.ClientID,
+		&code.Scope,
+		&code.Status,
+		&userID,
+		&pollInterval,
+		&lastPolledAt,
+		&code.ExpiresAt,
+		&code.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	code.UserID = userID.UUID
+	code.PollInterval = time.Duration(pollInterval) * time.Second
+
+	if lastPolledAt.Valid {
+		code.LastPolledAt = &lastPolledAt.Time
+	}
+
+	return &code, nil
+}
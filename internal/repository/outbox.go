@@ -0,0 +1,244 @@
+package repository
+
+import (
+	"context"
+	"medods-test-task/internal/db/postgres"
+	"medods-test-task/internal/models"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+)
+
+// outboxRecipientLock is the class of the advisory locks taken per
+// recipient while throttling emails.
+const outboxRecipientLock = 7_028_030
+
+type Outbox struct {
+	db postgres.DB
+}
+
+func NewOutboxRepo(db postgres.DB) *Outbox {
+	return &Outbox{
+		db: db,
+	}
+}
+
+// CreateOutboxEmail joins the transaction from the context so that the email
+// is only queued when the surrounding change commits.
+func (r *Outbox) CreateOutboxEmail(ctx context.Context, email *models.OutboxEmail) error {
+	userID := uuid.NullUUID{UUID: email.UserID, Valid: email.UserID != uuid.Nil}
+
+	row := sq.
+		Insert("emailOutbox").
+		Columns("userID", "kind", "recipient", "subject", "body", "textBody", "nextAttemptAt", "createdAt").
+		Values(userID, email.Kind, email.To, email.Subject, email.Body, email.Text, email.NextAttemptAt, email.CreatedAt).
+		Suffix("RETURNING id").
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		QueryRowContext(ctx)
+
+	return row.Scan(&email.ID)
+}
+
+// HasRecentOutboxEmail reports whether the user was already sent an email of
+// the kind since the given time.
+func (r *Outbox) HasRecentOutboxEmail(ctx context.Context, userID uuid.UUID, kind string, since time.Time) (bool, error) {
+	var exists bool
+
+	err := sq.
+		Select("1").
+		From("emailOutbox").
+		Where(sq.Eq{"userID": userID, "kind": kind}).
+		Where(sq.GtOrEq{"createdAt": since}).
+		Prefix("SELECT EXISTS(").
+		Suffix(")").
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		QueryRowContext(ctx).
+		Scan(&exists)
+
+	return exists, err
+}
+
+func (r *Outbox) CountRecentOutboxEmails(ctx context.Context, recipient string, since time.Time) (int, error) {
+	var count int
+
+	err := sq.
+		Select("COUNT(*)").
+		From("emailOutbox").
+		Where(sq.Eq{"recipient": recipient}).
+		Where(sq.GtOrEq{"createdAt": since}).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		QueryRowContext(ctx).
+		Scan(&count)
+
+	return count, err
+}
+
+// LockOutboxRecipient holds an advisory lock on the recipient until the
+// transaction from the context ends. Without a transaction it is released at
+// once.
+func (r *Outbox) LockOutboxRecipient(ctx context.Context, recipient string) error {
+	_, err := r.db.Runner(ctx).ExecContext(ctx, "SELECT pg_advisory_xact_lock($1, hashtext($2))", outboxRecipientLock, recipient)
+
+	return err
+}
+
+func (r *Outbox) CreateEmailSuppression(ctx context.Context, suppression *models.EmailSuppression) error {
+	userID := uuid.NullUUID{UUID: suppression.UserID, Valid: suppression.UserID != uuid.Nil}
+
+	row := sq.
+		Insert("emailSuppressions").
+		Columns("userID", "recipient", "locale", "kind", "reason", "createdAt").
+		Values(userID, suppression.To, suppression.Locale, suppression.Kind, suppression.Reason, suppression.CreatedAt).
+		Suffix("RETURNING id").
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		QueryRowContext(ctx)
+
+	return row.Scan(&suppression.ID)
+}
+
+// ClaimEmailSuppressions marks every pending suppression as digested and
+// returns them. It joins the transaction from the context, so the claim is
+// rolled back when the digest cannot be queued.
+func (r *Outbox) ClaimEmailSuppressions(ctx context.Context) ([]models.EmailSuppression, error) {
+	rows, err := sq.
+		Update("emailSuppressions").
+		Set("digestedAt", sq.Expr("now()")).
+		Where(sq.Eq{"digestedAt": nil}).
+		Suffix("RETURNING id, userID, recipient, locale, kind, reason, createdAt").
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var suppressions []models.EmailSuppression
+
+	for rows.Next() {
+		var (
+			suppression models.EmailSuppression
+			userID      uuid.NullUUID
+		)
+
+		err := rows.Scan(
+			&suppression.ID,
+			&userID,
+			&suppression.To,
+			&suppression.Locale,
+			&suppression.Kind,
+			&suppression.Reason,
+			&suppression.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		suppression.UserID = userID.UUID
+		suppressions = append(suppressions, suppression)
+	}
+
+	return suppressions, rows.Err()
+}
+
+// ClaimOutboxEmails picks due emails and hides them from other dispatchers
+// for the lease duration.
+func (r *Outbox) ClaimOutboxEmails(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEmail, error) {
+	due := sq.
+		Select("id").
+		From("emailOutbox").
+		Where(sq.Eq{"sentAt": nil, "failedAt": nil}).
+		Where(sq.Expr("nextAttemptAt <= now()")).
+		OrderBy("id").
+		Limit(uint64(limit)).
+		Suffix("FOR UPDATE SKIP LOCKED")
+
+	dueSQL, dueArgs, err := due.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := sq.
+		Update("emailOutbox").
+		Set("nextAttemptAt", sq.Expr("now() + make_interval(secs => ?)", lease.Seconds())).
+		Where("id IN ("+dueSQL+")", dueArgs...).
+		Suffix("RETURNING id, recipient, subject, body, textBody, attempts, lastError, createdAt").
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var emails []models.OutboxEmail
+
+	for rows.Next() {
+		var email models.OutboxEmail
+
+		err := rows.Scan(
+			&email.ID,
+			&email.To,
+			&email.Subject,
+			&email.Body,
+			&email.Text,
+			&email.Attempts,
+			&email.LastError,
+			&email.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		emails = append(emails, email)
+	}
+
+	return emails, rows.Err()
+}
+
+func (r *Outbox) MarkOutboxEmailSent(ctx context.Context, id uint, attempts int) error {
+	_, err := sq.
+		Update("emailOutbox").
+		Set("attempts", attempts).
+		Set("lastError", "").
+		Set("sentAt", sq.Expr("now()")).
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db).
+		ExecContext(ctx)
+
+	return err
+}
+
+func (r *Outbox) RescheduleOutboxEmail(ctx context.Context, id uint, attempts int, nextAttemptAt time.Time, lastError string) error {
+	_, err := sq.
+		Update("emailOutbox").
+		Set("attempts", attempts).
+		Set("nextAttemptAt", nextAttemptAt).
+		Set("lastError", lastError).
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db).
+		ExecContext(ctx)
+
+	return err
+}
+
+func (r *Outbox) FailOutboxEmail(ctx context.Context, id uint, attempts int, lastError string) error {
+	_, err := sq.
+		Update("emailOutbox").
+		Set("attempts", attempts).
+		Set("lastError", lastError).
+		Set("failedAt", sq.Expr("now()")).
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db).
+		ExecContext(ctx)
+
+	return err
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"medods-test-task/internal/db/postgres"
+	"medods-test-task/pkg/ratelimit"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+type RateLimit struct {
+	db postgres.DB
+}
+
+func NewRateLimitRepo(db postgres.DB) *RateLimit {
+	return &RateLimit{
+		db: db,
+	}
+}
+
+func (r *RateLimit) Allow(ctx context.Context, key string, rule ratelimit.Rule) (ratelimit.Result, error) {
+	if !rule.Enabled() {
+		return ratelimit.Result{Allowed: true}, nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+	defer tx.Rollback()
+
+	_, err = sq.
+		Insert("rateLimits").
+		Columns("key", "tokens", "updatedAt").
+		Values(key, rule.Limit, sq.Expr("now()")).
+		Suffix("ON CONFLICT (key) DO NOTHING").
+		PlaceholderFormat(sq.Dollar).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+
+	row := sq.
+		Select("tokens", "EXTRACT(EPOCH FROM now() - updatedAt)").
+		From("rateLimits").
+		Where(sq.Eq{"key": key}).
+		Suffix("FOR UPDATE").
+		PlaceholderFormat(sq.Dollar).
+		RunWith(tx).
+		QueryRowContext(ctx)
+
+	var tokens, elapsed float64
+
+	if err := row.Scan(&tokens, &elapsed); err != nil {
+		return ratelimit.Result{}, err
+	}
+
+	tokens, res := ratelimit.Take(tokens, time.Duration(elapsed*float64(time.Second)), rule)
+
+	_, err = sq.
+		Update("rateLimits").
+		Set("tokens", tokens).
+		Set("updatedAt", sq.Expr("now()")).
+		Set("fullAt", sq.Expr("now() + make_interval(secs => ?)", ratelimit.FullIn(tokens, rule).Seconds())).
+		Where(sq.Eq{"key": key}).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return ratelimit.Result{}, err
+	}
+
+	return res, nil
+}
+
+// DeleteFullRateLimits drops the buckets that have been refilled completely,
+// since a new bucket for the same key starts in exactly the same state.
+func (r *RateLimit) DeleteFullRateLimits(ctx context.Context) (int64, error) {
+	res, err := sq.
+		Delete("rateLimits").
+		Where("fullAt <= now()").
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		ExecContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
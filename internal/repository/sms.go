@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"medods-test-task/internal/db/postgres"
+	"medods-test-task/internal/models"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+)
+
+type SMSOutbox struct {
+	db postgres.DB
+}
+
+func NewSMSOutboxRepo(db postgres.DB) *SMSOutbox {
+	return &SMSOutbox{
+		db: db,
+	}
+}
+
+// CreateOutboxSMS joins the transaction from the context so that the message
+// is only queued when the surrounding change commits.
+func (r *SMSOutbox) CreateOutboxSMS(ctx context.Context, sms *models.OutboxSMS) error {
+	userID := uuid.NullUUID{UUID: sms.UserID, Valid: sms.UserID != uuid.Nil}
+
+	row := sq.
+		Insert("smsOutbox").
+		Columns("userID", "kind", "recipient", "body", "nextAttemptAt", "createdAt").
+		Values(userID, sms.Kind, sms.To, sms.Body, sms.NextAttemptAt, sms.CreatedAt).
+		Suffix("RETURNING id").
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		QueryRowContext(ctx)
+
+	return row.Scan(&sms.ID)
+}
+
+// ClaimOutboxSMS picks due messages and hides them from other dispatchers for
+// the lease duration.
+func (r *SMSOutbox) ClaimOutboxSMS(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxSMS, error) {
+	due := sq.
+		Select("id").
+		From("smsOutbox").
+		Where(sq.Eq{"sentAt": nil, "failedAt": nil}).
+		Where(sq.Expr("nextAttemptAt <= now()")).
+		OrderBy("id").
+		Limit(uint64(limit)).
+		Suffix("FOR UPDATE SKIP LOCKED")
+
+	dueSQL, dueArgs, err := due.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := sq.
+		Update("smsOutbox").
+		Set("nextAttemptAt", sq.Expr("now() + make_interval(secs => ?)", lease.Seconds())).
+		Where("id IN ("+dueSQL+")", dueArgs...).
+		Suffix("RETURNING id, kind, recipient, body, attempts, lastError, createdAt").
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []models.OutboxSMS
+
+	for rows.Next() {
+		var sms models.OutboxSMS
+
+		err := rows.Scan(
+			&sms.ID,
+			&sms.Kind,
+			&sms.To,
+			&sms.Body,
+			&sms.Attempts,
+			&sms.LastError,
+			&sms.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, sms)
+	}
+
+	return messages, rows.Err()
+}
+
+func (r *SMSOutbox) MarkOutboxSMSSent(ctx context.Context, id uint, attempts int) error {
+	_, err := sq.
+		Update("smsOutbox").
+		Set("attempts", attempts).
+		Set("lastError", "").
+		Set("sentAt", sq.Expr("now()")).
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db).
+		ExecContext(ctx)
+
+	return err
+}
+
+func (r *SMSOutbox) RescheduleOutboxSMS(ctx context.Context, id uint, attempts int, nextAttemptAt time.Time, lastError string) error {
+	_, err := sq.
+		Update("smsOutbox").
+		Set("attempts", attempts).
+		Set("nextAttemptAt", nextAttemptAt).
+		Set("lastError", lastError).
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db).
+		ExecContext(ctx)
+
+	return err
+}
+
+func (r *SMSOutbox) FailOutboxSMS(ctx context.Context, id uint, attempts int, lastError string) error {
+	_, err := sq.
+		Update("smsOutbox").
+		Set("attempts", attempts).
+		Set("lastError", lastError).
+		Set("failedAt", sq.Expr("now()")).
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db).
+		ExecContext(ctx)
+
+	return err
+}
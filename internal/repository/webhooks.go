@@ -0,0 +1,284 @@
+package repository
+
+import (
+	"context"
+	"medods-test-task/internal/db/postgres"
+	"medods-test-task/internal/models"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type Webhooks struct {
+	db postgres.DB
+}
+
+func NewWebhookRepo(db postgres.DB) *Webhooks {
+	return &Webhooks{
+		db: db,
+	}
+}
+
+func (r *Webhooks) CreateWebhookEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error {
+	userID := uuid.NullUUID{UUID: endpoint.UserID, Valid: endpoint.UserID != uuid.Nil}
+
+	_, err := sq.
+		Insert("webhookEndpoints").
+		Columns("id", "userID", "url", "secret", "events", "createdAt").
+		Values(endpoint.ID, userID, endpoint.URL, endpoint.Secret, pq.Array(endpoint.Events), endpoint.CreatedAt).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		ExecContext(ctx)
+
+	return err
+}
+
+// ListWebhookEndpoints returns operator endpoints. Endpoints registered by
+// users for their own notifications are left out.
+func (r *Webhooks) ListWebhookEndpoints(ctx context.Context) ([]models.WebhookEndpoint, error) {
+	return r.listEndpoints(ctx, sq.Eq{"userID": nil})
+}
+
+func (r *Webhooks) ListWebhookEndpointsByEvent(ctx context.Context, eventType string) ([]models.WebhookEndpoint, error) {
+	return r.listEndpoints(ctx, sq.And{sq.Eq{"userID": nil}, sq.Expr("? = ANY(events)", eventType)})
+}
+
+func (r *Webhooks) GetUserWebhookEndpoint(ctx context.Context, userID uuid.UUID) (*models.WebhookEndpoint, error) {
+	endpoints, err := r.listEndpoints(ctx, sq.Eq{"userID": userID})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(endpoints) == 0 {
+		return nil, models.ErrWebhookEndpointNotFound
+	}
+
+	return &endpoints[0], nil
+}
+
+func (r *Webhooks) listEndpoints(ctx context.Context, pred sq.Sqlizer) ([]models.WebhookEndpoint, error) {
+	rows, err := sq.
+		Select("id", "userID", "url", "secret", "events", "createdAt").
+		From("webhookEndpoints").
+		Where(pred).
+		OrderBy("createdAt").
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var endpoints []models.WebhookEndpoint
+
+	for rows.Next() {
+		var (
+			endpoint models.WebhookEndpoint
+			userID   uuid.NullUUID
+		)
+
+		err := rows.Scan(
+			&endpoint.ID,
+			&userID,
+			&endpoint.URL,
+			&endpoint.Secret,
+			pq.Array(&endpoint.Events),
+			&endpoint.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		endpoint.UserID = userID.UUID
+		endpoints = append(endpoints, endpoint)
+	}
+
+	return endpoints, rows.Err()
+}
+
+func (r *Webhooks) DeleteWebhookEndpoint(ctx context.Context, id uuid.UUID) error {
+	return r.deleteEndpoints(ctx, sq.Eq{"id": id, "userID": nil})
+}
+
+func (r *Webhooks) DeleteUserWebhookEndpoint(ctx context.Context, userID uuid.UUID) error {
+	return r.deleteEndpoints(ctx, sq.Eq{"userID": userID})
+}
+
+func (r *Webhooks) deleteEndpoints(ctx context.Context, pred sq.Sqlizer) error {
+	res, err := sq.
+		Delete("webhookEndpoints").
+		Where(pred).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return models.ErrWebhookEndpointNotFound
+	}
+
+	return nil
+}
+
+// CreateWebhookDeliveries joins the transaction from the context.
+func (r *Webhooks) CreateWebhookDeliveries(ctx context.Context, deliveries []models.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	query := sq.
+		Insert("webhookDeliveries").
+		Columns("endpointId", "eventType", "payload", "nextAttemptAt", "createdAt")
+
+	for _, d := range deliveries {
+		query = query.Values(d.EndpointID, d.EventType, d.Payload, d.NextAttemptAt, d.CreatedAt)
+	}
+
+	_, err := query.
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db.Runner(ctx)).
+		ExecContext(ctx)
+
+	return err
+}
+
+// ClaimWebhookDeliveries picks due deliveries and hides them from other
+// dispatchers for the lease duration.
+func (r *Webhooks) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	due := sq.
+		Select("id").
+		From("webhookDeliveries").
+		Where(sq.Eq{"deliveredAt": nil}).
+		Where(sq.Expr("nextAttemptAt <= now()")).
+		OrderBy("id").
+		Limit(uint64(limit)).
+		Suffix("FOR UPDATE SKIP LOCKED")
+
+	dueSQL, dueArgs, err := due.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := sq.
+		Update("webhookDeliveries d").
+		Set("nextAttemptAt", sq.Expr("now() + make_interval(secs => ?)", lease.Seconds())).
+		From("webhookEndpoints e").
+		Where("d.endpointId = e.id").
+		Where("d.id IN ("+dueSQL+")", dueArgs...).
+		Suffix("RETURNING d.id, d.endpointId, e.userID, e.url, e.secret, d.eventType, d.payload, d.attempts, d.lastError, d.createdAt").
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+
+	for rows.Next() {
+		var (
+			d      models.WebhookDelivery
+			userID uuid.NullUUID
+		)
+
+		err := rows.Scan(
+			&d.ID,
+			&d.EndpointID,
+			&userID,
+			&d.URL,
+			&d.Secret,
+			&d.EventType,
+			&d.Payload,
+			&d.Attempts,
+			&d.LastError,
+			&d.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		d.UserID = userID.UUID
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func (r *Webhooks) MarkWebhookDelivered(ctx context.Context, id uint, attempts int) error {
+	_, err := sq.
+		Update("webhookDeliveries").
+		Set("attempts", attempts).
+		Set("lastError", "").
+		Set("deliveredAt", sq.Expr("now()")).
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db).
+		ExecContext(ctx)
+
+	return err
+}
+
+func (r *Webhooks) RescheduleWebhookDelivery(ctx context.Context, id uint, attempts int, nextAttemptAt time.Time, lastError string) error {
+	_, err := sq.
+		Update("webhookDeliveries").
+		Set("attempts", attempts).
+		Set("nextAttemptAt", nextAttemptAt).
+		Set("lastError", lastError).
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(r.db).
+		ExecContext(ctx)
+
+	return err
+}
+
+// DeadLetterWebhookDelivery moves a delivery that ran out of attempts to the
+// dead-letter table.
+func (r *Webhooks) DeadLetterWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = sq.
+		Insert("webhookDeadLetters").
+		Columns("deliveryId", "endpointId", "eventType", "payload", "attempts", "lastError", "createdAt").
+		Values(delivery.ID, delivery.EndpointID, delivery.EventType, delivery.Payload, delivery.Attempts, delivery.LastError, delivery.CreatedAt).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	res, err := sq.
+		Delete("webhookDeliveries").
+		Where(sq.Eq{"id": delivery.ID}).
+		PlaceholderFormat(sq.Dollar).
+		RunWith(tx).
+		ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return models.ErrWebhookDeliveryNotFound
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"medods-test-task/config"
+	"medods-test-task/internal/models"
+	"medods-test-task/pkg/email"
+	"medods-test-task/pkg/password"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const opaqueTokenLength = 32
+
+//go:generate go run github.com/vektra/mockery/v2@latest --name AccountRepo
+type AccountRepo interface {
+	GetUserByID(ctx context.Context, userID uuid.UUID) (*models.User, error)
+	GetUserByEmail(ctx context.Context, canonicalEmail string) (*models.User, error)
+	UpdateUserEmail(ctx context.Context, userID uuid.UUID, email, canonicalEmail string) error
+	CreateEmailChange(ctx context.Context, change *models.EmailChange) error
+	ClaimEmailChange(ctx context.Context, tokenHash string) (*models.EmailChange, error)
+	GetUserPasswordHash(ctx context.Context, userID uuid.UUID) (string, error)
+	UpdateUserPassword(ctx context.Context, userID uuid.UUID, hash string) error
+}
+
+//go:generate go run github.com/vektra/mockery/v2@latest --name EmailPolicy
+type EmailPolicy interface {
+	Check(address string) (email.Address, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@latest --name PasswordPolicy
+type PasswordPolicy interface {
+	Validate(password, email string) error
+}
+
+//go:generate go run github.com/vektra/mockery/v2@latest --name AccountMailer
+type AccountMailer interface {
+	SendEmailChangeConfirmation(ctx context.Context, user *models.User, newEmail, token string, expiresAt time.Time) error
+	SendEmailChangeNotice(ctx context.Context, user *models.User, newEmail string, alert models.SecurityAlert) error
+}
+
+type accountService struct {
+	repo         AccountRepo
+	mailer       AccountMailer
+	policy       EmailPolicy
+	passwords    PasswordPolicy
+	notifier     Notifier
+	tokenManager TokenManager
+	guard        BruteForceGuard
+	transactor   Transactor
+	emailConfig  *config.EmailConfig
+}
+
+func NewAccountService(repo AccountRepo, mailer AccountMailer, policy EmailPolicy, passwords PasswordPolicy, notifier Notifier, token TokenManager, guard BruteForceGuard, transactor Transactor, emailConf *config.EmailConfig) *accountService {
+	return &accountService{
+		repo:         repo,
+		mailer:       mailer,
+		policy:       policy,
+		passwords:    passwords,
+		notifier:     notifier,
+		tokenManager: token,
+		guard:        guard,
+		transactor:   transactor,
+		emailConfig:  emailConf,
+	}
+}
+
+// ChangeEmail starts changing the address of the user. The new address gets
+// a confirmation link and the current one a notice; the address itself is
+// only updated by ConfirmEmailChange.
+func (s *accountService) ChangeEmail(ctx context.Context, userID uuid.UUID, newEmail, IPAddress, userAgent string) error {
+	address, err := s.policy.Check(newEmail)
+	if err != nil {
+		return err
+	}
+
+	newEmail = address.Email
+
+	return s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		user, err := s.repo.GetUserByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		if user.Email == newEmail {
+			return models.ErrEmailUnchanged
+		}
+
+		owner, err := s.repo.GetUserByEmail(ctx, address.Canonical)
+		if err == nil && owner.ID != userID {
+			return models.ErrEmailTaken
+		}
+		if err != nil && !errors.Is(err, models.ErrUserNotFound) {
+			return err
+		}
+
+		token, err := newOpaqueToken()
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		change := &models.EmailChange{
+			UserID:            userID,
+			NewEmail:          newEmail,
+			NewEmailCanonical: address.Canonical,
+			TokenHash:         hashOpaqueToken(token),
+			ExpiresAt:         now.Add(s.emailConfig.ChangeTTL),
+			CreatedAt:         now,
+		}
+
+		if err := s.repo.CreateEmailChange(ctx, change); err != nil {
+			return err
+		}
+
+		if err := s.mailer.SendEmailChangeConfirmation(ctx, user, newEmail, token, change.ExpiresAt); err != nil {
+			return err
+		}
+
+		revokeToken, err := s.tokenManager.NewRevokeToken(userID)
+		if err != nil {
+			return err
+		}
+
+		return s.mailer.SendEmailChangeNotice(ctx, user, newEmail, models.SecurityAlert{
+			NewIP:       IPAddress,
+			UserAgent:   userAgent,
+			OccurredAt:  now,
+			RevokeToken: revokeToken,
+		})
+	})
+}
+
+// ConfirmEmailChange applies the change the token was issued for. Each token
+// can be used once.
+func (s *accountService) ConfirmEmailChange(ctx context.Context, token string) error {
+	return s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		change, err := s.repo.ClaimEmailChange(ctx, hashOpaqueToken(token))
+		if err != nil {
+			return err
+		}
+
+		if time.Now().After(change.ExpiresAt) {
+			return models.ErrTokenExpired
+		}
+
+		return s.repo.UpdateUserEmail(ctx, change.UserID, change.NewEmail, change.NewEmailCanonical)
+	})
+}
+
+// SetPassword checks the new password against the policy and stores it.
+// Changing an existing password requires the current one. A wrong current
+// password counts as a failed sign in, so that a stolen access token cannot
+// be used to guess it. It is checked outside of the transaction, which would
+// roll the failure back.
+func (s *accountService) SetPassword(ctx context.Context, userID uuid.UUID, currentPassword, newPassword, IPAddress, userAgent string) error {
+	if err := s.guard.Check(ctx, userID, IPAddress); err != nil {
+		return err
+	}
+
+	hash, err := s.repo.GetUserPasswordHash(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if hash != "" {
+		if currentPassword == "" {
+			return models.ErrPasswordRequired
+		}
+
+		if err := password.Compare(hash, currentPassword); err != nil {
+			if errors.Is(err, models.ErrWrongPassword) {
+				s.guard.RegisterFailure(ctx, userID, IPAddress)
+			}
+
+			return err
+		}
+
+		s.guard.Reset(ctx, userID)
+	}
+
+	return s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		user, err := s.repo.GetUserByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		if err := s.passwords.Validate(newPassword, user.Email); err != nil {
+			return err
+		}
+
+		hash, err := password.Hash(newPassword)
+		if err != nil {
+			return err
+		}
+
+		if err := s.repo.UpdateUserPassword(ctx, userID, hash); err != nil {
+			return err
+		}
+
+		revokeToken, err := s.tokenManager.NewRevokeToken(userID)
+		if err != nil {
+			return err
+		}
+
+		return s.notifier.Notify(ctx, models.Notification{
+			Event: models.NotificationPasswordChange,
+			User:  user,
+			Alert: models.SecurityAlert{
+				NewIP:       IPAddress,
+				UserAgent:   userAgent,
+				OccurredAt:  time.Now(),
+				RevokeToken: revokeToken,
+			},
+		})
+	})
+}
+
+// newOpaqueToken creates a random token for links and codes. Only its hash
+// is stored, see hashOpaqueToken.
+func newOpaqueToken() (string, error) {
+	token := make([]byte, opaqueTokenLength)
+	if _, err := rand.Read(token); err != nil {
+		return "", fmt.Errorf("failed to create token: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+func hashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
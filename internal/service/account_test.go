@@ -0,0 +1,267 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"medods-test-task/config"
+	"medods-test-task/internal/models"
+	"medods-test-task/internal/service/mocks"
+	"medods-test-task/pkg/email"
+	"medods-test-task/pkg/password"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAccountService_ChangeEmail(t *testing.T) {
+	userID := uuid.New()
+	user := &models.User{ID: userID, Email: "old@email.com"}
+
+	type mockBehavior func(r *mocks.AccountRepo, m *mocks.AccountMailer, tm *mocks.TokenManager)
+
+	tests := []struct {
+		name        string
+		email       string
+		mock        mockBehavior
+		expectedErr error
+	}{
+		{
+			name:  "OK",
+			email: " New@EMAIL.com ",
+			mock: func(r *mocks.AccountRepo, m *mocks.AccountMailer, tm *mocks.TokenManager) {
+				r.On("GetUserByID", mock.Anything, userID).Return(user, nil)
+				r.On("GetUserByEmail", mock.Anything, "new@email.com").Return(nil, models.ErrUserNotFound)
+				r.On("CreateEmailChange", mock.Anything, mock.MatchedBy(func(c *models.EmailChange) bool {
+					return c.UserID == userID && c.NewEmail == "New@email.com" && c.NewEmailCanonical == "new@email.com" &&
+						len(c.TokenHash) == 64 && c.ExpiresAt.After(time.Now())
+				})).Return(nil)
+				m.On("SendEmailChangeConfirmation", mock.Anything, user, "New@email.com", mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).Return(nil)
+				tm.On("NewRevokeToken", userID).Return("revoke", nil)
+				m.On("SendEmailChangeNotice", mock.Anything, user, "New@email.com", mock.MatchedBy(func(a models.SecurityAlert) bool {
+					return a.RevokeToken == "revoke" && a.NewIP == "127.0.0.1"
+				})).Return(nil)
+			},
+		},
+		{
+			name:        "Invalid email",
+			email:       "not-an-email",
+			mock:        func(r *mocks.AccountRepo, m *mocks.AccountMailer, tm *mocks.TokenManager) {},
+			expectedErr: models.ErrEmailFormat,
+		},
+		{
+			name:        "Disposable domain",
+			email:       "someone@inbox.mailinator.com",
+			mock:        func(r *mocks.AccountRepo, m *mocks.AccountMailer, tm *mocks.TokenManager) {},
+			expectedErr: models.ErrDisposableEmail,
+		},
+		{
+			name:  "Same email",
+			email: "old@EMAIL.com",
+			mock: func(r *mocks.AccountRepo, m *mocks.AccountMailer, tm *mocks.TokenManager) {
+				r.On("GetUserByID", mock.Anything, userID).Return(user, nil)
+			},
+			expectedErr: models.ErrEmailUnchanged,
+		},
+		{
+			name:  "Email taken",
+			email: "taken@email.com",
+			mock: func(r *mocks.AccountRepo, m *mocks.AccountMailer, tm *mocks.TokenManager) {
+				r.On("GetUserByID", mock.Anything, userID).Return(user, nil)
+				r.On("GetUserByEmail", mock.Anything, "taken@email.com").Return(&models.User{ID: uuid.New()}, nil)
+			},
+			expectedErr: models.ErrEmailTaken,
+		},
+		{
+			name:  "Gmail alias of another user",
+			email: "Jane.Doe+shop@googlemail.com",
+			mock: func(r *mocks.AccountRepo, m *mocks.AccountMailer, tm *mocks.TokenManager) {
+				r.On("GetUserByID", mock.Anything, userID).Return(user, nil)
+				r.On("GetUserByEmail", mock.Anything, "janedoe@gmail.com").Return(&models.User{ID: uuid.New()}, nil)
+			},
+			expectedErr: models.ErrEmailTaken,
+		},
+		{
+			name:  "User not found",
+			email: "new@email.com",
+			mock: func(r *mocks.AccountRepo, m *mocks.AccountMailer, tm *mocks.TokenManager) {
+				r.On("GetUserByID", mock.Anything, userID).Return(nil, models.ErrUserNotFound)
+			},
+			expectedErr: models.ErrUserNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mocks.NewAccountRepo(t)
+			m := mocks.NewAccountMailer(t)
+			tm := mocks.NewTokenManager(t)
+			tt.mock(r, m, tm)
+
+			policy := email.NewPolicy(email.NewBlocklist("mailinator.com"), true)
+			s := NewAccountService(r, m, policy, mocks.NewPasswordPolicy(t), mocks.NewNotifier(t), tm, mocks.NewBruteForceGuard(t), nopTransactor{}, &config.EmailConfig{ChangeTTL: time.Hour})
+
+			err := s.ChangeEmail(context.Background(), userID, tt.email, "127.0.0.1", "test-agent")
+			if !errors.Is(err, tt.expectedErr) {
+				t.Errorf("error = %v, expectedError %v", err, tt.expectedErr)
+			}
+		})
+	}
+}
+
+func TestAccountService_ConfirmEmailChange(t *testing.T) {
+	userID := uuid.New()
+	token := "token"
+
+	tests := []struct {
+		name        string
+		change      *models.EmailChange
+		claimErr    error
+		updateErr   error
+		expectedErr error
+	}{
+		{
+			name:   "OK",
+			change: &models.EmailChange{UserID: userID, NewEmail: "new@email.com", NewEmailCanonical: "new@email.com", ExpiresAt: time.Now().Add(time.Hour)},
+		},
+		{
+			name:        "Unknown token",
+			claimErr:    models.ErrEmailChangeNotFound,
+			expectedErr: models.ErrEmailChangeNotFound,
+		},
+		{
+			name:        "Expired",
+			change:      &models.EmailChange{UserID: userID, NewEmail: "new@email.com", NewEmailCanonical: "new@email.com", ExpiresAt: time.Now().Add(-time.Minute)},
+			expectedErr: models.ErrTokenExpired,
+		},
+		{
+			name:        "Taken meanwhile",
+			change:      &models.EmailChange{UserID: userID, NewEmail: "new@email.com", NewEmailCanonical: "new@email.com", ExpiresAt: time.Now().Add(time.Hour)},
+			updateErr:   models.ErrEmailTaken,
+			expectedErr: models.ErrEmailTaken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mocks.NewAccountRepo(t)
+
+			r.On("ClaimEmailChange", mock.Anything, hashOpaqueToken(token)).Return(tt.change, tt.claimErr)
+			if tt.change != nil && tt.change.ExpiresAt.After(time.Now()) {
+				r.On("UpdateUserEmail", mock.Anything, userID, tt.change.NewEmail, tt.change.NewEmailCanonical).Return(tt.updateErr)
+			}
+
+			s := NewAccountService(r, mocks.NewAccountMailer(t), mocks.NewEmailPolicy(t), mocks.NewPasswordPolicy(t), mocks.NewNotifier(t), mocks.NewTokenManager(t), mocks.NewBruteForceGuard(t), nopTransactor{}, &config.EmailConfig{ChangeTTL: time.Hour})
+
+			err := s.ConfirmEmailChange(context.Background(), token)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Errorf("error = %v, expectedError %v", err, tt.expectedErr)
+			}
+		})
+	}
+}
+
+func TestAccountService_SetPassword(t *testing.T) {
+	userID := uuid.New()
+	user := &models.User{ID: userID, Email: "user@email.com"}
+
+	currentHash, err := password.Hash("current password")
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+
+	weak := &models.PasswordPolicyError{Err: models.ErrPasswordTooShort, Rule: models.PasswordRuleMinLength}
+	locked := &models.RetryError{Err: models.ErrAccountLocked, RetryAfter: time.Minute}
+
+	tests := []struct {
+		name        string
+		hash        string
+		current     string
+		newPassword string
+		guardErr    error
+		validateErr error
+		expectedErr error
+	}{
+		{
+			name:        "First password",
+			newPassword: "correct horse battery",
+		},
+		{
+			name:        "Change password",
+			hash:        currentHash,
+			current:     "current password",
+			newPassword: "correct horse battery",
+		},
+		{
+			name:        "Current password missing",
+			hash:        currentHash,
+			newPassword: "correct horse battery",
+			expectedErr: models.ErrPasswordRequired,
+		},
+		{
+			name:        "Current password wrong",
+			hash:        currentHash,
+			current:     "wrong password",
+			newPassword: "correct horse battery",
+			expectedErr: models.ErrWrongPassword,
+		},
+		{
+			name:        "Too many wrong passwords",
+			hash:        currentHash,
+			current:     "current password",
+			newPassword: "correct horse battery",
+			guardErr:    locked,
+			expectedErr: models.ErrAccountLocked,
+		},
+		{
+			name:        "Policy violated",
+			newPassword: "short",
+			validateErr: weak,
+			expectedErr: models.ErrPasswordTooShort,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mocks.NewAccountRepo(t)
+			p := mocks.NewPasswordPolicy(t)
+			n := mocks.NewNotifier(t)
+			tm := mocks.NewTokenManager(t)
+			guard := mocks.NewBruteForceGuard(t)
+
+			guard.On("Check", mock.Anything, userID, "127.0.0.1").Return(tt.guardErr)
+			if tt.guardErr == nil {
+				r.On("GetUserPasswordHash", mock.Anything, userID).Return(tt.hash, nil)
+			}
+
+			switch {
+			case tt.guardErr != nil, tt.hash == "", tt.current == "":
+			case tt.current == "current password":
+				guard.On("Reset", mock.Anything, userID).Return()
+			default:
+				guard.On("RegisterFailure", mock.Anything, userID, "127.0.0.1").Return()
+			}
+
+			authorized := tt.guardErr == nil && (tt.hash == "" || tt.current == "current password")
+			if authorized {
+				r.On("GetUserByID", mock.Anything, userID).Return(user, nil)
+				p.On("Validate", tt.newPassword, user.Email).Return(tt.validateErr)
+			}
+
+			if authorized && tt.validateErr == nil {
+				r.On("UpdateUserPassword", mock.Anything, userID, mock.MatchedBy(func(hash string) bool {
+					return password.Compare(hash, tt.newPassword) == nil
+				})).Return(nil)
+				tm.On("NewRevokeToken", userID).Return("revoke", nil)
+				n.On("Notify", mock.Anything, mock.MatchedBy(func(n models.Notification) bool {
+					return n.Event == models.NotificationPasswordChange && n.Alert.RevokeToken == "revoke"
+				})).Return(nil)
+			}
+
+			s := NewAccountService(r, mocks.NewAccountMailer(t), mocks.NewEmailPolicy(t), p, n, tm, guard, nopTransactor{}, &config.EmailConfig{})
+
+			err := s.SetPassword(context.Background(), userID, tt.current, tt.newPassword, "127.0.0.1", "test-agent")
+			if !errors.Is(err, tt.expectedErr) {
+				t.Errorf("error = %v, expectedError %v", err, tt.expectedErr)
+			}
+		})
+	}
+}
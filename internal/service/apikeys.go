@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"medods-test-task/internal/models"
+	"medods-test-task/pkg/apikey"
+	"medods-test-task/pkg/logger"
+	"medods-test-task/pkg/utils"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const maxAPIKeyNameLength = 100
+
+//go:generate go run github.com/vektra/mockery/v2@latest --name APIKeyRepo
+type APIKeyRepo interface {
+	GetUserByID(ctx context.Context, userID uuid.UUID) (*models.User, error)
+	CreateAPIKey(ctx context.Context, key *models.APIKey) error
+	ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]models.APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, keyID uuid.UUID, revokedAt time.Time) error
+	TouchAPIKey(ctx context.Context, keyID uuid.UUID, usedAt time.Time, IPAddress string) error
+}
+
+type apiKeyService struct {
+	repo   APIKeyRepo
+	roles  RolePolicy
+	logger logger.Logger
+}
+
+func NewAPIKeyService(repo APIKeyRepo, roles RolePolicy, logger logger.Logger) *apiKeyService {
+	return &apiKeyService{
+		repo:   repo,
+		roles:  roles,
+		logger: logger,
+	}
+}
+
+// CreateAPIKey issues a key to the user with scopes the roles of the user
+// grant. A key created with an access token gets no more roles and scopes
+// than the token carries. The key is returned only once, just its hash is
+// stored.
+func (s *apiKeyService) CreateAPIKey(ctx context.Context, userID uuid.UUID, params models.APIKeyParams) (*models.APIKey, error) {
+	name := strings.TrimSpace(params.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxAPIKeyNameLength {
+		return nil, models.ErrInvalidAPIKeyName
+	}
+
+	now := time.Now()
+
+	if !params.ExpiresAt.IsZero() && !params.ExpiresAt.After(now) {
+		return nil, models.ErrInvalidAPIKeyExpiry
+	}
+
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := userRoles(user.Roles)
+	allowed := s.roles.UserScopes(roles)
+
+	var keyRoles []string
+
+	if params.Limit != nil {
+		keyRoles = intersectScopes(roles, params.Limit.Roles)
+		allowed = intersectScopes(s.roles.UserScopes(keyRoles), params.Limit.Scopes)
+	}
+
+	if len(params.Scopes) == 0 {
+		return nil, models.ErrInvalidAPIKeyScope
+	}
+
+	for _, scope := range params.Scopes {
+		if !slices.Contains(allowed, scope) {
+			return nil, models.ErrInvalidAPIKeyScope
+		}
+	}
+
+	secret, err := apikey.New()
+	if err != nil {
+		return nil, err
+	}
+
+	key := &models.APIKey{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		Name:      name,
+		Prefix:    apikey.Display(secret),
+		KeyHash:   hashOpaqueToken(secret),
+		Scopes:    intersectScopes(params.Scopes, allowed),
+		Roles:     keyRoles,
+		ExpiresAt: params.ExpiresAt,
+		CreatedAt: now,
+		Key:       secret,
+	}
+
+	if err := s.repo.CreateAPIKey(ctx, key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+func (s *apiKeyService) ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]models.APIKey, error) {
+	return s.repo.ListAPIKeys(ctx, userID)
+}
+
+// RevokeAPIKey stops the key of the user from working at once. The key stays
+// listed with the time it was revoked.
+func (s *apiKeyService) RevokeAPIKey(ctx context.Context, userID, keyID uuid.UUID) error {
+	return s.repo.RevokeAPIKey(ctx, userID, keyID, time.Now())
+}
+
+// AuthenticateAPIKey returns claims for a request made with the key, as if
+// it carried an access token of the owner. The roles are the current roles
+// of the owner that the key was created with, and the scopes of the key are
+// limited to those these roles grant, so that taking a role away also
+// narrows the keys created with it.
+func (s *apiKeyService) AuthenticateAPIKey(ctx context.Context, key, IPAddress string) (*utils.Claims, error) {
+	if !apikey.Valid(key) {
+		return nil, models.ErrInvalidAPIKey
+	}
+
+	stored, err := s.repo.GetAPIKeyByHash(ctx, hashOpaqueToken(key))
+	if err != nil {
+		if errors.Is(err, models.ErrAPIKeyNotFound) {
+			return nil, models.ErrInvalidAPIKey
+		}
+
+		return nil, err
+	}
+
+	now := time.Now()
+
+	if !stored.RevokedAt.IsZero() {
+		return nil, models.ErrInvalidAPIKey
+	}
+
+	if !stored.ExpiresAt.IsZero() && now.After(stored.ExpiresAt) {
+		return nil, models.ErrAPIKeyExpired
+	}
+
+	user, err := s.repo.GetUserByID(ctx, stored.UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrUserNotFound) {
+			return nil, models.ErrInvalidAPIKey
+		}
+
+		return nil, err
+	}
+
+	// The last use is informational, failing to record it does not fail the
+	// request.
+	if err := s.repo.TouchAPIKey(ctx, stored.ID, now, IPAddress); err != nil {
+		s.logger.Error(ctx, "failed to record api key use", zap.String("key_id", stored.ID.String()), zap.Error(err))
+	}
+
+	roles := userRoles(user.Roles)
+	if stored.Roles != nil {
+		roles = intersectScopes(roles, stored.Roles)
+	}
+
+	claims := &utils.Claims{
+		UserID:    user.ID,
+		IPAddress: IPAddress,
+		Subject:   utils.APIKeySubject,
+		Roles:     roles,
+		Scope:     strings.Join(intersectScopes(stored.Scopes, s.roles.UserScopes(roles)), " "),
+	}
+	claims.Id = stored.ID.String()
+
+	return claims, nil
+}
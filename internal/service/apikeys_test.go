@@ -0,0 +1,279 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"medods-test-task/internal/models"
+	"medods-test-task/internal/service/mocks"
+	"medods-test-task/pkg/apikey"
+	"medods-test-task/pkg/utils"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAPIKeyService_CreateAPIKey(t *testing.T) {
+	userID := uuid.New()
+
+	valid := models.APIKeyParams{
+		Name:   "backup script",
+		Scopes: []string{"sessions:read"},
+	}
+
+	tests := []struct {
+		name           string
+		modify         func(params *models.APIKeyParams)
+		roles          []string
+		userErr        error
+		expectedScopes []string
+		expectedRoles  []string
+		expectedErr    error
+	}{
+		{
+			name:           "OK",
+			expectedScopes: []string{"sessions:read"},
+		},
+		{
+			name: "Scope of an assigned role",
+			modify: func(params *models.APIKeyParams) {
+				params.Scopes = []string{"sessions:admin", "profile", "sessions:admin"}
+				params.ExpiresAt = time.Now().Add(time.Hour)
+			},
+			roles:          []string{"admin"},
+			expectedScopes: []string{"sessions:admin", "profile"},
+		},
+		{
+			name: "Token of the user role asking for admin scopes",
+			modify: func(params *models.APIKeyParams) {
+				params.Scopes = []string{"sessions:admin"}
+				params.Limit = &models.APIKeyLimit{Roles: []string{models.RoleUser}, Scopes: []string{"profile", "sessions:read"}}
+			},
+			roles:       []string{"admin"},
+			expectedErr: models.ErrInvalidAPIKeyScope,
+		},
+		{
+			name: "Scope beyond the token",
+			modify: func(params *models.APIKeyParams) {
+				params.Limit = &models.APIKeyLimit{Roles: []string{models.RoleUser}, Scopes: []string{"profile"}}
+			},
+			expectedErr: models.ErrInvalidAPIKeyScope,
+		},
+		{
+			name: "Limited to the token",
+			modify: func(params *models.APIKeyParams) {
+				params.Scopes = []string{"profile"}
+				params.Limit = &models.APIKeyLimit{Roles: []string{models.RoleUser}, Scopes: []string{"profile", "sessions:read"}}
+			},
+			roles:          []string{"admin"},
+			expectedScopes: []string{"profile"},
+			expectedRoles:  []string{models.RoleUser},
+		},
+		{
+			name:        "Scope not granted",
+			modify:      func(params *models.APIKeyParams) { params.Scopes = []string{"sessions:admin"} },
+			expectedErr: models.ErrInvalidAPIKeyScope,
+		},
+		{
+			name:        "No scopes",
+			modify:      func(params *models.APIKeyParams) { params.Scopes = nil },
+			expectedErr: models.ErrInvalidAPIKeyScope,
+		},
+		{
+			name:        "Empty name",
+			modify:      func(params *models.APIKeyParams) { params.Name = "  " },
+			expectedErr: models.ErrInvalidAPIKeyName,
+		},
+		{
+			name:        "Long name",
+			modify:      func(params *models.APIKeyParams) { params.Name = strings.Repeat("a", maxAPIKeyNameLength+1) },
+			expectedErr: models.ErrInvalidAPIKeyName,
+		},
+		{
+			name:        "Expiry in the past",
+			modify:      func(params *models.APIKeyParams) { params.ExpiresAt = time.Now().Add(-time.Minute) },
+			expectedErr: models.ErrInvalidAPIKeyExpiry,
+		},
+		{
+			name:        "Unknown user",
+			userErr:     models.ErrUserNotFound,
+			expectedErr: models.ErrUserNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := valid
+			if tt.modify != nil {
+				tt.modify(&params)
+			}
+
+			r := mocks.NewAPIKeyRepo(t)
+			if !errors.Is(tt.expectedErr, models.ErrInvalidAPIKeyName) && !errors.Is(tt.expectedErr, models.ErrInvalidAPIKeyExpiry) {
+				if tt.userErr != nil {
+					r.On("GetUserByID", mock.Anything, userID).Return(nil, tt.userErr)
+				} else {
+					r.On("GetUserByID", mock.Anything, userID).Return(&models.User{ID: userID, Roles: tt.roles}, nil)
+				}
+			}
+
+			var stored *models.APIKey
+			if tt.expectedErr == nil {
+				r.On("CreateAPIKey", mock.Anything, mock.Anything).Run(func(args mock.Arguments) { stored = args.Get(1).(*models.APIKey) }).Return(nil)
+			}
+
+			s := NewAPIKeyService(r, testRoles, nopLogger{})
+
+			key, err := s.CreateAPIKey(context.Background(), userID, params)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("error = %v, expectedError %v", err, tt.expectedErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if !apikey.Valid(key.Key) || !strings.HasPrefix(key.Key, key.Prefix) {
+				t.Errorf("key = %q, prefix = %q, expected a valid key starting with its prefix", key.Key, key.Prefix)
+			}
+
+			if stored.KeyHash != hashOpaqueToken(key.Key) || stored.UserID != userID {
+				t.Errorf("stored key = %+v, expected the hash of the key of the user", stored)
+			}
+
+			if !reflect.DeepEqual(stored.Scopes, tt.expectedScopes) {
+				t.Errorf("scopes = %v, expected %v", stored.Scopes, tt.expectedScopes)
+			}
+
+			if !reflect.DeepEqual(stored.Roles, tt.expectedRoles) {
+				t.Errorf("roles = %v, expected %v", stored.Roles, tt.expectedRoles)
+			}
+		})
+	}
+}
+
+func TestAPIKeyService_AuthenticateAPIKey(t *testing.T) {
+	userID := uuid.New()
+	keyID := uuid.New()
+
+	key, err := apikey.New()
+	if err != nil {
+		t.Fatalf("apikey.New() error = %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		key           string
+		stored        func(key *models.APIKey)
+		storedErr     error
+		touchErr      error
+		roles         []string
+		expectedRoles []string
+		expectedScope string
+		expectedErr   error
+	}{
+		{
+			name:          "OK",
+			key:           key,
+			roles:         []string{"admin"},
+			expectedRoles: []string{models.RoleUser, "admin"},
+			expectedScope: "sessions:admin profile",
+		},
+		{
+			name:          "Created with a token of the user role",
+			key:           key,
+			stored:        func(key *models.APIKey) { key.Roles = []string{models.RoleUser} },
+			roles:         []string{"admin"},
+			expectedRoles: []string{models.RoleUser},
+			expectedScope: "profile",
+		},
+		{
+			name:          "Role taken away",
+			key:           key,
+			expectedRoles: []string{models.RoleUser},
+			expectedScope: "profile",
+		},
+		{
+			name:          "Last use not recorded",
+			key:           key,
+			touchErr:      errors.New("connection reset"),
+			expectedRoles: []string{models.RoleUser},
+			expectedScope: "profile",
+		},
+		{
+			name:        "Invalid checksum",
+			key:         key[:len(key)-1] + "x",
+			expectedErr: models.ErrInvalidAPIKey,
+		},
+		{
+			name:        "Unknown key",
+			key:         key,
+			storedErr:   models.ErrAPIKeyNotFound,
+			expectedErr: models.ErrInvalidAPIKey,
+		},
+		{
+			name:        "Revoked",
+			key:         key,
+			stored:      func(key *models.APIKey) { key.RevokedAt = time.Now().Add(-time.Minute) },
+			expectedErr: models.ErrInvalidAPIKey,
+		},
+		{
+			name:        "Expired",
+			key:         key,
+			stored:      func(key *models.APIKey) { key.ExpiresAt = time.Now().Add(-time.Minute) },
+			expectedErr: models.ErrAPIKeyExpired,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stored := &models.APIKey{
+				ID:      keyID,
+				UserID:  userID,
+				KeyHash: hashOpaqueToken(key),
+				Scopes:  []string{"sessions:admin", "profile"},
+			}
+			if tt.stored != nil {
+				tt.stored(stored)
+			}
+
+			r := mocks.NewAPIKeyRepo(t)
+			if tt.key == key {
+				if tt.storedErr != nil {
+					r.On("GetAPIKeyByHash", mock.Anything, hashOpaqueToken(key)).Return(nil, tt.storedErr)
+				} else {
+					r.On("GetAPIKeyByHash", mock.Anything, hashOpaqueToken(key)).Return(stored, nil)
+				}
+			}
+
+			if tt.expectedErr == nil {
+				r.On("GetUserByID", mock.Anything, userID).Return(&models.User{ID: userID, Roles: tt.roles}, nil)
+				r.On("TouchAPIKey", mock.Anything, keyID, mock.Anything, "127.0.0.1").Return(tt.touchErr)
+			}
+
+			s := NewAPIKeyService(r, testRoles, nopLogger{})
+
+			claims, err := s.AuthenticateAPIKey(context.Background(), tt.key, "127.0.0.1")
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("error = %v, expectedError %v", err, tt.expectedErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if claims.UserID != userID || claims.Id != keyID.String() || claims.Subject != utils.APIKeySubject || !claims.IsAPIKey() {
+				t.Errorf("claims = %+v, expected claims of the api key", claims)
+			}
+
+			if claims.Scope != tt.expectedScope {
+				t.Errorf("scope = %q, expected %q", claims.Scope, tt.expectedScope)
+			}
+
+			if !reflect.DeepEqual(claims.Roles, tt.expectedRoles) {
+				t.Errorf("roles = %v, expected %v", claims.Roles, tt.expectedRoles)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"medods-test-task/internal/models"
+	"medods-test-task/pkg/hashchain"
+	"medods-test-task/pkg/logger"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+//go:generate go run github.com/vektra/mockery/v2@latest --name AuditRepo
+type AuditRepo interface {
+	CreateAuthEvent(ctx context.Context, event *models.AuthEvent) error
+	ListAuthEvents(ctx context.Context, afterID uint, limit int) ([]models.AuthEvent, error)
+	GetLastAuthEventBefore(ctx context.Context, before time.Time) (*models.AuthEvent, error)
+	ListUncheckpointedDays(ctx context.Context, before time.Time) ([]time.Time, error)
+	CreateAuditCheckpoint(ctx context.Context, checkpoint *models.AuditCheckpoint) error
+	ListAuditCheckpoints(ctx context.Context) ([]models.AuditCheckpoint, error)
+}
+
+const verifyBatchSize = 1000
+
+type auditService struct {
+	repo   AuditRepo
+	logger logger.Logger
+	secret []byte
+}
+
+func NewAuditService(repo AuditRepo, logger logger.Logger, secret string) *auditService {
+	return &auditService{
+		repo:   repo,
+		logger: logger,
+		secret: []byte(secret),
+	}
+}
+
+// Record stores the event. Audit is best effort: a failed write is logged and
+// never interrupts the authentication flow.
+func (s *auditService) Record(ctx context.Context, event models.AuthEvent) {
+	if err := s.Write(context.WithoutCancel(ctx), event); err != nil {
+		s.logger.Error(ctx, "failed to record auth event",
+			zap.String("type", event.Type),
+			zap.String("outcome", event.Outcome),
+			zap.Error(err),
+		)
+	}
+}
+
+// Write stores the event and returns the error of a failed write, for events
+// the flow must not go on without.
+func (s *auditService) Write(ctx context.Context, event models.AuthEvent) error {
+	if requestID, ok := ctx.Value(logger.RequestIDKey{}).(string); ok && event.RequestID == "" {
+		event.RequestID = requestID
+	}
+
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	return s.repo.CreateAuthEvent(ctx, &event)
+}
+
+// Checkpoint signs the last chained event of every finished UTC day that has
+// no checkpoint yet.
+func (s *auditService) Checkpoint(ctx context.Context) error {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	days, err := s.repo.ListUncheckpointedDays(ctx, today)
+	if err != nil {
+		return fmt.Errorf("failed to list days without checkpoint: %w", err)
+	}
+
+	for _, day := range days {
+		last, err := s.repo.GetLastAuthEventBefore(ctx, day.AddDate(0, 0, 1))
+		if err != nil {
+			return fmt.Errorf("failed to get last event of %s: %w", day.Format(time.DateOnly), err)
+		}
+
+		checkpoint := &models.AuditCheckpoint{
+			Day:         day,
+			LastEventID: last.ID,
+			Hash:        last.Hash,
+			CreatedAt:   time.Now(),
+		}
+		checkpoint.Signature = hashchain.Sign(s.secret, checkpointParts(checkpoint)...)
+
+		if err := s.repo.CreateAuditCheckpoint(ctx, checkpoint); err != nil {
+			return fmt.Errorf("failed to create checkpoint for %s: %w", day.Format(time.DateOnly), err)
+		}
+
+		s.logger.Info(ctx, "audit checkpoint created", zap.String("day", day.Format(time.DateOnly)), zap.Uint("last_event_id", last.ID))
+	}
+
+	return nil
+}
+
+func (s *auditService) RunCheckpoints(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Checkpoint(ctx); err != nil && ctx.Err() == nil {
+			s.logger.Error(ctx, "failed to checkpoint audit log", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Verify walks the audit chain from the first chained event and reports the
+// first record that was altered or deleted.
+func (s *auditService) Verify(ctx context.Context) (*models.AuditVerification, error) {
+	res := &models.AuditVerification{}
+
+	checkpoints, err := s.repo.ListAuditCheckpoints(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list checkpoints: %w", err)
+	}
+
+	pending := make(map[uint]models.AuditCheckpoint, len(checkpoints))
+
+	for _, checkpoint := range checkpoints {
+		if !hashchain.VerifySignature(s.secret, checkpoint.Signature, checkpointParts(&checkpoint)...) {
+			res.BrokenAt = checkpoint.LastEventID
+			res.Reason = fmt.Sprintf("checkpoint of %s has an invalid signature", checkpoint.Day.Format(time.DateOnly))
+
+			return res, nil
+		}
+
+		pending[checkpoint.LastEventID] = checkpoint
+	}
+
+	var (
+		prevHash string
+		afterID  uint
+		chained  bool
+	)
+
+	for {
+		events, err := s.repo.ListAuthEvents(ctx, afterID, verifyBatchSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list events: %w", err)
+		}
+
+		if len(events) == 0 {
+			break
+		}
+
+		for _, event := range events {
+			afterID = event.ID
+
+			if event.Hash == "" && !chained {
+				continue
+			}
+			chained = true
+
+			switch {
+			case event.PrevHash != prevHash:
+				res.Reason = "previous hash does not match, a preceding record was altered or deleted"
+			case hashchain.Link(event.PrevHash, event.Canonical()) != event.Hash:
+				res.Reason = "record does not match its hash"
+			}
+
+			if checkpoint, ok := pending[event.ID]; ok && res.Reason == "" {
+				if checkpoint.Hash != event.Hash {
+					res.Reason = fmt.Sprintf("record does not match the checkpoint of %s", checkpoint.Day.Format(time.DateOnly))
+				}
+
+				res.Checkpoints++
+				delete(pending, event.ID)
+			}
+
+			if res.Reason != "" {
+				res.BrokenAt = event.ID
+
+				return res, nil
+			}
+
+			res.Events++
+			prevHash = event.Hash
+		}
+	}
+
+	for id, checkpoint := range pending {
+		if res.Reason == "" || id < res.BrokenAt {
+			res.BrokenAt = id
+			res.Reason = fmt.Sprintf("record of the checkpoint of %s is missing", checkpoint.Day.Format(time.DateOnly))
+		}
+	}
+
+	return res, nil
+}
+
+func checkpointParts(checkpoint *models.AuditCheckpoint) []string {
+	return []string{
+		checkpoint.Day.Format(time.DateOnly),
+		strconv.FormatUint(uint64(checkpoint.LastEventID), 10),
+		checkpoint.Hash,
+	}
+}
+
+func reasonCode(err error) string {
+	switch {
+	case errors.Is(err, models.ErrEmptyUserID), errors.Is(err, models.ErrInvalidUserID):
+		return models.AuthReasonInvalidUserID
+	case errors.Is(err, models.ErrInvalidToken):
+		return models.AuthReasonInvalidToken
+	case errors.Is(err, models.ErrMismatchedHashAndToken):
+		return models.AuthReasonTokenMismatch
+	case errors.Is(err, models.ErrSessionNotFound):
+		return models.AuthReasonSessionNotFound
+	case errors.Is(err, models.ErrTokenExpired):
+		return models.AuthReasonTokenExpired
+	case errors.Is(err, models.ErrInvalidSession):
+		return models.AuthReasonIPMismatch
+	case errors.Is(err, models.ErrAccountLocked):
+		return models.AuthReasonAccountLocked
+	case errors.Is(err, models.ErrTooManyAttempts):
+		return models.AuthReasonTooManyAttempts
+	case errors.Is(err, models.ErrClientMismatch):
+		return models.AuthReasonClientMismatch
+	case errors.Is(err, models.ErrInvalidClient), errors.Is(err, models.ErrUnauthorizedClient):
+		return models.AuthReasonInvalidClient
+	case errors.Is(err, models.ErrImpersonationForbidden):
+		return models.AuthReasonForbidden
+	case errors.Is(err, models.ErrUnknownSubject):
+		return models.AuthReasonInvalidUserID
+	case errors.Is(err, models.ErrScopeNotGranted):
+		return models.AuthReasonInvalidScope
+	default:
+		return models.AuthReasonInternalError
+	}
+}
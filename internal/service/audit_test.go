@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"medods-test-task/internal/models"
+	"medods-test-task/internal/service/mocks"
+	"medods-test-task/pkg/hashchain"
+	"medods-test-task/pkg/logger"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAuditService_Record(t *testing.T) {
+	userID := uuid.New()
+	requestID := "request-id"
+
+	tests := []struct {
+		name    string
+		repoErr error
+	}{
+		{
+			name:    "OK",
+			repoErr: nil,
+		},
+		{
+			name:    "Write failure is swallowed",
+			repoErr: errors.New("connection refused"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mocks.NewAuditRepo(t)
+			r.On("CreateAuthEvent", mock.Anything, mock.MatchedBy(func(e *models.AuthEvent) bool {
+				return e.UserID == userID && e.RequestID == requestID && !e.CreatedAt.IsZero()
+			})).Return(tt.repoErr)
+
+			s := NewAuditService(r, nopLogger{}, "secret")
+
+			ctx := context.WithValue(context.Background(), logger.RequestIDKey{}, requestID)
+
+			s.Record(ctx, models.AuthEvent{
+				Type:    models.AuthEventLogin,
+				Outcome: models.AuthOutcomeSuccess,
+				UserID:  userID,
+			})
+		})
+	}
+}
+
+func TestAuditService_Verify(t *testing.T) {
+	secret := "secret"
+	day := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	chain := func() []models.AuthEvent {
+		var (
+			events   []models.AuthEvent
+			prevHash string
+		)
+
+		for i := 1; i <= 3; i++ {
+			event := models.AuthEvent{
+				ID:        uint(i),
+				Type:      models.AuthEventLogin,
+				Outcome:   models.AuthOutcomeSuccess,
+				UserID:    uuid.New(),
+				IP:        "127.0.0.1",
+				CreatedAt: day.Add(time.Duration(i) * time.Hour),
+				PrevHash:  prevHash,
+			}
+			event.Hash = hashchain.Link(prevHash, event.Canonical())
+			prevHash = event.Hash
+
+			events = append(events, event)
+		}
+
+		return events
+	}
+
+	checkpoint := func(events []models.AuthEvent, secret string) models.AuditCheckpoint {
+		cp := models.AuditCheckpoint{Day: day, LastEventID: events[2].ID, Hash: events[2].Hash}
+		cp.Signature = hashchain.Sign([]byte(secret), checkpointParts(&cp)...)
+
+		return cp
+	}
+
+	tests := []struct {
+		name        string
+		prepare     func() ([]models.AuthEvent, []models.AuditCheckpoint)
+		expectedAt  uint
+		expectBroke bool
+	}{
+		{
+			name: "Intact",
+			prepare: func() ([]models.AuthEvent, []models.AuditCheckpoint) {
+				events := chain()
+
+				return events, []models.AuditCheckpoint{checkpoint(events, secret)}
+			},
+		},
+		{
+			name: "Altered record",
+			prepare: func() ([]models.AuthEvent, []models.AuditCheckpoint) {
+				events := chain()
+				events[1].IP = "10.0.0.1"
+
+				return events, nil
+			},
+			expectBroke: true,
+			expectedAt:  2,
+		},
+		{
+			name: "Deleted record",
+			prepare: func() ([]models.AuthEvent, []models.AuditCheckpoint) {
+				events := chain()
+
+				return []models.AuthEvent{events[0], events[2]}, nil
+			},
+			expectBroke: true,
+			expectedAt:  3,
+		},
+		{
+			name: "Deleted checkpointed record",
+			prepare: func() ([]models.AuthEvent, []models.AuditCheckpoint) {
+				events := chain()
+
+				return events[:2], []models.AuditCheckpoint{checkpoint(events, secret)}
+			},
+			expectBroke: true,
+			expectedAt:  3,
+		},
+		{
+			name: "Forged checkpoint",
+			prepare: func() ([]models.AuthEvent, []models.AuditCheckpoint) {
+				events := chain()
+
+				return events, []models.AuditCheckpoint{checkpoint(events, "other")}
+			},
+			expectBroke: true,
+			expectedAt:  3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, checkpoints := tt.prepare()
+
+			r := mocks.NewAuditRepo(t)
+			r.On("ListAuditCheckpoints", mock.Anything).Return(checkpoints, nil)
+			r.On("ListAuthEvents", mock.Anything, uint(0), verifyBatchSize).Return(events, nil).Maybe()
+			r.On("ListAuthEvents", mock.Anything, events[len(events)-1].ID, verifyBatchSize).Return(nil, nil).Maybe()
+
+			s := NewAuditService(r, nopLogger{}, secret)
+
+			res, err := s.Verify(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if res.Broken() != tt.expectBroke || res.BrokenAt != tt.expectedAt {
+				t.Errorf("broken = %v at %d (%s), expected %v at %d", res.Broken(), res.BrokenAt, res.Reason, tt.expectBroke, tt.expectedAt)
+			}
+		})
+	}
+}
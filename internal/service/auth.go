@@ -2,28 +2,57 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"log"
 	"medods-test-task/internal/models"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-//go:generate go run github.com/vektra/mockery/v2@latest --name EmailService
-type EmailService interface {
-	SendIPWarningEmail(ctx context.Context, email string)
+//go:generate go run github.com/vektra/mockery/v2@latest --name Notifier
+type Notifier interface {
+	Notify(ctx context.Context, n models.Notification) error
+}
+
+type Transactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
 }
 
 //go:generate go run github.com/vektra/mockery/v2@latest --name TokenManager
 type TokenManager interface {
-	NewTokenPair(userID uuid.UUID, IPAddress string) (string, string, error)
+	NewTokenPair(userID uuid.UUID, clientID, IPAddress string, accessTTL time.Duration, roles, scope []string) (string, string, error)
 	ParseRefreshToken(refreshToken string) (uuid.UUID, error)
+	NewRevokeToken(userID uuid.UUID) (string, error)
+	ParseRevokeToken(token string) (uuid.UUID, error)
 	HashToken(password string) (string, error)
 	ValidateToken(token, hashedToken string) error
+	GetAccessTTL() time.Duration
 	GetRefreshTTL() time.Duration
 }
 
+//go:generate go run github.com/vektra/mockery/v2@latest --name BruteForceGuard
+type BruteForceGuard interface {
+	Check(ctx context.Context, userID uuid.UUID, IPAddress string) error
+	RegisterFailure(ctx context.Context, userID uuid.UUID, IPAddress string)
+	Reset(ctx context.Context, userID uuid.UUID)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@latest --name AuditLogger
+type AuditLogger interface {
+	Record(ctx context.Context, event models.AuthEvent)
+	Write(ctx context.Context, event models.AuthEvent) error
+}
+
+//go:generate go run github.com/vektra/mockery/v2@latest --name EventPublisher
+type EventPublisher interface {
+	Publish(ctx context.Context, event models.AuthEvent)
+}
+
 //go:generate go run github.com/vektra/mockery/v2@latest --name AuthRepo
 type AuthRepo interface {
 	CreateSession(ctx context.Context, session *models.RefreshSession) error
@@ -31,87 +60,211 @@ type AuthRepo interface {
 	GetUserByID(ctx context.Context, userID uuid.UUID) (*models.User, error)
 	CreateUser(ctx context.Context, user *models.User) error
 	GetSessionByUserID(ctx context.Context, userID uuid.UUID) (*models.RefreshSession, error)
+	TouchUserDevice(ctx context.Context, userID uuid.UUID, fingerprint string) (bool, error)
+	DeleteEmailChange(ctx context.Context, userID uuid.UUID) error
+	ConsumeRevokeToken(ctx context.Context, userID uuid.UUID, tokenHash string) error
 }
 
 type AuthService struct {
 	authRepo     AuthRepo
 	tokenManager TokenManager
-	emailService EmailService
+	clients      OAuthClients
+	roles        RolePolicy
+	notifier     Notifier
+	guard        BruteForceGuard
+	auditLog     AuditLogger
+	events       EventPublisher
+	transactor   Transactor
 }
 
-func NewAuthService(auth AuthRepo, token TokenManager, email EmailService) *AuthService {
+func NewAuthService(auth AuthRepo, token TokenManager, clients OAuthClients, roles RolePolicy, notifier Notifier, guard BruteForceGuard, audit AuditLogger, events EventPublisher, transactor Transactor) *AuthService {
 	return &AuthService{
 		authRepo:     auth,
 		tokenManager: token,
-		emailService: email,
+		clients:      clients,
+		roles:        roles,
+		notifier:     notifier,
+		guard:        guard,
+		auditLog:     audit,
+		events:       events,
+		transactor:   transactor,
 	}
 }
 
-func (s *AuthService) NewSession(ctx context.Context, userID, IPAddress string) (string, string, error) {
+// NewSession issues a token pair to the user. The session is bound to the
+// client, which is empty for the legacy login. It is granted the requested
+// space separated scope as far as the roles of the user and the client allow,
+// or every allowed scope when none is requested. Sessions of the legacy login
+// get only the scopes of models.RoleUser.
+func (s *AuthService) NewSession(ctx context.Context, clientID, userID, scope, IPAddress, userAgent string) (tokens *models.TokenSet, err error) {
+	var userUUID uuid.UUID
+
+	defer func() {
+		if err != nil {
+			s.recordFailure(ctx, models.AuthEventLogin, userUUID, IPAddress, userAgent, err)
+		}
+	}()
+
 	if userID == "" {
-		return "", "", models.ErrEmptyUserID
+		return nil, models.ErrEmptyUserID
 	}
 
-	userUUID, err := uuid.Parse(userID)
+	userUUID, err = uuid.Parse(userID)
 	if err != nil {
-		return "", "", models.ErrInvalidUserID
+		s.guard.RegisterFailure(ctx, uuid.Nil, IPAddress)
+
+		return nil, models.ErrInvalidUserID
 	}
 
-	access, refresh, err := s.tokenManager.NewTokenPair(userUUID, IPAddress)
+	if err := s.guard.Check(ctx, userUUID, IPAddress); err != nil {
+		return nil, err
+	}
+
+	client, err := s.sessionClient(ctx, clientID)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
-	_, err = s.authRepo.GetUserByID(ctx, userUUID)
+	user, err := s.authRepo.GetUserByID(ctx, userUUID)
 	if err != nil {
 		if !errors.Is(err, models.ErrUserNotFound) {
-			return "", "", err
+			return nil, err
 		}
 
-		err := s.authRepo.CreateUser(ctx, &models.User{
+		user = &models.User{
 			ID: userUUID,
-		})
-		if err != nil {
-			return "", "", err
 		}
+
+		if err := s.authRepo.CreateUser(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+
+	granted := s.allowedScopes(client, user)
+	if requested := strings.Fields(scope); len(requested) != 0 {
+		granted = intersectScopes(requested, granted)
+	}
+
+	accessTTL, refreshTTL := s.tokenTTLs(client)
+
+	access, refresh, err := s.tokenManager.NewTokenPair(userUUID, clientID, IPAddress, accessTTL, sessionRoles(client, user), granted)
+	if err != nil {
+		return nil, err
 	}
 
 	err = s.authRepo.DeleteSessionByUserID(ctx, userUUID)
 	if err != nil && !errors.Is(err, models.ErrSessionNotFound) {
-		return "", "", err
+		return nil, err
+	}
+
+	if err == nil {
+		s.record(ctx, models.AuthEvent{
+			Type:      models.AuthEventRevocation,
+			Outcome:   models.AuthOutcomeSuccess,
+			Reason:    models.AuthReasonReplaced,
+			UserID:    userUUID,
+			IP:        IPAddress,
+			UserAgent: userAgent,
+		})
 	}
 
 	hashedRefresh, err := s.tokenManager.HashToken(refresh)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
-	err = s.authRepo.CreateSession(ctx, &models.RefreshSession{
+	session := &models.RefreshSession{
 		UserID:    userUUID,
+		ClientID:  clientID,
+		Scopes:    granted,
 		Token:     hashedRefresh,
 		IP:        IPAddress,
 		CreatedAt: time.Now(),
-		ExpiresAt: time.Now().Add(s.tokenManager.GetRefreshTTL()),
+		ExpiresAt: time.Now().Add(refreshTTL),
+	}
+
+	// The new device notification is queued together with the session, so it
+	// is not sent for a login that failed.
+	err = s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.authRepo.CreateSession(ctx, session); err != nil {
+			return err
+		}
+
+		newDevice, err := s.authRepo.TouchUserDevice(ctx, userUUID, deviceFingerprint(userAgent))
+		if err != nil || !newDevice {
+			return err
+		}
+
+		return s.warnNewDevice(ctx, user, IPAddress, userAgent)
 	})
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
+	s.record(ctx, models.AuthEvent{
+		Type:      models.AuthEventLogin,
+		Outcome:   models.AuthOutcomeSuccess,
+		UserID:    userUUID,
+		SessionID: session.ID,
+		IP:        IPAddress,
+		UserAgent: userAgent,
+	})
+
 	log.Print(hashedRefresh)
 	log.Print(refresh)
 
-	return access, refresh, err
+	return &models.TokenSet{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		AccessTTL:    accessTTL,
+		RefreshTTL:   refreshTTL,
+		Scope:        granted,
+	}, nil
+}
+
+// RefreshToken rotates the token pair of a session. Sessions created by an
+// oauth client can be refreshed only by the same client, which has to
+// authenticate with its secret when it is confidential.
+func (s *AuthService) RefreshToken(ctx context.Context, creds models.ClientCredentials, refreshToken, IPAddress, userAgent string) (*models.TokenSet, error) {
+	return s.refresh(ctx, creds, true, refreshToken, "", IPAddress, userAgent)
+}
+
+// RefreshClientSession rotates the token pair of a session of a client that
+// the caller has already authenticated. A requested scope narrows the new
+// access token and must not exceed the scope of the session.
+func (s *AuthService) RefreshClientSession(ctx context.Context, clientID, refreshToken, scope, IPAddress, userAgent string) (*models.TokenSet, error) {
+	return s.refresh(ctx, models.ClientCredentials{ID: clientID}, false, refreshToken, scope, IPAddress, userAgent)
 }
 
-func (s *AuthService) RefreshToken(ctx context.Context, refreshToken, IPAddress string) (string, string, error) {
-	userID, err := s.tokenManager.ParseRefreshToken(refreshToken)
+// refresh rotates the session. The scopes of the session are checked again
+// against the current roles of the user and the scopes of the client.
+func (s *AuthService) refresh(ctx context.Context, creds models.ClientCredentials, verifySecret bool, refreshToken, scope, IPAddress, userAgent string) (tokens *models.TokenSet, err error) {
+	var userID uuid.UUID
+
+	defer func() {
+		if err != nil {
+			s.recordFailure(ctx, models.AuthEventRefresh, userID, IPAddress, userAgent, err)
+		}
+	}()
+
+	userID, err = s.tokenManager.ParseRefreshToken(refreshToken)
 	if err != nil {
-		return "", "", err
+		s.guard.RegisterFailure(ctx, uuid.Nil, IPAddress)
+
+		return nil, err
+	}
+
+	if err := s.guard.Check(ctx, userID, IPAddress); err != nil {
+		return nil, err
 	}
 
 	session, err := s.authRepo.GetSessionByUserID(ctx, userID)
 	if err != nil {
-		return "", "", err
+		if errors.Is(err, models.ErrSessionNotFound) {
+			s.guard.RegisterFailure(ctx, userID, IPAddress)
+		}
+
+		return nil, err
 	}
 
 	log.Print(session.Token)
@@ -119,49 +272,329 @@ func (s *AuthService) RefreshToken(ctx context.Context, refreshToken, IPAddress
 
 	err = s.tokenManager.ValidateToken(refreshToken, session.Token)
 	if err != nil {
-		return "", "", err
+		if errors.Is(err, models.ErrMismatchedHashAndToken) {
+			s.guard.RegisterFailure(ctx, userID, IPAddress)
+		}
+
+		return nil, err
 	}
 
-	err = s.authRepo.DeleteSessionByUserID(ctx, session.UserID)
+	if session.ClientID != creds.ID {
+		s.guard.RegisterFailure(ctx, userID, IPAddress)
+
+		return nil, models.ErrClientMismatch
+	}
+
+	client, err := s.sessionClient(ctx, session.ClientID)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
-	if session.ExpiresAt.Before(time.Now()) {
-		return "", "", models.ErrTokenExpired
+	if client != nil {
+		if verifySecret && !verifyClientSecret(client, creds.Secret) {
+			s.guard.RegisterFailure(ctx, userID, IPAddress)
+
+			return nil, models.ErrInvalidClient
+		}
+
+		if !client.AllowsGrant(models.GrantTypeRefreshToken) {
+			return nil, models.ErrUnauthorizedClient
+		}
 	}
 
-	if session.IP != IPAddress {
-		user, err := s.authRepo.GetUserByID(ctx, session.UserID)
-		if err != nil {
-			return "", "", err
+	requested := strings.Fields(scope)
+	for _, item := range requested {
+		if !slices.Contains(session.Scopes, item) {
+			return nil, models.ErrScopeNotGranted
 		}
+	}
 
-		go s.emailService.SendIPWarningEmail(ctx, user.Email)
+	expired := session.ExpiresAt.Before(time.Now())
+	ipMismatch := !expired && session.IP != IPAddress
 
-		return "", "", models.ErrInvalidSession
+	// The warning is queued in the same transaction as the revocation, so it
+	// is neither lost nor sent for a session that was kept.
+	err = s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.authRepo.DeleteSessionByUserID(ctx, session.UserID); err != nil {
+			return err
+		}
+
+		if !ipMismatch {
+			return nil
+		}
+
+		return s.warnIPChange(ctx, session, IPAddress, userAgent)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	revocation := models.AuthEvent{
+		Type:      models.AuthEventRevocation,
+		Outcome:   models.AuthOutcomeSuccess,
+		Reason:    models.AuthReasonRotated,
+		UserID:    session.UserID,
+		SessionID: session.ID,
+		IP:        IPAddress,
+		UserAgent: userAgent,
 	}
 
-	accessToken, newRefreshToken, err := s.tokenManager.NewTokenPair(session.UserID, IPAddress)
+	if expired {
+		revocation.Reason = models.AuthReasonTokenExpired
+		s.record(ctx, revocation)
+
+		return nil, models.ErrTokenExpired
+	}
+
+	if ipMismatch {
+		revocation.Reason = models.AuthReasonIPMismatch
+		s.record(ctx, revocation)
+
+		s.record(ctx, models.AuthEvent{
+			Type:      models.AuthEventIPMismatch,
+			Outcome:   models.AuthOutcomeFailure,
+			Reason:    models.AuthReasonIPMismatch,
+			UserID:    session.UserID,
+			SessionID: session.ID,
+			IP:        IPAddress,
+			UserAgent: userAgent,
+		})
+
+		return nil, models.ErrInvalidSession
+	}
+
+	s.record(ctx, revocation)
+
+	user, err := s.authRepo.GetUserByID(ctx, session.UserID)
 	if err != nil {
-		return "", "", err
+		return nil, err
+	}
+
+	sessionScopes := intersectScopes(session.Scopes, s.allowedScopes(client, user))
+
+	tokenScopes := sessionScopes
+	if len(requested) != 0 {
+		tokenScopes = intersectScopes(requested, sessionScopes)
+	}
+
+	accessTTL, refreshTTL := s.tokenTTLs(client)
+
+	accessToken, newRefreshToken, err := s.tokenManager.NewTokenPair(session.UserID, session.ClientID, IPAddress, accessTTL, sessionRoles(client, user), tokenScopes)
+	if err != nil {
+		return nil, err
 	}
 
 	hashedRefresh, err := s.tokenManager.HashToken(newRefreshToken)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
-	err = s.authRepo.CreateSession(ctx, &models.RefreshSession{
+	newSession := &models.RefreshSession{
 		UserID:    session.UserID,
+		ClientID:  session.ClientID,
+		Scopes:    sessionScopes,
 		Token:     hashedRefresh,
 		IP:        IPAddress,
 		CreatedAt: time.Now(),
-		ExpiresAt: time.Now().Add(s.tokenManager.GetRefreshTTL()),
+		ExpiresAt: time.Now().Add(refreshTTL),
+	}
+
+	err = s.authRepo.CreateSession(ctx, newSession)
+	if err != nil {
+		return nil, err
+	}
+
+	s.record(ctx, models.AuthEvent{
+		Type:      models.AuthEventRotation,
+		Outcome:   models.AuthOutcomeSuccess,
+		UserID:    session.UserID,
+		SessionID: newSession.ID,
+		IP:        IPAddress,
+		UserAgent: userAgent,
 	})
+
+	s.guard.Reset(ctx, session.UserID)
+
+	return &models.TokenSet{
+		AccessToken:  accessToken,
+		RefreshToken: newRefreshToken,
+		AccessTTL:    accessTTL,
+		RefreshTTL:   refreshTTL,
+		Scope:        tokenScopes,
+	}, nil
+}
+
+func (s *AuthService) warnIPChange(ctx context.Context, session *models.RefreshSession, IPAddress, userAgent string) error {
+	user, err := s.authRepo.GetUserByID(ctx, session.UserID)
 	if err != nil {
-		return "", "", err
+		return err
 	}
 
-	return accessToken, newRefreshToken, nil
+	revokeToken, err := s.tokenManager.NewRevokeToken(session.UserID)
+	if err != nil {
+		return err
+	}
+
+	return s.notifier.Notify(ctx, models.Notification{
+		Event: models.NotificationIPChange,
+		User:  user,
+		Alert: models.SecurityAlert{
+			OldIP:       session.IP,
+			NewIP:       IPAddress,
+			UserAgent:   userAgent,
+			OccurredAt:  time.Now(),
+			RevokeToken: revokeToken,
+		},
+	})
+}
+
+func (s *AuthService) warnNewDevice(ctx context.Context, user *models.User, IPAddress, userAgent string) error {
+	revokeToken, err := s.tokenManager.NewRevokeToken(user.ID)
+	if err != nil {
+		return err
+	}
+
+	return s.notifier.Notify(ctx, models.Notification{
+		Event: models.NotificationNewDevice,
+		User:  user,
+		Alert: models.SecurityAlert{
+			NewIP:       IPAddress,
+			UserAgent:   userAgent,
+			OccurredAt:  time.Now(),
+			RevokeToken: revokeToken,
+		},
+	})
+}
+
+// RevokeSessions handles the "This wasn't me" link from a security alert. It
+// ends every session of the user so that they have to sign in again and
+// cancels a pending email change. The link works only once.
+func (s *AuthService) RevokeSessions(ctx context.Context, revokeToken, IPAddress, userAgent string) error {
+	userID, err := s.tokenManager.ParseRevokeToken(revokeToken)
+	if err != nil {
+		return err
+	}
+
+	err = s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.authRepo.ConsumeRevokeToken(ctx, userID, hashOpaqueToken(revokeToken)); err != nil {
+			return err
+		}
+
+		err := s.authRepo.DeleteSessionByUserID(ctx, userID)
+		if err != nil && !errors.Is(err, models.ErrSessionNotFound) {
+			return err
+		}
+
+		if err := s.authRepo.DeleteEmailChange(ctx, userID); err != nil {
+			return err
+		}
+
+		user, err := s.authRepo.GetUserByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		return s.notifier.Notify(ctx, models.Notification{
+			Event: models.NotificationSessionRevoked,
+			User:  user,
+			Alert: models.SecurityAlert{
+				NewIP:      IPAddress,
+				UserAgent:  userAgent,
+				OccurredAt: time.Now(),
+			},
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	s.record(ctx, models.AuthEvent{
+		Type:      models.AuthEventRevocation,
+		Outcome:   models.AuthOutcomeSuccess,
+		Reason:    models.AuthReasonReportedByUser,
+		UserID:    userID,
+		IP:        IPAddress,
+		UserAgent: userAgent,
+	})
+
+	return nil
+}
+
+// deviceFingerprint identifies a device by its user agent.
+func deviceFingerprint(userAgent string) string {
+	sum := sha256.Sum256([]byte(userAgent))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// sessionClient returns the client of a session, nil for sessions from the
+// legacy login.
+func (s *AuthService) sessionClient(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	if clientID == "" {
+		return nil, nil
+	}
+
+	client, err := s.clients.GetClient(ctx, clientID)
+	if errors.Is(err, models.ErrOAuthClientNotFound) {
+		return nil, models.ErrInvalidClient
+	}
+
+	return client, err
+}
+
+// sessionRoles returns the roles embedded in the tokens of a session of the
+// client. The legacy login takes no credentials, so its sessions get only
+// models.RoleUser, elevated roles come only with an authenticated grant.
+func sessionRoles(client *models.OAuthClient, user *models.User) []string {
+	if client == nil {
+		return userRoles(nil)
+	}
+
+	return userRoles(user.Roles)
+}
+
+// allowedScopes returns the scopes the session roles of the user allow that
+// the client may request as well.
+func (s *AuthService) allowedScopes(client *models.OAuthClient, user *models.User) []string {
+	allowed := s.roles.UserScopes(sessionRoles(client, user))
+	if client != nil {
+		allowed = intersectScopes(allowed, client.Scopes)
+	}
+
+	return allowed
+}
+
+// tokenTTLs applies the token ttls of the client over the defaults.
+func (s *AuthService) tokenTTLs(client *models.OAuthClient) (time.Duration, time.Duration) {
+	var accessTTL, refreshTTL time.Duration
+
+	if client != nil {
+		accessTTL, refreshTTL = client.AccessTokenTTL, client.RefreshTokenTTL
+	}
+
+	if accessTTL == 0 {
+		accessTTL = s.tokenManager.GetAccessTTL()
+	}
+
+	if refreshTTL == 0 {
+		refreshTTL = s.tokenManager.GetRefreshTTL()
+	}
+
+	return accessTTL, refreshTTL
+}
+
+func (s *AuthService) record(ctx context.Context, event models.AuthEvent) {
+	s.auditLog.Record(ctx, event)
+	s.events.Publish(ctx, event)
+}
+
+func (s *AuthService) recordFailure(ctx context.Context, eventType string, userID uuid.UUID, IPAddress, userAgent string, err error) {
+	s.record(ctx, models.AuthEvent{
+		Type:      eventType,
+		Outcome:   models.AuthOutcomeFailure,
+		Reason:    reasonCode(err),
+		UserID:    userID,
+		IP:        IPAddress,
+		UserAgent: userAgent,
+	})
 }
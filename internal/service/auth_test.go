@@ -2,9 +2,11 @@ package service
 
 import (
 	"context"
+	"errors"
 	"medods-test-task/internal/models"
 	"medods-test-task/internal/service/mocks"
 	"medods-test-task/pkg/utils"
+	"slices"
 	"testing"
 	"time"
 
@@ -17,17 +19,26 @@ func TestAuthService_RefreshToken(t *testing.T) {
 	userID := uuid.New()
 	ip := "127.0.0.1"
 	email := "test@email.com"
+	errTemplate := errors.New("template error")
 
-	_, refresh, _ := manager.NewTokenPair(userID, ip)
+	_, refresh, _ := manager.NewTokenPair(userID, "", ip, 0, nil, nil)
 	hashed, _ := manager.HashToken(refresh)
+	lockedErr := &models.RetryError{Err: models.ErrAccountLocked, RetryAfter: time.Minute}
 
 	type (
-		repoMockBehavior  func(r *mocks.AuthRepo, userID uuid.UUID, ip, hashedToken, newHashedToken string)
-		tokenMockBehavior func(t *mocks.TokenManager, userID uuid.UUID, token, hashedToken, newAccessToken, newRefreshToken, newHashedToken string)
-		args              struct {
+		repoMockBehavior     func(r *mocks.AuthRepo, userID uuid.UUID, ip, hashedToken, newHashedToken string)
+		tokenMockBehavior    func(t *mocks.TokenManager, userID uuid.UUID, token, hashedToken, newAccessToken, newRefreshToken, newHashedToken string)
+		guardMockBehavior    func(g *mocks.BruteForceGuard, userID uuid.UUID, ip string)
+		notifierMockBehavior func(n *mocks.Notifier)
+		clientsMockBehavior  func(c *mocks.OAuthClients)
+		args                 struct {
 			ctx          context.Context
+			creds        models.ClientCredentials
 			refreshToken string
 			IPAddress    string
+			// The client was authenticated by the caller, as on the oauth token endpoint
+			authenticated bool
+			scope         string
 		}
 	)
 
@@ -41,6 +52,9 @@ func TestAuthService_RefreshToken(t *testing.T) {
 		newHashedToken  string
 		repoMock        repoMockBehavior
 		tokenMock       tokenMockBehavior
+		guardMock       guardMockBehavior
+		notifierMock    notifierMockBehavior
+		clientsMock     clientsMockBehavior
 		expectedErr     error
 	}{
 		{
@@ -64,15 +78,21 @@ func TestAuthService_RefreshToken(t *testing.T) {
 					ExpiresAt: time.Now().Add(720 * time.Hour),
 				}, nil)
 				r.On("DeleteSessionByUserID", mock.Anything, userID).Return(nil)
+				r.On("GetUserByID", mock.Anything, userID).Return(&models.User{ID: userID}, nil)
 				r.On("CreateSession", mock.Anything, mock.Anything).Return(nil)
 			},
 			tokenMock: func(m *mocks.TokenManager, userID uuid.UUID, token, hashedToken, newAccessToken, newRefreshToken, newHashedToken string) {
 				m.On("ParseRefreshToken", token).Return(userID, nil)
 				m.On("ValidateToken", token, hashedToken).Return(nil)
-				m.On("NewTokenPair", userID, mock.Anything).Return(newAccessToken, newRefreshToken, nil)
+				m.On("NewTokenPair", userID, "", mock.Anything, 15*time.Minute, []string{models.RoleUser}, []string{}).Return(newAccessToken, newRefreshToken, nil)
 				m.On("HashToken", newRefreshToken).Return(newHashedToken, nil)
+				m.On("GetAccessTTL").Return(15 * time.Minute)
 				m.On("GetRefreshTTL").Return(time.Duration(720 * time.Hour))
 			},
+			guardMock: func(g *mocks.BruteForceGuard, userID uuid.UUID, ip string) {
+				g.On("Check", mock.Anything, userID, ip).Return(nil)
+				g.On("Reset", mock.Anything, userID)
+			},
 		},
 		{
 			name:            "Invalid Token",
@@ -93,6 +113,9 @@ func TestAuthService_RefreshToken(t *testing.T) {
 			tokenMock: func(m *mocks.TokenManager, userID uuid.UUID, token, hashedToken, newAccessToken, newRefreshToken, newHashedToken string) {
 				m.On("ParseRefreshToken", token).Return(uuid.UUID{}, models.ErrInvalidToken)
 			},
+			guardMock: func(g *mocks.BruteForceGuard, userID uuid.UUID, ip string) {
+				g.On("RegisterFailure", mock.Anything, uuid.Nil, ip)
+			},
 		},
 		{
 			name:            "Token expired",
@@ -120,6 +143,9 @@ func TestAuthService_RefreshToken(t *testing.T) {
 				m.On("ParseRefreshToken", token).Return(userID, nil)
 				m.On("ValidateToken", token, hashedToken).Return(nil)
 			},
+			guardMock: func(g *mocks.BruteForceGuard, userID uuid.UUID, ip string) {
+				g.On("Check", mock.Anything, userID, ip).Return(nil)
+			},
 		},
 		{
 			name:            "Wrong IP",
@@ -147,32 +173,725 @@ func TestAuthService_RefreshToken(t *testing.T) {
 					Email: email,
 				}, nil)
 			},
+			notifierMock: func(n *mocks.Notifier) {
+				n.On("Notify", mock.Anything, mock.MatchedBy(func(n models.Notification) bool {
+					return n.Event == models.NotificationIPChange && n.User.Email == email &&
+						n.Alert.OldIP == "127.1.0.1" && n.Alert.NewIP == ip && n.Alert.RevokeToken == "revoke"
+				})).Return(nil)
+			},
+			tokenMock: func(m *mocks.TokenManager, userID uuid.UUID, token, hashedToken, newAccessToken, newRefreshToken, newHashedToken string) {
+				m.On("ParseRefreshToken", token).Return(userID, nil)
+				m.On("ValidateToken", token, hashedToken).Return(nil)
+				m.On("NewRevokeToken", userID).Return("revoke", nil)
+			},
+			guardMock: func(g *mocks.BruteForceGuard, userID uuid.UUID, ip string) {
+				g.On("Check", mock.Anything, userID, ip).Return(nil)
+			},
+		},
+		{
+			name:            "Wrong IP, warning failed",
+			userID:          userID,
+			newAccessToken:  "access",
+			newRefreshToken: "refresh",
+			hashedToken:     "hashedinvalid",
+			newHashedToken:  "hashed",
+			expectedErr:     errTemplate,
+			args: args{
+				ctx:          context.Background(),
+				refreshToken: "inValId-Tokn",
+				IPAddress:    ip,
+			},
+			repoMock: func(r *mocks.AuthRepo, userID uuid.UUID, ip, hashedToken, newHashedToken string) {
+				r.On("GetSessionByUserID", mock.Anything, userID).Return(&models.RefreshSession{
+					UserID:    userID,
+					IP:        "127.1.0.1",
+					Token:     hashedToken,
+					ExpiresAt: time.Now().Add(720 * time.Hour),
+				}, nil)
+				r.On("DeleteSessionByUserID", mock.Anything, userID).Return(nil).Once()
+				r.On("GetUserByID", mock.Anything, userID).Return(&models.User{ID: userID, Email: email}, nil)
+			},
+			notifierMock: func(n *mocks.Notifier) {
+				n.On("Notify", mock.Anything, mock.Anything).Return(errTemplate)
+			},
+			tokenMock: func(m *mocks.TokenManager, userID uuid.UUID, token, hashedToken, newAccessToken, newRefreshToken, newHashedToken string) {
+				m.On("ParseRefreshToken", token).Return(userID, nil)
+				m.On("ValidateToken", token, hashedToken).Return(nil)
+				m.On("NewRevokeToken", userID).Return("revoke", nil)
+			},
+			guardMock: func(g *mocks.BruteForceGuard, userID uuid.UUID, ip string) {
+				g.On("Check", mock.Anything, userID, ip).Return(nil)
+			},
+		},
+		{
+			name:            "Mismatched token",
+			userID:          userID,
+			newAccessToken:  "access",
+			newRefreshToken: "refresh",
+			hashedToken:     "hashedother",
+			newHashedToken:  "hashed",
+			expectedErr:     models.ErrMismatchedHashAndToken,
+			args: args{
+				ctx:          context.Background(),
+				refreshToken: refresh,
+				IPAddress:    ip,
+			},
+			repoMock: func(r *mocks.AuthRepo, userID uuid.UUID, ip, hashedToken, newHashedToken string) {
+				r.On("GetSessionByUserID", mock.Anything, userID).Return(&models.RefreshSession{
+					UserID:    userID,
+					IP:        ip,
+					Token:     hashedToken,
+					ExpiresAt: time.Now().Add(720 * time.Hour),
+				}, nil)
+			},
+			tokenMock: func(m *mocks.TokenManager, userID uuid.UUID, token, hashedToken, newAccessToken, newRefreshToken, newHashedToken string) {
+				m.On("ParseRefreshToken", token).Return(userID, nil)
+				m.On("ValidateToken", token, hashedToken).Return(models.ErrMismatchedHashAndToken)
+			},
+			guardMock: func(g *mocks.BruteForceGuard, userID uuid.UUID, ip string) {
+				g.On("Check", mock.Anything, userID, ip).Return(nil)
+				g.On("RegisterFailure", mock.Anything, userID, ip)
+			},
+		},
+		{
+			name:            "Other client",
+			userID:          userID,
+			newAccessToken:  "access",
+			newRefreshToken: "refresh",
+			hashedToken:     hashed,
+			newHashedToken:  "hashed",
+			expectedErr:     models.ErrClientMismatch,
+			args: args{
+				ctx:          context.Background(),
+				creds:        models.ClientCredentials{ID: "mobile"},
+				refreshToken: refresh,
+				IPAddress:    ip,
+			},
+			repoMock: func(r *mocks.AuthRepo, userID uuid.UUID, ip, hashedToken, newHashedToken string) {
+				r.On("GetSessionByUserID", mock.Anything, userID).Return(&models.RefreshSession{
+					UserID:    userID,
+					ClientID:  "spa",
+					IP:        ip,
+					Token:     hashedToken,
+					ExpiresAt: time.Now().Add(720 * time.Hour),
+				}, nil)
+			},
+			tokenMock: func(m *mocks.TokenManager, userID uuid.UUID, token, hashedToken, newAccessToken, newRefreshToken, newHashedToken string) {
+				m.On("ParseRefreshToken", token).Return(userID, nil)
+				m.On("ValidateToken", token, hashedToken).Return(nil)
+			},
+			guardMock: func(g *mocks.BruteForceGuard, userID uuid.UUID, ip string) {
+				g.On("Check", mock.Anything, userID, ip).Return(nil)
+				g.On("RegisterFailure", mock.Anything, userID, ip)
+			},
+		},
+		{
+			name:            "Wrong client secret",
+			userID:          userID,
+			newAccessToken:  "access",
+			newRefreshToken: "refresh",
+			hashedToken:     hashed,
+			newHashedToken:  "hashed",
+			expectedErr:     models.ErrInvalidClient,
+			args: args{
+				ctx:          context.Background(),
+				creds:        models.ClientCredentials{ID: "backend", Secret: "wrong"},
+				refreshToken: refresh,
+				IPAddress:    ip,
+			},
+			repoMock: func(r *mocks.AuthRepo, userID uuid.UUID, ip, hashedToken, newHashedToken string) {
+				r.On("GetSessionByUserID", mock.Anything, userID).Return(&models.RefreshSession{
+					UserID:    userID,
+					ClientID:  "backend",
+					IP:        ip,
+					Token:     hashedToken,
+					ExpiresAt: time.Now().Add(720 * time.Hour),
+				}, nil)
+			},
+			tokenMock: func(m *mocks.TokenManager, userID uuid.UUID, token, hashedToken, newAccessToken, newRefreshToken, newHashedToken string) {
+				m.On("ParseRefreshToken", token).Return(userID, nil)
+				m.On("ValidateToken", token, hashedToken).Return(nil)
+			},
+			guardMock: func(g *mocks.BruteForceGuard, userID uuid.UUID, ip string) {
+				g.On("Check", mock.Anything, userID, ip).Return(nil)
+				g.On("RegisterFailure", mock.Anything, userID, ip)
+			},
+			clientsMock: func(c *mocks.OAuthClients) {
+				c.On("GetClient", mock.Anything, "backend").Return(&models.OAuthClient{
+					ID:         "backend",
+					SecretHash: hashOpaqueToken("cs_secret"),
+					GrantTypes: []string{models.GrantTypeAuthorizationCode, models.GrantTypeRefreshToken},
+				}, nil)
+			},
+		},
+		{
+			name:            "Client token ttls",
+			userID:          userID,
+			newAccessToken:  "access",
+			newRefreshToken: "refresh",
+			hashedToken:     hashed,
+			newHashedToken:  "hashed",
+			args: args{
+				ctx:          context.Background(),
+				creds:        models.ClientCredentials{ID: "backend", Secret: "cs_secret"},
+				refreshToken: refresh,
+				IPAddress:    ip,
+			},
+			repoMock: func(r *mocks.AuthRepo, userID uuid.UUID, ip, hashedToken, newHashedToken string) {
+				r.On("GetSessionByUserID", mock.Anything, userID).Return(&models.RefreshSession{
+					UserID:    userID,
+					ClientID:  "backend",
+					IP:        ip,
+					Token:     hashedToken,
+					ExpiresAt: time.Now().Add(720 * time.Hour),
+				}, nil)
+				r.On("DeleteSessionByUserID", mock.Anything, userID).Return(nil)
+				r.On("GetUserByID", mock.Anything, userID).Return(&models.User{ID: userID}, nil)
+				r.On("CreateSession", mock.Anything, mock.MatchedBy(func(s *models.RefreshSession) bool {
+					return s.ClientID == "backend" && time.Until(s.ExpiresAt) <= time.Hour
+				})).Return(nil)
+			},
+			tokenMock: func(m *mocks.TokenManager, userID uuid.UUID, token, hashedToken, newAccessToken, newRefreshToken, newHashedToken string) {
+				m.On("ParseRefreshToken", token).Return(userID, nil)
+				m.On("ValidateToken", token, hashedToken).Return(nil)
+				m.On("NewTokenPair", userID, "backend", mock.Anything, 5*time.Minute, []string{models.RoleUser}, []string{}).Return(newAccessToken, newRefreshToken, nil)
+				m.On("HashToken", newRefreshToken).Return(newHashedToken, nil)
+			},
+			guardMock: func(g *mocks.BruteForceGuard, userID uuid.UUID, ip string) {
+				g.On("Check", mock.Anything, userID, ip).Return(nil)
+				g.On("Reset", mock.Anything, userID)
+			},
+			clientsMock: func(c *mocks.OAuthClients) {
+				c.On("GetClient", mock.Anything, "backend").Return(&models.OAuthClient{
+					ID:              "backend",
+					SecretHash:      hashOpaqueToken("cs_secret"),
+					GrantTypes:      []string{models.GrantTypeAuthorizationCode, models.GrantTypeRefreshToken},
+					AccessTokenTTL:  5 * time.Minute,
+					RefreshTokenTTL: time.Hour,
+				}, nil)
+			},
+		},
+		{
+			name:            "Client authenticated by caller",
+			userID:          userID,
+			newAccessToken:  "access",
+			newRefreshToken: "refresh",
+			hashedToken:     hashed,
+			newHashedToken:  "hashed",
+			args: args{
+				ctx:           context.Background(),
+				creds:         models.ClientCredentials{ID: "signer"},
+				refreshToken:  refresh,
+				IPAddress:     ip,
+				authenticated: true,
+			},
+			repoMock: func(r *mocks.AuthRepo, userID uuid.UUID, ip, hashedToken, newHashedToken string) {
+				r.On("GetSessionByUserID", mock.Anything, userID).Return(&models.RefreshSession{
+					UserID:    userID,
+					ClientID:  "signer",
+					IP:        ip,
+					Token:     hashedToken,
+					ExpiresAt: time.Now().Add(720 * time.Hour),
+				}, nil)
+				r.On("DeleteSessionByUserID", mock.Anything, userID).Return(nil)
+				r.On("GetUserByID", mock.Anything, userID).Return(&models.User{ID: userID}, nil)
+				r.On("CreateSession", mock.Anything, mock.Anything).Return(nil)
+			},
+			tokenMock: func(m *mocks.TokenManager, userID uuid.UUID, token, hashedToken, newAccessToken, newRefreshToken, newHashedToken string) {
+				m.On("ParseRefreshToken", token).Return(userID, nil)
+				m.On("ValidateToken", token, hashedToken).Return(nil)
+				m.On("NewTokenPair", userID, "signer", mock.Anything, 15*time.Minute, []string{models.RoleUser}, []string{}).Return(newAccessToken, newRefreshToken, nil)
+				m.On("HashToken", newRefreshToken).Return(newHashedToken, nil)
+				m.On("GetAccessTTL").Return(15 * time.Minute)
+				m.On("GetRefreshTTL").Return(time.Duration(720 * time.Hour))
+			},
+			guardMock: func(g *mocks.BruteForceGuard, userID uuid.UUID, ip string) {
+				g.On("Check", mock.Anything, userID, ip).Return(nil)
+				g.On("Reset", mock.Anything, userID)
+			},
+			clientsMock: func(c *mocks.OAuthClients) {
+				c.On("GetClient", mock.Anything, "signer").Return(&models.OAuthClient{
+					ID:         "signer",
+					PublicKey:  "-----BEGIN PUBLIC KEY-----",
+					GrantTypes: []string{models.GrantTypeAuthorizationCode, models.GrantTypeRefreshToken},
+				}, nil)
+			},
+		},
+		{
+			name:            "Narrowed scope",
+			userID:          userID,
+			newAccessToken:  "access",
+			newRefreshToken: "refresh",
+			hashedToken:     hashed,
+			newHashedToken:  "hashed",
+			args: args{
+				ctx:           context.Background(),
+				creds:         models.ClientCredentials{ID: "signer"},
+				refreshToken:  refresh,
+				scope:         "sessions:admin",
+				IPAddress:     ip,
+				authenticated: true,
+			},
+			repoMock: func(r *mocks.AuthRepo, userID uuid.UUID, ip, hashedToken, newHashedToken string) {
+				r.On("GetSessionByUserID", mock.Anything, userID).Return(&models.RefreshSession{
+					UserID:    userID,
+					ClientID:  "signer",
+					Scopes:    []string{"profile", "sessions:admin"},
+					IP:        ip,
+					Token:     hashedToken,
+					ExpiresAt: time.Now().Add(720 * time.Hour),
+				}, nil)
+				r.On("DeleteSessionByUserID", mock.Anything, userID).Return(nil)
+				r.On("GetUserByID", mock.Anything, userID).Return(&models.User{ID: userID, Roles: []string{"admin"}}, nil)
+				r.On("CreateSession", mock.Anything, mock.MatchedBy(func(s *models.RefreshSession) bool {
+					return slices.Equal(s.Scopes, []string{"profile", "sessions:admin"})
+				})).Return(nil)
+			},
+			tokenMock: func(m *mocks.TokenManager, userID uuid.UUID, token, hashedToken, newAccessToken, newRefreshToken, newHashedToken string) {
+				m.On("ParseRefreshToken", token).Return(userID, nil)
+				m.On("ValidateToken", token, hashedToken).Return(nil)
+				m.On("NewTokenPair", userID, "signer", mock.Anything, 15*time.Minute, []string{models.RoleUser, "admin"}, []string{"sessions:admin"}).Return(newAccessToken, newRefreshToken, nil)
+				m.On("HashToken", newRefreshToken).Return(newHashedToken, nil)
+				m.On("GetAccessTTL").Return(15 * time.Minute)
+				m.On("GetRefreshTTL").Return(time.Duration(720 * time.Hour))
+			},
+			guardMock: func(g *mocks.BruteForceGuard, userID uuid.UUID, ip string) {
+				g.On("Check", mock.Anything, userID, ip).Return(nil)
+				g.On("Reset", mock.Anything, userID)
+			},
+			clientsMock: func(c *mocks.OAuthClients) {
+				c.On("GetClient", mock.Anything, "signer").Return(&models.OAuthClient{
+					ID:         "signer",
+					PublicKey:  "-----BEGIN PUBLIC KEY-----",
+					GrantTypes: []string{models.GrantTypeAuthorizationCode, models.GrantTypeRefreshToken},
+					Scopes:     []string{"profile", "sessions:read", "sessions:admin"},
+				}, nil)
+			},
+		},
+		{
+			name:            "Role removed",
+			userID:          userID,
+			newAccessToken:  "access",
+			newRefreshToken: "refresh",
+			hashedToken:     hashed,
+			newHashedToken:  "hashed",
+			args: args{
+				ctx:           context.Background(),
+				creds:         models.ClientCredentials{ID: "signer"},
+				refreshToken:  refresh,
+				scope:         "",
+				IPAddress:     ip,
+				authenticated: true,
+			},
+			repoMock: func(r *mocks.AuthRepo, userID uuid.UUID, ip, hashedToken, newHashedToken string) {
+				r.On("GetSessionByUserID", mock.Anything, userID).Return(&models.RefreshSession{
+					UserID:    userID,
+					ClientID:  "signer",
+					Scopes:    []string{"profile", "sessions:admin"},
+					IP:        ip,
+					Token:     hashedToken,
+					ExpiresAt: time.Now().Add(720 * time.Hour),
+				}, nil)
+				r.On("DeleteSessionByUserID", mock.Anything, userID).Return(nil)
+				r.On("GetUserByID", mock.Anything, userID).Return(&models.User{ID: userID, Roles: nil}, nil)
+				r.On("CreateSession", mock.Anything, mock.MatchedBy(func(s *models.RefreshSession) bool {
+					return slices.Equal(s.Scopes, []string{"profile"})
+				})).Return(nil)
+			},
+			tokenMock: func(m *mocks.TokenManager, userID uuid.UUID, token, hashedToken, newAccessToken, newRefreshToken, newHashedToken string) {
+				m.On("ParseRefreshToken", token).Return(userID, nil)
+				m.On("ValidateToken", token, hashedToken).Return(nil)
+				m.On("NewTokenPair", userID, "signer", mock.Anything, 15*time.Minute, []string{models.RoleUser}, []string{"profile"}).Return(newAccessToken, newRefreshToken, nil)
+				m.On("HashToken", newRefreshToken).Return(newHashedToken, nil)
+				m.On("GetAccessTTL").Return(15 * time.Minute)
+				m.On("GetRefreshTTL").Return(time.Duration(720 * time.Hour))
+			},
+			guardMock: func(g *mocks.BruteForceGuard, userID uuid.UUID, ip string) {
+				g.On("Check", mock.Anything, userID, ip).Return(nil)
+				g.On("Reset", mock.Anything, userID)
+			},
+			clientsMock: func(c *mocks.OAuthClients) {
+				c.On("GetClient", mock.Anything, "signer").Return(&models.OAuthClient{
+					ID:         "signer",
+					PublicKey:  "-----BEGIN PUBLIC KEY-----",
+					GrantTypes: []string{models.GrantTypeAuthorizationCode, models.GrantTypeRefreshToken},
+					Scopes:     []string{"profile", "sessions:read", "sessions:admin"},
+				}, nil)
+			},
+		},
+		{
+			name:            "Scope beyond session",
+			userID:          userID,
+			newAccessToken:  "access",
+			newRefreshToken: "refresh",
+			hashedToken:     hashed,
+			newHashedToken:  "hashed",
+			expectedErr:     models.ErrScopeNotGranted,
+			args: args{
+				ctx:           context.Background(),
+				creds:         models.ClientCredentials{ID: "signer"},
+				refreshToken:  refresh,
+				scope:         "sessions:read",
+				IPAddress:     ip,
+				authenticated: true,
+			},
+			repoMock: func(r *mocks.AuthRepo, userID uuid.UUID, ip, hashedToken, newHashedToken string) {
+				r.On("GetSessionByUserID", mock.Anything, userID).Return(&models.RefreshSession{
+					UserID:    userID,
+					ClientID:  "signer",
+					Scopes:    []string{"profile"},
+					IP:        ip,
+					Token:     hashedToken,
+					ExpiresAt: time.Now().Add(720 * time.Hour),
+				}, nil)
+			},
 			tokenMock: func(m *mocks.TokenManager, userID uuid.UUID, token, hashedToken, newAccessToken, newRefreshToken, newHashedToken string) {
 				m.On("ParseRefreshToken", token).Return(userID, nil)
 				m.On("ValidateToken", token, hashedToken).Return(nil)
 			},
+			guardMock: func(g *mocks.BruteForceGuard, userID uuid.UUID, ip string) {
+				g.On("Check", mock.Anything, userID, ip).Return(nil)
+			},
+			clientsMock: func(c *mocks.OAuthClients) {
+				c.On("GetClient", mock.Anything, "signer").Return(&models.OAuthClient{
+					ID:         "signer",
+					PublicKey:  "-----BEGIN PUBLIC KEY-----",
+					GrantTypes: []string{models.GrantTypeAuthorizationCode, models.GrantTypeRefreshToken},
+					Scopes:     []string{"profile", "sessions:read", "sessions:admin"},
+				}, nil)
+			},
+		},
+		{
+			name:            "Legacy session of an admin",
+			userID:          userID,
+			newAccessToken:  "access",
+			newRefreshToken: "refresh",
+			hashedToken:     hashed,
+			newHashedToken:  "hashed",
+			args: args{
+				ctx:          context.Background(),
+				refreshToken: refresh,
+				IPAddress:    ip,
+			},
+			repoMock: func(r *mocks.AuthRepo, userID uuid.UUID, ip, hashedToken, newHashedToken string) {
+				r.On("GetSessionByUserID", mock.Anything, userID).Return(&models.RefreshSession{
+					UserID:    userID,
+					Scopes:    []string{"profile", "sessions:admin"},
+					IP:        ip,
+					Token:     hashedToken,
+					ExpiresAt: time.Now().Add(720 * time.Hour),
+				}, nil)
+				r.On("DeleteSessionByUserID", mock.Anything, userID).Return(nil)
+				r.On("GetUserByID", mock.Anything, userID).Return(&models.User{ID: userID, Roles: []string{"admin"}}, nil)
+				r.On("CreateSession", mock.Anything, mock.MatchedBy(func(s *models.RefreshSession) bool {
+					return slices.Equal(s.Scopes, []string{"profile"})
+				})).Return(nil)
+			},
+			tokenMock: func(m *mocks.TokenManager, userID uuid.UUID, token, hashedToken, newAccessToken, newRefreshToken, newHashedToken string) {
+				m.On("ParseRefreshToken", token).Return(userID, nil)
+				m.On("ValidateToken", token, hashedToken).Return(nil)
+				m.On("NewTokenPair", userID, "", mock.Anything, 15*time.Minute, []string{models.RoleUser}, []string{"profile"}).Return(newAccessToken, newRefreshToken, nil)
+				m.On("HashToken", newRefreshToken).Return(newHashedToken, nil)
+				m.On("GetAccessTTL").Return(15 * time.Minute)
+				m.On("GetRefreshTTL").Return(time.Duration(720 * time.Hour))
+			},
+			guardMock: func(g *mocks.BruteForceGuard, userID uuid.UUID, ip string) {
+				g.On("Check", mock.Anything, userID, ip).Return(nil)
+				g.On("Reset", mock.Anything, userID)
+			},
+		},
+		{
+			name:            "Account locked",
+			userID:          userID,
+			newAccessToken:  "access",
+			newRefreshToken: "refresh",
+			hashedToken:     hashed,
+			newHashedToken:  "hashed",
+			expectedErr:     lockedErr,
+			args: args{
+				ctx:          context.Background(),
+				refreshToken: refresh,
+				IPAddress:    ip,
+			},
+			repoMock: func(r *mocks.AuthRepo, userID uuid.UUID, ip, hashedToken, newHashedToken string) {
+
+			},
+			tokenMock: func(m *mocks.TokenManager, userID uuid.UUID, token, hashedToken, newAccessToken, newRefreshToken, newHashedToken string) {
+				m.On("ParseRefreshToken", token).Return(userID, nil)
+			},
+			guardMock: func(g *mocks.BruteForceGuard, userID uuid.UUID, ip string) {
+				g.On("Check", mock.Anything, userID, ip).Return(lockedErr)
+			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			r := mocks.NewAuthRepo(t)
 			m := mocks.NewTokenManager(t)
-			e := mocks.NewEmailService(t)
+			n := mocks.NewNotifier(t)
+			c := mocks.NewOAuthClients(t)
+			g := mocks.NewBruteForceGuard(t)
+			a := mocks.NewAuditLogger(t)
+			p := mocks.NewEventPublisher(t)
 
 			s := &AuthService{
 				authRepo:     r,
 				tokenManager: m,
-				emailService: e,
+				clients:      c,
+				roles:        testRoles,
+				notifier:     n,
+				guard:        g,
+				auditLog:     a,
+				events:       p,
+				transactor:   nopTransactor{},
 			}
 
 			tt.repoMock(r, tt.userID, tt.args.IPAddress, tt.hashedToken, tt.newHashedToken)
 			tt.tokenMock(m, tt.userID, tt.args.refreshToken, tt.hashedToken, tt.newAccessToken, tt.newRefreshToken, tt.newHashedToken)
+			tt.guardMock(g, tt.userID, tt.args.IPAddress)
+			if tt.notifierMock != nil {
+				tt.notifierMock(n)
+			}
+			if tt.clientsMock != nil {
+				tt.clientsMock(c)
+			}
+			a.On("Record", mock.Anything, mock.Anything).Maybe()
+			p.On("Publish", mock.Anything, mock.Anything).Maybe()
 
-			_, _, err := s.RefreshToken(tt.args.ctx, tt.args.refreshToken, tt.args.IPAddress)
+			var (
+				tokens *models.TokenSet
+				err    error
+			)
+			if tt.args.authenticated {
+				tokens, err = s.RefreshClientSession(tt.args.ctx, tt.args.creds.ID, tt.args.refreshToken, tt.args.scope, tt.args.IPAddress, "test-agent")
+			} else {
+				tokens, err = s.RefreshToken(tt.args.ctx, tt.args.creds, tt.args.refreshToken, tt.args.IPAddress, "test-agent")
+			}
 			if err != tt.expectedErr {
 				t.Errorf("error = %v, expectedError %v", err, tt.expectedErr)
 				return
 			}
+
+			if err == nil && (tokens.AccessToken != tt.newAccessToken || tokens.RefreshToken != tt.newRefreshToken || tokens.AccessTTL == 0 || tokens.RefreshTTL == 0) {
+				t.Errorf("tokens = %+v, expected the new pair with its ttls", tokens)
+			}
+		})
+	}
+}
+
+func TestAuthService_NewSession(t *testing.T) {
+	userID := uuid.New()
+	ip := "127.0.0.1"
+
+	admin := &models.OAuthClient{
+		ID:         "admin-console",
+		GrantTypes: []string{models.GrantTypeAuthorizationCode, models.GrantTypeRefreshToken},
+		Scopes:     []string{"profile", "sessions:read", "sessions:admin"},
+	}
+
+	tests := []struct {
+		name           string
+		client         *models.OAuthClient
+		scope          string
+		expectedRoles  []string
+		expectedScopes []string
+	}{
+		{
+			name:           "Client",
+			client:         admin,
+			expectedRoles:  []string{models.RoleUser, "admin"},
+			expectedScopes: []string{"profile", "sessions:read", "sessions:admin"},
+		},
+		{
+			name:           "Legacy login",
+			expectedRoles:  []string{models.RoleUser},
+			expectedScopes: []string{"profile", "sessions:read"},
+		},
+		{
+			name:           "Legacy login requesting an elevated scope",
+			scope:          "sessions:admin profile",
+			expectedRoles:  []string{models.RoleUser},
+			expectedScopes: []string{"profile"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mocks.NewAuthRepo(t)
+			m := mocks.NewTokenManager(t)
+			c := mocks.NewOAuthClients(t)
+			g := mocks.NewBruteForceGuard(t)
+			a := mocks.NewAuditLogger(t)
+			p := mocks.NewEventPublisher(t)
+
+			var clientID string
+			if tt.client != nil {
+				clientID = tt.client.ID
+				c.On("GetClient", mock.Anything, clientID).Return(tt.client, nil)
+			}
+
+			g.On("Check", mock.Anything, userID, ip).Return(nil)
+			r.On("GetUserByID", mock.Anything, userID).Return(&models.User{ID: userID, Roles: []string{"admin"}}, nil)
+			r.On("DeleteSessionByUserID", mock.Anything, userID).Return(models.ErrSessionNotFound)
+			r.On("CreateSession", mock.Anything, mock.MatchedBy(func(s *models.RefreshSession) bool {
+				return s.ClientID == clientID && slices.Equal(s.Scopes, tt.expectedScopes)
+			})).Return(nil)
+			r.On("TouchUserDevice", mock.Anything, userID, mock.Anything).Return(false, nil)
+			m.On("GetAccessTTL").Return(15 * time.Minute)
+			m.On("GetRefreshTTL").Return(720 * time.Hour)
+			m.On("NewTokenPair", userID, clientID, ip, 15*time.Minute, tt.expectedRoles, tt.expectedScopes).Return("access", "refresh", nil)
+			m.On("HashToken", "refresh").Return("hashed", nil)
+			a.On("Record", mock.Anything, mock.Anything).Maybe()
+			p.On("Publish", mock.Anything, mock.Anything).Maybe()
+
+			s := &AuthService{
+				authRepo:     r,
+				tokenManager: m,
+				clients:      c,
+				roles:        testRoles,
+				guard:        g,
+				auditLog:     a,
+				events:       p,
+				transactor:   nopTransactor{},
+			}
+
+			tokens, err := s.NewSession(context.Background(), clientID, userID.String(), tt.scope, ip, "test-agent")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !slices.Equal(tokens.Scope, tt.expectedScopes) {
+				t.Errorf("scope = %v, expected %v", tokens.Scope, tt.expectedScopes)
+			}
+		})
+	}
+}
+
+func TestAuthService_NewSession_NewDeviceWarningFailed(t *testing.T) {
+	userID := uuid.New()
+	ip := "127.0.0.1"
+	errTemplate := errors.New("template error")
+
+	r := mocks.NewAuthRepo(t)
+	m := mocks.NewTokenManager(t)
+	n := mocks.NewNotifier(t)
+	g := mocks.NewBruteForceGuard(t)
+	a := mocks.NewAuditLogger(t)
+	p := mocks.NewEventPublisher(t)
+
+	g.On("Check", mock.Anything, userID, ip).Return(nil)
+	r.On("GetUserByID", mock.Anything, userID).Return(&models.User{ID: userID}, nil)
+	r.On("DeleteSessionByUserID", mock.Anything, userID).Return(models.ErrSessionNotFound)
+	r.On("CreateSession", mock.Anything, mock.Anything).Return(nil)
+	r.On("TouchUserDevice", mock.Anything, userID, mock.Anything).Return(true, nil)
+	m.On("GetAccessTTL").Return(15 * time.Minute)
+	m.On("GetRefreshTTL").Return(720 * time.Hour)
+	m.On("NewTokenPair", userID, "", ip, 15*time.Minute, mock.Anything, mock.Anything).Return("access", "refresh", nil)
+	m.On("HashToken", "refresh").Return("hashed", nil)
+	m.On("NewRevokeToken", userID).Return("revoke", nil)
+	n.On("Notify", mock.Anything, mock.MatchedBy(func(n models.Notification) bool {
+		return n.Event == models.NotificationNewDevice
+	})).Return(errTemplate)
+	a.On("Record", mock.Anything, mock.Anything).Maybe()
+	p.On("Publish", mock.Anything, mock.Anything).Maybe()
+
+	s := &AuthService{
+		authRepo:     r,
+		tokenManager: m,
+		roles:        testRoles,
+		notifier:     n,
+		guard:        g,
+		auditLog:     a,
+		events:       p,
+		transactor:   nopTransactor{},
+	}
+
+	// The warning is queued in the transaction of the login, which is rolled
+	// back rather than committed without it.
+	if _, err := s.NewSession(context.Background(), "", userID.String(), "", ip, "test-agent"); !errors.Is(err, errTemplate) {
+		t.Errorf("error = %v, expected %v", err, errTemplate)
+	}
+}
+
+type nopTransactor struct{}
+
+func (nopTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func TestAuthService_RevokeSessions(t *testing.T) {
+	userID := uuid.New()
+	ip := "127.0.0.1"
+
+	tests := []struct {
+		name        string
+		token       string
+		parseErr    error
+		consumeErr  error
+		deleteErr   error
+		expectedErr error
+	}{
+		{
+			name:        "OK",
+			token:       "revoke",
+			expectedErr: nil,
+		},
+		{
+			name:        "No active session",
+			token:       "revoke",
+			deleteErr:   models.ErrSessionNotFound,
+			expectedErr: nil,
+		},
+		{
+			name:        "Token expired",
+			token:       "expired",
+			parseErr:    models.ErrTokenExpired,
+			expectedErr: models.ErrTokenExpired,
+		},
+		{
+			name:        "Token used",
+			token:       "revoke",
+			consumeErr:  models.ErrTokenUsed,
+			expectedErr: models.ErrTokenUsed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mocks.NewAuthRepo(t)
+			m := mocks.NewTokenManager(t)
+			n := mocks.NewNotifier(t)
+			a := mocks.NewAuditLogger(t)
+			p := mocks.NewEventPublisher(t)
+
+			s := &AuthService{
+				authRepo:     r,
+				tokenManager: m,
+				notifier:     n,
+				auditLog:     a,
+				events:       p,
+				transactor:   nopTransactor{},
+			}
+
+			m.On("ParseRevokeToken", tt.token).Return(userID, tt.parseErr)
+			if tt.parseErr == nil {
+				r.On("ConsumeRevokeToken", mock.Anything, userID, hashOpaqueToken(tt.token)).Return(tt.consumeErr)
+			}
+			if tt.parseErr == nil && tt.consumeErr == nil {
+				r.On("DeleteSessionByUserID", mock.Anything, userID).Return(tt.deleteErr)
+				r.On("DeleteEmailChange", mock.Anything, userID).Return(nil)
+				r.On("GetUserByID", mock.Anything, userID).Return(&models.User{ID: userID}, nil)
+				n.On("Notify", mock.Anything, mock.MatchedBy(func(n models.Notification) bool {
+					return n.Event == models.NotificationSessionRevoked && n.User.ID == userID && n.Alert.NewIP == ip
+				})).Return(nil)
+				a.On("Record", mock.Anything, mock.MatchedBy(func(e models.AuthEvent) bool {
+					return e.Reason == models.AuthReasonReportedByUser && e.UserID == userID
+				}))
+				p.On("Publish", mock.Anything, mock.Anything)
+			}
+
+			err := s.RevokeSessions(context.Background(), tt.token, ip, "test-agent")
+			if err != tt.expectedErr {
+				t.Errorf("error = %v, expectedError %v", err, tt.expectedErr)
+			}
 		})
 	}
 }
@@ -0,0 +1,286 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"medods-test-task/internal/models"
+	"medods-test-task/pkg/utils"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	clientSecretPrefix = "cs_"
+
+	maxClientNameLength = 100
+)
+
+var clientIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{2,63}$`)
+
+// defaultGrantTypes are allowed for clients registered without grant types.
+var defaultGrantTypes = []string{models.GrantTypeAuthorizationCode, models.GrantTypeRefreshToken}
+
+//go:generate go run github.com/vektra/mockery/v2@latest --name ClientRepo
+type ClientRepo interface {
+	CreateClient(ctx context.Context, client *models.OAuthClient) error
+	GetClient(ctx context.Context, clientID string) (*models.OAuthClient, error)
+	ListClients(ctx context.Context) ([]models.OAuthClient, error)
+	UpdateClient(ctx context.Context, client *models.OAuthClient) error
+	UpdateClientSecret(ctx context.Context, clientID, secretHash string) error
+	DeleteClient(ctx context.Context, clientID string) error
+}
+
+type clientService struct {
+	repo ClientRepo
+}
+
+func NewClientService(repo ClientRepo) *clientService {
+	return &clientService{
+		repo: repo,
+	}
+}
+
+// CreateClient registers a client. A confidential client gets a secret that
+// is returned only once, a random id is used when params has none. A client
+// with a public key authenticates with private_key_jwt and needs no secret.
+func (s *clientService) CreateClient(ctx context.Context, params models.OAuthClientParams) (*models.OAuthClient, error) {
+	if params.ID == "" {
+		params.ID = uuid.NewString()
+	}
+
+	if !clientIDPattern.MatchString(params.ID) {
+		return nil, models.ErrInvalidClientID
+	}
+
+	now := time.Now()
+
+	client := &models.OAuthClient{
+		ID:        params.ID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if params.Confidential {
+		secret, err := newClientSecret()
+		if err != nil {
+			return nil, err
+		}
+
+		client.Secret = secret
+		client.SecretHash = hashOpaqueToken(secret)
+	}
+
+	if err := applyClientParams(client, params); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CreateClient(ctx, client); err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+func (s *clientService) GetClient(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	return s.repo.GetClient(ctx, clientID)
+}
+
+func (s *clientService) ListClients(ctx context.Context) ([]models.OAuthClient, error) {
+	return s.repo.ListClients(ctx)
+}
+
+// UpdateClient replaces the settings of the client. Its id, secret and
+// sessions are kept.
+func (s *clientService) UpdateClient(ctx context.Context, clientID string, params models.OAuthClientParams) (*models.OAuthClient, error) {
+	client, err := s.repo.GetClient(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := applyClientParams(client, params); err != nil {
+		return nil, err
+	}
+
+	client.UpdatedAt = time.Now()
+
+	if err := s.repo.UpdateClient(ctx, client); err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// RotateSecret replaces the secret of the client, the old one stops working
+// at once. A public client becomes confidential.
+func (s *clientService) RotateSecret(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	client, err := s.repo.GetClient(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := newClientSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.UpdateClientSecret(ctx, clientID, hashOpaqueToken(secret)); err != nil {
+		return nil, err
+	}
+
+	client.Secret = secret
+	client.SecretHash = hashOpaqueToken(secret)
+
+	return client, nil
+}
+
+// ImportClients registers the clients of the deprecated OAUTH_CLIENTS setting
+// that are not registered yet, as public clients with the redirect uris and
+// scopes given. Clients already registered are left as they are, so that
+// changes made with the admin api survive a restart. It returns the ids of
+// the registered clients.
+func (s *clientService) ImportClients(ctx context.Context, clients map[string][]string, scopes []string) ([]string, error) {
+	ids := make([]string, 0, len(clients))
+	for id := range clients {
+		ids = append(ids, id)
+	}
+
+	slices.Sort(ids)
+
+	imported := []string{}
+
+	for _, id := range ids {
+		_, err := s.CreateClient(ctx, models.OAuthClientParams{
+			ID:           id,
+			Name:         id,
+			RedirectURIs: clients[id],
+			Scopes:       scopes,
+		})
+		if errors.Is(err, models.ErrOAuthClientExists) {
+			continue
+		}
+
+		if err != nil {
+			return imported, fmt.Errorf("import client %s: %w", id, err)
+		}
+
+		imported = append(imported, id)
+	}
+
+	return imported, nil
+}
+
+// DeleteClient removes the client together with its sessions.
+func (s *clientService) DeleteClient(ctx context.Context, clientID string) error {
+	return s.repo.DeleteClient(ctx, clientID)
+}
+
+func applyClientParams(client *models.OAuthClient, params models.OAuthClientParams) error {
+	name := strings.TrimSpace(params.Name)
+	if name == "" || len(name) > maxClientNameLength {
+		return models.ErrEmptyClientName
+	}
+
+	grantTypes := params.GrantTypes
+	if len(grantTypes) == 0 {
+		grantTypes = defaultGrantTypes
+	}
+
+	for _, grantType := range grantTypes {
+		if !slices.Contains(models.GrantTypes, grantType) {
+			return models.ErrUnknownGrantType
+		}
+	}
+
+	for _, uri := range params.RedirectURIs {
+		u, err := url.Parse(uri)
+		if err != nil || !u.IsAbs() || u.Fragment != "" {
+			return models.ErrInvalidClientURI
+		}
+	}
+
+	if slices.Contains(grantTypes, models.GrantTypeAuthorizationCode) && len(params.RedirectURIs) == 0 {
+		return models.ErrMissingRedirectURI
+	}
+
+	for _, scope := range params.Scopes {
+		if !isScopeToken(scope) {
+			return models.ErrInvalidScope
+		}
+	}
+
+	if params.AccessTokenTTL < 0 || params.RefreshTokenTTL < 0 {
+		return models.ErrInvalidClientTokenTTL
+	}
+
+	if params.PublicKey != "" {
+		if _, err := utils.ParsePublicKey(params.PublicKey); err != nil {
+			return err
+		}
+	}
+
+	if (slices.Contains(grantTypes, models.GrantTypeClientCredentials) || slices.Contains(grantTypes, models.GrantTypeTokenExchange)) &&
+		client.SecretHash == "" && params.PublicKey == "" {
+		return models.ErrPublicClientGrant
+	}
+
+	client.Name = name
+	client.PublicKey = params.PublicKey
+	client.RedirectURIs = nonNil(params.RedirectURIs)
+	client.GrantTypes = slices.Clone(grantTypes)
+	client.Scopes = nonNil(params.Scopes)
+	client.AccessTokenTTL = params.AccessTokenTTL
+	client.RefreshTokenTTL = params.RefreshTokenTTL
+
+	return nil
+}
+
+// isScopeToken reports whether scope is a scope-token from RFC 6749, printable
+// ASCII without spaces, quotes and backslashes.
+func isScopeToken(scope string) bool {
+	if scope == "" {
+		return false
+	}
+
+	for i := 0; i < len(scope); i++ {
+		c := scope[i]
+		if c <= ' ' || c > '~' || c == '"' || c == '\\' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+
+	return values
+}
+
+// verifyClientSecret reports whether the secret authenticates the client.
+// Public clients have no secret to check.
+func verifyClientSecret(client *models.OAuthClient, secret string) bool {
+	if !client.IsConfidential() {
+		return true
+	}
+
+	return client.SecretHash != "" && subtle.ConstantTimeCompare([]byte(hashOpaqueToken(secret)), []byte(client.SecretHash)) == 1
+}
+
+func newClientSecret() (string, error) {
+	token, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	return clientSecretPrefix + token, nil
+}
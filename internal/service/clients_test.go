@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"medods-test-task/internal/models"
+	"medods-test-task/internal/service/mocks"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func testPublicKey(t *testing.T) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func TestClientService_CreateClient(t *testing.T) {
+	publicKey := testPublicKey(t)
+
+	valid := models.OAuthClientParams{
+		ID:           "spa",
+		Name:         "Single page app",
+		RedirectURIs: []string{"http://localhost:3000/callback", "ru.medods.app:/oauth/callback"},
+		Scopes:       []string{"profile", "sessions:read"},
+	}
+
+	tests := []struct {
+		name        string
+		modify      func(p *models.OAuthClientParams)
+		expectedErr error
+	}{
+		{
+			name:   "OK",
+			modify: func(p *models.OAuthClientParams) {},
+		},
+		{
+			name:   "Confidential",
+			modify: func(p *models.OAuthClientParams) { p.Confidential = true },
+		},
+		{
+			name:   "Generated id",
+			modify: func(p *models.OAuthClientParams) { p.ID = "" },
+		},
+		{
+			name:        "Invalid id",
+			modify:      func(p *models.OAuthClientParams) { p.ID = "My App" },
+			expectedErr: models.ErrInvalidClientID,
+		},
+		{
+			name:        "Empty name",
+			modify:      func(p *models.OAuthClientParams) { p.Name = "  " },
+			expectedErr: models.ErrEmptyClientName,
+		},
+		{
+			name:        "Relative redirect uri",
+			modify:      func(p *models.OAuthClientParams) { p.RedirectURIs = []string{"/callback"} },
+			expectedErr: models.ErrInvalidClientURI,
+		},
+		{
+			name:        "Redirect uri with fragment",
+			modify:      func(p *models.OAuthClientParams) { p.RedirectURIs = []string{"https://app.medods.ru/#/callback"} },
+			expectedErr: models.ErrInvalidClientURI,
+		},
+		{
+			name:        "Authorization code without redirect uri",
+			modify:      func(p *models.OAuthClientParams) { p.RedirectURIs = nil },
+			expectedErr: models.ErrMissingRedirectURI,
+		},
+		{
+			name: "Refresh only without redirect uri",
+			modify: func(p *models.OAuthClientParams) {
+				p.RedirectURIs = nil
+				p.GrantTypes = []string{models.GrantTypeRefreshToken}
+			},
+		},
+		{
+			name:        "Unknown grant type",
+			modify:      func(p *models.OAuthClientParams) { p.GrantTypes = []string{"password"} },
+			expectedErr: models.ErrUnknownGrantType,
+		},
+		{
+			name:        "Scope with space",
+			modify:      func(p *models.OAuthClientParams) { p.Scopes = []string{"read write"} },
+			expectedErr: models.ErrInvalidScope,
+		},
+		{
+			name:        "Negative ttl",
+			modify:      func(p *models.OAuthClientParams) { p.AccessTokenTTL = -time.Minute },
+			expectedErr: models.ErrInvalidClientTokenTTL,
+		},
+		{
+			name: "Client credentials with secret",
+			modify: func(p *models.OAuthClientParams) {
+				p.Confidential = true
+				p.GrantTypes = []string{models.GrantTypeClientCredentials}
+			},
+		},
+		{
+			name: "Client credentials with public key",
+			modify: func(p *models.OAuthClientParams) {
+				p.PublicKey = publicKey
+				p.GrantTypes = []string{models.GrantTypeClientCredentials}
+			},
+		},
+		{
+			name:        "Client credentials for public client",
+			modify:      func(p *models.OAuthClientParams) { p.GrantTypes = []string{models.GrantTypeClientCredentials} },
+			expectedErr: models.ErrPublicClientGrant,
+		},
+		{
+			name:        "Invalid public key",
+			modify:      func(p *models.OAuthClientParams) { p.PublicKey = "not a key" },
+			expectedErr: models.ErrInvalidClientKey,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := valid
+			tt.modify(&params)
+
+			r := mocks.NewClientRepo(t)
+			if tt.expectedErr == nil {
+				r.On("CreateClient", mock.Anything, mock.Anything).Return(nil)
+			}
+
+			client, err := NewClientService(r).CreateClient(context.Background(), params)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("error = %v, expectedError %v", err, tt.expectedErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if len(client.GrantTypes) == 0 {
+				t.Error("grant types are empty, expected the defaults")
+			}
+
+			if confidential := params.Confidential || params.PublicKey != ""; client.IsConfidential() != confidential {
+				t.Errorf("confidential = %v, expected %v", client.IsConfidential(), confidential)
+			}
+
+			if params.Confidential && (!strings.HasPrefix(client.Secret, clientSecretPrefix) || !verifyClientSecret(client, client.Secret)) {
+				t.Errorf("secret %q does not match its hash", client.Secret)
+			}
+
+			if !params.Confidential && client.Secret != "" {
+				t.Errorf("public client got a secret %q", client.Secret)
+			}
+		})
+	}
+}
+
+func TestClientService_RotateSecret(t *testing.T) {
+	oldSecret := "cs_old"
+
+	r := mocks.NewClientRepo(t)
+	r.On("GetClient", mock.Anything, "backend").Return(&models.OAuthClient{
+		ID:         "backend",
+		SecretHash: hashOpaqueToken(oldSecret),
+	}, nil)
+	r.On("UpdateClientSecret", mock.Anything, "backend", mock.Anything).Return(nil)
+
+	client, err := NewClientService(r).RotateSecret(context.Background(), "backend")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !verifyClientSecret(client, client.Secret) {
+		t.Error("new secret does not match its hash")
+	}
+
+	if verifyClientSecret(client, oldSecret) {
+		t.Error("old secret is still accepted")
+	}
+}
+
+func TestClientService_ImportClients(t *testing.T) {
+	scopes := []string{"profile", "sessions:read"}
+
+	var created []*models.OAuthClient
+
+	r := mocks.NewClientRepo(t)
+	r.On("CreateClient", mock.Anything, mock.MatchedBy(func(client *models.OAuthClient) bool { return client.ID == "mobile" })).
+		Return(models.ErrOAuthClientExists)
+	r.On("CreateClient", mock.Anything, mock.MatchedBy(func(client *models.OAuthClient) bool { return client.ID == "spa" })).
+		Run(func(args mock.Arguments) { created = append(created, args.Get(1).(*models.OAuthClient)) }).
+		Return(nil)
+
+	imported, err := NewClientService(r).ImportClients(context.Background(), map[string][]string{
+		"spa":    {"http://localhost:3000/callback"},
+		"mobile": {"ru.medods.app:/oauth/callback"},
+	}, scopes)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(imported, []string{"spa"}) {
+		t.Errorf("imported = %v, expected only the client not registered yet", imported)
+	}
+
+	client := created[0]
+	if client.IsConfidential() || !reflect.DeepEqual(client.RedirectURIs, []string{"http://localhost:3000/callback"}) ||
+		!reflect.DeepEqual(client.Scopes, scopes) || !reflect.DeepEqual(client.GrantTypes, defaultGrantTypes) {
+		t.Errorf("client = %+v, expected a public client with the redirect uris and scopes", client)
+	}
+}
+
+func TestClientService_ImportClients_InvalidID(t *testing.T) {
+	_, err := NewClientService(mocks.NewClientRepo(t)).ImportClients(context.Background(), map[string][]string{
+		"My App": {"http://localhost:3000/callback"},
+	}, nil)
+	if !errors.Is(err, models.ErrInvalidClientID) {
+		t.Errorf("error = %v, expected %v", err, models.ErrInvalidClientID)
+	}
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"medods-test-task/internal/models"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	deviceVerificationPath = "/oauth/device"
+
+	// userCodeAlphabet has no vowels, so that codes do not spell words, and
+	// no characters that are easily confused, see RFC 8628 section 6.1.
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength   = 8
+
+	maxUserCodeAttempts = 3
+
+	// slowDownStep is added to the poll interval of a device that polls too
+	// often, see RFC 8628 section 3.5.
+	slowDownStep = 5 * time.Second
+)
+
+// RequestDeviceCode starts the device authorization grant for a client that
+// cannot open a browser. The device shows the user code and verification uri
+// and polls the token endpoint with the device code until the user decides.
+func (s *oauthService) RequestDeviceCode(ctx context.Context, creds models.ClientCredentials, scope string) (*models.DeviceAuthorization, error) {
+	client, err := s.authenticateClient(ctx, creds)
+	if err != nil {
+		return nil, err
+	}
+
+	if !client.AllowsGrant(models.GrantTypeDeviceCode) {
+		return nil, models.ErrUnauthorizedClient
+	}
+
+	if _, err := grantScope(client, scope); err != nil {
+		return nil, err
+	}
+
+	deviceCode, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	code := &models.DeviceCode{
+		DeviceCodeHash: hashOpaqueToken(deviceCode),
+		ClientID:       client.ID,
+		Scope:          scope,
+		Status:         models.DeviceCodePending,
+		PollInterval:   s.oauthConfig.DevicePollInterval,
+		ExpiresAt:      now.Add(s.oauthConfig.DeviceCodeTTL),
+		CreatedAt:      now,
+	}
+
+	// User codes are short enough to clash with a pending one now and then.
+	for attempt := 1; ; attempt++ {
+		code.UserCode, err = newUserCode()
+		if err != nil {
+			return nil, err
+		}
+
+		err = s.repo.CreateDeviceCode(ctx, code)
+		if err == nil {
+			break
+		}
+
+		if !errors.Is(err, models.ErrUserCodeTaken) || attempt == maxUserCodeAttempts {
+			return nil, err
+		}
+	}
+
+	userCode := formatUserCode(code.UserCode)
+	verificationURI := strings.TrimSuffix(s.oauthConfig.Issuer, "/") + deviceVerificationPath
+
+	return &models.DeviceAuthorization{
+		DeviceCode:              deviceCode,
+		UserCode:                userCode,
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + "?" + url.Values{"user_code": {userCode}}.Encode(),
+		ExpiresIn:               s.oauthConfig.DeviceCodeTTL,
+		Interval:                s.oauthConfig.DevicePollInterval,
+	}, nil
+}
+
+// DeviceRequest returns the client waiting for the user code and the scopes
+// it asked for, so that the user can check what they are about to approve.
+func (s *oauthService) DeviceRequest(ctx context.Context, userCode string) (*models.DeviceRequest, error) {
+	code, err := s.repo.GetDeviceCodeByUserCode(ctx, normalizeUserCode(userCode))
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := s.getClient(ctx, code.ClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The session gets no scope the client has lost since the request.
+	scope := nonNil(client.Scopes)
+	if requested := strings.Fields(code.Scope); len(requested) != 0 {
+		scope = intersectScopes(requested, client.Scopes)
+	}
+
+	return &models.DeviceRequest{Client: client, Scope: scope}, nil
+}
+
+// ConfirmDevice records whether the user approved the device waiting for the
+// user code. The device gets its tokens on its next poll.
+func (s *oauthService) ConfirmDevice(ctx context.Context, userCode string, userID uuid.UUID, approved bool) error {
+	status := models.DeviceCodeDenied
+	if approved {
+		status = models.DeviceCodeApproved
+	}
+
+	return s.repo.DecideDeviceCode(ctx, normalizeUserCode(userCode), userID, status)
+}
+
+// ConfirmDeviceWithPassword signs the user in with their email and password
+// before recording their decision, for the hosted verification page.
+func (s *oauthService) ConfirmDeviceWithPassword(ctx context.Context, userCode, email, pass, IPAddress string, approved bool) error {
+	user, err := s.authenticate(ctx, email, pass, IPAddress)
+	if err != nil {
+		return err
+	}
+
+	return s.ConfirmDevice(ctx, userCode, user.ID, approved)
+}
+
+// ExchangeDeviceCode answers a poll of the device. Once the user approves, the
+// device code is consumed and redeemed for a new session of the client.
+func (s *oauthService) ExchangeDeviceCode(ctx context.Context, creds models.ClientCredentials, deviceCode, IPAddress, userAgent string) (*models.TokenSet, error) {
+	client, err := s.authenticateClient(ctx, creds)
+	if err != nil {
+		return nil, err
+	}
+
+	if !client.AllowsGrant(models.GrantTypeDeviceCode) {
+		return nil, models.ErrUnauthorizedClient
+	}
+
+	now := time.Now()
+	codeHash := hashOpaqueToken(deviceCode)
+
+	code, err := s.repo.PollDeviceCode(ctx, codeHash, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if code.ClientID != client.ID {
+		return nil, models.ErrInvalidGrant
+	}
+
+	if now.After(code.ExpiresAt) {
+		return nil, models.ErrExpiredToken
+	}
+
+	if code.LastPolledAt != nil && now.Sub(*code.LastPolledAt) < code.PollInterval {
+		if err := s.repo.SetDevicePollInterval(ctx, codeHash, code.PollInterval+slowDownStep); err != nil {
+			return nil, err
+		}
+
+		return nil, models.ErrSlowDown
+	}
+
+	switch code.Status {
+	case models.DeviceCodeApproved:
+		if err := s.repo.DeleteDeviceCode(ctx, codeHash); err != nil {
+			return nil, err
+		}
+
+		return s.sessions.NewSession(ctx, client.ID, code.UserID.String(), code.Scope, IPAddress, userAgent)
+	case models.DeviceCodeDenied:
+		if err := s.repo.DeleteDeviceCode(ctx, codeHash); err != nil {
+			return nil, err
+		}
+
+		return nil, models.ErrAccessDenied
+	default:
+		return nil, models.ErrAuthorizationPending
+	}
+}
+
+func newUserCode() (string, error) {
+	code := make([]byte, userCodeLength)
+	base := big.NewInt(int64(len(userCodeAlphabet)))
+
+	for i := range code {
+		n, err := rand.Int(rand.Reader, base)
+		if err != nil {
+			return "", fmt.Errorf("failed to create user code: %w", err)
+		}
+
+		code[i] = userCodeAlphabet[n.Int64()]
+	}
+
+	return string(code), nil
+}
+
+// formatUserCode splits the code in two halves, which are easier to read and
+// type.
+func formatUserCode(code string) string {
+	return code[:userCodeLength/2] + "-" + code[userCodeLength/2:]
+}
+
+// normalizeUserCode undoes formatUserCode and the changes users make while
+// typing a code, such as lowercase letters and spaces.
+func normalizeUserCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+
+		return r
+	}, strings.ToUpper(code))
+}
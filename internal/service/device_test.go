@@ -0,0 +1,314 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"medods-test-task/internal/models"
+	"medods-test-task/internal/service/mocks"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestOAuthService_RequestDeviceCode(t *testing.T) {
+	tests := []struct {
+		name        string
+		clientID    string
+		scope       string
+		clashes     int
+		expectedErr error
+	}{
+		{
+			name:     "OK",
+			clientID: "tv",
+			scope:    "profile",
+		},
+		{
+			name:     "User code clash",
+			clientID: "tv",
+			clashes:  1,
+		},
+		{
+			name:        "Too many clashes",
+			clientID:    "tv",
+			clashes:     maxUserCodeAttempts,
+			expectedErr: models.ErrUserCodeTaken,
+		},
+		{
+			name:        "Grant type not allowed",
+			clientID:    "spa",
+			expectedErr: models.ErrUnauthorizedClient,
+		},
+		{
+			name:        "Scope not allowed",
+			clientID:    "tv",
+			scope:       "users:write",
+			expectedErr: models.ErrScopeNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mocks.NewOAuthRepo(t)
+
+			isCode := mock.MatchedBy(func(code *models.DeviceCode) bool {
+				return code.ClientID == tt.clientID && code.Scope == tt.scope && code.Status == models.DeviceCodePending &&
+					code.PollInterval == 5*time.Second && len(code.UserCode) == userCodeLength
+			})
+
+			if tt.clashes > 0 {
+				r.On("CreateDeviceCode", mock.Anything, isCode).Return(models.ErrUserCodeTaken).Times(min(tt.clashes, maxUserCodeAttempts))
+			}
+			if tt.expectedErr == nil {
+				r.On("CreateDeviceCode", mock.Anything, isCode).Return(nil).Once()
+			}
+
+			s := newTestOAuthService(t, r, mocks.NewCredentialsRepo(t), mocks.NewSessionIssuer(t), mocks.NewClientTokenManager(t), mocks.NewBruteForceGuard(t))
+
+			auth, err := s.RequestDeviceCode(context.Background(), models.ClientCredentials{ID: tt.clientID}, tt.scope)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("error = %v, expectedError %v", err, tt.expectedErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if auth.DeviceCode == "" || len(auth.UserCode) != userCodeLength+1 || auth.UserCode[userCodeLength/2] != '-' {
+				t.Errorf("codes = %q, %q, expected a device code and a formatted user code", auth.DeviceCode, auth.UserCode)
+			}
+
+			if auth.VerificationURI != "https://auth.medods.ru/oauth/device" || auth.VerificationURIComplete != auth.VerificationURI+"?user_code="+auth.UserCode {
+				t.Errorf("verification uris = %q, %q", auth.VerificationURI, auth.VerificationURIComplete)
+			}
+		})
+	}
+}
+
+func TestOAuthService_DeviceRequest(t *testing.T) {
+	tests := []struct {
+		name          string
+		scope         string
+		codeErr       error
+		expectedScope []string
+		expectedErr   error
+	}{
+		{
+			name:          "Requested scope",
+			scope:         "profile",
+			expectedScope: []string{"profile"},
+		},
+		{
+			name:          "Every scope of the client",
+			expectedScope: []string{"profile"},
+		},
+		{
+			name:          "Scope the client lost",
+			scope:         "profile users:write",
+			expectedScope: []string{"profile"},
+		},
+		{
+			name:        "Invalid code",
+			codeErr:     models.ErrInvalidUserCode,
+			expectedErr: models.ErrInvalidUserCode,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mocks.NewOAuthRepo(t)
+			if tt.codeErr != nil {
+				r.On("GetDeviceCodeByUserCode", mock.Anything, "BCDFGHJK").Return(nil, tt.codeErr)
+			} else {
+				r.On("GetDeviceCodeByUserCode", mock.Anything, "BCDFGHJK").Return(&models.DeviceCode{ClientID: "tv", Scope: tt.scope}, nil)
+			}
+
+			s := newTestOAuthService(t, r, mocks.NewCredentialsRepo(t), mocks.NewSessionIssuer(t), mocks.NewClientTokenManager(t), mocks.NewBruteForceGuard(t))
+
+			request, err := s.DeviceRequest(context.Background(), "bcdf-ghjk")
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("error = %v, expectedError %v", err, tt.expectedErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if request.Client.ID != "tv" || !reflect.DeepEqual(request.Scope, tt.expectedScope) {
+				t.Errorf("request = %s %v, expected tv %v", request.Client.ID, request.Scope, tt.expectedScope)
+			}
+		})
+	}
+}
+
+func TestOAuthService_ConfirmDevice(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name           string
+		userCode       string
+		approved       bool
+		expectedStatus string
+	}{
+		{
+			name:           "Approve",
+			userCode:       "BCDF-GHJK",
+			approved:       true,
+			expectedStatus: models.DeviceCodeApproved,
+		},
+		{
+			name:           "Deny",
+			userCode:       "BCDF-GHJK",
+			expectedStatus: models.DeviceCodeDenied,
+		},
+		{
+			name:           "Typed by hand",
+			userCode:       "bcdf ghjk",
+			approved:       true,
+			expectedStatus: models.DeviceCodeApproved,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mocks.NewOAuthRepo(t)
+			r.On("DecideDeviceCode", mock.Anything, "BCDFGHJK", userID, tt.expectedStatus).Return(nil)
+
+			s := newTestOAuthService(t, r, mocks.NewCredentialsRepo(t), mocks.NewSessionIssuer(t), mocks.NewClientTokenManager(t), mocks.NewBruteForceGuard(t))
+
+			if err := s.ConfirmDevice(context.Background(), tt.userCode, userID, tt.approved); err != nil {
+				t.Fatalf("error = %v", err)
+			}
+		})
+	}
+}
+
+func TestOAuthService_ExchangeDeviceCode(t *testing.T) {
+	userID := uuid.New()
+	deviceCode := "device-code"
+	codeHash := hashOpaqueToken(deviceCode)
+
+	tests := []struct {
+		name           string
+		clientID       string
+		modify         func(c *models.DeviceCode)
+		expectDelete   bool
+		expectSlowDown bool
+		expectedErr    error
+	}{
+		{
+			name:         "Approved",
+			clientID:     "tv",
+			expectDelete: true,
+		},
+		{
+			name:        "Pending",
+			clientID:    "tv",
+			modify:      func(c *models.DeviceCode) { c.Status = models.DeviceCodePending },
+			expectedErr: models.ErrAuthorizationPending,
+		},
+		{
+			name:         "Denied",
+			clientID:     "tv",
+			modify:       func(c *models.DeviceCode) { c.Status = models.DeviceCodeDenied },
+			expectDelete: true,
+			expectedErr:  models.ErrAccessDenied,
+		},
+		{
+			name:     "Polled too often",
+			clientID: "tv",
+			modify: func(c *models.DeviceCode) {
+				polledAt := time.Now().Add(-time.Second)
+				c.LastPolledAt = &polledAt
+			},
+			expectSlowDown: true,
+			expectedErr:    models.ErrSlowDown,
+		},
+		{
+			name:     "Polled after the interval",
+			clientID: "tv",
+			modify: func(c *models.DeviceCode) {
+				polledAt := time.Now().Add(-6 * time.Second)
+				c.LastPolledAt = &polledAt
+			},
+			expectDelete: true,
+		},
+		{
+			name:        "Expired",
+			clientID:    "tv",
+			modify:      func(c *models.DeviceCode) { c.ExpiresAt = time.Now().Add(-time.Second) },
+			expectedErr: models.ErrExpiredToken,
+		},
+		{
+			name:        "Other client",
+			clientID:    "tv",
+			modify:      func(c *models.DeviceCode) { c.ClientID = "kiosk" },
+			expectedErr: models.ErrInvalidGrant,
+		},
+		{
+			name:        "Grant type not allowed",
+			clientID:    "spa",
+			expectedErr: models.ErrUnauthorizedClient,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mocks.NewOAuthRepo(t)
+			sessions := mocks.NewSessionIssuer(t)
+
+			polled := models.DeviceCode{
+				DeviceCodeHash: codeHash,
+				UserCode:       "BCDFGHJK",
+				ClientID:       "tv",
+				Scope:          "profile",
+				Status:         models.DeviceCodeApproved,
+				UserID:         userID,
+				PollInterval:   5 * time.Second,
+				ExpiresAt:      time.Now().Add(time.Minute),
+			}
+			if tt.modify != nil {
+				tt.modify(&polled)
+			}
+
+			if !errors.Is(tt.expectedErr, models.ErrUnauthorizedClient) {
+				r.On("PollDeviceCode", mock.Anything, codeHash, mock.AnythingOfType("time.Time")).Return(&polled, nil)
+			}
+			if tt.expectSlowDown {
+				r.On("SetDevicePollInterval", mock.Anything, codeHash, 10*time.Second).Return(nil)
+			}
+			if tt.expectDelete {
+				r.On("DeleteDeviceCode", mock.Anything, codeHash).Return(nil)
+			}
+			if tt.expectedErr == nil {
+				sessions.On("NewSession", mock.Anything, "tv", userID.String(), "profile", "127.0.0.1", "test-agent").Return(&models.TokenSet{AccessToken: "access", RefreshToken: "refresh"}, nil)
+			}
+
+			s := newTestOAuthService(t, r, mocks.NewCredentialsRepo(t), sessions, mocks.NewClientTokenManager(t), mocks.NewBruteForceGuard(t))
+
+			tokens, err := s.ExchangeDeviceCode(context.Background(), models.ClientCredentials{ID: tt.clientID}, deviceCode, "127.0.0.1", "test-agent")
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("error = %v, expectedError %v", err, tt.expectedErr)
+			}
+
+			if err == nil && (tokens.AccessToken != "access" || tokens.RefreshToken != "refresh") {
+				t.Errorf("tokens = %+v, expected the session tokens", tokens)
+			}
+		})
+	}
+}
+
+func TestNewUserCode(t *testing.T) {
+	code, err := newUserCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(code) != userCodeLength || strings.Trim(code, userCodeAlphabet) != "" {
+		t.Errorf("code = %q, expected %d characters of %q", code, userCodeLength, userCodeAlphabet)
+	}
+
+	if normalizeUserCode(formatUserCode(code)) != code {
+		t.Errorf("formatted code %q does not normalize back to %q", formatUserCode(code), code)
+	}
+}
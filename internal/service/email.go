@@ -2,46 +2,485 @@ package service
 
 import (
 	"context"
+	"errors"
 	"medods-test-task/config"
+	"medods-test-task/internal/models"
+	"medods-test-task/pkg/email/render"
 	"medods-test-task/pkg/email/smtp"
 	"medods-test-task/pkg/logger"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
+const (
+	ipWarningEmail     = "ip_warning"
+	accountLockedEmail = "account_locked"
+	digestEmail        = "digest"
+
+	emailChangeConfirmEmail = "email_change_confirm"
+	emailChangeNoticeEmail  = "email_change_notice"
+
+	revokePath       = "/v1/auth/revoke"
+	confirmEmailPath = "/v1/auth/email/confirm"
+)
+
+//go:generate go run github.com/vektra/mockery/v2@latest --name GeoLocator
+type GeoLocator interface {
+	Locate(ip string) string
+}
+
+//go:generate go run github.com/vektra/mockery/v2@latest --name SMTPSender
 type SMTPSender interface {
 	Send(input smtp.SendEmailInput) error
 }
 
+//go:generate go run github.com/vektra/mockery/v2@latest --name EmailRenderer
+type EmailRenderer interface {
+	Render(name, locale string, data any) (*render.Email, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@latest --name OutboxRepo
+type OutboxRepo interface {
+	CreateOutboxEmail(ctx context.Context, email *models.OutboxEmail) error
+	ClaimOutboxEmails(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEmail, error)
+	MarkOutboxEmailSent(ctx context.Context, id uint, attempts int) error
+	RescheduleOutboxEmail(ctx context.Context, id uint, attempts int, nextAttemptAt time.Time, lastError string) error
+	FailOutboxEmail(ctx context.Context, id uint, attempts int, lastError string) error
+	HasRecentOutboxEmail(ctx context.Context, userID uuid.UUID, kind string, since time.Time) (bool, error)
+	CountRecentOutboxEmails(ctx context.Context, recipient string, since time.Time) (int, error)
+	LockOutboxRecipient(ctx context.Context, recipient string) error
+	CreateEmailSuppression(ctx context.Context, suppression *models.EmailSuppression) error
+	ClaimEmailSuppressions(ctx context.Context) ([]models.EmailSuppression, error)
+}
+
 type emailService struct {
-	sender      SMTPSender
-	emailConfig *config.EmailConfig
-	logger      logger.Logger
+	repo           OutboxRepo
+	sender         SMTPSender
+	renderer       EmailRenderer
+	geo            GeoLocator
+	transactor     Transactor
+	emailConfig    *config.EmailConfig
+	outboxConfig   *config.OutboxConfig
+	throttleConfig *config.ThrottleConfig
+	logger         logger.Logger
 }
 
-func NewEmailService(s SMTPSender, logger logger.Logger, emailConf *config.EmailConfig) *emailService {
+func NewEmailService(repo OutboxRepo, s SMTPSender, renderer EmailRenderer, geo GeoLocator, transactor Transactor, logger logger.Logger, emailConf *config.EmailConfig, outboxConf *config.OutboxConfig, throttleConf *config.ThrottleConfig) *emailService {
 	return &emailService{
-		sender:      s,
-		emailConfig: emailConf,
-		logger:      logger,
+		repo:           repo,
+		sender:         s,
+		renderer:       renderer,
+		geo:            geo,
+		transactor:     transactor,
+		emailConfig:    emailConf,
+		outboxConfig:   outboxConf,
+		throttleConfig: throttleConf,
+		logger:         logger,
+	}
+}
+
+// SendIPWarningEmail queues the warning in the outbox. When ctx carries a
+// transaction the email is only sent if that transaction commits.
+func (s *emailService) SendIPWarningEmail(ctx context.Context, user *models.User, alert models.SecurityAlert) error {
+	if !s.hasEmail(ctx, user, ipWarningEmail) {
+		return nil
+	}
+
+	data := struct {
+		OldIP     string
+		NewIP     string
+		UserAgent string
+		Location  string
+		Time      string
+		RevokeURL string
+	}{
+		OldIP:     alert.OldIP,
+		NewIP:     alert.NewIP,
+		UserAgent: alert.UserAgent,
+		Location:  s.geo.Locate(alert.NewIP),
+		Time:      alert.OccurredAt.UTC().Format(time.RFC1123),
+		RevokeURL: revokeURL(s.emailConfig.PublicURL, alert.RevokeToken),
+	}
+
+	return s.enqueue(ctx, user, ipWarningEmail, data)
+}
+
+// SendNotificationEmail queues the email of a security notification. The
+// template is named after the event, except for IP changes that keep the
+// original warning.
+func (s *emailService) SendNotificationEmail(ctx context.Context, n models.Notification) error {
+	if n.Event == models.NotificationIPChange {
+		return s.SendIPWarningEmail(ctx, n.User, n.Alert)
+	}
+
+	if !s.hasEmail(ctx, n.User, n.Event) {
+		return nil
+	}
+
+	data := struct {
+		IP        string
+		UserAgent string
+		Location  string
+		Time      string
+		RevokeURL string
+	}{
+		IP:        n.Alert.NewIP,
+		UserAgent: n.Alert.UserAgent,
+		Location:  s.geo.Locate(n.Alert.NewIP),
+		Time:      n.Alert.OccurredAt.UTC().Format(time.RFC1123),
+		RevokeURL: revokeURL(s.emailConfig.PublicURL, n.Alert.RevokeToken),
+	}
+
+	return s.enqueue(ctx, n.User, n.Event, data)
+}
+
+func (s *emailService) SendAccountLockedEmail(ctx context.Context, user *models.User, until time.Time) error {
+	if !s.hasEmail(ctx, user, accountLockedEmail) {
+		return nil
+	}
+
+	data := struct {
+		Until string
+	}{
+		Until: until.Format(time.RFC1123),
+	}
+
+	return s.enqueue(ctx, user, accountLockedEmail, data)
+}
+
+// SendEmailChangeConfirmation sends the confirmation link to the new
+// address. The caller chooses that address, so the link is deduplicated and
+// counted against the hourly cap of the recipient like any other email.
+func (s *emailService) SendEmailChangeConfirmation(ctx context.Context, user *models.User, newEmail, token string, expiresAt time.Time) error {
+	data := struct {
+		NewEmail   string
+		ConfirmURL string
+		ExpiresAt  string
+	}{
+		NewEmail:   newEmail,
+		ConfirmURL: strings.TrimSuffix(s.emailConfig.PublicURL, "/") + confirmEmailPath + "?token=" + url.QueryEscape(token),
+		ExpiresAt:  expiresAt.UTC().Format(time.RFC1123),
+	}
+
+	recipient := *user
+	recipient.Email = newEmail
+
+	return s.enqueue(ctx, &recipient, emailChangeConfirmEmail, data)
+}
+
+// SendEmailChangeNotice tells the current address that a change was
+// requested, with a "This wasn't me" link that also cancels it.
+func (s *emailService) SendEmailChangeNotice(ctx context.Context, user *models.User, newEmail string, alert models.SecurityAlert) error {
+	if !s.hasEmail(ctx, user, emailChangeNoticeEmail) {
+		return nil
+	}
+
+	data := struct {
+		NewEmail  string
+		IP        string
+		UserAgent string
+		Location  string
+		Time      string
+		RevokeURL string
+	}{
+		NewEmail:  newEmail,
+		IP:        alert.NewIP,
+		UserAgent: alert.UserAgent,
+		Location:  s.geo.Locate(alert.NewIP),
+		Time:      alert.OccurredAt.UTC().Format(time.RFC1123),
+		RevokeURL: revokeURL(s.emailConfig.PublicURL, alert.RevokeToken),
+	}
+
+	return s.store(ctx, user, emailChangeNoticeEmail, data)
+}
+
+func (s *emailService) hasEmail(ctx context.Context, user *models.User, name string) bool {
+	if user.Email == "" {
+		s.logger.Debug(ctx, "user has no email, message skipped", zap.String("email", name))
+
+		return false
+	}
+
+	return true
+}
+
+// enqueue stores the email in the outbox unless the user got the same
+// notification recently or the recipient reached the hourly cap. Suppressed
+// emails are recorded for the digest. The recipient is locked until the
+// transaction commits, so that concurrent notifications cannot both pass the
+// checks.
+func (s *emailService) enqueue(ctx context.Context, user *models.User, name string, data any) error {
+	if s.throttleConfig.DedupWindow <= 0 && s.throttleConfig.HourlyCap <= 0 {
+		return s.store(ctx, user, name, data)
+	}
+
+	return s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.LockOutboxRecipient(ctx, user.Email); err != nil {
+			return err
+		}
+
+		reason, err := s.throttle(ctx, user, name)
+		if err != nil {
+			return err
+		}
+
+		if reason == "" {
+			return s.store(ctx, user, name, data)
+		}
+
+		s.logger.Info(ctx, "email suppressed", zap.String("to", user.Email), zap.String("email", name), zap.String("reason", reason))
+
+		return s.repo.CreateEmailSuppression(ctx, &models.EmailSuppression{
+			UserID:    user.ID,
+			To:        user.Email,
+			Locale:    user.Locale,
+			Kind:      name,
+			Reason:    reason,
+			CreatedAt: time.Now(),
+		})
+	})
+}
+
+// throttle returns the reason to suppress the email, or an empty string when
+// it may be sent.
+func (s *emailService) throttle(ctx context.Context, user *models.User, name string) (string, error) {
+	now := time.Now()
+
+	if s.throttleConfig.DedupWindow > 0 && user.ID != uuid.Nil {
+		duplicate, err := s.repo.HasRecentOutboxEmail(ctx, user.ID, name, now.Add(-s.throttleConfig.DedupWindow))
+		if err != nil {
+			return "", err
+		}
+
+		if duplicate {
+			return models.EmailSuppressedDuplicate, nil
+		}
+	}
+
+	if s.throttleConfig.HourlyCap > 0 {
+		count, err := s.repo.CountRecentOutboxEmails(ctx, user.Email, now.Add(-time.Hour))
+		if err != nil {
+			return "", err
+		}
+
+		if count >= s.throttleConfig.HourlyCap {
+			return models.EmailSuppressedRateLimited, nil
+		}
 	}
+
+	return "", nil
+}
+
+// store renders the email in the user's locale and puts it in the outbox.
+func (s *emailService) store(ctx context.Context, user *models.User, name string, data any) error {
+	email, err := s.renderer.Render(name, user.Locale, data)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	err = s.repo.CreateOutboxEmail(ctx, &models.OutboxEmail{
+		UserID:        user.ID,
+		Kind:          name,
+		To:            user.Email,
+		Subject:       email.Subject,
+		Body:          email.HTML,
+		Text:          email.Text,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.Debug(ctx, "email queued", zap.String("to", user.Email), zap.String("email", name))
+
+	return nil
 }
 
-func (s *emailService) SendIPWarningEmail(ctx context.Context, email string) {
-	sendInput := smtp.SendEmailInput{Subject: s.emailConfig.IPWarningSubject, To: email}
+// revokeURL builds the "This wasn't me" link. It is empty when the
+// notification carries no revoke token.
+func revokeURL(publicURL, token string) string {
+	if token == "" {
+		return ""
+	}
+
+	return strings.TrimSuffix(publicURL, "/") + revokePath + "?token=" + url.QueryEscape(token)
+}
 
-	if err := sendInput.GenerateBodyFromHTML(s.emailConfig.IPWarningTemplate, nil); err != nil {
-		s.logger.Error(ctx, "failed generate body from html template", zap.Error(err))
+type digestItem struct {
+	Kind  string
+	Count int
+}
 
+// RunDigests summarizes suppressed emails once per digest interval until ctx
+// is cancelled.
+func (s *emailService) RunDigests(ctx context.Context) {
+	if s.throttleConfig.DigestInterval <= 0 {
 		return
 	}
 
-	err := s.sender.Send(sendInput)
+	ticker := time.NewTicker(s.throttleConfig.DigestInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.SendDigests(ctx); err != nil && ctx.Err() == nil {
+				s.logger.Error(ctx, "failed to send email digests", zap.Error(err))
+			}
+		}
+	}
+}
+
+// SendDigests queues one digest per recipient with suppressed emails. The
+// digest bypasses deduplication and the hourly cap.
+func (s *emailService) SendDigests(ctx context.Context) error {
+	return s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		suppressions, err := s.repo.ClaimEmailSuppressions(ctx)
+		if err != nil {
+			return err
+		}
+
+		byRecipient := make(map[string][]models.EmailSuppression)
+		for _, suppression := range suppressions {
+			byRecipient[suppression.To] = append(byRecipient[suppression.To], suppression)
+		}
+
+		for recipient, group := range byRecipient {
+			last := group[len(group)-1]
+			user := &models.User{ID: last.UserID, Email: recipient, Locale: last.Locale}
+
+			if err := s.store(ctx, user, digestEmail, newDigest(group)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func newDigest(suppressions []models.EmailSuppression) any {
+	counts := make(map[string]int)
+	since := suppressions[0].CreatedAt
+
+	for _, suppression := range suppressions {
+		counts[suppression.Kind]++
+
+		if suppression.CreatedAt.Before(since) {
+			since = suppression.CreatedAt
+		}
+	}
+
+	items := make([]digestItem, 0, len(counts))
+	for kind, count := range counts {
+		items = append(items, digestItem{Kind: kind, Count: count})
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].Kind < items[j].Kind })
+
+	return struct {
+		Total int
+		Since string
+		Items []digestItem
+	}{
+		Total: len(suppressions),
+		Since: since.UTC().Format(time.RFC1123),
+		Items: items,
+	}
+}
+
+// Run sends queued emails until ctx is cancelled. A batch that is already
+// claimed is sent to the end, so Run returns only after in-flight sends finish.
+func (s *emailService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.outboxConfig.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.SendDue(ctx); err != nil && ctx.Err() == nil {
+			s.logger.Error(ctx, "failed to send queued emails", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SendDue claims a batch of queued emails and sends them one by one. Sending
+// starts only within the first half of the lease, so that a send started
+// last still ends before another instance may claim the email. The emails
+// left over are sent once their lease runs out.
+func (s *emailService) SendDue(ctx context.Context) error {
+	emails, err := s.repo.ClaimOutboxEmails(ctx, s.outboxConfig.BatchSize, s.outboxConfig.Lease)
 	if err != nil {
-		s.logger.Error(ctx, "failed send verification email", zap.Error(err))
+		return err
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	deadline := time.Now().Add(s.outboxConfig.Lease / 2)
+
+	for i := range emails {
+		if time.Now().After(deadline) {
+			s.logger.Warn(ctx, "email batch outlasted its lease", zap.Int("left", len(emails)-i))
+
+			break
+		}
+
+		s.send(ctx, &emails[i])
+	}
+
+	return nil
+}
+
+func (s *emailService) send(ctx context.Context, email *models.OutboxEmail) {
+	email.Attempts++
+
+	err := s.sender.Send(smtp.SendEmailInput{To: email.To, Subject: email.Subject, Body: email.Body, Text: email.Text})
+	if err == nil {
+		if err := s.repo.MarkOutboxEmailSent(ctx, email.ID, email.Attempts); err != nil {
+			s.logger.Error(ctx, "failed to mark email sent", zap.Uint("email_id", email.ID), zap.Error(err))
+		}
+
+		s.logger.Debug(ctx, "email sent", zap.String("to", email.To), zap.String("subject", email.Subject))
 
 		return
 	}
 
-	s.logger.Debug(ctx, "warning message sent", zap.String("to", email))
+	email.LastError = err.Error()
+
+	if email.Attempts >= s.outboxConfig.MaxAttempts || errors.Is(err, models.ErrSMTPRecipient) {
+		s.logger.Error(ctx, "email dropped", zap.Uint("email_id", email.ID), zap.Error(err))
+
+		if err := s.repo.FailOutboxEmail(ctx, email.ID, email.Attempts, email.LastError); err != nil {
+			s.logger.Error(ctx, "failed to mark email failed", zap.Uint("email_id", email.ID), zap.Error(err))
+		}
+
+		return
+	}
+
+	next := time.Now().Add(retryDelay(s.outboxConfig, email.Attempts))
+	if err := s.repo.RescheduleOutboxEmail(ctx, email.ID, email.Attempts, next, email.LastError); err != nil {
+		s.logger.Error(ctx, "failed to reschedule email", zap.Uint("email_id", email.ID), zap.Error(err))
+	}
+}
+
+// retryDelay doubles the delay after every failed attempt.
+func retryDelay(cfg *config.OutboxConfig, attempts int) time.Duration {
+	delay := cfg.BaseDelay
+	for i := 1; i < attempts && delay < cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, cfg.MaxDelay)
 }
@@ -0,0 +1,312 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"medods-test-task/config"
+	"medods-test-task/internal/models"
+	"medods-test-task/internal/service/mocks"
+	"medods-test-task/pkg/email/render"
+	"medods-test-task/pkg/email/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestEmailService_SendDue(t *testing.T) {
+	errSMTP := errors.New("smtp unavailable")
+
+	cfg := &config.OutboxConfig{
+		MaxAttempts: 3,
+		BaseDelay:   time.Second,
+		MaxDelay:    time.Minute,
+		Lease:       time.Minute,
+		BatchSize:   10,
+	}
+
+	tests := []struct {
+		name      string
+		attempts  int
+		sendErr   error
+		cancelled bool
+		repoMock  func(r *mocks.OutboxRepo)
+	}{
+		{
+			name:     "Sent",
+			attempts: 0,
+			sendErr:  nil,
+			repoMock: func(r *mocks.OutboxRepo) {
+				r.On("MarkOutboxEmailSent", mock.Anything, uint(1), 1).Return(nil)
+			},
+		},
+		{
+			name:      "Sent after shutdown started",
+			attempts:  0,
+			sendErr:   nil,
+			cancelled: true,
+			repoMock: func(r *mocks.OutboxRepo) {
+				r.On("MarkOutboxEmailSent", mock.MatchedBy(func(ctx context.Context) bool {
+					return ctx.Err() == nil
+				}), uint(1), 1).Return(nil)
+			},
+		},
+		{
+			name:     "Retried with backoff",
+			attempts: 1,
+			sendErr:  errSMTP,
+			repoMock: func(r *mocks.OutboxRepo) {
+				r.On("RescheduleOutboxEmail", mock.Anything, uint(1), 2, mock.MatchedBy(func(next time.Time) bool {
+					return next.After(time.Now().Add(time.Second))
+				}), errSMTP.Error()).Return(nil)
+			},
+		},
+		{
+			name:     "Failed after max attempts",
+			attempts: 2,
+			sendErr:  errSMTP,
+			repoMock: func(r *mocks.OutboxRepo) {
+				r.On("FailOutboxEmail", mock.Anything, uint(1), 3, errSMTP.Error()).Return(nil)
+			},
+		},
+		{
+			name:     "Recipient rejected",
+			attempts: 0,
+			sendErr:  models.ErrSMTPRecipient,
+			repoMock: func(r *mocks.OutboxRepo) {
+				r.On("FailOutboxEmail", mock.Anything, uint(1), 1, models.ErrSMTPRecipient.Error()).Return(nil)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email := models.OutboxEmail{
+				ID:       1,
+				To:       "test@email.com",
+				Subject:  "subject",
+				Body:     "<p>body</p>",
+				Attempts: tt.attempts,
+			}
+
+			r := mocks.NewOutboxRepo(t)
+			r.On("ClaimOutboxEmails", mock.Anything, cfg.BatchSize, cfg.Lease).Return([]models.OutboxEmail{email}, nil)
+			tt.repoMock(r)
+
+			sender := mocks.NewSMTPSender(t)
+			sender.On("Send", smtp.SendEmailInput{To: email.To, Subject: email.Subject, Body: email.Body}).Return(tt.sendErr)
+
+			s := NewEmailService(r, sender, mocks.NewEmailRenderer(t), mocks.NewGeoLocator(t), nopTransactor{}, nopLogger{}, &config.EmailConfig{}, cfg, &config.ThrottleConfig{})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.cancelled {
+				cancel()
+			}
+			defer cancel()
+
+			if err := s.SendDue(ctx); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestEmailService_SendDue_LeaseOutlasted(t *testing.T) {
+	// A lease already half gone leaves no time to send, so the claimed email
+	// is left for the next claim once its lease runs out.
+	cfg := &config.OutboxConfig{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute, Lease: -time.Second, BatchSize: 10}
+
+	r := mocks.NewOutboxRepo(t)
+	r.On("ClaimOutboxEmails", mock.Anything, cfg.BatchSize, cfg.Lease).Return([]models.OutboxEmail{{ID: 1, To: "test@email.com"}}, nil)
+
+	s := NewEmailService(r, mocks.NewSMTPSender(t), mocks.NewEmailRenderer(t), mocks.NewGeoLocator(t), nopTransactor{}, nopLogger{}, &config.EmailConfig{}, cfg, &config.ThrottleConfig{})
+
+	if err := s.SendDue(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestEmailService_SendIPWarningEmail(t *testing.T) {
+	rendered := &render.Email{Subject: "Warning", HTML: "<p>warning</p>", Text: "warning"}
+	alert := models.SecurityAlert{
+		OldIP:       "10.0.0.1",
+		NewIP:       "10.1.0.1",
+		UserAgent:   "test-agent",
+		OccurredAt:  time.Date(2025, time.January, 2, 15, 4, 5, 0, time.UTC),
+		RevokeToken: "revoke+token",
+	}
+
+	tests := []struct {
+		name     string
+		user     *models.User
+		mockRepo bool
+	}{
+		{
+			name:     "Queued in user locale",
+			user:     &models.User{Email: "test@email.com", Locale: "en"},
+			mockRepo: true,
+		},
+		{
+			name:     "User without email",
+			user:     &models.User{Locale: "en"},
+			mockRepo: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mocks.NewOutboxRepo(t)
+			renderer := mocks.NewEmailRenderer(t)
+			geo := mocks.NewGeoLocator(t)
+
+			if tt.mockRepo {
+				geo.On("Locate", alert.NewIP).Return("Moscow, Russia")
+				renderer.On("Render", ipWarningEmail, tt.user.Locale, mock.MatchedBy(func(data any) bool {
+					s := fmt.Sprintf("%+v", data)
+					for _, field := range []string{
+						"OldIP:10.0.0.1",
+						"NewIP:10.1.0.1",
+						"UserAgent:test-agent",
+						"Location:Moscow, Russia",
+						"Time:Thu, 02 Jan 2025 15:04:05 UTC",
+						"RevokeURL:https://auth.example.com/v1/auth/revoke?token=revoke%2Btoken",
+					} {
+						if !strings.Contains(s, field) {
+							return false
+						}
+					}
+
+					return true
+				})).Return(rendered, nil)
+				r.On("CreateOutboxEmail", mock.Anything, mock.MatchedBy(func(e *models.OutboxEmail) bool {
+					return e.To == tt.user.Email && e.Subject == rendered.Subject && e.Body == rendered.HTML && e.Text == rendered.Text
+				})).Return(nil)
+			}
+
+			s := NewEmailService(r, mocks.NewSMTPSender(t), renderer, geo, nopTransactor{}, nopLogger{}, &config.EmailConfig{PublicURL: "https://auth.example.com/"}, &config.OutboxConfig{}, &config.ThrottleConfig{})
+
+			if err := s.SendIPWarningEmail(context.Background(), tt.user, alert); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestEmailService_Throttle(t *testing.T) {
+	user := &models.User{ID: uuid.New(), Email: "test@email.com", Locale: "en"}
+	until := time.Date(2025, time.January, 2, 15, 4, 5, 0, time.UTC)
+	rendered := &render.Email{Subject: "Locked", HTML: "<p>locked</p>", Text: "locked"}
+
+	cfg := &config.ThrottleConfig{
+		DedupWindow: 15 * time.Minute,
+		HourlyCap:   5,
+	}
+
+	tests := []struct {
+		name     string
+		repoMock func(r *mocks.OutboxRepo, renderer *mocks.EmailRenderer)
+	}{
+		{
+			name: "Sent",
+			repoMock: func(r *mocks.OutboxRepo, renderer *mocks.EmailRenderer) {
+				r.On("HasRecentOutboxEmail", mock.Anything, user.ID, accountLockedEmail, mock.Anything).Return(false, nil)
+				r.On("CountRecentOutboxEmails", mock.Anything, user.Email, mock.Anything).Return(4, nil)
+				renderer.On("Render", accountLockedEmail, user.Locale, mock.Anything).Return(rendered, nil)
+				r.On("CreateOutboxEmail", mock.Anything, mock.MatchedBy(func(e *models.OutboxEmail) bool {
+					return e.UserID == user.ID && e.Kind == accountLockedEmail && e.To == user.Email
+				})).Return(nil)
+			},
+		},
+		{
+			name: "Duplicate",
+			repoMock: func(r *mocks.OutboxRepo, renderer *mocks.EmailRenderer) {
+				r.On("HasRecentOutboxEmail", mock.Anything, user.ID, accountLockedEmail, mock.MatchedBy(func(since time.Time) bool {
+					return since.Before(time.Now().Add(-14 * time.Minute))
+				})).Return(true, nil)
+				r.On("CreateEmailSuppression", mock.Anything, mock.MatchedBy(func(e *models.EmailSuppression) bool {
+					return e.UserID == user.ID && e.Kind == accountLockedEmail && e.Reason == models.EmailSuppressedDuplicate
+				})).Return(nil)
+			},
+		},
+		{
+			name: "Hourly cap reached",
+			repoMock: func(r *mocks.OutboxRepo, renderer *mocks.EmailRenderer) {
+				r.On("HasRecentOutboxEmail", mock.Anything, user.ID, accountLockedEmail, mock.Anything).Return(false, nil)
+				r.On("CountRecentOutboxEmails", mock.Anything, user.Email, mock.Anything).Return(5, nil)
+				r.On("CreateEmailSuppression", mock.Anything, mock.MatchedBy(func(e *models.EmailSuppression) bool {
+					return e.To == user.Email && e.Locale == user.Locale && e.Reason == models.EmailSuppressedRateLimited
+				})).Return(nil)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mocks.NewOutboxRepo(t)
+			r.On("LockOutboxRecipient", mock.Anything, user.Email).Return(nil)
+			renderer := mocks.NewEmailRenderer(t)
+			tt.repoMock(r, renderer)
+
+			s := NewEmailService(r, mocks.NewSMTPSender(t), renderer, mocks.NewGeoLocator(t), nopTransactor{}, nopLogger{}, &config.EmailConfig{}, &config.OutboxConfig{}, cfg)
+
+			if err := s.SendAccountLockedEmail(context.Background(), user, until); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestEmailService_SendEmailChangeConfirmation_HourlyCap(t *testing.T) {
+	user := &models.User{ID: uuid.New(), Email: "test@email.com", Locale: "en"}
+	cfg := &config.ThrottleConfig{HourlyCap: 5}
+
+	// The cap is counted on the new address, which the caller chose.
+	r := mocks.NewOutboxRepo(t)
+	r.On("LockOutboxRecipient", mock.Anything, "victim@email.com").Return(nil)
+	r.On("CountRecentOutboxEmails", mock.Anything, "victim@email.com", mock.Anything).Return(5, nil)
+	r.On("CreateEmailSuppression", mock.Anything, mock.MatchedBy(func(e *models.EmailSuppression) bool {
+		return e.To == "victim@email.com" && e.Kind == emailChangeConfirmEmail && e.Reason == models.EmailSuppressedRateLimited
+	})).Return(nil)
+
+	s := NewEmailService(r, mocks.NewSMTPSender(t), mocks.NewEmailRenderer(t), mocks.NewGeoLocator(t), nopTransactor{}, nopLogger{}, &config.EmailConfig{}, &config.OutboxConfig{}, cfg)
+
+	if err := s.SendEmailChangeConfirmation(context.Background(), user, "victim@email.com", "token", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestEmailService_SendDigests(t *testing.T) {
+	firstID, secondID := uuid.New(), uuid.New()
+	since := time.Date(2025, time.January, 2, 15, 4, 5, 0, time.UTC)
+	rendered := &render.Email{Subject: "Digest", HTML: "<p>digest</p>", Text: "digest"}
+
+	r := mocks.NewOutboxRepo(t)
+	r.On("ClaimEmailSuppressions", mock.Anything).Return([]models.EmailSuppression{
+		{UserID: firstID, To: "first@email.com", Locale: "en", Kind: ipWarningEmail, CreatedAt: since},
+		{UserID: firstID, To: "first@email.com", Locale: "en", Kind: ipWarningEmail, CreatedAt: since.Add(time.Minute)},
+		{UserID: firstID, To: "first@email.com", Locale: "en", Kind: accountLockedEmail, CreatedAt: since.Add(time.Minute)},
+		{UserID: secondID, To: "second@email.com", Locale: "ru", Kind: ipWarningEmail, CreatedAt: since},
+	}, nil)
+	r.On("CreateOutboxEmail", mock.Anything, mock.MatchedBy(func(e *models.OutboxEmail) bool {
+		return e.Kind == digestEmail &&
+			(e.To == "first@email.com" && e.UserID == firstID || e.To == "second@email.com" && e.UserID == secondID)
+	})).Return(nil).Twice()
+
+	renderer := mocks.NewEmailRenderer(t)
+	renderer.On("Render", digestEmail, "en", mock.MatchedBy(func(data any) bool {
+		s := fmt.Sprintf("%+v", data)
+
+		return strings.Contains(s, "Total:3") &&
+			strings.Contains(s, "Since:Thu, 02 Jan 2025 15:04:05 UTC") &&
+			strings.Contains(s, "Items:[{Kind:account_locked Count:1} {Kind:ip_warning Count:2}]")
+	})).Return(rendered, nil)
+	renderer.On("Render", digestEmail, "ru", mock.MatchedBy(func(data any) bool {
+		return strings.Contains(fmt.Sprintf("%+v", data), "Total:1")
+	})).Return(rendered, nil)
+
+	s := NewEmailService(r, mocks.NewSMTPSender(t), renderer, mocks.NewGeoLocator(t), nopTransactor{}, nopLogger{}, &config.EmailConfig{}, &config.OutboxConfig{}, &config.ThrottleConfig{HourlyCap: 1})
+
+	if err := s.SendDigests(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"medods-test-task/internal/models"
+	"medods-test-task/pkg/utils"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ExchangeToken implements the token exchange grant of RFC 8693. A client
+// exchanges the access token of a user for a token meant for another audience
+// with the same or a narrower scope, and becomes the actor of the new token.
+// With a requested subject the token of an admin is exchanged for a token of
+// that user instead, see impersonate. No refresh token is issued.
+func (s *oauthService) ExchangeToken(ctx context.Context, creds models.ClientCredentials, req models.TokenExchangeRequest, IPAddress, userAgent string) (*models.TokenSet, error) {
+	client, err := s.authenticateClient(ctx, creds)
+	if err != nil {
+		return nil, err
+	}
+
+	if !client.IsConfidential() || !client.AllowsGrant(models.GrantTypeTokenExchange) {
+		return nil, models.ErrUnauthorizedClient
+	}
+
+	if req.SubjectTokenType != models.TokenTypeAccessToken && req.SubjectTokenType != models.TokenTypeJWT {
+		return nil, models.ErrUnsupportedTokenType
+	}
+
+	if req.RequestedTokenType != "" && req.RequestedTokenType != models.TokenTypeAccessToken {
+		return nil, models.ErrUnsupportedTokenType
+	}
+
+	if !slices.Contains(s.oauthConfig.ExchangeAudiences, req.Audience) {
+		return nil, models.ErrInvalidTarget
+	}
+
+	subject, err := s.tokens.ParseJWT(req.SubjectToken)
+	if err != nil || subject.IsClient() {
+		return nil, models.ErrInvalidGrant
+	}
+
+	if req.RequestedSubject != "" {
+		return s.impersonate(ctx, client, subject, req, IPAddress, userAgent)
+	}
+
+	scope, err := narrowScope(exchangeScopes(client, subject, strings.Fields(subject.Scope)), req.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := *subject
+	claims.Actor = &utils.Actor{Subject: client.ID, ClientID: client.ID, Actor: subject.Actor}
+
+	return s.issueExchangedToken(client, claims, subject, req.Audience, scope)
+}
+
+// impersonate issues a token of the requested user to the admin owning the
+// subject token. The token names the admin as its actor and is marked as
+// impersonated, and every attempt is audited. The token is returned only once
+// its audit event is stored, so that no impersonation goes unrecorded.
+func (s *oauthService) impersonate(ctx context.Context, client *models.OAuthClient, subject *utils.Claims, req models.TokenExchangeRequest, IPAddress, userAgent string) (*models.TokenSet, error) {
+	event := models.AuthEvent{
+		Type:      models.AuthEventImpersonation,
+		Outcome:   models.AuthOutcomeSuccess,
+		ActorID:   subject.UserID,
+		IP:        IPAddress,
+		UserAgent: userAgent,
+	}
+
+	tokens, err := s.impersonationToken(ctx, client, subject, req, &event)
+	if err != nil {
+		event.Outcome = models.AuthOutcomeFailure
+		event.Reason = reasonCode(err)
+		s.auditLog.Record(ctx, event)
+
+		return nil, err
+	}
+
+	if err := s.auditLog.Write(ctx, event); err != nil {
+		return nil, fmt.Errorf("failed to audit impersonation: %w", err)
+	}
+
+	return tokens, nil
+}
+
+func (s *oauthService) impersonationToken(ctx context.Context, client *models.OAuthClient, subject *utils.Claims, req models.TokenExchangeRequest, event *models.AuthEvent) (*models.TokenSet, error) {
+	// Only the own token of an admin may be used, not one they already act
+	// with on behalf of someone else.
+	if subject.Actor != nil {
+		return nil, models.ErrImpersonationForbidden
+	}
+
+	admin, err := s.users.GetUserByID(ctx, subject.UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrUserNotFound) {
+			return nil, models.ErrInvalidGrant
+		}
+
+		return nil, err
+	}
+
+	// The token must carry the role too: tokens of the legacy login and those
+	// issued before the role was granted do not.
+	if !slices.Contains(admin.Roles, models.RoleAdmin) || !subject.HasRole(models.RoleAdmin) {
+		return nil, models.ErrImpersonationForbidden
+	}
+
+	userID, err := uuid.Parse(req.RequestedSubject)
+	if err != nil {
+		return nil, models.ErrUnknownSubject
+	}
+
+	event.UserID = userID
+
+	user, err := s.users.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrUserNotFound) {
+			return nil, models.ErrUnknownSubject
+		}
+
+		return nil, err
+	}
+
+	scope, err := narrowScope(exchangeScopes(client, subject, s.roles.UserScopes(user.Roles)), req.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := *subject
+	claims.UserID = user.ID
+	claims.Roles = userRoles(user.Roles)
+	claims.Actor = &utils.Actor{Subject: admin.ID.String()}
+	claims.Impersonated = true
+
+	return s.issueExchangedToken(client, claims, subject, req.Audience, scope)
+}
+
+// issueExchangedToken signs claims derived from the subject token. The new
+// token does not outlive the subject token.
+func (s *oauthService) issueExchangedToken(client *models.OAuthClient, claims utils.Claims, subject *utils.Claims, audience string, scope []string) (*models.TokenSet, error) {
+	ttl := s.oauthConfig.ExchangeTokenTTL
+	if client.AccessTokenTTL != 0 {
+		ttl = client.AccessTokenTTL
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+
+	if subjectExpiresAt := time.Unix(subject.ExpiresAt, 0); subjectExpiresAt.Before(expiresAt) {
+		expiresAt = subjectExpiresAt
+	}
+
+	claims.ClientID = client.ID
+	claims.Scope = strings.Join(scope, " ")
+	claims.Id = uuid.NewString()
+	claims.Audience = audience
+	claims.IssuedAt = now.Unix()
+	claims.ExpiresAt = expiresAt.Unix()
+
+	token, err := s.tokens.SignToken(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TokenSet{
+		AccessToken:     token,
+		AccessTTL:       expiresAt.Sub(now).Truncate(time.Second),
+		Scope:           scope,
+		IssuedTokenType: models.TokenTypeAccessToken,
+	}, nil
+}
+
+// exchangeScopes limits the scopes an exchanged token may get to those the
+// client may request and the subject token carries.
+func exchangeScopes(client *models.OAuthClient, subject *utils.Claims, scopes []string) []string {
+	return intersectScopes(intersectScopes(scopes, client.Scopes), strings.Fields(subject.Scope))
+}
+
+// narrowScope returns the requested scopes, which must all be granted, or the
+// granted scopes when none are requested.
+func narrowScope(granted []string, scope string) ([]string, error) {
+	requested := strings.Fields(scope)
+	if len(requested) == 0 {
+		return nonNil(granted), nil
+	}
+
+	for _, item := range requested {
+		if !slices.Contains(granted, item) {
+			return nil, models.ErrScopeNotGranted
+		}
+	}
+
+	return intersectScopes(requested, granted), nil
+}
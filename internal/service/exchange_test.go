@@ -0,0 +1,401 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"medods-test-task/internal/models"
+	"medods-test-task/internal/service/mocks"
+	"medods-test-task/pkg/utils"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+const testAudience = "https://api.medods.ru"
+
+func TestOAuthService_ExchangeToken(t *testing.T) {
+	userID := uuid.New()
+
+	subjectClaims := func() *utils.Claims {
+		return &utils.Claims{
+			UserID:  userID,
+			Subject: "access",
+			Roles:   []string{models.RoleUser},
+			Scope:   "profile sessions:read",
+			StandardClaims: jwt.StandardClaims{
+				ExpiresAt: time.Now().Add(time.Hour).Unix(),
+			},
+		}
+	}
+
+	valid := models.TokenExchangeRequest{
+		SubjectToken:     "subject",
+		SubjectTokenType: models.TokenTypeAccessToken,
+		Audience:         testAudience,
+	}
+
+	tests := []struct {
+		name          string
+		clientID      string
+		modify        func(req *models.TokenExchangeRequest)
+		subject       func(claims *utils.Claims)
+		subjectErr    error
+		expectedScope []string
+		expectedActor *utils.Actor
+		expectedTTL   time.Duration
+		expectedErr   error
+	}{
+		{
+			name:          "OK",
+			clientID:      "gateway",
+			expectedScope: []string{"profile", "sessions:read"},
+			expectedActor: &utils.Actor{Subject: "gateway", ClientID: "gateway"},
+			expectedTTL:   5 * time.Minute,
+		},
+		{
+			name:          "Reduced scope",
+			clientID:      "gateway",
+			modify:        func(req *models.TokenExchangeRequest) { req.Scope = "profile" },
+			expectedScope: []string{"profile"},
+			expectedActor: &utils.Actor{Subject: "gateway", ClientID: "gateway"},
+			expectedTTL:   5 * time.Minute,
+		},
+		{
+			name:          "Delegation chain",
+			clientID:      "gateway",
+			subject:       func(claims *utils.Claims) { claims.Actor = &utils.Actor{Subject: "edge", ClientID: "edge"} },
+			expectedScope: []string{"profile", "sessions:read"},
+			expectedActor: &utils.Actor{Subject: "gateway", ClientID: "gateway", Actor: &utils.Actor{Subject: "edge", ClientID: "edge"}},
+			expectedTTL:   5 * time.Minute,
+		},
+		{
+			name:     "Subject token expires first",
+			clientID: "gateway",
+			subject: func(claims *utils.Claims) {
+				claims.ExpiresAt = time.Now().Add(time.Minute).Unix()
+			},
+			expectedScope: []string{"profile", "sessions:read"},
+			expectedActor: &utils.Actor{Subject: "gateway", ClientID: "gateway"},
+			expectedTTL:   time.Minute,
+		},
+		{
+			name:          "Scope beyond the client",
+			clientID:      "gateway",
+			subject:       func(claims *utils.Claims) { claims.Scope = "profile sessions:read sessions:admin" },
+			expectedScope: []string{"profile", "sessions:read"},
+			expectedActor: &utils.Actor{Subject: "gateway", ClientID: "gateway"},
+			expectedTTL:   5 * time.Minute,
+		},
+		{
+			name:        "Requested scope beyond the client",
+			clientID:    "gateway",
+			subject:     func(claims *utils.Claims) { claims.Scope = "profile sessions:admin" },
+			modify:      func(req *models.TokenExchangeRequest) { req.Scope = "sessions:admin" },
+			expectedErr: models.ErrScopeNotGranted,
+		},
+		{
+			name:        "Scope beyond subject token",
+			clientID:    "gateway",
+			modify:      func(req *models.TokenExchangeRequest) { req.Scope = "profile sessions:admin" },
+			expectedErr: models.ErrScopeNotGranted,
+		},
+		{
+			name:        "Audience not allowed",
+			clientID:    "gateway",
+			modify:      func(req *models.TokenExchangeRequest) { req.Audience = "https://evil.example.com" },
+			expectedErr: models.ErrInvalidTarget,
+		},
+		{
+			name:     "Refresh token",
+			clientID: "gateway",
+			modify: func(req *models.TokenExchangeRequest) {
+				req.SubjectTokenType = "urn:ietf:params:oauth:token-type:refresh_token"
+			},
+			expectedErr: models.ErrUnsupportedTokenType,
+		},
+		{
+			name:        "Invalid subject token",
+			clientID:    "gateway",
+			subjectErr:  models.ErrTokenExpired,
+			expectedErr: models.ErrInvalidGrant,
+		},
+		{
+			name:     "Client token",
+			clientID: "gateway",
+			subject: func(claims *utils.Claims) {
+				claims.UserID = uuid.Nil
+				claims.ClientID = "worker"
+			},
+			expectedErr: models.ErrInvalidGrant,
+		},
+		{
+			name:        "Grant type not allowed",
+			clientID:    "backend",
+			expectedErr: models.ErrUnauthorizedClient,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid
+			if tt.modify != nil {
+				tt.modify(&req)
+			}
+
+			subject := subjectClaims()
+			if tt.subject != nil {
+				tt.subject(subject)
+			}
+
+			tokens := mocks.NewClientTokenManager(t)
+			if !errors.Is(tt.expectedErr, models.ErrUnauthorizedClient) && !errors.Is(tt.expectedErr, models.ErrInvalidTarget) &&
+				!errors.Is(tt.expectedErr, models.ErrUnsupportedTokenType) {
+				if tt.subjectErr != nil {
+					tokens.On("ParseJWT", "subject").Return(nil, tt.subjectErr)
+				} else {
+					tokens.On("ParseJWT", "subject").Return(subject, nil)
+				}
+			}
+
+			var signed utils.Claims
+			if tt.expectedErr == nil {
+				tokens.On("SignToken", mock.Anything).Run(func(args mock.Arguments) { signed = args.Get(0).(utils.Claims) }).Return("exchanged", nil)
+			}
+
+			s := newTestOAuthService(t, mocks.NewOAuthRepo(t), mocks.NewCredentialsRepo(t), mocks.NewSessionIssuer(t), tokens, mocks.NewBruteForceGuard(t))
+
+			creds := models.ClientCredentials{ID: tt.clientID, Secret: "cs_secret"}
+
+			set, err := s.ExchangeToken(context.Background(), creds, req, "127.0.0.1", "test-agent")
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("error = %v, expectedError %v", err, tt.expectedErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if set.AccessToken != "exchanged" || set.RefreshToken != "" || set.IssuedTokenType != models.TokenTypeAccessToken {
+				t.Errorf("tokens = %+v, expected only an access token", set)
+			}
+
+			if !reflect.DeepEqual(set.Scope, tt.expectedScope) {
+				t.Errorf("scope = %v, expected %v", set.Scope, tt.expectedScope)
+			}
+
+			if set.AccessTTL < tt.expectedTTL-time.Second || set.AccessTTL > tt.expectedTTL {
+				t.Errorf("ttl = %v, expected %v", set.AccessTTL, tt.expectedTTL)
+			}
+
+			if signed.UserID != userID || signed.Audience != testAudience || signed.ClientID != tt.clientID ||
+				signed.Impersonated || signed.Id == "" {
+				t.Errorf("claims = %+v, expected a delegated token of the user", signed)
+			}
+
+			if !reflect.DeepEqual(signed.Actor, tt.expectedActor) {
+				t.Errorf("actor = %+v, expected %+v", signed.Actor, tt.expectedActor)
+			}
+		})
+	}
+}
+
+func TestOAuthService_ExchangeToken_Impersonation(t *testing.T) {
+	adminID := uuid.New()
+	userID := uuid.New()
+	errAudit := errors.New("audit log is unavailable")
+
+	tests := []struct {
+		name           string
+		adminRoles     []string
+		tokenRoles     []string
+		adminScope     string
+		subjectActor   *utils.Actor
+		requested      string
+		scope          string
+		userErr        error
+		auditErr       error
+		expectedScope  []string
+		expectedReason string
+		expectedErr    error
+	}{
+		{
+			name:          "OK",
+			adminRoles:    []string{models.RoleAdmin},
+			requested:     userID.String(),
+			expectedScope: []string{"profile", "sessions:read"},
+		},
+		{
+			name:          "Reduced scope",
+			adminRoles:    []string{models.RoleAdmin},
+			requested:     userID.String(),
+			scope:         "profile",
+			expectedScope: []string{"profile"},
+		},
+		{
+			name:          "Scope beyond the admin token",
+			adminRoles:    []string{models.RoleAdmin},
+			adminScope:    "profile sessions:admin",
+			requested:     userID.String(),
+			expectedScope: []string{"profile"},
+		},
+		{
+			name:        "Audit write fails",
+			adminRoles:  []string{models.RoleAdmin},
+			requested:   userID.String(),
+			auditErr:    errAudit,
+			expectedErr: errAudit,
+		},
+		{
+			name:           "Scope beyond the user",
+			adminRoles:     []string{models.RoleAdmin},
+			requested:      userID.String(),
+			scope:          "sessions:admin",
+			expectedReason: models.AuthReasonInvalidScope,
+			expectedErr:    models.ErrScopeNotGranted,
+		},
+		{
+			name:           "Not an admin",
+			requested:      userID.String(),
+			expectedReason: models.AuthReasonForbidden,
+			expectedErr:    models.ErrImpersonationForbidden,
+		},
+		{
+			name:           "Admin with a token of the user role",
+			adminRoles:     []string{models.RoleAdmin},
+			tokenRoles:     []string{models.RoleUser},
+			requested:      userID.String(),
+			expectedReason: models.AuthReasonForbidden,
+			expectedErr:    models.ErrImpersonationForbidden,
+		},
+		{
+			name:           "Delegated admin token",
+			adminRoles:     []string{models.RoleAdmin},
+			subjectActor:   &utils.Actor{Subject: "gateway", ClientID: "gateway"},
+			requested:      userID.String(),
+			expectedReason: models.AuthReasonForbidden,
+			expectedErr:    models.ErrImpersonationForbidden,
+		},
+		{
+			name:           "Unknown user",
+			adminRoles:     []string{models.RoleAdmin},
+			requested:      userID.String(),
+			userErr:        models.ErrUserNotFound,
+			expectedReason: models.AuthReasonInvalidUserID,
+			expectedErr:    models.ErrUnknownSubject,
+		},
+		{
+			name:           "Invalid user id",
+			adminRoles:     []string{models.RoleAdmin},
+			requested:      "not-a-uuid",
+			expectedReason: models.AuthReasonInvalidUserID,
+			expectedErr:    models.ErrUnknownSubject,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adminScope := tt.adminScope
+			if adminScope == "" {
+				adminScope = "profile sessions:read sessions:admin"
+			}
+
+			tokenRoles := tt.tokenRoles
+			if tokenRoles == nil {
+				tokenRoles = userRoles(tt.adminRoles)
+			}
+
+			subject := &utils.Claims{
+				UserID:  adminID,
+				Subject: "access",
+				Roles:   tokenRoles,
+				Scope:   adminScope,
+				Actor:   tt.subjectActor,
+				StandardClaims: jwt.StandardClaims{
+					ExpiresAt: time.Now().Add(time.Hour).Unix(),
+				},
+			}
+
+			tokens := mocks.NewClientTokenManager(t)
+			tokens.On("ParseJWT", "admin").Return(subject, nil)
+
+			issued := tt.expectedErr == nil || tt.auditErr != nil
+
+			var signed utils.Claims
+			if issued {
+				tokens.On("SignToken", mock.Anything).Run(func(args mock.Arguments) { signed = args.Get(0).(utils.Claims) }).Return("impersonation", nil)
+			}
+
+			users := mocks.NewCredentialsRepo(t)
+			if tt.subjectActor == nil {
+				users.On("GetUserByID", mock.Anything, adminID).Return(&models.User{ID: adminID, Roles: tt.adminRoles}, nil)
+			}
+			if tt.expectedReason != models.AuthReasonForbidden && tt.requested == userID.String() {
+				if tt.userErr != nil {
+					users.On("GetUserByID", mock.Anything, userID).Return(nil, tt.userErr)
+				} else {
+					users.On("GetUserByID", mock.Anything, userID).Return(&models.User{ID: userID}, nil)
+				}
+			}
+
+			expectedUserID := userID
+			if tt.requested != userID.String() || tt.expectedReason == models.AuthReasonForbidden {
+				expectedUserID = uuid.Nil
+			}
+
+			expectedEvent := models.AuthEvent{
+				Type:      models.AuthEventImpersonation,
+				Outcome:   models.AuthOutcomeSuccess,
+				UserID:    expectedUserID,
+				ActorID:   adminID,
+				IP:        "127.0.0.1",
+				UserAgent: "test-agent",
+			}
+			a := mocks.NewAuditLogger(t)
+			if issued {
+				a.On("Write", mock.Anything, expectedEvent).Return(tt.auditErr).Once()
+			} else {
+				expectedEvent.Outcome = models.AuthOutcomeFailure
+				expectedEvent.Reason = tt.expectedReason
+				a.On("Record", mock.Anything, expectedEvent).Once()
+			}
+
+			s := newTestOAuthService(t, mocks.NewOAuthRepo(t), users, mocks.NewSessionIssuer(t), tokens, mocks.NewBruteForceGuard(t))
+			s.auditLog = a
+
+			req := models.TokenExchangeRequest{
+				SubjectToken:     "admin",
+				SubjectTokenType: models.TokenTypeAccessToken,
+				RequestedSubject: tt.requested,
+				Audience:         testAudience,
+				Scope:            tt.scope,
+			}
+
+			set, err := s.ExchangeToken(context.Background(), models.ClientCredentials{ID: "gateway", Secret: "cs_secret"}, req, "127.0.0.1", "test-agent")
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("error = %v, expectedError %v", err, tt.expectedErr)
+			}
+
+			if err != nil {
+				if set != nil {
+					t.Errorf("tokens = %+v, expected none", set)
+				}
+
+				return
+			}
+
+			if !reflect.DeepEqual(set.Scope, tt.expectedScope) {
+				t.Errorf("scope = %v, expected %v", set.Scope, tt.expectedScope)
+			}
+
+			if signed.UserID != userID || !signed.Impersonated || !reflect.DeepEqual(signed.Roles, []string{models.RoleUser}) {
+				t.Errorf("claims = %+v, expected an impersonated token of the user", signed)
+			}
+
+			if !reflect.DeepEqual(signed.Actor, &utils.Actor{Subject: adminID.String()}) {
+				t.Errorf("actor = %+v, expected the admin", signed.Actor)
+			}
+		})
+	}
+}
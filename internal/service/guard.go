@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"medods-test-task/config"
+	"medods-test-task/internal/models"
+	"medods-test-task/pkg/logger"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+//go:generate go run github.com/vektra/mockery/v2@latest --name BruteForceRepo
+type BruteForceRepo interface {
+	GetAuthFailures(ctx context.Context, keys []string) ([]models.AuthFailure, error)
+	RegisterAuthFailure(ctx context.Context, key string, window time.Duration) (*models.AuthFailure, error)
+	LockAuthFailure(ctx context.Context, key string, until time.Time) error
+	ResetAuthFailures(ctx context.Context, keys []string) error
+	GetUserByID(ctx context.Context, userID uuid.UUID) (*models.User, error)
+}
+
+type bruteForceGuard struct {
+	repo         BruteForceRepo
+	emailService EmailService
+	config       *config.BruteForceConfig
+	logger       logger.Logger
+	now          func() time.Time
+}
+
+func NewBruteForceGuard(repo BruteForceRepo, email EmailService, logger logger.Logger, cfg *config.BruteForceConfig) *bruteForceGuard {
+	return &bruteForceGuard{
+		repo:         repo,
+		emailService: email,
+		config:       cfg,
+		logger:       logger,
+		now:          time.Now,
+	}
+}
+
+func userFailureKey(userID uuid.UUID) string {
+	return "user:" + userID.String()
+}
+
+func ipFailureKey(IPAddress string) string {
+	return "ip:" + IPAddress
+}
+
+func failureKeys(userID uuid.UUID, IPAddress string) []string {
+	keys := []string{ipFailureKey(IPAddress)}
+	if userID != uuid.Nil {
+		keys = append(keys, userFailureKey(userID))
+	}
+
+	return keys
+}
+
+// Check returns a *models.RetryError when the account is locked or when the
+// user or the IP address has to wait before the next attempt.
+func (g *bruteForceGuard) Check(ctx context.Context, userID uuid.UUID, IPAddress string) error {
+	failures, err := g.repo.GetAuthFailures(ctx, failureKeys(userID, IPAddress))
+	if err != nil {
+		g.logger.Error(ctx, "failed to get auth failures", zap.Error(err))
+
+		return nil
+	}
+
+	now := g.now()
+
+	for _, f := range failures {
+		if f.LockedUntil.After(now) && f.Key == userFailureKey(userID) {
+			return &models.RetryError{Err: models.ErrAccountLocked, RetryAfter: f.LockedUntil.Sub(now)}
+		}
+
+		if next := f.LastFailureAt.Add(g.backoff(f.Failures)); next.After(now) {
+			return &models.RetryError{Err: models.ErrTooManyAttempts, RetryAfter: next.Sub(now)}
+		}
+	}
+
+	return nil
+}
+
+func (g *bruteForceGuard) RegisterFailure(ctx context.Context, userID uuid.UUID, IPAddress string) {
+	for _, key := range failureKeys(userID, IPAddress) {
+		failure, err := g.repo.RegisterAuthFailure(ctx, key, g.config.Window)
+		if err != nil {
+			g.logger.Error(ctx, "failed to register auth failure", zap.String("key", key), zap.Error(err))
+
+			continue
+		}
+
+		if key != userFailureKey(userID) || g.config.LockAfter <= 0 || failure.Failures < g.config.LockAfter {
+			continue
+		}
+
+		g.lock(ctx, userID, key)
+	}
+}
+
+// Reset clears the failures of the user after a successful attempt. The
+// failures of the IP address are kept, otherwise an attacker could wipe the
+// backoff of their address by signing in to their own account between
+// guesses at the accounts of others.
+func (g *bruteForceGuard) Reset(ctx context.Context, userID uuid.UUID) {
+	if err := g.repo.ResetAuthFailures(ctx, []string{userFailureKey(userID)}); err != nil {
+		g.logger.Error(ctx, "failed to reset auth failures", zap.Error(err))
+	}
+}
+
+func (g *bruteForceGuard) lock(ctx context.Context, userID uuid.UUID, key string) {
+	until := g.now().Add(g.config.LockDuration)
+
+	if err := g.repo.LockAuthFailure(ctx, key, until); err != nil {
+		g.logger.Error(ctx, "failed to lock account", zap.String("key", key), zap.Error(err))
+
+		return
+	}
+
+	g.logger.Warn(ctx, "account locked", zap.String("user_id", userID.String()), zap.Time("until", until))
+
+	user, err := g.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		g.logger.Error(ctx, "failed to get locked user", zap.Error(err))
+
+		return
+	}
+
+	if err := g.emailService.SendAccountLockedEmail(ctx, user, until); err != nil {
+		g.logger.Error(ctx, "failed to queue account locked email", zap.Error(err))
+	}
+}
+
+// backoff doubles the delay for every failure past BackoffAfter.
+func (g *bruteForceGuard) backoff(failures int) time.Duration {
+	if g.config.BackoffAfter <= 0 || failures < g.config.BackoffAfter {
+		return 0
+	}
+
+	delay := g.config.BaseDelay
+	for i := g.config.BackoffAfter; i < failures && delay < g.config.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, g.config.MaxDelay)
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"medods-test-task/config"
+	"medods-test-task/internal/models"
+	"medods-test-task/internal/service/mocks"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestBruteForceGuard_Check(t *testing.T) {
+	now := time.Now()
+	userID := uuid.New()
+	ip := "127.0.0.1"
+
+	cfg := &config.BruteForceConfig{
+		BackoffAfter: 3,
+		BaseDelay:    time.Second,
+		MaxDelay:     time.Minute,
+		LockAfter:    10,
+		LockDuration: 30 * time.Minute,
+		Window:       time.Hour,
+	}
+
+	tests := []struct {
+		name        string
+		failures    []models.AuthFailure
+		expectedErr error
+	}{
+		{
+			name:        "No failures",
+			failures:    nil,
+			expectedErr: nil,
+		},
+		{
+			name: "Below backoff threshold",
+			failures: []models.AuthFailure{
+				{Key: userFailureKey(userID), Failures: 2, LastFailureAt: now},
+			},
+			expectedErr: nil,
+		},
+		{
+			name: "Backoff not elapsed",
+			failures: []models.AuthFailure{
+				{Key: ipFailureKey(ip), Failures: 5, LastFailureAt: now.Add(-2 * time.Second)},
+			},
+			expectedErr: models.ErrTooManyAttempts,
+		},
+		{
+			name: "Backoff elapsed",
+			failures: []models.AuthFailure{
+				{Key: ipFailureKey(ip), Failures: 5, LastFailureAt: now.Add(-5 * time.Second)},
+			},
+			expectedErr: nil,
+		},
+		{
+			name: "Account locked",
+			failures: []models.AuthFailure{
+				{Key: userFailureKey(userID), LastFailureAt: now.Add(-time.Hour), LockedUntil: now.Add(time.Minute)},
+			},
+			expectedErr: models.ErrAccountLocked,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mocks.NewBruteForceRepo(t)
+			r.On("GetAuthFailures", mock.Anything, []string{ipFailureKey(ip), userFailureKey(userID)}).Return(tt.failures, nil)
+
+			g := &bruteForceGuard{
+				repo:   r,
+				config: cfg,
+				now:    func() time.Time { return now },
+			}
+
+			err := g.Check(context.Background(), userID, ip)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Errorf("error = %v, expectedError %v", err, tt.expectedErr)
+				return
+			}
+
+			var retryErr *models.RetryError
+			if err != nil && (!errors.As(err, &retryErr) || retryErr.RetryAfter <= 0) {
+				t.Errorf("expected positive retry after, got %v", err)
+			}
+		})
+	}
+}
+
+func TestBruteForceGuard_Reset(t *testing.T) {
+	userID := uuid.New()
+
+	r := mocks.NewBruteForceRepo(t)
+	r.On("ResetAuthFailures", mock.Anything, []string{userFailureKey(userID)}).Return(nil).Once()
+
+	g := &bruteForceGuard{repo: r}
+
+	g.Reset(context.Background(), userID)
+}
@@ -0,0 +1,16 @@
+package service
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(ctx context.Context, msg string, fields ...zap.Field) {}
+func (nopLogger) Info(ctx context.Context, msg string, fields ...zap.Field)  {}
+func (nopLogger) Warn(ctx context.Context, msg string, fields ...zap.Field)  {}
+func (nopLogger) Error(ctx context.Context, msg string, fields ...zap.Field) {}
+func (nopLogger) Fatal(ctx context.Context, msg string, fields ...zap.Field) {}
+func (nopLogger) Stop() error                                                { return nil }
@@ -0,0 +1,67 @@
+// Code generated by mockery v2.53.7. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	models "medods-test-task/internal/models"
+
+	mock "github.com/stretchr/testify/mock"
+
+	time "time"
+)
+
+// AccountMailer is an autogenerated mock type for the AccountMailer type
+type AccountMailer struct {
+	mock.Mock
+}
+
+// SendEmailChangeConfirmation provides a mock function with given fields: ctx, user, newEmail, token, expiresAt
+func (_m *AccountMailer) SendEmailChangeConfirmation(ctx context.Context, user *models.User, newEmail string, token string, expiresAt time.Time) error {
+	ret := _m.Called(ctx, user, newEmail, token, expiresAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SendEmailChangeConfirmation")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.User, string, string, time.Time) error); ok {
+		r0 = rf(ctx, user, newEmail, token, expiresAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SendEmailChangeNotice provides a mock function with given fields: ctx, user, newEmail, alert
+func (_m *AccountMailer) SendEmailChangeNotice(ctx context.Context, user *models.User, newEmail string, alert models.SecurityAlert) error {
+	ret := _m.Called(ctx, user, newEmail, alert)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SendEmailChangeNotice")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.User, string, models.SecurityAlert) error); ok {
+		r0 = rf(ctx, user, newEmail, alert)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewAccountMailer creates a new instance of AccountMailer. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewAccountMailer(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *AccountMailer {
+	mock := &AccountMailer{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
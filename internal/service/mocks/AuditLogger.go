@@ -0,0 +1,52 @@
+// Code generated by mockery v2.53.7. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	models "medods-test-task/internal/models"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// AuditLogger is an autogenerated mock type for the AuditLogger type
+type AuditLogger struct {
+	mock.Mock
+}
+
+// Record provides a mock function with given fields: ctx, event
+func (_m *AuditLogger) Record(ctx context.Context, event models.AuthEvent) {
+	_m.Called(ctx, event)
+}
+
+// Write provides a mock function with given fields: ctx, event
+func (_m *AuditLogger) Write(ctx context.Context, event models.AuthEvent) error {
+	ret := _m.Called(ctx, event)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Write")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.AuthEvent) error); ok {
+		r0 = rf(ctx, event)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewAuditLogger creates a new instance of AuditLogger. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewAuditLogger(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *AuditLogger {
+	mock := &AuditLogger{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
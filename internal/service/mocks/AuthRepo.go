@@ -1,4 +1,4 @@
-// Code generated by mockery v2.53.3. DO NOT EDIT.
+// Code generated by mockery v2.53.7. DO NOT EDIT.
 
 package mocks
 
@@ -16,6 +16,24 @@ type AuthRepo struct {
 	mock.Mock
 }
 
+// ConsumeRevokeToken provides a mock function with given fields: ctx, userID, tokenHash
+func (_m *AuthRepo) ConsumeRevokeToken(ctx context.Context, userID uuid.UUID, tokenHash string) error {
+	ret := _m.Called(ctx, userID, tokenHash)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ConsumeRevokeToken")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string) error); ok {
+		r0 = rf(ctx, userID, tokenHash)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // CreateSession provides a mock function with given fields: ctx, session
 func (_m *AuthRepo) CreateSession(ctx context.Context, session *models.RefreshSession) error {
 	ret := _m.Called(ctx, session)
@@ -52,6 +70,24 @@ func (_m *AuthRepo) CreateUser(ctx context.Context, user *models.User) error {
 	return r0
 }
 
+// DeleteEmailChange provides a mock function with given fields: ctx, userID
+func (_m *AuthRepo) DeleteEmailChange(ctx context.Context, userID uuid.UUID) error {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteEmailChange")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) error); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // DeleteSessionByUserID provides a mock function with given fields: ctx, userID
 func (_m *AuthRepo) DeleteSessionByUserID(ctx context.Context, userID uuid.UUID) error {
 	ret := _m.Called(ctx, userID)
@@ -130,6 +166,34 @@ func (_m *AuthRepo) GetUserByID(ctx context.Context, userID uuid.UUID) (*models.
 	return r0, r1
 }
 
+// TouchUserDevice provides a mock function with given fields: ctx, userID, fingerprint
+func (_m *AuthRepo) TouchUserDevice(ctx context.Context, userID uuid.UUID, fingerprint string) (bool, error) {
+	ret := _m.Called(ctx, userID, fingerprint)
+
+	if len(ret) == 0 {
+		panic("no return value specified for TouchUserDevice")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string) (bool, error)); ok {
+		return rf(ctx, userID, fingerprint)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string) bool); ok {
+		r0 = rf(ctx, userID, fingerprint)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, string) error); ok {
+		r1 = rf(ctx, userID, fingerprint)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // NewAuthRepo creates a new instance of AuthRepo. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewAuthRepo(t interface {
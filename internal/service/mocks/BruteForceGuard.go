@@ -0,0 +1,58 @@
+// Code generated by mockery v2.53.7. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	uuid "github.com/google/uuid"
+)
+
+// BruteForceGuard is an autogenerated mock type for the BruteForceGuard type
+type BruteForceGuard struct {
+	mock.Mock
+}
+
+// Check provides a mock function with given fields: ctx, userID, IPAddress
+func (_m *BruteForceGuard) Check(ctx context.Context, userID uuid.UUID, IPAddress string) error {
+	ret := _m.Called(ctx, userID, IPAddress)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Check")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string) error); ok {
+		r0 = rf(ctx, userID, IPAddress)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RegisterFailure provides a mock function with given fields: ctx, userID, IPAddress
+func (_m *BruteForceGuard) RegisterFailure(ctx context.Context, userID uuid.UUID, IPAddress string) {
+	_m.Called(ctx, userID, IPAddress)
+}
+
+// Reset provides a mock function with given fields: ctx, userID
+func (_m *BruteForceGuard) Reset(ctx context.Context, userID uuid.UUID) {
+	_m.Called(ctx, userID)
+}
+
+// NewBruteForceGuard creates a new instance of BruteForceGuard. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewBruteForceGuard(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *BruteForceGuard {
+	mock := &BruteForceGuard{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,161 @@
+// Code generated by mockery v2.53.7. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	models "medods-test-task/internal/models"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// ClientRepo is an autogenerated mock type for the ClientRepo type
+type ClientRepo struct {
+	mock.Mock
+}
+
+// CreateClient provides a mock function with given fields: ctx, client
+func (_m *ClientRepo) CreateClient(ctx context.Context, client *models.OAuthClient) error {
+	ret := _m.Called(ctx, client)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateClient")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.OAuthClient) error); ok {
+		r0 = rf(ctx, client)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteClient provides a mock function with given fields: ctx, clientID
+func (_m *ClientRepo) DeleteClient(ctx context.Context, clientID string) error {
+	ret := _m.Called(ctx, clientID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteClient")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, clientID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetClient provides a mock function with given fields: ctx, clientID
+func (_m *ClientRepo) GetClient(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	ret := _m.Called(ctx, clientID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetClient")
+	}
+
+	var r0 *models.OAuthClient
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*models.OAuthClient, error)); ok {
+		return rf(ctx, clientID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *models.OAuthClient); ok {
+		r0 = rf(ctx, clientID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.OAuthClient)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, clientID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListClients provides a mock function with given fields: ctx
+func (_m *ClientRepo) ListClients(ctx context.Context) ([]models.OAuthClient, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListClients")
+	}
+
+	var r0 []models.OAuthClient
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]models.OAuthClient, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []models.OAuthClient); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.OAuthClient)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateClient provides a mock function with given fields: ctx, client
+func (_m *ClientRepo) UpdateClient(ctx context.Context, client *models.OAuthClient) error {
+	ret := _m.Called(ctx, client)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateClient")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.OAuthClient) error); ok {
+		r0 = rf(ctx, client)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateClientSecret provides a mock function with given fields: ctx, clientID, secretHash
+func (_m *ClientRepo) UpdateClientSecret(ctx context.Context, clientID string, secretHash string) error {
+	ret := _m.Called(ctx, clientID, secretHash)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateClientSecret")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, clientID, secretHash)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewClientRepo creates a new instance of ClientRepo. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewClientRepo(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *ClientRepo {
+	mock := &ClientRepo{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
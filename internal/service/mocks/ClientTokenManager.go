@@ -0,0 +1,148 @@
+// Code generated by mockery v2.53.7. DO NOT EDIT.
+
+package mocks
+
+import (
+	models "medods-test-task/internal/models"
+
+	mock "github.com/stretchr/testify/mock"
+
+	time "time"
+
+	utils "medods-test-task/pkg/utils"
+)
+
+// ClientTokenManager is an autogenerated mock type for the ClientTokenManager type
+type ClientTokenManager struct {
+	mock.Mock
+}
+
+// NewClientToken provides a mock function with given fields: clientID, scope, ttl
+func (_m *ClientTokenManager) NewClientToken(clientID string, scope []string, ttl time.Duration) (string, error) {
+	ret := _m.Called(clientID, scope, ttl)
+
+	if len(ret) == 0 {
+		panic("no return value specified for NewClientToken")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, []string, time.Duration) (string, error)); ok {
+		return rf(clientID, scope, ttl)
+	}
+	if rf, ok := ret.Get(0).(func(string, []string, time.Duration) string); ok {
+		r0 = rf(clientID, scope, ttl)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(string, []string, time.Duration) error); ok {
+		r1 = rf(clientID, scope, ttl)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ParseClientAssertion provides a mock function with given fields: assertion, publicKey, clientID, audiences
+func (_m *ClientTokenManager) ParseClientAssertion(assertion string, publicKey string, clientID string, audiences []string) (*models.ClientAssertion, error) {
+	ret := _m.Called(assertion, publicKey, clientID, audiences)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ParseClientAssertion")
+	}
+
+	var r0 *models.ClientAssertion
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string, string, []string) (*models.ClientAssertion, error)); ok {
+		return rf(assertion, publicKey, clientID, audiences)
+	}
+	if rf, ok := ret.Get(0).(func(string, string, string, []string) *models.ClientAssertion); ok {
+		r0 = rf(assertion, publicKey, clientID, audiences)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.ClientAssertion)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string, string, []string) error); ok {
+		r1 = rf(assertion, publicKey, clientID, audiences)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ParseJWT provides a mock function with given fields: token
+func (_m *ClientTokenManager) ParseJWT(token string) (*utils.Claims, error) {
+	ret := _m.Called(token)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ParseJWT")
+	}
+
+	var r0 *utils.Claims
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (*utils.Claims, error)); ok {
+		return rf(token)
+	}
+	if rf, ok := ret.Get(0).(func(string) *utils.Claims); ok {
+		r0 = rf(token)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*utils.Claims)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(token)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SignToken provides a mock function with given fields: claims
+func (_m *ClientTokenManager) SignToken(claims utils.Claims) (string, error) {
+	ret := _m.Called(claims)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SignToken")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(utils.Claims) (string, error)); ok {
+		return rf(claims)
+	}
+	if rf, ok := ret.Get(0).(func(utils.Claims) string); ok {
+		r0 = rf(claims)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(utils.Claims) error); ok {
+		r1 = rf(claims)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewClientTokenManager creates a new instance of ClientTokenManager. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewClientTokenManager(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *ClientTokenManager {
+	mock := &ClientTokenManager{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,56 @@
+// Code generated by mockery v2.53.7. DO NOT EDIT.
+
+package mocks
+
+import (
+	email "medods-test-task/pkg/email"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// EmailPolicy is an autogenerated mock type for the EmailPolicy type
+type EmailPolicy struct {
+	mock.Mock
+}
+
+// Check provides a mock function with given fields: address
+func (_m *EmailPolicy) Check(address string) (email.Address, error) {
+	ret := _m.Called(address)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Check")
+	}
+
+	var r0 email.Address
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (email.Address, error)); ok {
+		return rf(address)
+	}
+	if rf, ok := ret.Get(0).(func(string) email.Address); ok {
+		r0 = rf(address)
+	} else {
+		r0 = ret.Get(0).(email.Address)
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(address)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewEmailPolicy creates a new instance of EmailPolicy. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewEmailPolicy(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *EmailPolicy {
+	mock := &EmailPolicy{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
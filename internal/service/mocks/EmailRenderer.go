@@ -0,0 +1,58 @@
+// Code generated by mockery v2.53.7. DO NOT EDIT.
+
+package mocks
+
+import (
+	render "medods-test-task/pkg/email/render"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// EmailRenderer is an autogenerated mock type for the EmailRenderer type
+type EmailRenderer struct {
+	mock.Mock
+}
+
+// Render provides a mock function with given fields: name, locale, data
+func (_m *EmailRenderer) Render(name string, locale string, data interface{}) (*render.Email, error) {
+	ret := _m.Called(name, locale, data)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Render")
+	}
+
+	var r0 *render.Email
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string, interface{}) (*render.Email, error)); ok {
+		return rf(name, locale, data)
+	}
+	if rf, ok := ret.Get(0).(func(string, string, interface{}) *render.Email); ok {
+		r0 = rf(name, locale, data)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*render.Email)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string, interface{}) error); ok {
+		r1 = rf(name, locale, data)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewEmailRenderer creates a new instance of EmailRenderer. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewEmailRenderer(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *EmailRenderer {
+	mock := &EmailRenderer{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -1,11 +1,14 @@
-// Code generated by mockery v2.53.3. DO NOT EDIT.
+// Code generated by mockery v2.53.7. DO NOT EDIT.
 
 package mocks
 
 import (
 	context "context"
+	models "medods-test-task/internal/models"
 
 	mock "github.com/stretchr/testify/mock"
+
+	time "time"
 )
 
 // EmailService is an autogenerated mock type for the EmailService type
@@ -13,9 +16,40 @@ type EmailService struct {
 	mock.Mock
 }
 
-// SendIPWarningEmail provides a mock function with given fields: ctx, email
-func (_m *EmailService) SendIPWarningEmail(ctx context.Context, email string) {
-	_m.Called(ctx, email)
+// SendAccountLockedEmail provides a mock function with given fields: ctx, user, until
+func (_m *EmailService) SendAccountLockedEmail(ctx context.Context, user *models.User, until time.Time) error {
+	ret := _m.Called(ctx, user, until)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SendAccountLockedEmail")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.User, time.Time) error); ok {
+		r0 = rf(ctx, user, until)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SendNotificationEmail provides a mock function with given fields: ctx, n
+func (_m *EmailService) SendNotificationEmail(ctx context.Context, n models.Notification) error {
+	ret := _m.Called(ctx, n)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SendNotificationEmail")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.Notification) error); ok {
+		r0 = rf(ctx, n)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
 }
 
 // NewEmailService creates a new instance of EmailService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
@@ -0,0 +1,42 @@
+// Code generated by mockery v2.53.7. DO NOT EDIT.
+
+package mocks
+
+import mock "github.com/stretchr/testify/mock"
+
+// GeoLocator is an autogenerated mock type for the GeoLocator type
+type GeoLocator struct {
+	mock.Mock
+}
+
+// Locate provides a mock function with given fields: ip
+func (_m *GeoLocator) Locate(ip string) string {
+	ret := _m.Called(ip)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Locate")
+	}
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(string) string); ok {
+		r0 = rf(ip)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	return r0
+}
+
+// NewGeoLocator creates a new instance of GeoLocator. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewGeoLocator(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *GeoLocator {
+	mock := &GeoLocator{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
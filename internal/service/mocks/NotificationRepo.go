@@ -0,0 +1,221 @@
+// Code generated by mockery v2.53.7. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	models "medods-test-task/internal/models"
+
+	mock "github.com/stretchr/testify/mock"
+
+	time "time"
+
+	uuid "github.com/google/uuid"
+)
+
+// NotificationRepo is an autogenerated mock type for the NotificationRepo type
+type NotificationRepo struct {
+	mock.Mock
+}
+
+// CountPhoneVerifications provides a mock function with given fields: ctx, userID, since
+func (_m *NotificationRepo) CountPhoneVerifications(ctx context.Context, userID uuid.UUID, since time.Time) (int, error) {
+	ret := _m.Called(ctx, userID, since)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CountPhoneVerifications")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, time.Time) (int, error)); ok {
+		return rf(ctx, userID, since)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, time.Time) int); ok {
+		r0 = rf(ctx, userID, since)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, time.Time) error); ok {
+		r1 = rf(ctx, userID, since)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreatePhoneVerification provides a mock function with given fields: ctx, verification
+func (_m *NotificationRepo) CreatePhoneVerification(ctx context.Context, verification *models.PhoneVerification) error {
+	ret := _m.Called(ctx, verification)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreatePhoneVerification")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.PhoneVerification) error); ok {
+		r0 = rf(ctx, verification)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetNotificationPreferences provides a mock function with given fields: ctx, userID
+func (_m *NotificationRepo) GetNotificationPreferences(ctx context.Context, userID uuid.UUID) ([]models.NotificationPreference, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetNotificationPreferences")
+	}
+
+	var r0 []models.NotificationPreference
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) ([]models.NotificationPreference, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) []models.NotificationPreference); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.NotificationPreference)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetPhoneVerification provides a mock function with given fields: ctx, userID
+func (_m *NotificationRepo) GetPhoneVerification(ctx context.Context, userID uuid.UUID) (*models.PhoneVerification, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetPhoneVerification")
+	}
+
+	var r0 *models.PhoneVerification
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) (*models.PhoneVerification, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) *models.PhoneVerification); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.PhoneVerification)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RecordPhoneCodeAttempt provides a mock function with given fields: ctx, id
+func (_m *NotificationRepo) RecordPhoneCodeAttempt(ctx context.Context, id uint) (int, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RecordPhoneCodeAttempt")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint) (int, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint) int); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetNotificationPreferences provides a mock function with given fields: ctx, userID, preferences
+func (_m *NotificationRepo) SetNotificationPreferences(ctx context.Context, userID uuid.UUID, preferences []models.NotificationPreference) error {
+	ret := _m.Called(ctx, userID, preferences)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetNotificationPreferences")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, []models.NotificationPreference) error); ok {
+		r0 = rf(ctx, userID, preferences)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateUserPhone provides a mock function with given fields: ctx, userID, phone
+func (_m *NotificationRepo) UpdateUserPhone(ctx context.Context, userID uuid.UUID, phone string) error {
+	ret := _m.Called(ctx, userID, phone)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateUserPhone")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string) error); ok {
+		r0 = rf(ctx, userID, phone)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UsePhoneVerification provides a mock function with given fields: ctx, id, usedAt
+func (_m *NotificationRepo) UsePhoneVerification(ctx context.Context, id uint, usedAt time.Time) error {
+	ret := _m.Called(ctx, id, usedAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UsePhoneVerification")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, time.Time) error); ok {
+		r0 = rf(ctx, id, usedAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewNotificationRepo creates a new instance of NotificationRepo. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewNotificationRepo(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *NotificationRepo {
+	mock := &NotificationRepo{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,59 @@
+// Code generated by mockery v2.53.7. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	models "medods-test-task/internal/models"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// OAuthClients is an autogenerated mock type for the OAuthClients type
+type OAuthClients struct {
+	mock.Mock
+}
+
+// GetClient provides a mock function with given fields: ctx, clientID
+func (_m *OAuthClients) GetClient(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	ret := _m.Called(ctx, clientID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetClient")
+	}
+
+	var r0 *models.OAuthClient
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*models.OAuthClient, error)); ok {
+		return rf(ctx, clientID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *models.OAuthClient); ok {
+		r0 = rf(ctx, clientID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.OAuthClient)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, clientID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewOAuthClients creates a new instance of OAuthClients. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewOAuthClients(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *OAuthClients {
+	mock := &OAuthClients{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,231 @@
+// Code generated by mockery v2.53.7. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	models "medods-test-task/internal/models"
+
+	mock "github.com/stretchr/testify/mock"
+
+	time "time"
+
+	uuid "github.com/google/uuid"
+)
+
+// OAuthRepo is an autogenerated mock type for the OAuthRepo type
+type OAuthRepo struct {
+	mock.Mock
+}
+
+// ClaimAuthorizationCode provides a mock function with given fields: ctx, codeHash
+func (_m *OAuthRepo) ClaimAuthorizationCode(ctx context.Context, codeHash string) (*models.AuthorizationCode, error) {
+	ret := _m.Called(ctx, codeHash)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ClaimAuthorizationCode")
+	}
+
+	var r0 *models.AuthorizationCode
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*models.AuthorizationCode, error)); ok {
+		return rf(ctx, codeHash)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *models.AuthorizationCode); ok {
+		r0 = rf(ctx, codeHash)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.AuthorizationCode)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, codeHash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateAuthorizationCode provides a mock function with given fields: ctx, code
+func (_m *OAuthRepo) CreateAuthorizationCode(ctx context.Context, code *models.AuthorizationCode) error {
+	ret := _m.Called(ctx, code)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateAuthorizationCode")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.AuthorizationCode) error); ok {
+		r0 = rf(ctx, code)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateDeviceCode provides a mock function with given fields: ctx, code
+func (_m *OAuthRepo) CreateDeviceCode(ctx context.Context, code *models.DeviceCode) error {
+	ret := _m.Called(ctx, code)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateDeviceCode")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.DeviceCode) error); ok {
+		r0 = rf(ctx, code)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DecideDeviceCode provides a mock function with given fields: ctx, userCode, userID, status
+func (_m *OAuthRepo) DecideDeviceCode(ctx context.Context, userCode string, userID uuid.UUID, status string) error {
+	ret := _m.Called(ctx, userCode, userID, status)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DecideDeviceCode")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, uuid.UUID, string) error); ok {
+		r0 = rf(ctx, userCode, userID, status)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteDeviceCode provides a mock function with given fields: ctx, deviceCodeHash
+func (_m *OAuthRepo) DeleteDeviceCode(ctx context.Context, deviceCodeHash string) error {
+	ret := _m.Called(ctx, deviceCodeHash)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteDeviceCode")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, deviceCodeHash)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetDeviceCodeByUserCode provides a mock function with given fields: ctx, userCode
+func (_m *OAuthRepo) GetDeviceCodeByUserCode(ctx context.Context, userCode string) (*models.DeviceCode, error) {
+	ret := _m.Called(ctx, userCode)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetDeviceCodeByUserCode")
+	}
+
+	var r0 *models.DeviceCode
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*models.DeviceCode, error)); ok {
+		return rf(ctx, userCode)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *models.DeviceCode); ok {
+		r0 = rf(ctx, userCode)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.DeviceCode)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, userCode)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PollDeviceCode provides a mock function with given fields: ctx, deviceCodeHash, polledAt
+func (_m *OAuthRepo) PollDeviceCode(ctx context.Context, deviceCodeHash string, polledAt time.Time) (*models.DeviceCode, error) {
+	ret := _m.Called(ctx, deviceCodeHash, polledAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PollDeviceCode")
+	}
+
+	var r0 *models.DeviceCode
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) (*models.DeviceCode, error)); ok {
+		return rf(ctx, deviceCodeHash, polledAt)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) *models.DeviceCode); ok {
+		r0 = rf(ctx, deviceCodeHash, polledAt)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.DeviceCode)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Time) error); ok {
+		r1 = rf(ctx, deviceCodeHash, polledAt)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetDevicePollInterval provides a mock function with given fields: ctx, deviceCodeHash, interval
+func (_m *OAuthRepo) SetDevicePollInterval(ctx context.Context, deviceCodeHash string, interval time.Duration) error {
+	ret := _m.Called(ctx, deviceCodeHash, interval)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetDevicePollInterval")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Duration) error); ok {
+		r0 = rf(ctx, deviceCodeHash, interval)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UseClientAssertion provides a mock function with given fields: ctx, clientID, assertion
+func (_m *OAuthRepo) UseClientAssertion(ctx context.Context, clientID string, assertion models.ClientAssertion) error {
+	ret := _m.Called(ctx, clientID, assertion)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UseClientAssertion")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, models.ClientAssertion) error); ok {
+		r0 = rf(ctx, clientID, assertion)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewOAuthRepo creates a new instance of OAuthRepo. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewOAuthRepo(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *OAuthRepo {
+	mock := &OAuthRepo{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,257 @@
+// Code generated by mockery v2.53.7. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	models "medods-test-task/internal/models"
+
+	mock "github.com/stretchr/testify/mock"
+
+	time "time"
+
+	uuid "github.com/google/uuid"
+)
+
+// OutboxRepo is an autogenerated mock type for the OutboxRepo type
+type OutboxRepo struct {
+	mock.Mock
+}
+
+// ClaimEmailSuppressions provides a mock function with given fields: ctx
+func (_m *OutboxRepo) ClaimEmailSuppressions(ctx context.Context) ([]models.EmailSuppression, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ClaimEmailSuppressions")
+	}
+
+	var r0 []models.EmailSuppression
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]models.EmailSuppression, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []models.EmailSuppression); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.EmailSuppression)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ClaimOutboxEmails provides a mock function with given fields: ctx, limit, lease
+func (_m *OutboxRepo) ClaimOutboxEmails(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEmail, error) {
+	ret := _m.Called(ctx, limit, lease)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ClaimOutboxEmails")
+	}
+
+	var r0 []models.OutboxEmail
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, time.Duration) ([]models.OutboxEmail, error)); ok {
+		return rf(ctx, limit, lease)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, time.Duration) []models.OutboxEmail); ok {
+		r0 = rf(ctx, limit, lease)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.OutboxEmail)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, time.Duration) error); ok {
+		r1 = rf(ctx, limit, lease)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CountRecentOutboxEmails provides a mock function with given fields: ctx, recipient, since
+func (_m *OutboxRepo) CountRecentOutboxEmails(ctx context.Context, recipient string, since time.Time) (int, error) {
+	ret := _m.Called(ctx, recipient, since)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CountRecentOutboxEmails")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) (int, error)); ok {
+		return rf(ctx, recipient, since)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) int); ok {
+		r0 = rf(ctx, recipient, since)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Time) error); ok {
+		r1 = rf(ctx, recipient, since)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateEmailSuppression provides a mock function with given fields: ctx, suppression
+func (_m *OutboxRepo) CreateEmailSuppression(ctx context.Context, suppression *models.EmailSuppression) error {
+	ret := _m.Called(ctx, suppression)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateEmailSuppression")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.EmailSuppression) error); ok {
+		r0 = rf(ctx, suppression)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateOutboxEmail provides a mock function with given fields: ctx, email
+func (_m *OutboxRepo) CreateOutboxEmail(ctx context.Context, email *models.OutboxEmail) error {
+	ret := _m.Called(ctx, email)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateOutboxEmail")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.OutboxEmail) error); ok {
+		r0 = rf(ctx, email)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// FailOutboxEmail provides a mock function with given fields: ctx, id, attempts, lastError
+func (_m *OutboxRepo) FailOutboxEmail(ctx context.Context, id uint, attempts int, lastError string) error {
+	ret := _m.Called(ctx, id, attempts, lastError)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FailOutboxEmail")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, int, string) error); ok {
+		r0 = rf(ctx, id, attempts, lastError)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// HasRecentOutboxEmail provides a mock function with given fields: ctx, userID, kind, since
+func (_m *OutboxRepo) HasRecentOutboxEmail(ctx context.Context, userID uuid.UUID, kind string, since time.Time) (bool, error) {
+	ret := _m.Called(ctx, userID, kind, since)
+
+	if len(ret) == 0 {
+		panic("no return value specified for HasRecentOutboxEmail")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string, time.Time) (bool, error)); ok {
+		return rf(ctx, userID, kind, since)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string, time.Time) bool); ok {
+		r0 = rf(ctx, userID, kind, since)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, string, time.Time) error); ok {
+		r1 = rf(ctx, userID, kind, since)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// LockOutboxRecipient provides a mock function with given fields: ctx, recipient
+func (_m *OutboxRepo) LockOutboxRecipient(ctx context.Context, recipient string) error {
+	ret := _m.Called(ctx, recipient)
+
+	if len(ret) == 0 {
+		panic("no return value specified for LockOutboxRecipient")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, recipient)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MarkOutboxEmailSent provides a mock function with given fields: ctx, id, attempts
+func (_m *OutboxRepo) MarkOutboxEmailSent(ctx context.Context, id uint, attempts int) error {
+	ret := _m.Called(ctx, id, attempts)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MarkOutboxEmailSent")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, int) error); ok {
+		r0 = rf(ctx, id, attempts)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RescheduleOutboxEmail provides a mock function with given fields: ctx, id, attempts, nextAttemptAt, lastError
+func (_m *OutboxRepo) RescheduleOutboxEmail(ctx context.Context, id uint, attempts int, nextAttemptAt time.Time, lastError string) error {
+	ret := _m.Called(ctx, id, attempts, nextAttemptAt, lastError)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RescheduleOutboxEmail")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, int, time.Time, string) error); ok {
+		r0 = rf(ctx, id, attempts, nextAttemptAt, lastError)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewOutboxRepo creates a new instance of OutboxRepo. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewOutboxRepo(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *OutboxRepo {
+	mock := &OutboxRepo{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,44 @@
+// Code generated by mockery v2.53.7. DO NOT EDIT.
+
+package mocks
+
+import mock "github.com/stretchr/testify/mock"
+
+// RolePolicy is an autogenerated mock type for the RolePolicy type
+type RolePolicy struct {
+	mock.Mock
+}
+
+// UserScopes provides a mock function with given fields: roles
+func (_m *RolePolicy) UserScopes(roles []string) []string {
+	ret := _m.Called(roles)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UserScopes")
+	}
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func([]string) []string); ok {
+		r0 = rf(roles)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	return r0
+}
+
+// NewRolePolicy creates a new instance of RolePolicy. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewRolePolicy(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *RolePolicy {
+	mock := &RolePolicy{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,46 @@
+// Code generated by mockery v2.53.7. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// SMSProvider is an autogenerated mock type for the SMSProvider type
+type SMSProvider struct {
+	mock.Mock
+}
+
+// Send provides a mock function with given fields: ctx, to, text
+func (_m *SMSProvider) Send(ctx context.Context, to string, text string) error {
+	ret := _m.Called(ctx, to, text)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Send")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, to, text)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewSMSProvider creates a new instance of SMSProvider. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewSMSProvider(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *SMSProvider {
+	mock := &SMSProvider{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
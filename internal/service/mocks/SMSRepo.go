@@ -0,0 +1,133 @@
+// Code generated by mockery v2.53.7. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	models "medods-test-task/internal/models"
+
+	mock "github.com/stretchr/testify/mock"
+
+	time "time"
+)
+
+// SMSRepo is an autogenerated mock type for the SMSRepo type
+type SMSRepo struct {
+	mock.Mock
+}
+
+// ClaimOutboxSMS provides a mock function with given fields: ctx, limit, lease
+func (_m *SMSRepo) ClaimOutboxSMS(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxSMS, error) {
+	ret := _m.Called(ctx, limit, lease)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ClaimOutboxSMS")
+	}
+
+	var r0 []models.OutboxSMS
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, time.Duration) ([]models.OutboxSMS, error)); ok {
+		return rf(ctx, limit, lease)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, time.Duration) []models.OutboxSMS); ok {
+		r0 = rf(ctx, limit, lease)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.OutboxSMS)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, time.Duration) error); ok {
+		r1 = rf(ctx, limit, lease)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateOutboxSMS provides a mock function with given fields: ctx, sms
+func (_m *SMSRepo) CreateOutboxSMS(ctx context.Context, sms *models.OutboxSMS) error {
+	ret := _m.Called(ctx, sms)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateOutboxSMS")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.OutboxSMS) error); ok {
+		r0 = rf(ctx, sms)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// FailOutboxSMS provides a mock function with given fields: ctx, id, attempts, lastError
+func (_m *SMSRepo) FailOutboxSMS(ctx context.Context, id uint, attempts int, lastError string) error {
+	ret := _m.Called(ctx, id, attempts, lastError)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FailOutboxSMS")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, int, string) error); ok {
+		r0 = rf(ctx, id, attempts, lastError)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MarkOutboxSMSSent provides a mock function with given fields: ctx, id, attempts
+func (_m *SMSRepo) MarkOutboxSMSSent(ctx context.Context, id uint, attempts int) error {
+	ret := _m.Called(ctx, id, attempts)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MarkOutboxSMSSent")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, int) error); ok {
+		r0 = rf(ctx, id, attempts)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RescheduleOutboxSMS provides a mock function with given fields: ctx, id, attempts, nextAttemptAt, lastError
+func (_m *SMSRepo) RescheduleOutboxSMS(ctx context.Context, id uint, attempts int, nextAttemptAt time.Time, lastError string) error {
+	ret := _m.Called(ctx, id, attempts, nextAttemptAt, lastError)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RescheduleOutboxSMS")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, int, time.Time, string) error); ok {
+		r0 = rf(ctx, id, attempts, nextAttemptAt, lastError)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewSMSRepo creates a new instance of SMSRepo. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewSMSRepo(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *SMSRepo {
+	mock := &SMSRepo{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
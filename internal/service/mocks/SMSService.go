@@ -0,0 +1,65 @@
+// Code generated by mockery v2.53.7. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	models "medods-test-task/internal/models"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// SMSService is an autogenerated mock type for the SMSService type
+type SMSService struct {
+	mock.Mock
+}
+
+// SendNotificationSMS provides a mock function with given fields: ctx, n
+func (_m *SMSService) SendNotificationSMS(ctx context.Context, n models.Notification) error {
+	ret := _m.Called(ctx, n)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SendNotificationSMS")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.Notification) error); ok {
+		r0 = rf(ctx, n)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SendPhoneCode provides a mock function with given fields: ctx, user, phone, code
+func (_m *SMSService) SendPhoneCode(ctx context.Context, user *models.User, phone string, code string) error {
+	ret := _m.Called(ctx, user, phone, code)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SendPhoneCode")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.User, string, string) error); ok {
+		r0 = rf(ctx, user, phone, code)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewSMSService creates a new instance of SMSService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewSMSService(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *SMSService {
+	mock := &SMSService{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
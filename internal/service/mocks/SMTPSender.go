@@ -0,0 +1,46 @@
+// Code generated by mockery v2.53.7. DO NOT EDIT.
+
+package mocks
+
+import (
+	mock "github.com/stretchr/testify/mock"
+
+	smtp "medods-test-task/pkg/email/smtp"
+)
+
+// SMTPSender is an autogenerated mock type for the SMTPSender type
+type SMTPSender struct {
+	mock.Mock
+}
+
+// Send provides a mock function with given fields: input
+func (_m *SMTPSender) Send(input smtp.SendEmailInput) error {
+	ret := _m.Called(input)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Send")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(smtp.SendEmailInput) error); ok {
+		r0 = rf(input)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewSMTPSender creates a new instance of SMTPSender. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewSMTPSender(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *SMTPSender {
+	mock := &SMTPSender{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
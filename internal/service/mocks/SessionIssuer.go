@@ -0,0 +1,89 @@
+// Code generated by mockery v2.53.7. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	models "medods-test-task/internal/models"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// SessionIssuer is an autogenerated mock type for the SessionIssuer type
+type SessionIssuer struct {
+	mock.Mock
+}
+
+// NewSession provides a mock function with given fields: ctx, clientID, userID, scope, IPAddress, userAgent
+func (_m *SessionIssuer) NewSession(ctx context.Context, clientID string, userID string, scope string, IPAddress string, userAgent string) (*models.TokenSet, error) {
+	ret := _m.Called(ctx, clientID, userID, scope, IPAddress, userAgent)
+
+	if len(ret) == 0 {
+		panic("no return value specified for NewSession")
+	}
+
+	var r0 *models.TokenSet
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, string, string) (*models.TokenSet, error)); ok {
+		return rf(ctx, clientID, userID, scope, IPAddress, userAgent)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, string, string) *models.TokenSet); ok {
+		r0 = rf(ctx, clientID, userID, scope, IPAddress, userAgent)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.TokenSet)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, string, string, string) error); ok {
+		r1 = rf(ctx, clientID, userID, scope, IPAddress, userAgent)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RefreshClientSession provides a mock function with given fields: ctx, clientID, refreshToken, scope, IPAddress, userAgent
+func (_m *SessionIssuer) RefreshClientSession(ctx context.Context, clientID string, refreshToken string, scope string, IPAddress string, userAgent string) (*models.TokenSet, error) {
+	ret := _m.Called(ctx, clientID, refreshToken, scope, IPAddress, userAgent)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RefreshClientSession")
+	}
+
+	var r0 *models.TokenSet
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, string, string) (*models.TokenSet, error)); ok {
+		return rf(ctx, clientID, refreshToken, scope, IPAddress, userAgent)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, string, string) *models.TokenSet); ok {
+		r0 = rf(ctx, clientID, refreshToken, scope, IPAddress, userAgent)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.TokenSet)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, string, string, string) error); ok {
+		r1 = rf(ctx, clientID, refreshToken, scope, IPAddress, userAgent)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewSessionIssuer creates a new instance of SessionIssuer. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewSessionIssuer(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *SessionIssuer {
+	mock := &SessionIssuer{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -1,4 +1,4 @@
-// Code generated by mockery v2.53.3. DO NOT EDIT.
+// Code generated by mockery v2.53.7. DO NOT EDIT.
 
 package mocks
 
@@ -15,6 +15,24 @@ type TokenManager struct {
 	mock.Mock
 }
 
+// GetAccessTTL provides a mock function with no fields
+func (_m *TokenManager) GetAccessTTL() time.Duration {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetAccessTTL")
+	}
+
+	var r0 time.Duration
+	if rf, ok := ret.Get(0).(func() time.Duration); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(time.Duration)
+	}
+
+	return r0
+}
+
 // GetRefreshTTL provides a mock function with no fields
 func (_m *TokenManager) GetRefreshTTL() time.Duration {
 	ret := _m.Called()
@@ -61,9 +79,37 @@ func (_m *TokenManager) HashToken(password string) (string, error) {
 	return r0, r1
 }
 
-// NewTokenPair provides a mock function with given fields: userID, IPAddress
-func (_m *TokenManager) NewTokenPair(userID uuid.UUID, IPAddress string) (string, string, error) {
-	ret := _m.Called(userID, IPAddress)
+// NewRevokeToken provides a mock function with given fields: userID
+func (_m *TokenManager) NewRevokeToken(userID uuid.UUID) (string, error) {
+	ret := _m.Called(userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for NewRevokeToken")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID) (string, error)); ok {
+		return rf(userID)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID) string); ok {
+		r0 = rf(userID)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID) error); ok {
+		r1 = rf(userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewTokenPair provides a mock function with given fields: userID, clientID, IPAddress, accessTTL, roles, scope
+func (_m *TokenManager) NewTokenPair(userID uuid.UUID, clientID string, IPAddress string, accessTTL time.Duration, roles []string, scope []string) (string, string, error) {
+	ret := _m.Called(userID, clientID, IPAddress, accessTTL, roles, scope)
 
 	if len(ret) == 0 {
 		panic("no return value specified for NewTokenPair")
@@ -72,23 +118,23 @@ func (_m *TokenManager) NewTokenPair(userID uuid.UUID, IPAddress string) (string
 	var r0 string
 	var r1 string
 	var r2 error
-	if rf, ok := ret.Get(0).(func(uuid.UUID, string) (string, string, error)); ok {
-		return rf(userID, IPAddress)
+	if rf, ok := ret.Get(0).(func(uuid.UUID, string, string, time.Duration, []string, []string) (string, string, error)); ok {
+		return rf(userID, clientID, IPAddress, accessTTL, roles, scope)
 	}
-	if rf, ok := ret.Get(0).(func(uuid.UUID, string) string); ok {
-		r0 = rf(userID, IPAddress)
+	if rf, ok := ret.Get(0).(func(uuid.UUID, string, string, time.Duration, []string, []string) string); ok {
+		r0 = rf(userID, clientID, IPAddress, accessTTL, roles, scope)
 	} else {
 		r0 = ret.Get(0).(string)
 	}
 
-	if rf, ok := ret.Get(1).(func(uuid.UUID, string) string); ok {
-		r1 = rf(userID, IPAddress)
+	if rf, ok := ret.Get(1).(func(uuid.UUID, string, string, time.Duration, []string, []string) string); ok {
+		r1 = rf(userID, clientID, IPAddress, accessTTL, roles, scope)
 	} else {
 		r1 = ret.Get(1).(string)
 	}
 
-	if rf, ok := ret.Get(2).(func(uuid.UUID, string) error); ok {
-		r2 = rf(userID, IPAddress)
+	if rf, ok := ret.Get(2).(func(uuid.UUID, string, string, time.Duration, []string, []string) error); ok {
+		r2 = rf(userID, clientID, IPAddress, accessTTL, roles, scope)
 	} else {
 		r2 = ret.Error(2)
 	}
@@ -126,6 +172,36 @@ func (_m *TokenManager) ParseRefreshToken(refreshToken string) (uuid.UUID, error
 	return r0, r1
 }
 
+// ParseRevokeToken provides a mock function with given fields: token
+func (_m *TokenManager) ParseRevokeToken(token string) (uuid.UUID, error) {
+	ret := _m.Called(token)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ParseRevokeToken")
+	}
+
+	var r0 uuid.UUID
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (uuid.UUID, error)); ok {
+		return rf(token)
+	}
+	if rf, ok := ret.Get(0).(func(string) uuid.UUID); ok {
+		r0 = rf(token)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(uuid.UUID)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(token)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ValidateToken provides a mock function with given fields: token, hashedToken
 func (_m *TokenManager) ValidateToken(token string, hashedToken string) error {
 	ret := _m.Called(token, hashedToken)
@@ -0,0 +1,127 @@
+// Code generated by mockery v2.53.7. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	models "medods-test-task/internal/models"
+
+	mock "github.com/stretchr/testify/mock"
+
+	uuid "github.com/google/uuid"
+)
+
+// UserWebhooks is an autogenerated mock type for the UserWebhooks type
+type UserWebhooks struct {
+	mock.Mock
+}
+
+// DeleteUserEndpoint provides a mock function with given fields: ctx, userID
+func (_m *UserWebhooks) DeleteUserEndpoint(ctx context.Context, userID uuid.UUID) error {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteUserEndpoint")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) error); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetUserEndpoint provides a mock function with given fields: ctx, userID
+func (_m *UserWebhooks) GetUserEndpoint(ctx context.Context, userID uuid.UUID) (*models.WebhookEndpoint, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUserEndpoint")
+	}
+
+	var r0 *models.WebhookEndpoint
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) (*models.WebhookEndpoint, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) *models.WebhookEndpoint); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.WebhookEndpoint)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PublishToUser provides a mock function with given fields: ctx, n
+func (_m *UserWebhooks) PublishToUser(ctx context.Context, n models.Notification) error {
+	ret := _m.Called(ctx, n)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PublishToUser")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.Notification) error); ok {
+		r0 = rf(ctx, n)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetUserEndpoint provides a mock function with given fields: ctx, userID, url
+func (_m *UserWebhooks) SetUserEndpoint(ctx context.Context, userID uuid.UUID, url string) (*models.WebhookEndpoint, error) {
+	ret := _m.Called(ctx, userID, url)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetUserEndpoint")
+	}
+
+	var r0 *models.WebhookEndpoint
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string) (*models.WebhookEndpoint, error)); ok {
+		return rf(ctx, userID, url)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string) *models.WebhookEndpoint); ok {
+		r0 = rf(ctx, userID, url)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.WebhookEndpoint)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, string) error); ok {
+		r1 = rf(ctx, userID, url)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewUserWebhooks creates a new instance of UserWebhooks. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewUserWebhooks(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *UserWebhooks {
+	mock := &UserWebhooks{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,377 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"medods-test-task/internal/models"
+	"medods-test-task/pkg/logger"
+	"medods-test-task/pkg/sms"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	phoneCodeTTL      = 10 * time.Minute
+	phoneCodeInterval = time.Minute
+	phoneCodesPerDay  = 5
+	phoneCodeAttempts = 5
+)
+
+//go:generate go run github.com/vektra/mockery/v2@latest --name EmailService
+type EmailService interface {
+	SendNotificationEmail(ctx context.Context, n models.Notification) error
+	SendAccountLockedEmail(ctx context.Context, user *models.User, until time.Time) error
+}
+
+//go:generate go run github.com/vektra/mockery/v2@latest --name SMSService
+type SMSService interface {
+	SendNotificationSMS(ctx context.Context, n models.Notification) error
+	SendPhoneCode(ctx context.Context, user *models.User, phone, code string) error
+}
+
+//go:generate go run github.com/vektra/mockery/v2@latest --name UserWebhooks
+type UserWebhooks interface {
+	PublishToUser(ctx context.Context, n models.Notification) error
+	SetUserEndpoint(ctx context.Context, userID uuid.UUID, url string) (*models.WebhookEndpoint, error)
+	GetUserEndpoint(ctx context.Context, userID uuid.UUID) (*models.WebhookEndpoint, error)
+	DeleteUserEndpoint(ctx context.Context, userID uuid.UUID) error
+}
+
+//go:generate go run github.com/vektra/mockery/v2@latest --name UserRepo
+type UserRepo interface {
+	GetUserByID(ctx context.Context, userID uuid.UUID) (*models.User, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@latest --name NotificationRepo
+type NotificationRepo interface {
+	GetNotificationPreferences(ctx context.Context, userID uuid.UUID) ([]models.NotificationPreference, error)
+	SetNotificationPreferences(ctx context.Context, userID uuid.UUID, preferences []models.NotificationPreference) error
+	UpdateUserPhone(ctx context.Context, userID uuid.UUID, phone string) error
+	CreatePhoneVerification(ctx context.Context, verification *models.PhoneVerification) error
+	GetPhoneVerification(ctx context.Context, userID uuid.UUID) (*models.PhoneVerification, error)
+	CountPhoneVerifications(ctx context.Context, userID uuid.UUID, since time.Time) (int, error)
+	RecordPhoneCodeAttempt(ctx context.Context, id uint) (int, error)
+	UsePhoneVerification(ctx context.Context, id uint, usedAt time.Time) error
+}
+
+type notifier struct {
+	repo       NotificationRepo
+	users      UserRepo
+	email      EmailService
+	sms        SMSService
+	webhooks   UserWebhooks
+	transactor Transactor
+	logger     logger.Logger
+}
+
+func NewNotifier(repo NotificationRepo, users UserRepo, email EmailService, sms SMSService, webhooks UserWebhooks, transactor Transactor, logger logger.Logger) *notifier {
+	return &notifier{
+		repo:       repo,
+		users:      users,
+		email:      email,
+		sms:        sms,
+		webhooks:   webhooks,
+		transactor: transactor,
+		logger:     logger,
+	}
+}
+
+// Notify sends the notification on every channel the user chose for the
+// event. Channels queue their messages, so when ctx carries a transaction
+// nothing is sent unless it commits.
+func (n *notifier) Notify(ctx context.Context, notification models.Notification) error {
+	preferences, err := n.repo.GetNotificationPreferences(ctx, notification.User.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, channel := range channelsFor(preferences, notification.Event) {
+		var err error
+
+		switch channel {
+		case models.ChannelEmail:
+			err = n.email.SendNotificationEmail(ctx, notification)
+		case models.ChannelSMS:
+			err = n.sms.SendNotificationSMS(ctx, notification)
+		case models.ChannelWebhook:
+			err = n.webhooks.PublishToUser(ctx, notification)
+		default:
+			n.logger.Warn(ctx, "unknown notification channel skipped", zap.String("channel", channel))
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (n *notifier) GetSettings(ctx context.Context, userID uuid.UUID) (*models.NotificationSettings, error) {
+	user, err := n.users.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return n.settings(ctx, user)
+}
+
+// UpdateSettings applies the update in a single transaction. It is rolled
+// back when an event is left on the sms or webhook channel without a phone or
+// a webhook url. A new phone gets a code and replaces the current one only
+// once ConfirmPhone checks the code.
+func (n *notifier) UpdateSettings(ctx context.Context, userID uuid.UUID, update models.NotificationSettingsUpdate) (*models.NotificationSettings, error) {
+	if err := validatePreferences(update.Preferences); err != nil {
+		return nil, err
+	}
+
+	if update.Phone != nil && *update.Phone != "" && !sms.IsValidPhone(*update.Phone) {
+		return nil, models.ErrInvalidPhone
+	}
+
+	var settings *models.NotificationSettings
+
+	err := n.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		user, err := n.users.GetUserByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		var pendingPhone string
+
+		if update.Phone != nil {
+			switch *update.Phone {
+			case user.Phone:
+			case "":
+				if err := n.repo.UpdateUserPhone(ctx, userID, ""); err != nil {
+					return err
+				}
+
+				user.Phone = ""
+			default:
+				if err := n.sendPhoneCode(ctx, user, *update.Phone); err != nil {
+					return err
+				}
+
+				pendingPhone = *update.Phone
+			}
+		}
+
+		var secret string
+
+		if update.WebhookURL != nil {
+			if *update.WebhookURL == "" {
+				err = n.webhooks.DeleteUserEndpoint(ctx, userID)
+			} else {
+				var endpoint *models.WebhookEndpoint
+
+				endpoint, err = n.webhooks.SetUserEndpoint(ctx, userID, *update.WebhookURL)
+				if endpoint != nil {
+					secret = endpoint.Secret
+				}
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := n.repo.SetNotificationPreferences(ctx, userID, update.Preferences); err != nil {
+			return err
+		}
+
+		settings, err = n.settings(ctx, user)
+		if err != nil {
+			return err
+		}
+
+		settings.PendingPhone = pendingPhone
+		settings.WebhookSecret = secret
+
+		return checkChannels(settings)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+// sendPhoneCode texts a code to the phone. Codes are limited per user, so that
+// the settings cannot be used to send texts to numbers of anyone's choice.
+func (n *notifier) sendPhoneCode(ctx context.Context, user *models.User, phone string) error {
+	now := time.Now()
+
+	last, err := n.repo.GetPhoneVerification(ctx, user.ID)
+	if err != nil && !errors.Is(err, models.ErrPhoneVerificationNotFound) {
+		return err
+	}
+
+	if last != nil && now.Sub(last.CreatedAt) < phoneCodeInterval {
+		return models.ErrPhoneCodeTooSoon
+	}
+
+	sent, err := n.repo.CountPhoneVerifications(ctx, user.ID, now.Add(-24*time.Hour))
+	if err != nil {
+		return err
+	}
+
+	if sent >= phoneCodesPerDay {
+		return models.ErrPhoneCodeTooSoon
+	}
+
+	code, err := newPhoneCode()
+	if err != nil {
+		return err
+	}
+
+	err = n.repo.CreatePhoneVerification(ctx, &models.PhoneVerification{
+		UserID:    user.ID,
+		Phone:     phone,
+		CodeHash:  hashOpaqueToken(code),
+		ExpiresAt: now.Add(phoneCodeTTL),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return err
+	}
+
+	return n.sms.SendPhoneCode(ctx, user, phone, code)
+}
+
+// ConfirmPhone sets the phone the code was sent to. Only the latest code
+// works, and only for a few tries.
+func (n *notifier) ConfirmPhone(ctx context.Context, userID uuid.UUID, code string) error {
+	verification, err := n.repo.GetPhoneVerification(ctx, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrPhoneVerificationNotFound) {
+			return models.ErrInvalidPhoneCode
+		}
+
+		return err
+	}
+
+	now := time.Now()
+
+	if !verification.UsedAt.IsZero() || now.After(verification.ExpiresAt) {
+		return models.ErrInvalidPhoneCode
+	}
+
+	// The try is counted outside of the transaction, so that a wrong code
+	// is not rolled back.
+	attempts, err := n.repo.RecordPhoneCodeAttempt(ctx, verification.ID)
+	if err != nil {
+		return err
+	}
+
+	if attempts > phoneCodeAttempts || subtle.ConstantTimeCompare([]byte(hashOpaqueToken(code)), []byte(verification.CodeHash)) != 1 {
+		return models.ErrInvalidPhoneCode
+	}
+
+	return n.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		if err := n.repo.UsePhoneVerification(ctx, verification.ID, now); err != nil {
+			if errors.Is(err, models.ErrPhoneVerificationNotFound) {
+				return models.ErrInvalidPhoneCode
+			}
+
+			return err
+		}
+
+		return n.repo.UpdateUserPhone(ctx, userID, verification.Phone)
+	})
+}
+
+func newPhoneCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", fmt.Errorf("failed to create phone code: %w", err)
+	}
+
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// settings lists every event, filling in the default channels for events the
+// user has not configured.
+func (n *notifier) settings(ctx context.Context, user *models.User) (*models.NotificationSettings, error) {
+	preferences, err := n.repo.GetNotificationPreferences(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := &models.NotificationSettings{
+		Preferences: make([]models.NotificationPreference, 0, len(models.NotificationEvents)),
+		Phone:       user.Phone,
+	}
+
+	for _, event := range models.NotificationEvents {
+		settings.Preferences = append(settings.Preferences, models.NotificationPreference{
+			Event:    event,
+			Channels: channelsFor(preferences, event),
+		})
+	}
+
+	endpoint, err := n.webhooks.GetUserEndpoint(ctx, user.ID)
+	if err != nil && !errors.Is(err, models.ErrWebhookEndpointNotFound) {
+		return nil, err
+	}
+
+	if endpoint != nil {
+		settings.WebhookURL = endpoint.URL
+	}
+
+	return settings, nil
+}
+
+func channelsFor(preferences []models.NotificationPreference, event string) []string {
+	for _, preference := range preferences {
+		if preference.Event == event {
+			return preference.Channels
+		}
+	}
+
+	return models.DefaultNotificationChannels
+}
+
+func validatePreferences(preferences []models.NotificationPreference) error {
+	for i, preference := range preferences {
+		if !slices.Contains(models.NotificationEvents, preference.Event) {
+			return models.ErrUnknownNotificationEvent
+		}
+
+		for _, channel := range preference.Channels {
+			if !slices.Contains(models.NotificationChannels, channel) {
+				return models.ErrUnknownNotificationChannel
+			}
+		}
+
+		if preference.Channels == nil {
+			preferences[i].Channels = []string{}
+		}
+
+		slices.Sort(preferences[i].Channels)
+		preferences[i].Channels = slices.Compact(preferences[i].Channels)
+	}
+
+	return nil
+}
+
+func checkChannels(settings *models.NotificationSettings) error {
+	for _, preference := range settings.Preferences {
+		if slices.Contains(preference.Channels, models.ChannelSMS) && settings.Phone == "" {
+			return models.ErrChannelNotConfigured
+		}
+
+		if slices.Contains(preference.Channels, models.ChannelWebhook) && settings.WebhookURL == "" {
+			return models.ErrChannelNotConfigured
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,371 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"medods-test-task/internal/models"
+	"medods-test-task/internal/service/mocks"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNotifier_Notify(t *testing.T) {
+	user := &models.User{ID: uuid.New(), Email: "test@email.com", Phone: "+79991234567"}
+	notification := models.Notification{Event: models.NotificationNewDevice, User: user}
+
+	tests := []struct {
+		name          string
+		preferences   []models.NotificationPreference
+		expectEmail   bool
+		expectSMS     bool
+		expectWebhook bool
+	}{
+		{
+			name:        "Default channels",
+			preferences: nil,
+			expectEmail: true,
+		},
+		{
+			name: "All channels",
+			preferences: []models.NotificationPreference{
+				{Event: models.NotificationNewDevice, Channels: []string{models.ChannelEmail, models.ChannelSMS, models.ChannelWebhook}},
+			},
+			expectEmail:   true,
+			expectSMS:     true,
+			expectWebhook: true,
+		},
+		{
+			name: "Other event configured",
+			preferences: []models.NotificationPreference{
+				{Event: models.NotificationIPChange, Channels: []string{models.ChannelSMS}},
+			},
+			expectEmail: true,
+		},
+		{
+			name: "Event turned off",
+			preferences: []models.NotificationPreference{
+				{Event: models.NotificationNewDevice, Channels: []string{}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mocks.NewNotificationRepo(t)
+			email := mocks.NewEmailService(t)
+			sms := mocks.NewSMSService(t)
+			webhooks := mocks.NewUserWebhooks(t)
+
+			r.On("GetNotificationPreferences", mock.Anything, user.ID).Return(tt.preferences, nil)
+			if tt.expectEmail {
+				email.On("SendNotificationEmail", mock.Anything, notification).Return(nil)
+			}
+			if tt.expectSMS {
+				sms.On("SendNotificationSMS", mock.Anything, notification).Return(nil)
+			}
+			if tt.expectWebhook {
+				webhooks.On("PublishToUser", mock.Anything, notification).Return(nil)
+			}
+
+			n := NewNotifier(r, mocks.NewUserRepo(t), email, sms, webhooks, nopTransactor{}, nopLogger{})
+
+			if err := n.Notify(context.Background(), notification); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestNotifier_UpdateSettings(t *testing.T) {
+	userID := uuid.New()
+	phone := "+79991234567"
+	invalidPhone := "89991234567"
+	webhookURL := "https://example.com/hook"
+
+	type mockBehavior func(r *mocks.NotificationRepo, users *mocks.UserRepo, webhooks *mocks.UserWebhooks, sms *mocks.SMSService)
+
+	tests := []struct {
+		name           string
+		update         models.NotificationSettingsUpdate
+		mock           mockBehavior
+		expectedSecret string
+		expectedPhone  string
+		expectedErr    error
+	}{
+		{
+			name: "Sms with confirmed phone",
+			update: models.NotificationSettingsUpdate{
+				Preferences: []models.NotificationPreference{
+					{Event: models.NotificationIPChange, Channels: []string{models.ChannelSMS, models.ChannelEmail, models.ChannelSMS}},
+				},
+				Phone: &phone,
+			},
+			mock: func(r *mocks.NotificationRepo, users *mocks.UserRepo, webhooks *mocks.UserWebhooks, sms *mocks.SMSService) {
+				users.On("GetUserByID", mock.Anything, userID).Return(&models.User{ID: userID, Phone: phone}, nil)
+				r.On("SetNotificationPreferences", mock.Anything, userID, []models.NotificationPreference{
+					{Event: models.NotificationIPChange, Channels: []string{models.ChannelEmail, models.ChannelSMS}},
+				}).Return(nil)
+				r.On("GetNotificationPreferences", mock.Anything, userID).Return([]models.NotificationPreference{
+					{Event: models.NotificationIPChange, Channels: []string{models.ChannelEmail, models.ChannelSMS}},
+				}, nil)
+				webhooks.On("GetUserEndpoint", mock.Anything, userID).Return(nil, models.ErrWebhookEndpointNotFound)
+			},
+		},
+		{
+			name: "New phone gets a code",
+			update: models.NotificationSettingsUpdate{
+				Phone: &phone,
+			},
+			mock: func(r *mocks.NotificationRepo, users *mocks.UserRepo, webhooks *mocks.UserWebhooks, sms *mocks.SMSService) {
+				user := &models.User{ID: userID}
+
+				users.On("GetUserByID", mock.Anything, userID).Return(user, nil)
+				r.On("GetPhoneVerification", mock.Anything, userID).Return(nil, models.ErrPhoneVerificationNotFound)
+				r.On("CountPhoneVerifications", mock.Anything, userID, mock.Anything).Return(0, nil)
+				r.On("CreatePhoneVerification", mock.Anything, mock.MatchedBy(func(v *models.PhoneVerification) bool {
+					return v.UserID == userID && v.Phone == phone && v.ExpiresAt.After(time.Now())
+				})).Return(nil)
+				sms.On("SendPhoneCode", mock.Anything, user, phone, mock.MatchedBy(func(code string) bool {
+					return len(code) == 6
+				})).Return(nil)
+				r.On("SetNotificationPreferences", mock.Anything, userID, mock.Anything).Return(nil)
+				r.On("GetNotificationPreferences", mock.Anything, userID).Return(nil, nil)
+				webhooks.On("GetUserEndpoint", mock.Anything, userID).Return(nil, models.ErrWebhookEndpointNotFound)
+			},
+			expectedPhone: phone,
+		},
+		{
+			name: "Sms with unconfirmed phone",
+			update: models.NotificationSettingsUpdate{
+				Preferences: []models.NotificationPreference{
+					{Event: models.NotificationIPChange, Channels: []string{models.ChannelSMS}},
+				},
+				Phone: &phone,
+			},
+			mock: func(r *mocks.NotificationRepo, users *mocks.UserRepo, webhooks *mocks.UserWebhooks, sms *mocks.SMSService) {
+				users.On("GetUserByID", mock.Anything, userID).Return(&models.User{ID: userID}, nil)
+				r.On("GetPhoneVerification", mock.Anything, userID).Return(nil, models.ErrPhoneVerificationNotFound)
+				r.On("CountPhoneVerifications", mock.Anything, userID, mock.Anything).Return(0, nil)
+				r.On("CreatePhoneVerification", mock.Anything, mock.Anything).Return(nil)
+				sms.On("SendPhoneCode", mock.Anything, mock.Anything, phone, mock.Anything).Return(nil)
+				r.On("SetNotificationPreferences", mock.Anything, userID, mock.Anything).Return(nil)
+				r.On("GetNotificationPreferences", mock.Anything, userID).Return([]models.NotificationPreference{
+					{Event: models.NotificationIPChange, Channels: []string{models.ChannelSMS}},
+				}, nil)
+				webhooks.On("GetUserEndpoint", mock.Anything, userID).Return(nil, models.ErrWebhookEndpointNotFound)
+			},
+			expectedErr: models.ErrChannelNotConfigured,
+		},
+		{
+			name: "Phone code sent recently",
+			update: models.NotificationSettingsUpdate{
+				Phone: &phone,
+			},
+			mock: func(r *mocks.NotificationRepo, users *mocks.UserRepo, webhooks *mocks.UserWebhooks, sms *mocks.SMSService) {
+				users.On("GetUserByID", mock.Anything, userID).Return(&models.User{ID: userID}, nil)
+				r.On("GetPhoneVerification", mock.Anything, userID).Return(&models.PhoneVerification{CreatedAt: time.Now().Add(-10 * time.Second)}, nil)
+			},
+			expectedErr: models.ErrPhoneCodeTooSoon,
+		},
+		{
+			name: "Daily phone codes used up",
+			update: models.NotificationSettingsUpdate{
+				Phone: &phone,
+			},
+			mock: func(r *mocks.NotificationRepo, users *mocks.UserRepo, webhooks *mocks.UserWebhooks, sms *mocks.SMSService) {
+				users.On("GetUserByID", mock.Anything, userID).Return(&models.User{ID: userID}, nil)
+				r.On("GetPhoneVerification", mock.Anything, userID).Return(&models.PhoneVerification{CreatedAt: time.Now().Add(-time.Hour)}, nil)
+				r.On("CountPhoneVerifications", mock.Anything, userID, mock.Anything).Return(phoneCodesPerDay, nil)
+			},
+			expectedErr: models.ErrPhoneCodeTooSoon,
+		},
+		{
+			name: "Webhook url set",
+			update: models.NotificationSettingsUpdate{
+				Preferences: []models.NotificationPreference{
+					{Event: models.NotificationNewDevice, Channels: []string{models.ChannelWebhook}},
+				},
+				WebhookURL: &webhookURL,
+			},
+			mock: func(r *mocks.NotificationRepo, users *mocks.UserRepo, webhooks *mocks.UserWebhooks, sms *mocks.SMSService) {
+				endpoint := &models.WebhookEndpoint{UserID: userID, URL: webhookURL, Secret: "whsec_secret"}
+
+				users.On("GetUserByID", mock.Anything, userID).Return(&models.User{ID: userID}, nil)
+				webhooks.On("SetUserEndpoint", mock.Anything, userID, webhookURL).Return(endpoint, nil)
+				r.On("SetNotificationPreferences", mock.Anything, userID, mock.Anything).Return(nil)
+				r.On("GetNotificationPreferences", mock.Anything, userID).Return([]models.NotificationPreference{
+					{Event: models.NotificationNewDevice, Channels: []string{models.ChannelWebhook}},
+				}, nil)
+				webhooks.On("GetUserEndpoint", mock.Anything, userID).Return(endpoint, nil)
+			},
+			expectedSecret: "whsec_secret",
+		},
+		{
+			name: "Webhook without url",
+			update: models.NotificationSettingsUpdate{
+				Preferences: []models.NotificationPreference{
+					{Event: models.NotificationNewDevice, Channels: []string{models.ChannelWebhook}},
+				},
+			},
+			mock: func(r *mocks.NotificationRepo, users *mocks.UserRepo, webhooks *mocks.UserWebhooks, sms *mocks.SMSService) {
+				users.On("GetUserByID", mock.Anything, userID).Return(&models.User{ID: userID}, nil)
+				r.On("SetNotificationPreferences", mock.Anything, userID, mock.Anything).Return(nil)
+				r.On("GetNotificationPreferences", mock.Anything, userID).Return([]models.NotificationPreference{
+					{Event: models.NotificationNewDevice, Channels: []string{models.ChannelWebhook}},
+				}, nil)
+				webhooks.On("GetUserEndpoint", mock.Anything, userID).Return(nil, models.ErrWebhookEndpointNotFound)
+			},
+			expectedErr: models.ErrChannelNotConfigured,
+		},
+		{
+			name: "Unknown channel",
+			update: models.NotificationSettingsUpdate{
+				Preferences: []models.NotificationPreference{
+					{Event: models.NotificationNewDevice, Channels: []string{"pigeon"}},
+				},
+			},
+			mock: func(r *mocks.NotificationRepo, users *mocks.UserRepo, webhooks *mocks.UserWebhooks, sms *mocks.SMSService) {
+			},
+			expectedErr: models.ErrUnknownNotificationChannel,
+		},
+		{
+			name: "Unknown event",
+			update: models.NotificationSettingsUpdate{
+				Preferences: []models.NotificationPreference{
+					{Event: "birthday", Channels: []string{models.ChannelEmail}},
+				},
+			},
+			mock: func(r *mocks.NotificationRepo, users *mocks.UserRepo, webhooks *mocks.UserWebhooks, sms *mocks.SMSService) {
+			},
+			expectedErr: models.ErrUnknownNotificationEvent,
+		},
+		{
+			name:   "Invalid phone",
+			update: models.NotificationSettingsUpdate{Phone: &invalidPhone},
+			mock: func(r *mocks.NotificationRepo, users *mocks.UserRepo, webhooks *mocks.UserWebhooks, sms *mocks.SMSService) {
+			},
+			expectedErr: models.ErrInvalidPhone,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mocks.NewNotificationRepo(t)
+			users := mocks.NewUserRepo(t)
+			webhooks := mocks.NewUserWebhooks(t)
+			sms := mocks.NewSMSService(t)
+			tt.mock(r, users, webhooks, sms)
+
+			n := NewNotifier(r, users, mocks.NewEmailService(t), sms, webhooks, nopTransactor{}, nopLogger{})
+
+			settings, err := n.UpdateSettings(context.Background(), userID, tt.update)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("error = %v, expectedError %v", err, tt.expectedErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if len(settings.Preferences) != len(models.NotificationEvents) {
+				t.Errorf("preferences = %v, expected every event", settings.Preferences)
+			}
+
+			if settings.WebhookSecret != tt.expectedSecret {
+				t.Errorf("webhook secret = %q, expected %q", settings.WebhookSecret, tt.expectedSecret)
+			}
+
+			if settings.PendingPhone != tt.expectedPhone {
+				t.Errorf("pending phone = %q, expected %q", settings.PendingPhone, tt.expectedPhone)
+			}
+		})
+	}
+}
+
+func TestNotifier_ConfirmPhone(t *testing.T) {
+	userID := uuid.New()
+	phone := "+79991234567"
+	code := "123456"
+
+	tests := []struct {
+		name        string
+		stored      func(v *models.PhoneVerification)
+		storedErr   error
+		code        string
+		attempts    int
+		expectedErr error
+	}{
+		{
+			name:     "OK",
+			code:     code,
+			attempts: 1,
+		},
+		{
+			name:        "Wrong code",
+			code:        "654321",
+			attempts:    1,
+			expectedErr: models.ErrInvalidPhoneCode,
+		},
+		{
+			name:        "Too many attempts",
+			code:        code,
+			attempts:    phoneCodeAttempts + 1,
+			expectedErr: models.ErrInvalidPhoneCode,
+		},
+		{
+			name:        "Expired",
+			stored:      func(v *models.PhoneVerification) { v.ExpiresAt = time.Now().Add(-time.Minute) },
+			code:        code,
+			expectedErr: models.ErrInvalidPhoneCode,
+		},
+		{
+			name:        "Used",
+			stored:      func(v *models.PhoneVerification) { v.UsedAt = time.Now().Add(-time.Minute) },
+			code:        code,
+			expectedErr: models.ErrInvalidPhoneCode,
+		},
+		{
+			name:        "No code sent",
+			storedErr:   models.ErrPhoneVerificationNotFound,
+			code:        code,
+			expectedErr: models.ErrInvalidPhoneCode,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verification := &models.PhoneVerification{
+				ID:        1,
+				UserID:    userID,
+				Phone:     phone,
+				CodeHash:  hashOpaqueToken(code),
+				ExpiresAt: time.Now().Add(time.Minute),
+			}
+			if tt.stored != nil {
+				tt.stored(verification)
+			}
+
+			r := mocks.NewNotificationRepo(t)
+			if tt.storedErr != nil {
+				r.On("GetPhoneVerification", mock.Anything, userID).Return(nil, tt.storedErr)
+			} else {
+				r.On("GetPhoneVerification", mock.Anything, userID).Return(verification, nil)
+			}
+
+			if tt.attempts != 0 {
+				r.On("RecordPhoneCodeAttempt", mock.Anything, uint(1)).Return(tt.attempts, nil)
+			}
+
+			if tt.expectedErr == nil {
+				r.On("UsePhoneVerification", mock.Anything, uint(1), mock.Anything).Return(nil)
+				r.On("UpdateUserPhone", mock.Anything, userID, phone).Return(nil)
+			}
+
+			n := NewNotifier(r, mocks.NewUserRepo(t), mocks.NewEmailService(t), mocks.NewSMSService(t), mocks.NewUserWebhooks(t), nopTransactor{}, nopLogger{})
+
+			if err := n.ConfirmPhone(context.Background(), userID, tt.code); !errors.Is(err, tt.expectedErr) {
+				t.Errorf("error = %v, expectedError %v", err, tt.expectedErr)
+			}
+		})
+	}
+}
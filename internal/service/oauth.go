@@ -0,0 +1,358 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"medods-test-task/config"
+	"medods-test-task/internal/models"
+	"medods-test-task/pkg/password"
+	"medods-test-task/pkg/utils"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	responseTypeCode = "code"
+
+	tokenEndpointPath = "/oauth/token"
+
+	minCodeVerifierLength = 43
+	maxCodeVerifierLength = 128
+)
+
+//go:generate go run github.com/vektra/mockery/v2@latest --name OAuthRepo
+type OAuthRepo interface {
+	CreateAuthorizationCode(ctx context.Context, code *models.AuthorizationCode) error
+	ClaimAuthorizationCode(ctx context.Context, codeHash string) (*models.AuthorizationCode, error)
+	UseClientAssertion(ctx context.Context, clientID string, assertion models.ClientAssertion) error
+	CreateDeviceCode(ctx context.Context, code *models.DeviceCode) error
+	GetDeviceCodeByUserCode(ctx context.Context, userCode string) (*models.DeviceCode, error)
+	DecideDeviceCode(ctx context.Context, userCode string, userID uuid.UUID, status string) error
+	PollDeviceCode(ctx context.Context, deviceCodeHash string, polledAt time.Time) (*models.DeviceCode, error)
+	SetDevicePollInterval(ctx context.Context, deviceCodeHash string, interval time.Duration) error
+	DeleteDeviceCode(ctx context.Context, deviceCodeHash string) error
+}
+
+//go:generate go run github.com/vektra/mockery/v2@latest --name OAuthClients
+type OAuthClients interface {
+	GetClient(ctx context.Context, clientID string) (*models.OAuthClient, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@latest --name ClientTokenManager
+type ClientTokenManager interface {
+	NewClientToken(clientID string, scope []string, ttl time.Duration) (string, error)
+	ParseClientAssertion(assertion, publicKey, clientID string, audiences []string) (*models.ClientAssertion, error)
+	ParseJWT(token string) (*utils.Claims, error)
+	SignToken(claims utils.Claims) (string, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@latest --name CredentialsRepo
+type CredentialsRepo interface {
+	GetUserByEmail(ctx context.Context, canonicalEmail string) (*models.User, error)
+	GetUserByID(ctx context.Context, userID uuid.UUID) (*models.User, error)
+	GetUserPasswordHash(ctx context.Context, userID uuid.UUID) (string, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@latest --name SessionIssuer
+type SessionIssuer interface {
+	NewSession(ctx context.Context, clientID, userID, scope, IPAddress, userAgent string) (*models.TokenSet, error)
+	RefreshClientSession(ctx context.Context, clientID, refreshToken, scope, IPAddress, userAgent string) (*models.TokenSet, error)
+}
+
+type oauthService struct {
+	repo        OAuthRepo
+	clients     OAuthClients
+	users       CredentialsRepo
+	emails      EmailPolicy
+	sessions    SessionIssuer
+	tokens      ClientTokenManager
+	roles       RolePolicy
+	guard       BruteForceGuard
+	auditLog    AuditLogger
+	oauthConfig *config.OAuthConfig
+}
+
+func NewOAuthService(repo OAuthRepo, clients OAuthClients, users CredentialsRepo, emails EmailPolicy, sessions SessionIssuer, tokens ClientTokenManager, roles RolePolicy, guard BruteForceGuard, auditLog AuditLogger, oauthConf *config.OAuthConfig) *oauthService {
+	return &oauthService{
+		repo:        repo,
+		clients:     clients,
+		users:       users,
+		emails:      emails,
+		sessions:    sessions,
+		tokens:      tokens,
+		roles:       roles,
+		guard:       guard,
+		auditLog:    auditLog,
+		oauthConfig: oauthConf,
+	}
+}
+
+// ValidateAuthorizeRequest returns the client of a valid request. With
+// models.ErrInvalidClient or models.ErrInvalidRedirectURI the user must not be
+// redirected back, other errors can be reported to the redirect uri.
+func (s *oauthService) ValidateAuthorizeRequest(ctx context.Context, req models.AuthorizeRequest) (*models.OAuthClient, error) {
+	client, err := s.getClient(ctx, req.ClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !slices.Contains(client.RedirectURIs, req.RedirectURI) {
+		return nil, models.ErrInvalidRedirectURI
+	}
+
+	if !client.AllowsGrant(models.GrantTypeAuthorizationCode) {
+		return nil, models.ErrUnauthorizedClient
+	}
+
+	if req.ResponseType != responseTypeCode {
+		return nil, models.ErrUnsupportedResponseType
+	}
+
+	if _, err := grantScope(client, req.Scope); err != nil {
+		return nil, err
+	}
+
+	if req.CodeChallengeMethod != models.CodeChallengeS256 || !isCodeChallenge(req.CodeChallenge) {
+		return nil, models.ErrInvalidCodeChallenge
+	}
+
+	return client, nil
+}
+
+// Authorize signs the user in with their email and password and issues an
+// authorization code bound to the client, redirect uri and code challenge.
+func (s *oauthService) Authorize(ctx context.Context, req models.AuthorizeRequest, email, pass, IPAddress string) (string, error) {
+	if _, err := s.ValidateAuthorizeRequest(ctx, req); err != nil {
+		return "", err
+	}
+
+	user, err := s.authenticate(ctx, email, pass, IPAddress)
+	if err != nil {
+		return "", err
+	}
+
+	code, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+
+	err = s.repo.CreateAuthorizationCode(ctx, &models.AuthorizationCode{
+		CodeHash:      hashOpaqueToken(code),
+		ClientID:      req.ClientID,
+		UserID:        user.ID,
+		RedirectURI:   req.RedirectURI,
+		Scope:         req.Scope,
+		CodeChallenge: req.CodeChallenge,
+		ExpiresAt:     now.Add(s.oauthConfig.CodeTTL),
+		CreatedAt:     now,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// ExchangeCode redeems an authorization code for a new session of the client.
+// The code is consumed even when the exchange fails.
+func (s *oauthService) ExchangeCode(ctx context.Context, creds models.ClientCredentials, code, redirectURI, codeVerifier, IPAddress, userAgent string) (*models.TokenSet, error) {
+	client, err := s.authenticateClient(ctx, creds)
+	if err != nil {
+		return nil, err
+	}
+
+	if !client.AllowsGrant(models.GrantTypeAuthorizationCode) {
+		return nil, models.ErrUnauthorizedClient
+	}
+
+	authCode, err := s.repo.ClaimAuthorizationCode(ctx, hashOpaqueToken(code))
+	if err != nil {
+		return nil, err
+	}
+
+	if authCode.ClientID != client.ID || authCode.RedirectURI != redirectURI || time.Now().After(authCode.ExpiresAt) ||
+		!verifyCodeChallenge(authCode.CodeChallenge, codeVerifier) {
+		return nil, models.ErrInvalidGrant
+	}
+
+	return s.sessions.NewSession(ctx, client.ID, authCode.UserID.String(), authCode.Scope, IPAddress, userAgent)
+}
+
+// RefreshToken rotates the token pair of a session created by the client.
+// Unlike the legacy refresh endpoint it accepts every kind of client
+// authentication and a scope narrowing the new access token.
+func (s *oauthService) RefreshToken(ctx context.Context, creds models.ClientCredentials, refreshToken, scope, IPAddress, userAgent string) (*models.TokenSet, error) {
+	client, err := s.authenticateClient(ctx, creds)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.sessions.RefreshClientSession(ctx, client.ID, refreshToken, scope, IPAddress, userAgent)
+}
+
+// ClientCredentials issues an access token to a confidential client acting on
+// its own behalf. The token has the client as its subject and the requested
+// scope, or every scope of the client when none is requested.
+func (s *oauthService) ClientCredentials(ctx context.Context, creds models.ClientCredentials, scope string) (*models.TokenSet, error) {
+	client, err := s.authenticateClient(ctx, creds)
+	if err != nil {
+		return nil, err
+	}
+
+	if !client.IsConfidential() || !client.AllowsGrant(models.GrantTypeClientCredentials) {
+		return nil, models.ErrUnauthorizedClient
+	}
+
+	granted, err := grantScope(client, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := s.oauthConfig.ClientTokenTTL
+	if client.AccessTokenTTL != 0 {
+		ttl = client.AccessTokenTTL
+	}
+
+	token, err := s.tokens.NewClientToken(client.ID, granted, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TokenSet{AccessToken: token, AccessTTL: ttl, Scope: granted}, nil
+}
+
+func (s *oauthService) getClient(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	client, err := s.clients.GetClient(ctx, clientID)
+	if errors.Is(err, models.ErrOAuthClientNotFound) {
+		return nil, models.ErrInvalidClient
+	}
+
+	return client, err
+}
+
+// authenticateClient checks the secret or the private_key_jwt assertion of a
+// confidential client. Public clients are identified by their id alone and
+// rely on PKCE.
+func (s *oauthService) authenticateClient(ctx context.Context, creds models.ClientCredentials) (*models.OAuthClient, error) {
+	client, err := s.getClient(ctx, creds.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if creds.Assertion == "" {
+		if !verifyClientSecret(client, creds.Secret) {
+			return nil, models.ErrInvalidClient
+		}
+
+		return client, nil
+	}
+
+	if client.PublicKey == "" {
+		return nil, models.ErrInvalidClient
+	}
+
+	audiences := []string{s.oauthConfig.Issuer, strings.TrimSuffix(s.oauthConfig.Issuer, "/") + tokenEndpointPath}
+
+	assertion, err := s.tokens.ParseClientAssertion(creds.Assertion, client.PublicKey, client.ID, audiences)
+	if err != nil {
+		return nil, models.ErrInvalidClient
+	}
+
+	err = s.repo.UseClientAssertion(ctx, client.ID, *assertion)
+	if err != nil {
+		if errors.Is(err, models.ErrAssertionReplayed) {
+			return nil, models.ErrInvalidClient
+		}
+
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// grantScope checks the space separated scope of a request against the
+// scopes of the client.
+func grantScope(client *models.OAuthClient, scope string) ([]string, error) {
+	requested := strings.Fields(scope)
+	if len(requested) == 0 {
+		return nonNil(client.Scopes), nil
+	}
+
+	granted := make([]string, 0, len(requested))
+	for _, item := range requested {
+		if !slices.Contains(client.Scopes, item) {
+			return nil, models.ErrScopeNotAllowed
+		}
+
+		if !slices.Contains(granted, item) {
+			granted = append(granted, item)
+		}
+	}
+
+	return granted, nil
+}
+
+func (s *oauthService) authenticate(ctx context.Context, email, pass, IPAddress string) (*models.User, error) {
+	address, err := s.emails.Check(email)
+	if err != nil {
+		return nil, models.ErrInvalidCredentials
+	}
+
+	user, err := s.users.GetUserByEmail(ctx, address.Canonical)
+	if err != nil {
+		if errors.Is(err, models.ErrUserNotFound) {
+			s.guard.RegisterFailure(ctx, uuid.Nil, IPAddress)
+
+			return nil, models.ErrInvalidCredentials
+		}
+
+		return nil, err
+	}
+
+	if err := s.guard.Check(ctx, user.ID, IPAddress); err != nil {
+		return nil, err
+	}
+
+	hash, err := s.users.GetUserPasswordHash(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if hash == "" || password.Compare(hash, pass) != nil {
+		s.guard.RegisterFailure(ctx, user.ID, IPAddress)
+
+		return nil, models.ErrInvalidCredentials
+	}
+
+	s.guard.Reset(ctx, user.ID)
+
+	return user, nil
+}
+
+// isCodeChallenge reports whether the challenge is a base64url encoded
+// SHA-256 hash.
+func isCodeChallenge(challenge string) bool {
+	decoded, err := base64.RawURLEncoding.DecodeString(challenge)
+
+	return err == nil && len(decoded) == sha256.Size
+}
+
+// verifyCodeChallenge checks the PKCE verifier against an S256 challenge.
+func verifyCodeChallenge(challenge, verifier string) bool {
+	if len(verifier) < minCodeVerifierLength || len(verifier) > maxCodeVerifierLength {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
@@ -0,0 +1,542 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"medods-test-task/config"
+	"medods-test-task/internal/models"
+	"medods-test-task/internal/service/mocks"
+	"medods-test-task/pkg/email"
+	"medods-test-task/pkg/password"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+const testRedirectURI = "http://localhost:3000/callback"
+
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// testClients is a client registry with public spa and mobile clients, a
+// confidential backend client, a cli client without the authorization code
+// grant, worker and signer clients of the client credentials grant, the
+// latter authenticating with a private key, a tv client of the device code
+// grant and a gateway client of token exchange.
+type testClients map[string]models.OAuthClient
+
+func (c testClients) GetClient(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	client, ok := c[clientID]
+	if !ok {
+		return nil, models.ErrOAuthClientNotFound
+	}
+
+	return &client, nil
+}
+
+func newTestOAuthService(t *testing.T, r *mocks.OAuthRepo, users *mocks.CredentialsRepo, sessions *mocks.SessionIssuer, tokens *mocks.ClientTokenManager, guard *mocks.BruteForceGuard) *oauthService {
+	clients := testClients{
+		"spa":     {ID: "spa", RedirectURIs: []string{testRedirectURI}, GrantTypes: defaultGrantTypes},
+		"mobile":  {ID: "mobile", RedirectURIs: []string{"ru.medods.app:/oauth/callback"}, GrantTypes: defaultGrantTypes},
+		"backend": {ID: "backend", SecretHash: hashOpaqueToken("cs_secret"), RedirectURIs: []string{testRedirectURI}, GrantTypes: defaultGrantTypes},
+		"cli":     {ID: "cli", RedirectURIs: []string{testRedirectURI}, GrantTypes: []string{models.GrantTypeRefreshToken}},
+		"worker": {
+			ID: "worker", SecretHash: hashOpaqueToken("cs_secret"), GrantTypes: []string{models.GrantTypeClientCredentials},
+			Scopes: []string{"sessions:read", "users:read"},
+		},
+		"signer": {
+			ID: "signer", PublicKey: "-----BEGIN PUBLIC KEY-----", GrantTypes: []string{models.GrantTypeClientCredentials},
+			Scopes: []string{"users:read"}, AccessTokenTTL: time.Minute,
+		},
+		"tv": {
+			ID: "tv", Name: "TV", GrantTypes: []string{models.GrantTypeDeviceCode, models.GrantTypeRefreshToken},
+			Scopes: []string{"profile"},
+		},
+		"gateway": {
+			ID: "gateway", SecretHash: hashOpaqueToken("cs_secret"), GrantTypes: []string{models.GrantTypeTokenExchange},
+			Scopes: []string{"profile", "sessions:read"},
+		},
+	}
+
+	conf := &config.OAuthConfig{
+		Issuer:             "https://auth.medods.ru",
+		CodeTTL:            time.Minute,
+		ClientTokenTTL:     15 * time.Minute,
+		DeviceCodeTTL:      10 * time.Minute,
+		DevicePollInterval: 5 * time.Second,
+		ExchangeAudiences:  []string{"https://api.medods.ru"},
+		ExchangeTokenTTL:   5 * time.Minute,
+	}
+
+	return NewOAuthService(r, clients, users, email.NewPolicy(nil, true), sessions, tokens, testRoles, guard, mocks.NewAuditLogger(t), conf)
+}
+
+func TestOAuthService_ValidateAuthorizeRequest(t *testing.T) {
+	valid := models.AuthorizeRequest{
+		ResponseType:        "code",
+		ClientID:            "spa",
+		RedirectURI:         testRedirectURI,
+		CodeChallenge:       codeChallenge(strings.Repeat("v", 43)),
+		CodeChallengeMethod: models.CodeChallengeS256,
+	}
+
+	tests := []struct {
+		name        string
+		modify      func(req *models.AuthorizeRequest)
+		expectedErr error
+	}{
+		{
+			name:   "OK",
+			modify: func(req *models.AuthorizeRequest) {},
+		},
+		{
+			name:        "Unknown client",
+			modify:      func(req *models.AuthorizeRequest) { req.ClientID = "unknown" },
+			expectedErr: models.ErrInvalidClient,
+		},
+		{
+			name:        "Grant type not allowed",
+			modify:      func(req *models.AuthorizeRequest) { req.ClientID = "cli" },
+			expectedErr: models.ErrUnauthorizedClient,
+		},
+		{
+			name:        "Unregistered redirect uri",
+			modify:      func(req *models.AuthorizeRequest) { req.RedirectURI = "https://evil.example.com/callback" },
+			expectedErr: models.ErrInvalidRedirectURI,
+		},
+		{
+			name:        "Implicit flow",
+			modify:      func(req *models.AuthorizeRequest) { req.ResponseType = "token" },
+			expectedErr: models.ErrUnsupportedResponseType,
+		},
+		{
+			name:        "Plain challenge",
+			modify:      func(req *models.AuthorizeRequest) { req.CodeChallengeMethod = "plain" },
+			expectedErr: models.ErrInvalidCodeChallenge,
+		},
+		{
+			name:        "Missing challenge",
+			modify:      func(req *models.AuthorizeRequest) { req.CodeChallenge = "" },
+			expectedErr: models.ErrInvalidCodeChallenge,
+		},
+		{
+			name:        "Scope not allowed",
+			modify:      func(req *models.AuthorizeRequest) { req.Scope = "users:write" },
+			expectedErr: models.ErrScopeNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid
+			tt.modify(&req)
+
+			s := newTestOAuthService(t, mocks.NewOAuthRepo(t), mocks.NewCredentialsRepo(t), mocks.NewSessionIssuer(t), mocks.NewClientTokenManager(t), mocks.NewBruteForceGuard(t))
+
+			_, err := s.ValidateAuthorizeRequest(context.Background(), req)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Errorf("error = %v, expectedError %v", err, tt.expectedErr)
+			}
+		})
+	}
+}
+
+func TestOAuthService_Authorize(t *testing.T) {
+	user := &models.User{ID: uuid.New(), Email: "user@email.com"}
+	ip := "127.0.0.1"
+	req := models.AuthorizeRequest{
+		ResponseType:        "code",
+		ClientID:            "spa",
+		RedirectURI:         testRedirectURI,
+		CodeChallenge:       codeChallenge(strings.Repeat("v", 43)),
+		CodeChallengeMethod: models.CodeChallengeS256,
+	}
+
+	hash, err := password.Hash("correct horse battery")
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+
+	type mockBehavior func(r *mocks.OAuthRepo, users *mocks.CredentialsRepo, guard *mocks.BruteForceGuard)
+
+	tests := []struct {
+		name        string
+		email       string
+		password    string
+		mock        mockBehavior
+		expectedErr error
+	}{
+		{
+			name:     "OK",
+			email:    "User@Email.com",
+			password: "correct horse battery",
+			mock: func(r *mocks.OAuthRepo, users *mocks.CredentialsRepo, guard *mocks.BruteForceGuard) {
+				users.On("GetUserByEmail", mock.Anything, "user@email.com").Return(user, nil)
+				guard.On("Check", mock.Anything, user.ID, ip).Return(nil)
+				users.On("GetUserPasswordHash", mock.Anything, user.ID).Return(hash, nil)
+				guard.On("Reset", mock.Anything, user.ID)
+				r.On("CreateAuthorizationCode", mock.Anything, mock.MatchedBy(func(c *models.AuthorizationCode) bool {
+					return c.UserID == user.ID && c.ClientID == "spa" && c.CodeChallenge == req.CodeChallenge && len(c.CodeHash) == 64
+				})).Return(nil)
+			},
+		},
+		{
+			name:     "Wrong password",
+			email:    "user@email.com",
+			password: "wrong password",
+			mock: func(r *mocks.OAuthRepo, users *mocks.CredentialsRepo, guard *mocks.BruteForceGuard) {
+				users.On("GetUserByEmail", mock.Anything, "user@email.com").Return(user, nil)
+				guard.On("Check", mock.Anything, user.ID, ip).Return(nil)
+				users.On("GetUserPasswordHash", mock.Anything, user.ID).Return(hash, nil)
+				guard.On("RegisterFailure", mock.Anything, user.ID, ip)
+			},
+			expectedErr: models.ErrInvalidCredentials,
+		},
+		{
+			name:     "No password set",
+			email:    "user@email.com",
+			password: "correct horse battery",
+			mock: func(r *mocks.OAuthRepo, users *mocks.CredentialsRepo, guard *mocks.BruteForceGuard) {
+				users.On("GetUserByEmail", mock.Anything, "user@email.com").Return(user, nil)
+				guard.On("Check", mock.Anything, user.ID, ip).Return(nil)
+				users.On("GetUserPasswordHash", mock.Anything, user.ID).Return("", nil)
+				guard.On("RegisterFailure", mock.Anything, user.ID, ip)
+			},
+			expectedErr: models.ErrInvalidCredentials,
+		},
+		{
+			name:     "Unknown email",
+			email:    "nobody@email.com",
+			password: "correct horse battery",
+			mock: func(r *mocks.OAuthRepo, users *mocks.CredentialsRepo, guard *mocks.BruteForceGuard) {
+				users.On("GetUserByEmail", mock.Anything, "nobody@email.com").Return(nil, models.ErrUserNotFound)
+				guard.On("RegisterFailure", mock.Anything, uuid.Nil, ip)
+			},
+			expectedErr: models.ErrInvalidCredentials,
+		},
+		{
+			name:     "Account locked",
+			email:    "user@email.com",
+			password: "correct horse battery",
+			mock: func(r *mocks.OAuthRepo, users *mocks.CredentialsRepo, guard *mocks.BruteForceGuard) {
+				users.On("GetUserByEmail", mock.Anything, "user@email.com").Return(user, nil)
+				guard.On("Check", mock.Anything, user.ID, ip).Return(&models.RetryError{Err: models.ErrAccountLocked, RetryAfter: time.Minute})
+			},
+			expectedErr: models.ErrAccountLocked,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mocks.NewOAuthRepo(t)
+			users := mocks.NewCredentialsRepo(t)
+			guard := mocks.NewBruteForceGuard(t)
+			tt.mock(r, users, guard)
+
+			s := newTestOAuthService(t, r, users, mocks.NewSessionIssuer(t), mocks.NewClientTokenManager(t), guard)
+
+			code, err := s.Authorize(context.Background(), req, tt.email, tt.password, ip)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("error = %v, expectedError %v", err, tt.expectedErr)
+			}
+
+			if err == nil && code == "" {
+				t.Error("expected an authorization code")
+			}
+		})
+	}
+}
+
+func TestOAuthService_ExchangeCode(t *testing.T) {
+	userID := uuid.New()
+	verifier := strings.Repeat("a1b2", 12)
+	code := "code"
+
+	authCode := models.AuthorizationCode{
+		CodeHash:      hashOpaqueToken(code),
+		ClientID:      "spa",
+		UserID:        userID,
+		RedirectURI:   testRedirectURI,
+		CodeChallenge: codeChallenge(verifier),
+		ExpiresAt:     time.Now().Add(time.Minute),
+	}
+
+	tests := []struct {
+		name        string
+		clientID    string
+		secret      string
+		redirectURI string
+		verifier    string
+		modify      func(c *models.AuthorizationCode)
+		expectedErr error
+	}{
+		{
+			name:        "OK",
+			clientID:    "spa",
+			redirectURI: testRedirectURI,
+			verifier:    verifier,
+		},
+		{
+			name:        "Wrong verifier",
+			clientID:    "spa",
+			redirectURI: testRedirectURI,
+			verifier:    strings.Repeat("x", 48),
+			expectedErr: models.ErrInvalidGrant,
+		},
+		{
+			name:        "Short verifier",
+			clientID:    "spa",
+			redirectURI: testRedirectURI,
+			verifier:    "short",
+			expectedErr: models.ErrInvalidGrant,
+		},
+		{
+			name:        "Confidential client",
+			clientID:    "backend",
+			secret:      "cs_secret",
+			redirectURI: testRedirectURI,
+			verifier:    verifier,
+			modify:      func(c *models.AuthorizationCode) { c.ClientID = "backend" },
+		},
+		{
+			name:        "Wrong client secret",
+			clientID:    "backend",
+			secret:      "cs_wrong",
+			redirectURI: testRedirectURI,
+			verifier:    verifier,
+			modify:      func(c *models.AuthorizationCode) { c.ClientID = "backend" },
+			expectedErr: models.ErrInvalidClient,
+		},
+		{
+			name:        "Unknown client",
+			clientID:    "unknown",
+			redirectURI: testRedirectURI,
+			verifier:    verifier,
+			expectedErr: models.ErrInvalidClient,
+		},
+		{
+			name:        "Other client",
+			clientID:    "mobile",
+			redirectURI: testRedirectURI,
+			verifier:    verifier,
+			expectedErr: models.ErrInvalidGrant,
+		},
+		{
+			name:        "Other redirect uri",
+			clientID:    "spa",
+			redirectURI: "http://localhost:3000/other",
+			verifier:    verifier,
+			expectedErr: models.ErrInvalidGrant,
+		},
+		{
+			name:        "Expired",
+			clientID:    "spa",
+			redirectURI: testRedirectURI,
+			verifier:    verifier,
+			modify:      func(c *models.AuthorizationCode) { c.ExpiresAt = time.Now().Add(-time.Second) },
+			expectedErr: models.ErrInvalidGrant,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mocks.NewOAuthRepo(t)
+			sessions := mocks.NewSessionIssuer(t)
+
+			claimed := authCode
+			if tt.modify != nil {
+				tt.modify(&claimed)
+			}
+
+			if !errors.Is(tt.expectedErr, models.ErrInvalidClient) {
+				r.On("ClaimAuthorizationCode", mock.Anything, hashOpaqueToken(code)).Return(&claimed, nil)
+			}
+			if tt.expectedErr == nil {
+				sessions.On("NewSession", mock.Anything, tt.clientID, userID.String(), "", "127.0.0.1", "test-agent").Return(&models.TokenSet{AccessToken: "access", RefreshToken: "refresh"}, nil)
+			}
+
+			s := newTestOAuthService(t, r, mocks.NewCredentialsRepo(t), sessions, mocks.NewClientTokenManager(t), mocks.NewBruteForceGuard(t))
+
+			tokens, err := s.ExchangeCode(context.Background(), models.ClientCredentials{ID: tt.clientID, Secret: tt.secret}, code, tt.redirectURI, tt.verifier, "127.0.0.1", "test-agent")
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("error = %v, expectedError %v", err, tt.expectedErr)
+			}
+
+			if err == nil && (tokens.AccessToken != "access" || tokens.RefreshToken != "refresh") {
+				t.Errorf("tokens = %+v, expected the session tokens", tokens)
+			}
+		})
+	}
+}
+
+func TestOAuthService_RefreshToken(t *testing.T) {
+	tests := []struct {
+		name        string
+		creds       models.ClientCredentials
+		sessionErr  error
+		expectedErr error
+	}{
+		{
+			name:  "Public client",
+			creds: models.ClientCredentials{ID: "spa"},
+		},
+		{
+			name:  "Confidential client",
+			creds: models.ClientCredentials{ID: "backend", Secret: "cs_secret"},
+		},
+		{
+			name:        "Wrong client secret",
+			creds:       models.ClientCredentials{ID: "backend", Secret: "cs_wrong"},
+			expectedErr: models.ErrInvalidClient,
+		},
+		{
+			name:        "Unknown client",
+			creds:       models.ClientCredentials{ID: "unknown"},
+			expectedErr: models.ErrInvalidClient,
+		},
+		{
+			name:        "Session of other client",
+			creds:       models.ClientCredentials{ID: "mobile"},
+			sessionErr:  models.ErrClientMismatch,
+			expectedErr: models.ErrClientMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions := mocks.NewSessionIssuer(t)
+			if !errors.Is(tt.expectedErr, models.ErrInvalidClient) {
+				var tokens *models.TokenSet
+				if tt.sessionErr == nil {
+					tokens = &models.TokenSet{AccessToken: "access", RefreshToken: "refresh"}
+				}
+
+				sessions.On("RefreshClientSession", mock.Anything, tt.creds.ID, "refresh", "", "127.0.0.1", "test-agent").Return(tokens, tt.sessionErr)
+			}
+
+			s := newTestOAuthService(t, mocks.NewOAuthRepo(t), mocks.NewCredentialsRepo(t), sessions, mocks.NewClientTokenManager(t), mocks.NewBruteForceGuard(t))
+
+			tokens, err := s.RefreshToken(context.Background(), tt.creds, "refresh", "", "127.0.0.1", "test-agent")
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("error = %v, expectedError %v", err, tt.expectedErr)
+			}
+
+			if err == nil && tokens.AccessToken != "access" {
+				t.Errorf("tokens = %+v, expected the session tokens", tokens)
+			}
+		})
+	}
+}
+
+func TestOAuthService_ClientCredentials(t *testing.T) {
+	assertion := &models.ClientAssertion{ID: "jti", ExpiresAt: time.Now().Add(time.Minute)}
+
+	tests := []struct {
+		name          string
+		creds         models.ClientCredentials
+		scope         string
+		assertionErr  error
+		replayed      bool
+		expectedScope []string
+		expectedTTL   time.Duration
+		expectedErr   error
+	}{
+		{
+			name:          "OK",
+			creds:         models.ClientCredentials{ID: "worker", Secret: "cs_secret"},
+			expectedScope: []string{"sessions:read", "users:read"},
+			expectedTTL:   15 * time.Minute,
+		},
+		{
+			name:          "Requested scope",
+			creds:         models.ClientCredentials{ID: "worker", Secret: "cs_secret"},
+			scope:         "users:read users:read",
+			expectedScope: []string{"users:read"},
+			expectedTTL:   15 * time.Minute,
+		},
+		{
+			name:        "Scope not allowed",
+			creds:       models.ClientCredentials{ID: "worker", Secret: "cs_secret"},
+			scope:       "users:write",
+			expectedErr: models.ErrScopeNotAllowed,
+		},
+		{
+			name:        "Wrong secret",
+			creds:       models.ClientCredentials{ID: "worker", Secret: "cs_wrong"},
+			expectedErr: models.ErrInvalidClient,
+		},
+		{
+			name:        "Public client",
+			creds:       models.ClientCredentials{ID: "spa"},
+			expectedErr: models.ErrUnauthorizedClient,
+		},
+		{
+			name:        "Grant type not allowed",
+			creds:       models.ClientCredentials{ID: "backend", Secret: "cs_secret"},
+			expectedErr: models.ErrUnauthorizedClient,
+		},
+		{
+			name:          "Private key jwt",
+			creds:         models.ClientCredentials{ID: "signer", Assertion: "assertion"},
+			expectedScope: []string{"users:read"},
+			expectedTTL:   time.Minute,
+		},
+		{
+			name:         "Invalid assertion",
+			creds:        models.ClientCredentials{ID: "signer", Assertion: "assertion"},
+			assertionErr: models.ErrInvalidToken,
+			expectedErr:  models.ErrInvalidClient,
+		},
+		{
+			name:        "Replayed assertion",
+			creds:       models.ClientCredentials{ID: "signer", Assertion: "assertion"},
+			replayed:    true,
+			expectedErr: models.ErrInvalidClient,
+		},
+		{
+			name:        "Assertion without key",
+			creds:       models.ClientCredentials{ID: "worker", Assertion: "assertion"},
+			expectedErr: models.ErrInvalidClient,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mocks.NewOAuthRepo(t)
+			tokens := mocks.NewClientTokenManager(t)
+
+			if tt.creds.ID == "signer" {
+				audiences := []string{"https://auth.medods.ru", "https://auth.medods.ru/oauth/token"}
+
+				if tt.assertionErr != nil {
+					tokens.On("ParseClientAssertion", tt.creds.Assertion, mock.Anything, "signer", audiences).Return(nil, tt.assertionErr)
+				} else {
+					tokens.On("ParseClientAssertion", tt.creds.Assertion, mock.Anything, "signer", audiences).Return(assertion, nil)
+
+					var useErr error
+					if tt.replayed {
+						useErr = models.ErrAssertionReplayed
+					}
+					r.On("UseClientAssertion", mock.Anything, "signer", *assertion).Return(useErr)
+				}
+			}
+			if tt.expectedErr == nil {
+				tokens.On("NewClientToken", tt.creds.ID, tt.expectedScope, tt.expectedTTL).Return("access", nil)
+			}
+
+			s := newTestOAuthService(t, r, mocks.NewCredentialsRepo(t), mocks.NewSessionIssuer(t), tokens, mocks.NewBruteForceGuard(t))
+
+			set, err := s.ClientCredentials(context.Background(), tt.creds, tt.scope)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("error = %v, expectedError %v", err, tt.expectedErr)
+			}
+
+			if err == nil && (set.AccessToken != "access" || set.RefreshToken != "" || set.AccessTTL != tt.expectedTTL) {
+				t.Errorf("token set = %+v, expected an access token only", set)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"medods-test-task/config"
+	"medods-test-task/internal/models"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+//go:generate go run github.com/vektra/mockery/v2@latest --name RoleRepo
+type RoleRepo interface {
+	SetUserRoles(ctx context.Context, userID uuid.UUID, roles []string) error
+}
+
+//go:generate go run github.com/vektra/mockery/v2@latest --name RolePolicy
+type RolePolicy interface {
+	UserScopes(roles []string) []string
+}
+
+type roleService struct {
+	repo   RoleRepo
+	scopes map[string][]string
+}
+
+func NewRoleService(repo RoleRepo, conf *config.RolesConfig) *roleService {
+	return &roleService{
+		repo:   repo,
+		scopes: conf.Scopes,
+	}
+}
+
+// SetUserRoles replaces the roles assigned to the user and returns all roles
+// of the user. models.RoleUser is implied and is not stored.
+func (s *roleService) SetUserRoles(ctx context.Context, userID uuid.UUID, roles []string) ([]string, error) {
+	assigned := make([]string, 0, len(roles))
+
+	for _, role := range roles {
+		if role == models.RoleUser || slices.Contains(assigned, role) {
+			continue
+		}
+
+		if _, ok := s.scopes[role]; !ok {
+			return nil, fmt.Errorf("%w: %s", models.ErrUnknownRole, role)
+		}
+
+		assigned = append(assigned, role)
+	}
+
+	if err := s.repo.SetUserRoles(ctx, userID, assigned); err != nil {
+		return nil, err
+	}
+
+	return userRoles(assigned), nil
+}
+
+// UserScopes returns the scopes a user with the assigned roles may be
+// granted.
+func (s *roleService) UserScopes(roles []string) []string {
+	scopes := []string{}
+
+	for _, role := range userRoles(roles) {
+		for _, scope := range s.scopes[role] {
+			if !slices.Contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+
+	return scopes
+}
+
+// userRoles adds models.RoleUser to the assigned roles of a user.
+func userRoles(assigned []string) []string {
+	roles := []string{models.RoleUser}
+
+	for _, role := range assigned {
+		if !slices.Contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+
+	return roles
+}
+
+// intersectScopes returns the requested scopes that are allowed, keeping the
+// order of the request.
+func intersectScopes(requested, allowed []string) []string {
+	scopes := []string{}
+
+	for _, scope := range requested {
+		if slices.Contains(allowed, scope) && !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return scopes
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"medods-test-task/config"
+	"medods-test-task/internal/models"
+	"medods-test-task/internal/service/mocks"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+// testRoles lets every user read their profile and sessions and admins manage
+// sessions as well.
+var testRoles = NewRoleService(nil, &config.RolesConfig{Scopes: map[string][]string{
+	models.RoleUser: {"profile", "sessions:read"},
+	"admin":         {"profile", "sessions:admin"},
+	"support":       {"users:read"},
+}})
+
+func TestRoleService_SetUserRoles(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name          string
+		roles         []string
+		stored        []string
+		expectedRoles []string
+		expectedErr   error
+	}{
+		{
+			name:          "OK",
+			roles:         []string{"admin", "support"},
+			stored:        []string{"admin", "support"},
+			expectedRoles: []string{models.RoleUser, "admin", "support"},
+		},
+		{
+			name:          "Implied and repeated roles",
+			roles:         []string{models.RoleUser, "admin", "admin"},
+			stored:        []string{"admin"},
+			expectedRoles: []string{models.RoleUser, "admin"},
+		},
+		{
+			name:          "No roles",
+			roles:         nil,
+			stored:        []string{},
+			expectedRoles: []string{models.RoleUser},
+		},
+		{
+			name:        "Unknown role",
+			roles:       []string{"root"},
+			expectedErr: models.ErrUnknownRole,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mocks.NewRoleRepo(t)
+			if tt.expectedErr == nil {
+				r.On("SetUserRoles", mock.Anything, userID, tt.stored).Return(nil)
+			}
+
+			s := NewRoleService(r, &config.RolesConfig{Scopes: testRoles.scopes})
+
+			roles, err := s.SetUserRoles(context.Background(), userID, tt.roles)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("error = %v, expectedError %v", err, tt.expectedErr)
+			}
+
+			if !slices.Equal(roles, tt.expectedRoles) {
+				t.Errorf("roles = %v, expected %v", roles, tt.expectedRoles)
+			}
+		})
+	}
+}
+
+func TestRoleService_UserScopes(t *testing.T) {
+	tests := []struct {
+		name     string
+		roles    []string
+		expected []string
+	}{
+		{
+			name:     "User",
+			roles:    nil,
+			expected: []string{"profile", "sessions:read"},
+		},
+		{
+			name:     "Admin and support",
+			roles:    []string{"admin", "support"},
+			expected: []string{"profile", "sessions:read", "sessions:admin", "users:read"},
+		},
+		{
+			name:     "Role without scopes",
+			roles:    []string{"removed"},
+			expected: []string{"profile", "sessions:read"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if scopes := testRoles.UserScopes(tt.roles); !slices.Equal(scopes, tt.expected) {
+				t.Errorf("scopes = %v, expected %v", scopes, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"medods-test-task/config"
+	"medods-test-task/internal/models"
+	"medods-test-task/pkg/logger"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const phoneCodeSMS = "phone_code"
+
+// smsTexts are kept short to fit a single message. The first argument is the
+// IP address, the second one is the revoke link or an empty string.
+var smsTexts = map[string]map[string]string{
+	"ru": {
+		models.NotificationNewDevice:      "Medods: вход с нового устройства, IP %s. Не вы? %s",
+		models.NotificationIPChange:       "Medods: вход с нового IP-адреса %s. Не вы? %s",
+		models.NotificationPasswordChange: "Medods: пароль изменён, IP %s. Не вы? %s",
+		models.NotificationSessionRevoked: "Medods: все сессии завершены, IP %s. %s",
+	},
+	"en": {
+		models.NotificationNewDevice:      "Medods: sign-in from a new device, IP %s. Not you? %s",
+		models.NotificationIPChange:       "Medods: sign-in from a new IP address %s. Not you? %s",
+		models.NotificationPasswordChange: "Medods: your password was changed, IP %s. Not you? %s",
+		models.NotificationSessionRevoked: "Medods: all sessions were ended, IP %s. %s",
+	},
+}
+
+// phoneCodeTexts carry the code confirming a phone.
+var phoneCodeTexts = map[string]string{
+	"ru": "Medods: код подтверждения телефона %s",
+	"en": "Medods: your phone confirmation code is %s",
+}
+
+//go:generate go run github.com/vektra/mockery/v2@latest --name SMSProvider
+type SMSProvider interface {
+	Send(ctx context.Context, to, text string) error
+}
+
+//go:generate go run github.com/vektra/mockery/v2@latest --name SMSRepo
+type SMSRepo interface {
+	CreateOutboxSMS(ctx context.Context, sms *models.OutboxSMS) error
+	ClaimOutboxSMS(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxSMS, error)
+	MarkOutboxSMSSent(ctx context.Context, id uint, attempts int) error
+	RescheduleOutboxSMS(ctx context.Context, id uint, attempts int, nextAttemptAt time.Time, lastError string) error
+	FailOutboxSMS(ctx context.Context, id uint, attempts int, lastError string) error
+}
+
+type smsService struct {
+	repo         SMSRepo
+	provider     SMSProvider
+	emailConfig  *config.EmailConfig
+	outboxConfig *config.OutboxConfig
+	logger       logger.Logger
+}
+
+// NewSMSService queues messages like the email outbox and retries them with
+// the same policy.
+func NewSMSService(repo SMSRepo, provider SMSProvider, logger logger.Logger, emailConf *config.EmailConfig, outboxConf *config.OutboxConfig) *smsService {
+	return &smsService{
+		repo:         repo,
+		provider:     provider,
+		emailConfig:  emailConf,
+		outboxConfig: outboxConf,
+		logger:       logger,
+	}
+}
+
+// SendNotificationSMS queues the message in the outbox. When ctx carries a
+// transaction the message is only sent if that transaction commits.
+func (s *smsService) SendNotificationSMS(ctx context.Context, n models.Notification) error {
+	if n.User.Phone == "" {
+		s.logger.Debug(ctx, "user has no phone, sms skipped", zap.String("event", n.Event))
+
+		return nil
+	}
+
+	texts, ok := smsTexts[n.User.Locale]
+	if !ok {
+		texts = smsTexts[s.emailConfig.DefaultLocale]
+	}
+
+	format, ok := texts[n.Event]
+	if !ok {
+		return fmt.Errorf("%w: %s", models.ErrUnknownNotificationEvent, n.Event)
+	}
+
+	now := time.Now()
+
+	return s.repo.CreateOutboxSMS(ctx, &models.OutboxSMS{
+		UserID:        n.User.ID,
+		Kind:          n.Event,
+		To:            n.User.Phone,
+		Body:          fmt.Sprintf(format, n.Alert.NewIP, revokeURL(s.emailConfig.PublicURL, n.Alert.RevokeToken)),
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	})
+}
+
+// SendPhoneCode queues the code confirming the phone of the user. It is sent
+// to the new phone, which is not stored on the user yet.
+func (s *smsService) SendPhoneCode(ctx context.Context, user *models.User, phone, code string) error {
+	format, ok := phoneCodeTexts[user.Locale]
+	if !ok {
+		format = phoneCodeTexts[s.emailConfig.DefaultLocale]
+	}
+
+	now := time.Now()
+
+	return s.repo.CreateOutboxSMS(ctx, &models.OutboxSMS{
+		UserID:        user.ID,
+		Kind:          phoneCodeSMS,
+		To:            phone,
+		Body:          fmt.Sprintf(format, code),
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	})
+}
+
+func (s *smsService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.outboxConfig.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.SendDue(ctx); err != nil && ctx.Err() == nil {
+			s.logger.Error(ctx, "failed to send queued sms", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *smsService) SendDue(ctx context.Context) error {
+	messages, err := s.repo.ClaimOutboxSMS(ctx, s.outboxConfig.BatchSize, s.outboxConfig.Lease)
+	if err != nil {
+		return err
+	}
+
+	ctx = context.WithoutCancel(ctx)
+
+	for i := range messages {
+		s.send(ctx, &messages[i])
+	}
+
+	return nil
+}
+
+func (s *smsService) send(ctx context.Context, sms *models.OutboxSMS) {
+	sms.Attempts++
+
+	err := s.provider.Send(ctx, sms.To, sms.Body)
+	if err == nil {
+		if err := s.repo.MarkOutboxSMSSent(ctx, sms.ID, sms.Attempts); err != nil {
+			s.logger.Error(ctx, "failed to mark sms sent", zap.Uint("sms_id", sms.ID), zap.Error(err))
+		}
+
+		return
+	}
+
+	sms.LastError = err.Error()
+
+	if sms.Attempts >= s.outboxConfig.MaxAttempts {
+		s.logger.Error(ctx, "sms dropped after max attempts", zap.Uint("sms_id", sms.ID), zap.Error(err))
+
+		if err := s.repo.FailOutboxSMS(ctx, sms.ID, sms.Attempts, sms.LastError); err != nil {
+			s.logger.Error(ctx, "failed to mark sms failed", zap.Uint("sms_id", sms.ID), zap.Error(err))
+		}
+
+		return
+	}
+
+	next := time.Now().Add(retryDelay(s.outboxConfig, sms.Attempts))
+	if err := s.repo.RescheduleOutboxSMS(ctx, sms.ID, sms.Attempts, next, sms.LastError); err != nil {
+		s.logger.Error(ctx, "failed to reschedule sms", zap.Uint("sms_id", sms.ID), zap.Error(err))
+	}
+}
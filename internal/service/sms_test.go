@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"medods-test-task/config"
+	"medods-test-task/internal/models"
+	"medods-test-task/internal/service/mocks"
+	"medods-test-task/pkg/sms/fake"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSMSService_SendNotificationSMS(t *testing.T) {
+	alert := models.SecurityAlert{NewIP: "10.1.0.1", RevokeToken: "token"}
+
+	tests := []struct {
+		name         string
+		user         *models.User
+		expectedBody string
+	}{
+		{
+			name:         "User locale",
+			user:         &models.User{ID: uuid.New(), Phone: "+79991234567", Locale: "en"},
+			expectedBody: "Medods: sign-in from a new device, IP 10.1.0.1. Not you? https://auth.example.com/v1/auth/revoke?token=token",
+		},
+		{
+			name:         "Default locale",
+			user:         &models.User{ID: uuid.New(), Phone: "+79991234567"},
+			expectedBody: "Medods: вход с нового устройства, IP 10.1.0.1. Не вы? https://auth.example.com/v1/auth/revoke?token=token",
+		},
+		{
+			name: "User without phone",
+			user: &models.User{ID: uuid.New(), Locale: "en"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mocks.NewSMSRepo(t)
+			if tt.expectedBody != "" {
+				r.On("CreateOutboxSMS", mock.Anything, mock.MatchedBy(func(sms *models.OutboxSMS) bool {
+					return sms.To == tt.user.Phone && sms.UserID == tt.user.ID && sms.Kind == models.NotificationNewDevice && sms.Body == tt.expectedBody
+				})).Return(nil)
+			}
+
+			emailConf := &config.EmailConfig{DefaultLocale: "ru", PublicURL: "https://auth.example.com"}
+			s := NewSMSService(r, fake.NewProvider(), nopLogger{}, emailConf, &config.OutboxConfig{})
+
+			err := s.SendNotificationSMS(context.Background(), models.Notification{Event: models.NotificationNewDevice, User: tt.user, Alert: alert})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestSMSService_SendPhoneCode(t *testing.T) {
+	user := &models.User{ID: uuid.New(), Phone: "+79991234567", Locale: "en"}
+	phone := "+79997654321"
+
+	r := mocks.NewSMSRepo(t)
+	r.On("CreateOutboxSMS", mock.Anything, mock.MatchedBy(func(sms *models.OutboxSMS) bool {
+		return sms.To == phone && sms.UserID == user.ID && sms.Kind == phoneCodeSMS && sms.Body == "Medods: your phone confirmation code is 012345"
+	})).Return(nil)
+
+	s := NewSMSService(r, fake.NewProvider(), nopLogger{}, &config.EmailConfig{DefaultLocale: "ru"}, &config.OutboxConfig{})
+
+	if err := s.SendPhoneCode(context.Background(), user, phone, "012345"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestSMSService_SendDue(t *testing.T) {
+	cfg := &config.OutboxConfig{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute, Lease: time.Minute, BatchSize: 10}
+	sms := models.OutboxSMS{ID: 1, To: "+79991234567", Body: "Medods: test"}
+
+	r := mocks.NewSMSRepo(t)
+	r.On("ClaimOutboxSMS", mock.Anything, cfg.BatchSize, cfg.Lease).Return([]models.OutboxSMS{sms}, nil)
+	r.On("MarkOutboxSMSSent", mock.Anything, uint(1), 1).Return(nil)
+
+	provider := fake.NewProvider()
+	s := NewSMSService(r, provider, nopLogger{}, &config.EmailConfig{}, cfg)
+
+	if err := s.SendDue(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if messages := provider.Messages(); len(messages) != 1 || messages[0] != (fake.Message{To: sms.To, Text: sms.Body}) {
+		t.Errorf("messages = %+v, expected the queued sms", messages)
+	}
+}
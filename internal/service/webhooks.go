@@ -0,0 +1,393 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"medods-test-task/config"
+	"medods-test-task/internal/models"
+	"medods-test-task/pkg/logger"
+	"medods-test-task/pkg/webhook"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	webhookSecretLength = 32
+	webhookSecretPrefix = "whsec_"
+
+	webhookNotificationPrefix = "notification."
+)
+
+//go:generate go run github.com/vektra/mockery/v2@latest --name WebhookRepo
+type WebhookRepo interface {
+	CreateWebhookEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error
+	ListWebhookEndpoints(ctx context.Context) ([]models.WebhookEndpoint, error)
+	ListWebhookEndpointsByEvent(ctx context.Context, eventType string) ([]models.WebhookEndpoint, error)
+	GetUserWebhookEndpoint(ctx context.Context, userID uuid.UUID) (*models.WebhookEndpoint, error)
+	DeleteWebhookEndpoint(ctx context.Context, id uuid.UUID) error
+	DeleteUserWebhookEndpoint(ctx context.Context, userID uuid.UUID) error
+	CreateWebhookDeliveries(ctx context.Context, deliveries []models.WebhookDelivery) error
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error)
+	MarkWebhookDelivered(ctx context.Context, id uint, attempts int) error
+	RescheduleWebhookDelivery(ctx context.Context, id uint, attempts int, nextAttemptAt time.Time, lastError string) error
+	DeadLetterWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+}
+
+//go:generate go run github.com/vektra/mockery/v2@latest --name WebhookSender
+type WebhookSender interface {
+	Send(ctx context.Context, url, secret, id string, body []byte) error
+}
+
+type webhookPayload struct {
+	ID        uuid.UUID          `json:"id"`
+	Type      string             `json:"type"`
+	CreatedAt time.Time          `json:"created_at"`
+	Data      webhookPayloadData `json:"data"`
+}
+
+type webhookPayloadData struct {
+	UserID    string `json:"user_id,omitempty"`
+	SessionID uint   `json:"session_id,omitempty"`
+	IP        string `json:"ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+type webhookService struct {
+	repo   WebhookRepo
+	sender WebhookSender
+	// userSender delivers to the endpoints of users and must refuse
+	// receivers inside the network of the service.
+	userSender WebhookSender
+	config     *config.WebhookConfig
+	logger     logger.Logger
+}
+
+func NewWebhookService(repo WebhookRepo, sender, userSender WebhookSender, logger logger.Logger, cfg *config.WebhookConfig) *webhookService {
+	return &webhookService{
+		repo:       repo,
+		sender:     sender,
+		userSender: userSender,
+		config:     cfg,
+		logger:     logger,
+	}
+}
+
+func (s *webhookService) RegisterEndpoint(ctx context.Context, endpointURL string, events []string) (*models.WebhookEndpoint, error) {
+	if len(events) == 0 {
+		events = models.WebhookEvents
+	}
+
+	for _, event := range events {
+		if !slices.Contains(models.WebhookEvents, event) {
+			return nil, models.ErrUnknownWebhookEvent
+		}
+	}
+
+	endpoint, err := newWebhookEndpoint(uuid.Nil, endpointURL, events)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CreateWebhookEndpoint(ctx, endpoint); err != nil {
+		return nil, err
+	}
+
+	return endpoint, nil
+}
+
+// SetUserEndpoint replaces the endpoint that receives the user's own
+// notifications. The new endpoint gets a fresh signing secret.
+func (s *webhookService) SetUserEndpoint(ctx context.Context, userID uuid.UUID, endpointURL string) (*models.WebhookEndpoint, error) {
+	endpoint, err := newWebhookEndpoint(userID, endpointURL, models.NotificationEvents)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.DeleteUserEndpoint(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CreateWebhookEndpoint(ctx, endpoint); err != nil {
+		return nil, err
+	}
+
+	return endpoint, nil
+}
+
+func (s *webhookService) GetUserEndpoint(ctx context.Context, userID uuid.UUID) (*models.WebhookEndpoint, error) {
+	return s.repo.GetUserWebhookEndpoint(ctx, userID)
+}
+
+func (s *webhookService) DeleteUserEndpoint(ctx context.Context, userID uuid.UUID) error {
+	err := s.repo.DeleteUserWebhookEndpoint(ctx, userID)
+	if errors.Is(err, models.ErrWebhookEndpointNotFound) {
+		return nil
+	}
+
+	return err
+}
+
+func newWebhookEndpoint(userID uuid.UUID, endpointURL string, events []string) (*models.WebhookEndpoint, error) {
+	u, err := url.Parse(endpointURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, models.ErrInvalidWebhookURL
+	}
+
+	// Only users are kept to public receivers. Their host names are checked
+	// again on every delivery, once resolved.
+	if userID != uuid.Nil {
+		if addr, err := netip.ParseAddr(u.Hostname()); u.Hostname() == "localhost" || err == nil && !webhook.IsPublic(addr) {
+			return nil, models.ErrNonPublicWebhookURL
+		}
+	}
+
+	secret := make([]byte, webhookSecretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	return &models.WebhookEndpoint{
+		ID:        uuid.New(),
+		UserID:    userID,
+		URL:       u.String(),
+		Secret:    webhookSecretPrefix + hex.EncodeToString(secret),
+		Events:    events,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+func (s *webhookService) ListEndpoints(ctx context.Context) ([]models.WebhookEndpoint, error) {
+	return s.repo.ListWebhookEndpoints(ctx)
+}
+
+func (s *webhookService) DeleteEndpoint(ctx context.Context, id uuid.UUID) error {
+	return s.repo.DeleteWebhookEndpoint(ctx, id)
+}
+
+// Publish queues a delivery of the event for every endpoint subscribed to it.
+// Failures are logged and never interrupt the authentication flow.
+func (s *webhookService) Publish(ctx context.Context, event models.AuthEvent) {
+	eventType := webhookEventType(event)
+	if eventType == "" {
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+
+	endpoints, err := s.repo.ListWebhookEndpointsByEvent(ctx, eventType)
+	if err != nil {
+		s.logger.Error(ctx, "failed to list webhook endpoints", zap.String("event", eventType), zap.Error(err))
+
+		return
+	}
+
+	if len(endpoints) == 0 {
+		return
+	}
+
+	if requestID, ok := ctx.Value(logger.RequestIDKey{}).(string); ok && event.RequestID == "" {
+		event.RequestID = requestID
+	}
+
+	payload := webhookPayload{
+		ID:        uuid.New(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data: webhookPayloadData{
+			SessionID: event.SessionID,
+			IP:        event.IP,
+			UserAgent: event.UserAgent,
+			Reason:    event.Reason,
+			RequestID: event.RequestID,
+		},
+	}
+
+	if event.UserID != uuid.Nil {
+		payload.Data.UserID = event.UserID.String()
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		s.logger.Error(ctx, "failed to marshal webhook payload", zap.Error(err))
+
+		return
+	}
+
+	deliveries := make([]models.WebhookDelivery, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		deliveries = append(deliveries, models.WebhookDelivery{
+			EndpointID:    endpoint.ID,
+			EventType:     eventType,
+			Payload:       body,
+			NextAttemptAt: payload.CreatedAt,
+			CreatedAt:     payload.CreatedAt,
+		})
+	}
+
+	if err := s.repo.CreateWebhookDeliveries(ctx, deliveries); err != nil {
+		s.logger.Error(ctx, "failed to queue webhook deliveries", zap.String("event", eventType), zap.Error(err))
+	}
+}
+
+// PublishToUser queues the notification for the user's own endpoint. Unlike
+// Publish it joins the transaction from the context and returns errors, as
+// the user chose this channel.
+func (s *webhookService) PublishToUser(ctx context.Context, n models.Notification) error {
+	endpoint, err := s.repo.GetUserWebhookEndpoint(ctx, n.User.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrWebhookEndpointNotFound) {
+			s.logger.Debug(ctx, "user has no webhook endpoint, notification skipped", zap.String("event", n.Event))
+
+			return nil
+		}
+
+		return err
+	}
+
+	payload := webhookPayload{
+		ID:        uuid.New(),
+		Type:      webhookNotificationPrefix + n.Event,
+		CreatedAt: time.Now().UTC(),
+		Data: webhookPayloadData{
+			UserID:    n.User.ID.String(),
+			IP:        n.Alert.NewIP,
+			UserAgent: n.Alert.UserAgent,
+		},
+	}
+
+	if requestID, ok := ctx.Value(logger.RequestIDKey{}).(string); ok {
+		payload.Data.RequestID = requestID
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.CreateWebhookDeliveries(ctx, []models.WebhookDelivery{{
+		EndpointID:    endpoint.ID,
+		EventType:     payload.Type,
+		Payload:       body,
+		NextAttemptAt: payload.CreatedAt,
+		CreatedAt:     payload.CreatedAt,
+	}})
+}
+
+func (s *webhookService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.DeliverDue(ctx); err != nil && ctx.Err() == nil {
+			s.logger.Error(ctx, "failed to deliver webhooks", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue claims a batch of due deliveries and sends them concurrently.
+// Every attempt is cut off after the configured timeout, so the whole batch
+// is done well within the lease and no other instance picks up a delivery
+// that is still in flight. Attempts in flight are finished on shutdown.
+func (s *webhookService) DeliverDue(ctx context.Context) error {
+	deliveries, err := s.repo.ClaimWebhookDeliveries(ctx, s.config.BatchSize, 2*s.config.Timeout)
+	if err != nil {
+		return err
+	}
+
+	ctx = context.WithoutCancel(ctx)
+
+	var wg sync.WaitGroup
+
+	for i := range deliveries {
+		wg.Add(1)
+
+		go func(d *models.WebhookDelivery) {
+			defer wg.Done()
+
+			s.deliver(ctx, d)
+		}(&deliveries[i])
+	}
+
+	wg.Wait()
+
+	return nil
+}
+
+func (s *webhookService) deliver(ctx context.Context, d *models.WebhookDelivery) {
+	d.Attempts++
+
+	sender := s.sender
+	if d.UserID != uuid.Nil {
+		sender = s.userSender
+	}
+
+	err := sender.Send(ctx, d.URL, d.Secret, strconv.FormatUint(uint64(d.ID), 10), d.Payload)
+	if err == nil {
+		if err := s.repo.MarkWebhookDelivered(ctx, d.ID, d.Attempts); err != nil {
+			s.logger.Error(ctx, "failed to mark webhook delivered", zap.Uint("delivery_id", d.ID), zap.Error(err))
+		}
+
+		return
+	}
+
+	d.LastError = err.Error()
+
+	if d.Attempts >= s.config.MaxAttempts {
+		s.logger.Warn(ctx, "webhook moved to dead letters", zap.Uint("delivery_id", d.ID), zap.Error(err))
+
+		if err := s.repo.DeadLetterWebhookDelivery(ctx, d); err != nil {
+			s.logger.Error(ctx, "failed to dead-letter webhook", zap.Uint("delivery_id", d.ID), zap.Error(err))
+		}
+
+		return
+	}
+
+	next := time.Now().Add(s.retryDelay(d.Attempts))
+	if err := s.repo.RescheduleWebhookDelivery(ctx, d.ID, d.Attempts, next, d.LastError); err != nil {
+		s.logger.Error(ctx, "failed to reschedule webhook", zap.Uint("delivery_id", d.ID), zap.Error(err))
+	}
+}
+
+// retryDelay doubles the delay after every failed attempt.
+func (s *webhookService) retryDelay(attempts int) time.Duration {
+	delay := s.config.BaseDelay
+	for i := 1; i < attempts && delay < s.config.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, s.config.MaxDelay)
+}
+
+func webhookEventType(event models.AuthEvent) string {
+	switch {
+	case event.Outcome == models.AuthOutcomeSuccess &&
+		(event.Type == models.AuthEventLogin || event.Type == models.AuthEventRotation):
+		return models.WebhookEventSessionCreated
+	case event.Type == models.AuthEventRevocation:
+		return models.WebhookEventSessionRevoked
+	case event.Type == models.AuthEventIPMismatch:
+		return models.WebhookEventIPMismatch
+	case event.Reason == models.AuthReasonTokenMismatch:
+		return models.WebhookEventTokenReuseDetected
+	default:
+		return ""
+	}
+}
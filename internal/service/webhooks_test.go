@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"medods-test-task/config"
+	"medods-test-task/internal/models"
+	"medods-test-task/internal/service/mocks"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWebhookService_DeliverDue(t *testing.T) {
+	secret := "whsec_test"
+	payload := []byte(`{"type":"session.created"}`)
+	errReceiver := errors.New("webhook receiver responded with status 503")
+
+	cfg := &config.WebhookConfig{
+		MaxAttempts: 3,
+		BaseDelay:   time.Second,
+		MaxDelay:    time.Minute,
+		Timeout:     time.Second,
+		BatchSize:   10,
+	}
+
+	tests := []struct {
+		name     string
+		userID   uuid.UUID
+		sendErr  error
+		attempts int
+		repoMock func(r *mocks.WebhookRepo)
+	}{
+		{
+			name:     "Delivered",
+			sendErr:  nil,
+			attempts: 0,
+			repoMock: func(r *mocks.WebhookRepo) {
+				r.On("MarkWebhookDelivered", mock.Anything, uint(1), 1).Return(nil)
+			},
+		},
+		{
+			name:     "Delivered to a user endpoint",
+			userID:   uuid.New(),
+			sendErr:  nil,
+			attempts: 0,
+			repoMock: func(r *mocks.WebhookRepo) {
+				r.On("MarkWebhookDelivered", mock.Anything, uint(1), 1).Return(nil)
+			},
+		},
+		{
+			name:     "Retried with backoff",
+			sendErr:  errReceiver,
+			attempts: 1,
+			repoMock: func(r *mocks.WebhookRepo) {
+				r.On("RescheduleWebhookDelivery", mock.Anything, uint(1), 2, mock.MatchedBy(func(next time.Time) bool {
+					return next.After(time.Now().Add(time.Second))
+				}), mock.Anything).Return(nil)
+			},
+		},
+		{
+			name:     "Dead-lettered",
+			sendErr:  errReceiver,
+			attempts: 2,
+			repoMock: func(r *mocks.WebhookRepo) {
+				r.On("DeadLetterWebhookDelivery", mock.Anything, mock.MatchedBy(func(d *models.WebhookDelivery) bool {
+					return d.Attempts == 3 && d.LastError != ""
+				})).Return(nil)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mocks.NewWebhookRepo(t)
+			r.On("ClaimWebhookDeliveries", mock.Anything, cfg.BatchSize, mock.Anything).Return([]models.WebhookDelivery{
+				{
+					ID:         1,
+					EndpointID: uuid.New(),
+					UserID:     tt.userID,
+					URL:        "https://hooks.example.com/auth",
+					Secret:     secret,
+					EventType:  models.WebhookEventSessionCreated,
+					Payload:    payload,
+					Attempts:   tt.attempts,
+				},
+			}, nil)
+			tt.repoMock(r)
+
+			// Deliveries to the endpoint of a user go through the sender that
+			// refuses receivers inside the network.
+			sender, userSender := mocks.NewWebhookSender(t), mocks.NewWebhookSender(t)
+
+			expected := sender
+			if tt.userID != uuid.Nil {
+				expected = userSender
+			}
+
+			expected.On("Send", mock.Anything, "https://hooks.example.com/auth", secret, "1", payload).Return(tt.sendErr)
+
+			s := NewWebhookService(r, sender, userSender, nopLogger{}, cfg)
+
+			if err := s.DeliverDue(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestNewWebhookEndpoint(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name        string
+		userID      uuid.UUID
+		url         string
+		expectedErr error
+	}{
+		{name: "Public", userID: userID, url: "https://hooks.example.com/auth", expectedErr: nil},
+		{name: "Invalid scheme", userID: userID, url: "ftp://hooks.example.com/auth", expectedErr: models.ErrInvalidWebhookURL},
+		{name: "Localhost", userID: userID, url: "http://localhost:8080/hook", expectedErr: models.ErrNonPublicWebhookURL},
+		{name: "Loopback", userID: userID, url: "http://127.0.0.1/hook", expectedErr: models.ErrNonPublicWebhookURL},
+		{name: "Link-local", userID: userID, url: "http://169.254.169.254/latest/meta-data", expectedErr: models.ErrNonPublicWebhookURL},
+		{name: "Private IPv6", userID: userID, url: "http://[fd00::1]/hook", expectedErr: models.ErrNonPublicWebhookURL},
+		{name: "Operator endpoint inside the network", userID: uuid.Nil, url: "http://10.0.0.5:8080/hook", expectedErr: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newWebhookEndpoint(tt.userID, tt.url, nil)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Errorf("error = %v, expectedError %v", err, tt.expectedErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"medods-test-task/internal/models"
+	"medods-test-task/internal/transport/http/middleware"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type CreateAPIKeyRequest struct {
+	// Shown in the list of keys
+	Name string `json:"name"`
+	// Scopes granted to the user by their roles and to the access token
+	Scopes []string `json:"scopes"`
+	// The key never expires when absent
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func (r *CreateAPIKeyRequest) params() models.APIKeyParams {
+	params := models.APIKeyParams{
+		Name:   r.Name,
+		Scopes: r.Scopes,
+	}
+
+	if r.ExpiresAt != nil {
+		params.ExpiresAt = *r.ExpiresAt
+	}
+
+	return params
+}
+
+// CreateAPIKey godoc
+// @Summary      CreateAPIKey
+// @Description  Creates an api key of the current user for scripts and machine users. The key gets no more roles and scopes than the access token it is created with. The key is returned only once. It is sent as the bearer token or in the X-API-Key header and cannot manage credentials.
+// @Tags         me
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param key body CreateAPIKeyRequest true "Key settings"
+// @Success      201 {object} APIKeyResponse "Created key"
+// @Failure      400 {object} ErrorResponse "Invalid key settings"
+// @Failure      401 {object} ErrorResponse "Unauthorized"
+// @Failure      403 {object} ErrorResponse "Token of the legacy login or an api key"
+// @Failure      500 {object} ErrorResponse "An unexpected error occurred"
+// @Router /me/api-keys [post]
+func (c *AppController) CreateAPIKey(ctx *gin.Context) {
+	claims := middleware.Claims(ctx)
+
+	c.createAPIKey(ctx, middleware.UserID(ctx), &models.APIKeyLimit{
+		Roles:  claims.Roles,
+		Scopes: strings.Fields(claims.Scope),
+	})
+}
+
+// ListAPIKeys godoc
+// @Summary      ListAPIKeys
+// @Description  Lists the api keys of the current user, revoked ones included, newest first
+// @Tags         me
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {array} APIKeyResponse "Keys"
+// @Failure      401 {object} ErrorResponse "Unauthorized"
+// @Failure      403 {object} ErrorResponse "Token of the legacy login or an api key"
+// @Failure      500 {object} ErrorResponse "An unexpected error occurred"
+// @Router /me/api-keys [get]
+func (c *AppController) ListAPIKeys(ctx *gin.Context) {
+	c.listAPIKeys(ctx, middleware.UserID(ctx))
+}
+
+// RevokeAPIKey godoc
+// @Summary      RevokeAPIKey
+// @Description  Revokes an api key of the current user, it stops working at once
+// @Tags         me
+// @Security     BearerAuth
+// @Param id path string true "Key id"
+// @Success      204 "Key revoked"
+// @Failure      400 {object} ErrorResponse "Key id must be a valid UUID"
+// @Failure      401 {object} ErrorResponse "Unauthorized"
+// @Failure      403 {object} ErrorResponse "Token of the legacy login or an api key"
+// @Failure      404 {object} ErrorResponse "API key was not found"
+// @Failure      500 {object} ErrorResponse "An unexpected error occurred"
+// @Router /me/api-keys/{id} [delete]
+func (c *AppController) RevokeAPIKey(ctx *gin.Context) {
+	c.revokeAPIKey(ctx, middleware.UserID(ctx), ctx.Param("id"))
+}
+
+// CreateUserAPIKey godoc
+// @Summary      CreateUserAPIKey
+// @Description  Creates an api key of a user, such as a machine user. The key is returned only once.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param id path string true "User id"
+// @Param key body CreateAPIKeyRequest true "Key settings"
+// @Success      201 {object} APIKeyResponse "Created key"
+// @Failure      400 {object} ErrorResponse "Invalid key settings"
+// @Failure      401 {object} ErrorResponse "Unauthorized"
+// @Failure      404 {object} ErrorResponse "User was not found"
+// @Failure      500 {object} ErrorResponse "An unexpected error occurred"
+// @Router /admin/users/{id}/api-keys [post]
+func (c *AppController) CreateUserAPIKey(ctx *gin.Context) {
+	userID, ok := userIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	c.createAPIKey(ctx, userID, nil)
+}
+
+// ListUserAPIKeys godoc
+// @Summary      ListUserAPIKeys
+// @Description  Lists the api keys of a user, revoked ones included, newest first
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param id path string true "User id"
+// @Success      200 {array} APIKeyResponse "Keys"
+// @Failure      400 {object} ErrorResponse "User id must be a valid UUID"
+// @Failure      401 {object} ErrorResponse "Unauthorized"
+// @Failure      500 {object} ErrorResponse "An unexpected error occurred"
+// @Router /admin/users/{id}/api-keys [get]
+func (c *AppController) ListUserAPIKeys(ctx *gin.Context) {
+	userID, ok := userIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	c.listAPIKeys(ctx, userID)
+}
+
+// RevokeUserAPIKey godoc
+// @Summary      RevokeUserAPIKey
+// @Description  Revokes an api key of a user, it stops working at once
+// @Tags         admin
+// @Security     BearerAuth
+// @Param id path string true "User id"
+// @Param keyID path string true "Key id"
+// @Success      204 "Key revoked"
+// @Failure      400 {object} ErrorResponse "User id must be a valid UUID"
+// @Failure      401 {object} ErrorResponse "Unauthorized"
+// @Failure      404 {object} ErrorResponse "API key was not found"
+// @Failure      500 {object} ErrorResponse "An unexpected error occurred"
+// @Router /admin/users/{id}/api-keys/{keyID} [delete]
+func (c *AppController) RevokeUserAPIKey(ctx *gin.Context) {
+	userID, ok := userIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	c.revokeAPIKey(ctx, userID, ctx.Param("keyID"))
+}
+
+// createAPIKey creates a key of the user. A key created by the user with an
+// access token is limited to the token, one created by an operator is not.
+func (c *AppController) createAPIKey(ctx *gin.Context, userID uuid.UUID, limit *models.APIKeyLimit) {
+	var req CreateAPIKeyRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body."})
+
+		return
+	}
+
+	params := req.params()
+	params.Limit = limit
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), RequestTimeout)
+	defer cancel()
+
+	key, err := c.apiKeys.CreateAPIKey(ctxWithTimeout, userID, params)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidAPIKeyName), errors.Is(err, models.ErrInvalidAPIKeyScope),
+			errors.Is(err, models.ErrInvalidAPIKeyExpiry):
+			ctx.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		case errors.Is(err, models.ErrUserNotFound):
+			ctx.JSON(http.StatusNotFound, ErrorResponse{Error: "User was not found."})
+		default:
+			c.logger.Error(ctx, "Failed to create api key", zap.Error(err))
+			ctx.JSON(http.StatusInternalServerError, ErrorResponse{Error: "An unexpected error occurred."})
+		}
+
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, newAPIKeyResponse(key))
+}
+
+func (c *AppController) listAPIKeys(ctx *gin.Context, userID uuid.UUID) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), RequestTimeout)
+	defer cancel()
+
+	keys, err := c.apiKeys.ListAPIKeys(ctxWithTimeout, userID)
+	if err != nil {
+		c.logger.Error(ctx, "Failed to list api keys", zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError, ErrorResponse{Error: "An unexpected error occurred."})
+
+		return
+	}
+
+	resp := make([]APIKeyResponse, 0, len(keys))
+	for i := range keys {
+		resp = append(resp, newAPIKeyResponse(&keys[i]))
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+func (c *AppController) revokeAPIKey(ctx *gin.Context, userID uuid.UUID, id string) {
+	keyID, err := uuid.Parse(id)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{Error: "Key id must be a valid UUID."})
+
+		return
+	}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), RequestTimeout)
+	defer cancel()
+
+	if err := c.apiKeys.RevokeAPIKey(ctxWithTimeout, userID, keyID); err != nil {
+		if errors.Is(err, models.ErrAPIKeyNotFound) {
+			ctx.JSON(http.StatusNotFound, ErrorResponse{Error: "API key was not found."})
+
+			return
+		}
+
+		c.logger.Error(ctx, "Failed to revoke api key", zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError, ErrorResponse{Error: "An unexpected error occurred."})
+
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func userIDParam(ctx *gin.Context) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{Error: "User id must be a valid UUID."})
+
+		return uuid.Nil, false
+	}
+
+	return userID, true
+}
+
+func newAPIKeyResponse(key *models.APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		ID:         key.ID.String(),
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.Scopes,
+		Key:        key.Key,
+		ExpiresAt:  timeOrNil(key.ExpiresAt),
+		LastUsedAt: timeOrNil(key.LastUsedAt),
+		LastUsedIP: key.LastUsedIP,
+		RevokedAt:  timeOrNil(key.RevokedAt),
+		CreatedAt:  key.CreatedAt,
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"medods-test-task/internal/models"
+	"medods-test-task/internal/transport/http/middleware"
 	"net/http"
 	"time"
 
@@ -15,28 +16,39 @@ const RequestTimeout = 10 * time.Second
 
 type RefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token"`
+	// Required for sessions created by an oauth client
+	ClientID string `json:"client_id"`
+	// Required for sessions of a confidential client
+	ClientSecret string `json:"client_secret"`
 }
 
 // Login godoc
 // @Summary      Login
-// @Description  Authenticates a user and generates access and refresh tokens
+// @Description  Authenticates a user and generates access and refresh tokens. Deprecated: clients should use the authorization code flow at /oauth/authorize.
 // @Tags         auth
+// @Deprecated
 // @Accept       json
 // @Produce      json
 // @Param user_id query string true "User GUID (xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx)"
+// @Param scope query string false "Space separated scopes of the user role, all of them when empty. Elevated roles are granted only through /oauth/authorize."
 // @Success      200 {object} TokenResponse "access_token & refresh_token"
 // @Failure      400 {object} ErrorResponse "User id is empty"
 // @Failure      400 {object} ErrorResponse "User id must be a valid UUID"
+// @Failure      423 {object} ErrorResponse "Account is temporarily locked"
+// @Failure      429 {object} ErrorResponse "Too many requests"
 // @Failure      500 {object} ErrorResponse "An unexpected error occurred"
 // @Router /auth/login [post]
 func (c *AppController) Login(ctx *gin.Context) {
+	ctx.Header("Deprecation", "true")
+	ctx.Header("Link", `</oauth/authorize>; rel="alternate"`)
+
 	userID := ctx.Query("user_id")
 	IPAddress := ctx.ClientIP()
 
-	ctxWithTimeout, cancel := context.WithTimeout(context.Background(), RequestTimeout)
+	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), RequestTimeout)
 	defer cancel()
 
-	accessToken, refreshToken, err := c.serv.NewSession(ctxWithTimeout, userID, IPAddress)
+	tokens, err := c.serv.NewSession(ctxWithTimeout, "", userID, ctx.Query("scope"), IPAddress, ctx.Request.UserAgent())
 	if err != nil {
 		if errors.Is(err, models.ErrEmptyUserID) {
 			ctx.JSON(http.StatusBadRequest, ErrorResponse{Error: "User id is empty."})
@@ -50,19 +62,24 @@ func (c *AppController) Login(ctx *gin.Context) {
 			return
 		}
 
+		if retryAfter(ctx, err) {
+			return
+		}
+
 		c.logger.Error(ctx, "Failed to create new session", zap.Error(err))
 		ctx.JSON(http.StatusInternalServerError, ErrorResponse{Error: "An unexpected error occurred."})
 
 		return
 	}
 
-	ctx.JSON(http.StatusOK, TokenResponse{AccessToken: accessToken, RefreshToken: refreshToken})
+	ctx.JSON(http.StatusOK, newTokenResponse(tokens))
 }
 
 // RefreshToken godoc
 // @Summary      RefreshToken
-// @Description  Refreshes token pair
+// @Description  Refreshes token pair. Sessions created by an oauth client can be refreshed only by that client. Deprecated for oauth clients, which get a Deprecation header and should use grant_type=refresh_token at /oauth/token.
 // @Tags         auth
+// @Deprecated
 // @Accept       json
 // @Produce      json
 // @Param token body RefreshTokenRequest true "Refresh Token"
@@ -70,7 +87,11 @@ func (c *AppController) Login(ctx *gin.Context) {
 // @Failure      400 {object} ErrorResponse "Invalid or missing refresh token"
 // @Failure      400 {object} ErrorResponse "Token is invalid"
 // @Failure      401 {object} ErrorResponse ""
+// @Failure      401 {object} ErrorResponse "Client credentials are wrong"
 // @Failure      403 {object} ErrorResponse "Session is invalid"
+// @Failure      403 {object} ErrorResponse "Refresh token was issued to another client"
+// @Failure      423 {object} ErrorResponse "Account is temporarily locked"
+// @Failure      429 {object} ErrorResponse "Too many requests"
 // @Failure      500 {object} ErrorResponse "An unexpected error occurred"
 // @Router /auth/refresh [post]
 func (c *AppController) RefreshToken(ctx *gin.Context) {
@@ -82,15 +103,29 @@ func (c *AppController) RefreshToken(ctx *gin.Context) {
 
 		return
 	}
+
+	// Only sessions of oauth clients are refreshed at /oauth/token, a session
+	// is refreshed only by the client it was created by, so the client id
+	// tells them apart from sessions of the legacy login.
+	if refreshTokenRequest.ClientID != "" {
+		ctx.Header("Deprecation", "true")
+		ctx.Header("Link", `</oauth/token>; rel="alternate"`)
+	}
 	IPAddress := ctx.ClientIP()
 
-	ctxWithTimeout, cancel := context.WithTimeout(context.Background(), RequestTimeout)
+	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), RequestTimeout)
 	defer cancel()
 
-	accessToken, refreshToken, err := c.serv.RefreshToken(ctxWithTimeout, refreshTokenRequest.RefreshToken, IPAddress)
+	creds := models.ClientCredentials{ID: refreshTokenRequest.ClientID, Secret: refreshTokenRequest.ClientSecret}
+
+	tokens, err := c.serv.RefreshToken(ctxWithTimeout, creds, refreshTokenRequest.RefreshToken, IPAddress, ctx.Request.UserAgent())
 	if err != nil {
-		if errors.Is(err, models.ErrTokenExpired) ||
-			errors.Is(err, models.ErrMismatchedHashAndToken) || errors.Is(err, models.ErrSessionNotFound) {
+		if retryAfter(ctx, err) {
+			return
+		}
+
+		if errors.Is(err, models.ErrTokenExpired) || errors.Is(err, models.ErrMismatchedHashAndToken) ||
+			errors.Is(err, models.ErrSessionNotFound) || errors.Is(err, models.ErrInvalidClient) {
 			ctx.JSON(http.StatusUnauthorized, ErrorResponse{Error: err.Error()})
 
 			return
@@ -108,11 +143,170 @@ func (c *AppController) RefreshToken(ctx *gin.Context) {
 			return
 		}
 
+		if errors.Is(err, models.ErrClientMismatch) || errors.Is(err, models.ErrUnauthorizedClient) {
+			ctx.JSON(http.StatusForbidden, ErrorResponse{Error: err.Error()})
+
+			return
+		}
+
 		c.logger.Error(ctx, "Failed to refresh token", zap.Error(err))
 		ctx.JSON(http.StatusInternalServerError, ErrorResponse{Error: "An unexpected error occurred."})
 
 		return
 	}
 
-	ctx.JSON(http.StatusOK, TokenResponse{AccessToken: accessToken, RefreshToken: refreshToken})
+	ctx.JSON(http.StatusOK, newTokenResponse(tokens))
+}
+
+// linkPage asks to confirm a link from an email, the token is posted back.
+type linkPage struct {
+	Token string
+}
+
+// RevokeSessionsPage godoc
+// @Summary      RevokeSessionsPage
+// @Description  Opens the "This wasn't me" link from a security alert email. It only asks the user to confirm, so that mail scanners following the link do not end the sessions.
+// @Tags         auth
+// @Produce      html
+// @Param token query string true "Signed token from the alert email"
+// @Success      200 {string} string "Confirmation page"
+// @Failure      400 {string} string "Link is invalid"
+// @Router /auth/revoke [get]
+func (c *AppController) RevokeSessionsPage(ctx *gin.Context) {
+	token := ctx.Query("token")
+	if token == "" {
+		renderPage(ctx, http.StatusBadRequest, "link_error", "Link is invalid.")
+
+		return
+	}
+
+	renderPage(ctx, http.StatusOK, "revoke", linkPage{Token: token})
+}
+
+// RevokeSessions godoc
+// @Summary      RevokeSessions
+// @Description  Confirms the "This wasn't me" link from a security alert email and ends every session of the user. The link works only once.
+// @Tags         auth
+// @Accept       x-www-form-urlencoded
+// @Produce      html
+// @Param token formData string true "Signed token from the alert email"
+// @Success      200 {string} string "Sessions revoked"
+// @Failure      400 {string} string "Link is invalid"
+// @Failure      410 {string} string "Link has expired or was already used"
+// @Failure      500 {string} string "An unexpected error occurred"
+// @Router /auth/revoke [post]
+func (c *AppController) RevokeSessions(ctx *gin.Context) {
+	token := ctx.PostForm("token")
+	if token == "" {
+		renderPage(ctx, http.StatusBadRequest, "link_error", "Link is invalid.")
+
+		return
+	}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), RequestTimeout)
+	defer cancel()
+
+	err := c.serv.RevokeSessions(ctxWithTimeout, token, ctx.ClientIP(), ctx.Request.UserAgent())
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrTokenExpired):
+			renderPage(ctx, http.StatusGone, "link_error", "Link has expired.")
+		case errors.Is(err, models.ErrTokenUsed):
+			renderPage(ctx, http.StatusGone, "link_error", "Link was already used.")
+		case errors.Is(err, models.ErrInvalidToken):
+			renderPage(ctx, http.StatusBadRequest, "link_error", "Link is invalid.")
+		default:
+			c.logger.Error(ctx, "Failed to revoke sessions", zap.Error(err))
+			renderPage(ctx, http.StatusInternalServerError, "link_error", "An unexpected error occurred.")
+		}
+
+		return
+	}
+
+	renderPage(ctx, http.StatusOK, "revoke_done", nil)
+}
+
+// ConfirmEmailChangePage godoc
+// @Summary      ConfirmEmailChangePage
+// @Description  Opens the confirmation link sent to the new address. It only asks the user to confirm, so that mail scanners following the link do not change the email.
+// @Tags         auth
+// @Produce      html
+// @Param token query string true "Token from the confirmation email"
+// @Success      200 {string} string "Confirmation page"
+// @Failure      400 {string} string "Link is invalid"
+// @Router /auth/email/confirm [get]
+func (c *AppController) ConfirmEmailChangePage(ctx *gin.Context) {
+	token := ctx.Query("token")
+	if token == "" {
+		renderPage(ctx, http.StatusBadRequest, "link_error", "Link is invalid.")
+
+		return
+	}
+
+	renderPage(ctx, http.StatusOK, "email_confirm", linkPage{Token: token})
+}
+
+// ConfirmEmailChange godoc
+// @Summary      ConfirmEmailChange
+// @Description  Confirms the link sent to the new address and changes the email of the user. The link works only once.
+// @Tags         auth
+// @Accept       x-www-form-urlencoded
+// @Produce      html
+// @Param token formData string true "Token from the confirmation email"
+// @Success      200 {string} string "Email changed"
+// @Failure      400 {string} string "Link is invalid"
+// @Failure      409 {string} string "Email is already used"
+// @Failure      410 {string} string "Link has expired"
+// @Failure      500 {string} string "An unexpected error occurred"
+// @Router /auth/email/confirm [post]
+func (c *AppController) ConfirmEmailChange(ctx *gin.Context) {
+	token := ctx.PostForm("token")
+	if token == "" {
+		renderPage(ctx, http.StatusBadRequest, "link_error", "Link is invalid.")
+
+		return
+	}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), RequestTimeout)
+	defer cancel()
+
+	err := c.accounts.ConfirmEmailChange(ctxWithTimeout, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrTokenExpired):
+			renderPage(ctx, http.StatusGone, "link_error", "Link has expired.")
+		case errors.Is(err, models.ErrEmailChangeNotFound), errors.Is(err, models.ErrUserNotFound):
+			renderPage(ctx, http.StatusBadRequest, "link_error", "Link is invalid.")
+		case errors.Is(err, models.ErrEmailTaken):
+			// Only the owner of the address gets the link, so this does not
+			// reveal the address to anyone else.
+			renderPage(ctx, http.StatusConflict, "link_error", "Email is already used.")
+		default:
+			c.logger.Error(ctx, "Failed to confirm email change", zap.Error(err))
+			renderPage(ctx, http.StatusInternalServerError, "link_error", "An unexpected error occurred.")
+		}
+
+		return
+	}
+
+	renderPage(ctx, http.StatusOK, "email_changed", nil)
+}
+
+// retryAfter writes a response with the Retry-After header for errors that
+// ask the client to wait before the next attempt.
+func retryAfter(ctx *gin.Context, err error) bool {
+	var retryErr *models.RetryError
+	if !errors.As(err, &retryErr) {
+		return false
+	}
+
+	if errors.Is(err, models.ErrAccountLocked) {
+		middleware.AbortWithRetryAfter(ctx, http.StatusLocked, retryErr.RetryAfter, "Account is temporarily locked.")
+
+		return true
+	}
+
+	middleware.AbortWithRetryAfter(ctx, http.StatusTooManyRequests, retryErr.RetryAfter, "Too many failed attempts.")
+
+	return true
 }
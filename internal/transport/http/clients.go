@@ -0,0 +1,266 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"medods-test-task/internal/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type UpdateClientRequest struct {
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	// authorization_code and refresh_token when empty
+	GrantTypes []string `json:"grant_types"`
+	Scopes     []string `json:"scopes"`
+	// PEM encoded RSA or EC key for private_key_jwt
+	PublicKey string `json:"public_key"`
+	// Seconds, the server default when 0
+	AccessTokenTTL int64 `json:"access_token_ttl"`
+	// Seconds, the server default when 0
+	RefreshTokenTTL int64 `json:"refresh_token_ttl"`
+}
+
+type CreateClientRequest struct {
+	// Generated when empty
+	ID string `json:"id"`
+	// Confidential clients get a secret, public clients without a public key rely on PKCE
+	Confidential bool `json:"confidential"`
+	UpdateClientRequest
+}
+
+func (r *UpdateClientRequest) params() models.OAuthClientParams {
+	return models.OAuthClientParams{
+		Name:            r.Name,
+		RedirectURIs:    r.RedirectURIs,
+		GrantTypes:      r.GrantTypes,
+		Scopes:          r.Scopes,
+		PublicKey:       r.PublicKey,
+		AccessTokenTTL:  time.Duration(r.AccessTokenTTL) * time.Second,
+		RefreshTokenTTL: time.Duration(r.RefreshTokenTTL) * time.Second,
+	}
+}
+
+// CreateClient godoc
+// @Summary      CreateClient
+// @Description  Registers an oauth client. The secret of a confidential client is returned only once.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param client body CreateClientRequest true "Client settings"
+// @Success      201 {object} ClientResponse "Registered client with its secret"
+// @Failure      400 {object} ErrorResponse "Invalid client settings"
+// @Failure      401 {object} ErrorResponse "Unauthorized"
+// @Failure      409 {object} ErrorResponse "Client id is taken"
+// @Failure      500 {object} ErrorResponse "An unexpected error occurred"
+// @Router /admin/clients [post]
+func (c *AppController) CreateClient(ctx *gin.Context) {
+	var req CreateClientRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body."})
+
+		return
+	}
+
+	params := req.params()
+	params.ID = req.ID
+	params.Confidential = req.Confidential
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), RequestTimeout)
+	defer cancel()
+
+	client, err := c.clients.CreateClient(ctxWithTimeout, params)
+	if err != nil {
+		c.clientError(ctx, err, "Failed to create client")
+
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, newClientResponse(client))
+}
+
+// ListClients godoc
+// @Summary      ListClients
+// @Description  Lists registered oauth clients
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {array} ClientResponse "Registered clients"
+// @Failure      401 {object} ErrorResponse "Unauthorized"
+// @Failure      500 {object} ErrorResponse "An unexpected error occurred"
+// @Router /admin/clients [get]
+func (c *AppController) ListClients(ctx *gin.Context) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), RequestTimeout)
+	defer cancel()
+
+	clients, err := c.clients.ListClients(ctxWithTimeout)
+	if err != nil {
+		c.logger.Error(ctx, "Failed to list clients", zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError, ErrorResponse{Error: "An unexpected error occurred."})
+
+		return
+	}
+
+	resp := make([]ClientResponse, 0, len(clients))
+	for i := range clients {
+		resp = append(resp, newClientResponse(&clients[i]))
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// GetClient godoc
+// @Summary      GetClient
+// @Description  Returns an oauth client
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param id path string true "Client id"
+// @Success      200 {object} ClientResponse "Client"
+// @Failure      401 {object} ErrorResponse "Unauthorized"
+// @Failure      404 {object} ErrorResponse "Client was not found"
+// @Failure      500 {object} ErrorResponse "An unexpected error occurred"
+// @Router /admin/clients/{id} [get]
+func (c *AppController) GetClient(ctx *gin.Context) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), RequestTimeout)
+	defer cancel()
+
+	client, err := c.clients.GetClient(ctxWithTimeout, ctx.Param("id"))
+	if err != nil {
+		c.clientError(ctx, err, "Failed to get client")
+
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newClientResponse(client))
+}
+
+// UpdateClient godoc
+// @Summary      UpdateClient
+// @Description  Replaces the settings of an oauth client. The secret and the sessions of the client are kept.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param id path string true "Client id"
+// @Param client body UpdateClientRequest true "Client settings"
+// @Success      200 {object} ClientResponse "Updated client"
+// @Failure      400 {object} ErrorResponse "Invalid client settings"
+// @Failure      401 {object} ErrorResponse "Unauthorized"
+// @Failure      404 {object} ErrorResponse "Client was not found"
+// @Failure      500 {object} ErrorResponse "An unexpected error occurred"
+// @Router /admin/clients/{id} [put]
+func (c *AppController) UpdateClient(ctx *gin.Context) {
+	var req UpdateClientRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body."})
+
+		return
+	}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), RequestTimeout)
+	defer cancel()
+
+	client, err := c.clients.UpdateClient(ctxWithTimeout, ctx.Param("id"), req.params())
+	if err != nil {
+		c.clientError(ctx, err, "Failed to update client")
+
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newClientResponse(client))
+}
+
+// RotateClientSecret godoc
+// @Summary      RotateClientSecret
+// @Description  Issues a new secret for an oauth client, the old secret stops working at once. A public client becomes confidential.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param id path string true "Client id"
+// @Success      200 {object} ClientResponse "Client with its new secret"
+// @Failure      401 {object} ErrorResponse "Unauthorized"
+// @Failure      404 {object} ErrorResponse "Client was not found"
+// @Failure      500 {object} ErrorResponse "An unexpected error occurred"
+// @Router /admin/clients/{id}/secret [post]
+func (c *AppController) RotateClientSecret(ctx *gin.Context) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), RequestTimeout)
+	defer cancel()
+
+	client, err := c.clients.RotateSecret(ctxWithTimeout, ctx.Param("id"))
+	if err != nil {
+		c.clientError(ctx, err, "Failed to rotate client secret")
+
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newClientResponse(client))
+}
+
+// DeleteClient godoc
+// @Summary      DeleteClient
+// @Description  Deletes an oauth client and ends its sessions
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param id path string true "Client id"
+// @Success      204 "Deleted"
+// @Failure      401 {object} ErrorResponse "Unauthorized"
+// @Failure      404 {object} ErrorResponse "Client was not found"
+// @Failure      500 {object} ErrorResponse "An unexpected error occurred"
+// @Router /admin/clients/{id} [delete]
+func (c *AppController) DeleteClient(ctx *gin.Context) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), RequestTimeout)
+	defer cancel()
+
+	err := c.clients.DeleteClient(ctxWithTimeout, ctx.Param("id"))
+	if err != nil {
+		c.clientError(ctx, err, "Failed to delete client")
+
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (c *AppController) clientError(ctx *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, models.ErrOAuthClientNotFound):
+		ctx.JSON(http.StatusNotFound, ErrorResponse{Error: "Client was not found."})
+	case errors.Is(err, models.ErrOAuthClientExists):
+		ctx.JSON(http.StatusConflict, ErrorResponse{Error: err.Error()})
+	case errors.Is(err, models.ErrInvalidClientID), errors.Is(err, models.ErrEmptyClientName),
+		errors.Is(err, models.ErrInvalidClientURI), errors.Is(err, models.ErrMissingRedirectURI),
+		errors.Is(err, models.ErrUnknownGrantType), errors.Is(err, models.ErrInvalidScope),
+		errors.Is(err, models.ErrInvalidClientTokenTTL), errors.Is(err, models.ErrInvalidClientKey),
+		errors.Is(err, models.ErrPublicClientGrant):
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	default:
+		c.logger.Error(ctx, msg, zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError, ErrorResponse{Error: "An unexpected error occurred."})
+	}
+}
+
+func newClientResponse(client *models.OAuthClient) ClientResponse {
+	return ClientResponse{
+		ID:              client.ID,
+		Name:            client.Name,
+		Confidential:    client.IsConfidential(),
+		RedirectURIs:    client.RedirectURIs,
+		GrantTypes:      client.GrantTypes,
+		Scopes:          client.Scopes,
+		PublicKey:       client.PublicKey,
+		AccessTokenTTL:  int64(client.AccessTokenTTL / time.Second),
+		RefreshTokenTTL: int64(client.RefreshTokenTTL / time.Second),
+		Secret:          client.Secret,
+		CreatedAt:       client.CreatedAt,
+		UpdatedAt:       client.UpdatedAt,
+	}
+}
@@ -2,22 +2,82 @@ package http
 
 import (
 	"context"
+	"medods-test-task/internal/models"
 	"medods-test-task/pkg/logger"
+
+	"github.com/google/uuid"
 )
 
 type AuthService interface {
-	NewSession(ctx context.Context, userID, IPAddress string) (string, string, error)
-	RefreshToken(ctx context.Context, refreshToken, IPAdress string) (string, string, error)
+	NewSession(ctx context.Context, clientID, userID, scope, IPAddress, userAgent string) (*models.TokenSet, error)
+	RefreshToken(ctx context.Context, creds models.ClientCredentials, refreshToken, IPAdress, userAgent string) (*models.TokenSet, error)
+	RevokeSessions(ctx context.Context, revokeToken, IPAddress, userAgent string) error
+}
+
+type WebhookService interface {
+	RegisterEndpoint(ctx context.Context, url string, events []string) (*models.WebhookEndpoint, error)
+	ListEndpoints(ctx context.Context) ([]models.WebhookEndpoint, error)
+	DeleteEndpoint(ctx context.Context, id uuid.UUID) error
+}
+
+type NotificationService interface {
+	GetSettings(ctx context.Context, userID uuid.UUID) (*models.NotificationSettings, error)
+	UpdateSettings(ctx context.Context, userID uuid.UUID, update models.NotificationSettingsUpdate) (*models.NotificationSettings, error)
+	ConfirmPhone(ctx context.Context, userID uuid.UUID, code string) error
+}
+
+type AccountService interface {
+	ChangeEmail(ctx context.Context, userID uuid.UUID, newEmail, IPAddress, userAgent string) error
+	ConfirmEmailChange(ctx context.Context, token string) error
+	SetPassword(ctx context.Context, userID uuid.UUID, currentPassword, newPassword, IPAddress, userAgent string) error
+}
+
+type ClientService interface {
+	CreateClient(ctx context.Context, params models.OAuthClientParams) (*models.OAuthClient, error)
+	GetClient(ctx context.Context, clientID string) (*models.OAuthClient, error)
+	ListClients(ctx context.Context) ([]models.OAuthClient, error)
+	UpdateClient(ctx context.Context, clientID string, params models.OAuthClientParams) (*models.OAuthClient, error)
+	RotateSecret(ctx context.Context, clientID string) (*models.OAuthClient, error)
+	DeleteClient(ctx context.Context, clientID string) error
+}
+
+type RoleService interface {
+	SetUserRoles(ctx context.Context, userID uuid.UUID, roles []string) ([]string, error)
+}
+
+type DeviceService interface {
+	DeviceRequest(ctx context.Context, userCode string) (*models.DeviceRequest, error)
+	ConfirmDevice(ctx context.Context, userCode string, userID uuid.UUID, approved bool) error
+}
+
+type APIKeyService interface {
+	CreateAPIKey(ctx context.Context, userID uuid.UUID, params models.APIKeyParams) (*models.APIKey, error)
+	ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, keyID uuid.UUID) error
 }
 
 type AppController struct {
-	serv   AuthService
-	logger logger.Logger
+	serv          AuthService
+	webhooks      WebhookService
+	notifications NotificationService
+	accounts      AccountService
+	clients       ClientService
+	roles         RoleService
+	devices       DeviceService
+	apiKeys       APIKeyService
+	logger        logger.Logger
 }
 
-func NewAppController(serv AuthService, logger logger.Logger) *AppController {
+func NewAppController(serv AuthService, webhooks WebhookService, notifications NotificationService, accounts AccountService, clients ClientService, roles RoleService, devices DeviceService, apiKeys APIKeyService, logger logger.Logger) *AppController {
 	return &AppController{
-		serv:   serv,
-		logger: logger,
+		serv:          serv,
+		webhooks:      webhooks,
+		notifications: notifications,
+		accounts:      accounts,
+		clients:       clients,
+		roles:         roles,
+		devices:       devices,
+		apiKeys:       apiKeys,
+		logger:        logger,
 	}
 }
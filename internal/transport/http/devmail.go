@@ -0,0 +1,96 @@
+package http
+
+import (
+	"medods-test-task/pkg/email/catcher"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MailCatcher interface {
+	List(to string) []catcher.Message
+	Get(id string) (catcher.Message, bool)
+	Clear()
+}
+
+// DevMailController exposes the development mail catcher. It is registered
+// only when the catcher transport is enabled, which requires development mode,
+// and is left out of the public API docs.
+type DevMailController struct {
+	mailbox MailCatcher
+}
+
+func NewDevMailController(mailbox MailCatcher) *DevMailController {
+	return &DevMailController{
+		mailbox: mailbox,
+	}
+}
+
+// ListMail returns caught emails as JSON, newest first, optionally filtered
+// by the "to" query parameter.
+func (c *DevMailController) ListMail(ctx *gin.Context) {
+	messages := c.mailbox.List(ctx.Query("to"))
+
+	resp := make([]MailMessageResponse, 0, len(messages))
+	for _, msg := range messages {
+		resp = append(resp, newMailMessageResponse(msg))
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// GetMail returns a caught email as JSON.
+func (c *DevMailController) GetMail(ctx *gin.Context) {
+	msg, ok := c.mailbox.Get(ctx.Param("id"))
+	if !ok {
+		ctx.JSON(http.StatusNotFound, ErrorResponse{Error: "Message not found."})
+
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newMailMessageResponse(msg))
+}
+
+// GetRawMail downloads a caught email as a MIME message.
+func (c *DevMailController) GetRawMail(ctx *gin.Context) {
+	msg, ok := c.mailbox.Get(ctx.Param("id"))
+	if !ok {
+		ctx.JSON(http.StatusNotFound, ErrorResponse{Error: "Message not found."})
+
+		return
+	}
+
+	ctx.Header("Content-Disposition", `attachment; filename="`+msg.ID+`.eml"`)
+	ctx.Data(http.StatusOK, "message/rfc822", msg.Raw)
+}
+
+// GetMailHTML renders the html body of a caught email.
+func (c *DevMailController) GetMailHTML(ctx *gin.Context) {
+	msg, ok := c.mailbox.Get(ctx.Param("id"))
+	if !ok {
+		ctx.JSON(http.StatusNotFound, ErrorResponse{Error: "Message not found."})
+
+		return
+	}
+
+	ctx.Data(http.StatusOK, "text/html; charset=utf-8", []byte(msg.Body))
+}
+
+// ClearMail deletes every caught email.
+func (c *DevMailController) ClearMail(ctx *gin.Context) {
+	c.mailbox.Clear()
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func newMailMessageResponse(msg catcher.Message) MailMessageResponse {
+	return MailMessageResponse{
+		ID:        msg.ID,
+		From:      msg.From,
+		To:        msg.To,
+		Subject:   msg.Subject,
+		Body:      msg.Body,
+		Text:      msg.Text,
+		CreatedAt: msg.CreatedAt,
+	}
+}
@@ -0,0 +1,106 @@
+package http
+
+import (
+	"context"
+	"medods-test-task/pkg/circuitbreaker"
+	"medods-test-task/pkg/email/failover"
+	"medods-test-task/pkg/logger"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	healthTimeout = 2 * time.Second
+
+	healthStatusOK          = "ok"
+	healthStatusDegraded    = "degraded"
+	healthStatusUnavailable = "unavailable"
+)
+
+type DatabasePinger interface {
+	PingContext(ctx context.Context) error
+}
+
+type MailHealth interface {
+	Health() []failover.RelayStatus
+}
+
+// HealthController reports whether the service can serve requests. It lives
+// outside /v1 for load balancers and is left out of the public API docs.
+type HealthController struct {
+	db     DatabasePinger
+	mail   MailHealth
+	logger logger.Logger
+}
+
+// NewHealthController takes a nil mail when the transport has no relays.
+func NewHealthController(db DatabasePinger, mail MailHealth, logger logger.Logger) *HealthController {
+	return &HealthController{
+		db:     db,
+		mail:   mail,
+		logger: logger,
+	}
+}
+
+// Health responds with 503 when the database is unreachable. Relays with an
+// open circuit only degrade the service, since emails wait in the outbox. The
+// response is public, so it carries only the status.
+func (c *HealthController) Health(ctx *gin.Context) {
+	resp, status := c.check(ctx)
+
+	ctx.JSON(status, HealthStatusResponse{Status: resp.Status})
+}
+
+// HealthDetails reports the same status with the database and the state of
+// every relay. Relay names and errors reveal the mail setup, so it is served
+// behind the admin token.
+func (c *HealthController) HealthDetails(ctx *gin.Context) {
+	resp, status := c.check(ctx)
+
+	ctx.JSON(status, resp)
+}
+
+func (c *HealthController) check(ctx *gin.Context) (HealthResponse, int) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), healthTimeout)
+	defer cancel()
+
+	resp := HealthResponse{Status: healthStatusOK, Database: healthStatusOK}
+
+	if c.mail != nil {
+		for _, relay := range c.mail.Health() {
+			if relay.State != circuitbreaker.StateClosed {
+				resp.Status = healthStatusDegraded
+			}
+
+			resp.Relays = append(resp.Relays, RelayHealthResponse{
+				Name:          relay.Name,
+				State:         string(relay.State),
+				Failures:      relay.Failures,
+				LastError:     relay.LastError,
+				LastFailureAt: timeOrNil(relay.LastFailureAt),
+			})
+		}
+	}
+
+	if err := c.db.PingContext(ctxWithTimeout); err != nil {
+		c.logger.Error(ctx, "health check failed to ping database", zap.Error(err))
+
+		resp.Status = healthStatusUnavailable
+		resp.Database = healthStatusUnavailable
+
+		return resp, http.StatusServiceUnavailable
+	}
+
+	return resp, http.StatusOK
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
@@ -0,0 +1,417 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"medods-test-task/internal/models"
+	"medods-test-task/internal/transport/http/middleware"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type NotificationPreferenceRequest struct {
+	Event    string   `json:"event"`
+	Channels []string `json:"channels"`
+}
+
+type UpdateNotificationSettingsRequest struct {
+	// Events to change, other events keep their channels
+	Preferences []NotificationPreferenceRequest `json:"preferences"`
+	// E.164 phone for the sms channel, empty string removes it. A new phone
+	// gets a code and is used once the code is confirmed.
+	Phone *string `json:"phone"`
+	// Url for the webhook channel, empty string removes it
+	WebhookURL *string `json:"webhook_url"`
+}
+
+type ConfirmPhoneRequest struct {
+	// Code texted to the new phone
+	Code string `json:"code"`
+}
+
+type ChangeEmailRequest struct {
+	Email string `json:"email"`
+}
+
+type SetPasswordRequest struct {
+	// Required when the user already has a password
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type ConfirmDeviceRequest struct {
+	// Code shown on the device
+	UserCode string `json:"user_code"`
+	// False denies the device
+	Approve bool `json:"approve"`
+}
+
+// GetNotificationSettings godoc
+// @Summary      GetNotificationSettings
+// @Description  Returns the channels of every security notification of the current user
+// @Tags         me
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} NotificationSettingsResponse "Notification settings"
+// @Failure      401 {object} ErrorResponse "Unauthorized"
+// @Failure      403 {object} ErrorResponse "Token lacks the profile scope"
+// @Failure      404 {object} ErrorResponse "User was not found"
+// @Failure      500 {object} ErrorResponse "An unexpected error occurred"
+// @Router /me/notifications [get]
+func (c *AppController) GetNotificationSettings(ctx *gin.Context) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), RequestTimeout)
+	defer cancel()
+
+	settings, err := c.notifications.GetSettings(ctxWithTimeout, middleware.UserID(ctx))
+	if err != nil {
+		if errors.Is(err, models.ErrUserNotFound) {
+			ctx.JSON(http.StatusNotFound, ErrorResponse{Error: "User was not found."})
+
+			return
+		}
+
+		c.logger.Error(ctx, "Failed to get notification settings", zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError, ErrorResponse{Error: "An unexpected error occurred."})
+
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newNotificationSettingsResponse(settings))
+}
+
+// UpdateNotificationSettings godoc
+// @Summary      UpdateNotificationSettings
+// @Description  Chooses the channels of security notifications. Events are new_device, ip_change, password_change and session_revoked; channels are email, webhook and sms. The webhook signing secret is returned only when the url changes. A new phone is texted a code and can be chosen for sms once the code is confirmed.
+// @Tags         me
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param settings body UpdateNotificationSettingsRequest true "Changed settings"
+// @Success      200 {object} NotificationSettingsResponse "Notification settings"
+// @Failure      400 {object} ErrorResponse "Invalid settings"
+// @Failure      401 {object} ErrorResponse "Unauthorized"
+// @Failure      403 {object} ErrorResponse "Token lacks the profile scope"
+// @Failure      404 {object} ErrorResponse "User was not found"
+// @Failure      429 {object} ErrorResponse "A phone code was sent recently"
+// @Failure      500 {object} ErrorResponse "An unexpected error occurred"
+// @Router /me/notifications [put]
+func (c *AppController) UpdateNotificationSettings(ctx *gin.Context) {
+	var req UpdateNotificationSettingsRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body."})
+
+		return
+	}
+
+	update := models.NotificationSettingsUpdate{
+		Phone:      req.Phone,
+		WebhookURL: req.WebhookURL,
+	}
+
+	for _, preference := range req.Preferences {
+		update.Preferences = append(update.Preferences, models.NotificationPreference{
+			Event:    preference.Event,
+			Channels: preference.Channels,
+		})
+	}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), RequestTimeout)
+	defer cancel()
+
+	settings, err := c.notifications.UpdateSettings(ctxWithTimeout, middleware.UserID(ctx), update)
+	if err != nil {
+		if errors.Is(err, models.ErrUnknownNotificationEvent) || errors.Is(err, models.ErrUnknownNotificationChannel) ||
+			errors.Is(err, models.ErrChannelNotConfigured) || errors.Is(err, models.ErrInvalidPhone) ||
+			errors.Is(err, models.ErrInvalidWebhookURL) || errors.Is(err, models.ErrNonPublicWebhookURL) {
+			ctx.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+
+			return
+		}
+
+		if errors.Is(err, models.ErrPhoneCodeTooSoon) {
+			ctx.JSON(http.StatusTooManyRequests, ErrorResponse{Error: err.Error()})
+
+			return
+		}
+
+		if errors.Is(err, models.ErrUserNotFound) {
+			ctx.JSON(http.StatusNotFound, ErrorResponse{Error: "User was not found."})
+
+			return
+		}
+
+		c.logger.Error(ctx, "Failed to update notification settings", zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError, ErrorResponse{Error: "An unexpected error occurred."})
+
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newNotificationSettingsResponse(settings))
+}
+
+// ConfirmPhone godoc
+// @Summary      ConfirmPhone
+// @Description  Confirms the new phone with the code texted to it. The phone then replaces the current one and can be chosen for the sms channel.
+// @Tags         me
+// @Accept       json
+// @Security     BearerAuth
+// @Param code body ConfirmPhoneRequest true "Texted code"
+// @Success      204 "Phone confirmed"
+// @Failure      400 {object} ErrorResponse "Invalid or expired code"
+// @Failure      401 {object} ErrorResponse "Unauthorized"
+// @Failure      403 {object} ErrorResponse "Token lacks the profile scope"
+// @Failure      500 {object} ErrorResponse "An unexpected error occurred"
+// @Router /me/notifications/phone [post]
+func (c *AppController) ConfirmPhone(ctx *gin.Context) {
+	var req ConfirmPhoneRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body."})
+
+		return
+	}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), RequestTimeout)
+	defer cancel()
+
+	if err := c.notifications.ConfirmPhone(ctxWithTimeout, middleware.UserID(ctx), req.Code); err != nil {
+		if errors.Is(err, models.ErrInvalidPhoneCode) {
+			ctx.JSON(http.StatusBadRequest, ErrorResponse{Error: "The code is invalid or has expired."})
+
+			return
+		}
+
+		c.logger.Error(ctx, "Failed to confirm phone", zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError, ErrorResponse{Error: "An unexpected error occurred."})
+
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// ChangeEmail godoc
+// @Summary      ChangeEmail
+// @Description  Starts changing the email of the current user. The new address gets a confirmation link and the current one a notice; the email changes only after the link is confirmed. The response is the same when the address belongs to another account, which then gets no link.
+// @Tags         me
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param email body ChangeEmailRequest true "New email"
+// @Success      202 {object} MessageResponse "Confirmation sent"
+// @Failure      400 {object} ErrorResponse "Invalid or disposable email"
+// @Failure      401 {object} ErrorResponse "Unauthorized"
+// @Failure      403 {object} ErrorResponse "Token of the legacy login or an api key"
+// @Failure      404 {object} ErrorResponse "User was not found"
+// @Failure      429 {object} ErrorResponse "Too many requests"
+// @Failure      500 {object} ErrorResponse "An unexpected error occurred"
+// @Router /me/email [put]
+func (c *AppController) ChangeEmail(ctx *gin.Context) {
+	var req ChangeEmailRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body."})
+
+		return
+	}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), RequestTimeout)
+	defer cancel()
+
+	// A taken address is answered like a free one, so that the endpoint does
+	// not tell which addresses are registered.
+	err := c.accounts.ChangeEmail(ctxWithTimeout, middleware.UserID(ctx), req.Email, ctx.ClientIP(), ctx.Request.UserAgent())
+	if err != nil && !errors.Is(err, models.ErrEmailTaken) {
+		if errors.Is(err, models.ErrEmailFormat) || errors.Is(err, models.ErrDisposableEmail) ||
+			errors.Is(err, models.ErrEmailUnchanged) {
+			ctx.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+
+			return
+		}
+
+		if errors.Is(err, models.ErrUserNotFound) {
+			ctx.JSON(http.StatusNotFound, ErrorResponse{Error: "User was not found."})
+
+			return
+		}
+
+		c.logger.Error(ctx, "Failed to change email", zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError, ErrorResponse{Error: "An unexpected error occurred."})
+
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, MessageResponse{Message: "We sent a confirmation link to the new address."})
+}
+
+// SetPassword godoc
+// @Summary      SetPassword
+// @Description  Sets or changes the password of the current user. The password is checked against the password policy and a local corpus of breached passwords; a rejected password names the failed rule.
+// @Tags         me
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param password body SetPasswordRequest true "Passwords"
+// @Success      200 {object} MessageResponse "Password changed"
+// @Failure      400 {object} PasswordErrorResponse "Password does not meet the policy"
+// @Failure      401 {object} ErrorResponse "Unauthorized"
+// @Failure      403 {object} ErrorResponse "Current password is wrong, or a token of the legacy login or an api key"
+// @Failure      404 {object} ErrorResponse "User was not found"
+// @Failure      423 {object} ErrorResponse "Account is temporarily locked"
+// @Failure      429 {object} ErrorResponse "Too many failed attempts"
+// @Failure      500 {object} ErrorResponse "An unexpected error occurred"
+// @Router /me/password [put]
+func (c *AppController) SetPassword(ctx *gin.Context) {
+	var req SetPasswordRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body."})
+
+		return
+	}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), RequestTimeout)
+	defer cancel()
+
+	err := c.accounts.SetPassword(ctxWithTimeout, middleware.UserID(ctx), req.CurrentPassword, req.NewPassword, ctx.ClientIP(), ctx.Request.UserAgent())
+	if err != nil {
+		if retryAfter(ctx, err) {
+			return
+		}
+
+		var policyErr *models.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			ctx.JSON(http.StatusBadRequest, PasswordErrorResponse{Error: policyErr.Error(), Rule: policyErr.Rule})
+
+			return
+		}
+
+		if errors.Is(err, models.ErrPasswordRequired) {
+			ctx.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+
+			return
+		}
+
+		if errors.Is(err, models.ErrWrongPassword) {
+			ctx.JSON(http.StatusForbidden, ErrorResponse{Error: "Current password is wrong."})
+
+			return
+		}
+
+		if errors.Is(err, models.ErrUserNotFound) {
+			ctx.JSON(http.StatusNotFound, ErrorResponse{Error: "User was not found."})
+
+			return
+		}
+
+		c.logger.Error(ctx, "Failed to set password", zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError, ErrorResponse{Error: "An unexpected error occurred."})
+
+		return
+	}
+
+	ctx.JSON(http.StatusOK, MessageResponse{Message: "Your password has been changed."})
+}
+
+func newNotificationSettingsResponse(settings *models.NotificationSettings) NotificationSettingsResponse {
+	resp := NotificationSettingsResponse{
+		Preferences:   make([]NotificationPreferenceResponse, 0, len(settings.Preferences)),
+		Phone:         settings.Phone,
+		PendingPhone:  settings.PendingPhone,
+		WebhookURL:    settings.WebhookURL,
+		WebhookSecret: settings.WebhookSecret,
+	}
+
+	for _, preference := range settings.Preferences {
+		resp.Preferences = append(resp.Preferences, NotificationPreferenceResponse{
+			Event:    preference.Event,
+			Channels: preference.Channels,
+		})
+	}
+
+	return resp
+}
+
+// GetDeviceRequest godoc
+// @Summary      GetDeviceRequest
+// @Description  Shows the client and scope a device waiting for the user code asks for, to be shown to the user before they approve it with POST /me/devices.
+// @Tags         me
+// @Produce      json
+// @Security     BearerAuth
+// @Param userCode path string true "Code shown on the device"
+// @Success      200 {object} DeviceRequestResponse "Client and scope"
+// @Failure      401 {object} ErrorResponse "Unauthorized"
+// @Failure      403 {object} ErrorResponse "Token of the legacy login or an api key"
+// @Failure      404 {object} ErrorResponse "Invalid or expired code"
+// @Failure      500 {object} ErrorResponse "An unexpected error occurred"
+// @Router /me/devices/{userCode} [get]
+func (c *AppController) GetDeviceRequest(ctx *gin.Context) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), RequestTimeout)
+	defer cancel()
+
+	request, err := c.devices.DeviceRequest(ctxWithTimeout, ctx.Param("userCode"))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidUserCode) {
+			ctx.JSON(http.StatusNotFound, ErrorResponse{Error: "The code is invalid or has expired."})
+
+			return
+		}
+
+		c.logger.Error(ctx, "Failed to get device request", zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError, ErrorResponse{Error: "An unexpected error occurred."})
+
+		return
+	}
+
+	ctx.JSON(http.StatusOK, DeviceRequestResponse{
+		ClientID:   request.Client.ID,
+		ClientName: request.Client.Name,
+		Scope:      request.Scope,
+	})
+}
+
+// ConfirmDevice godoc
+// @Summary      ConfirmDevice
+// @Description  Approves or denies a device that signs in with the device authorization grant. Show the user what GET /me/devices/{userCode} returns before they approve. The device gets its tokens on its next poll of /oauth/token.
+// @Tags         me
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param device body ConfirmDeviceRequest true "User code and decision"
+// @Success      204 "Decision recorded"
+// @Failure      400 {object} ErrorResponse "Invalid or expired code"
+// @Failure      401 {object} ErrorResponse "Unauthorized"
+// @Failure      403 {object} ErrorResponse "Token of the legacy login or an api key"
+// @Failure      500 {object} ErrorResponse "An unexpected error occurred"
+// @Router /me/devices [post]
+func (c *AppController) ConfirmDevice(ctx *gin.Context) {
+	var req ConfirmDeviceRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body."})
+
+		return
+	}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), RequestTimeout)
+	defer cancel()
+
+	err := c.devices.ConfirmDevice(ctxWithTimeout, req.UserCode, middleware.UserID(ctx), req.Approve)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidUserCode) {
+			ctx.JSON(http.StatusBadRequest, ErrorResponse{Error: "The code is invalid or has expired."})
+
+			return
+		}
+
+		c.logger.Error(ctx, "Failed to confirm device", zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError, ErrorResponse{Error: "An unexpected error occurred."})
+
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"medods-test-task/internal/models"
+	"medods-test-task/pkg/apikey"
+	"medods-test-task/pkg/logger"
+	"medods-test-task/pkg/utils"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	userIDKey = "user_id"
+	claimsKey = "claims"
+)
+
+// APIKeyHeader carries an api key for clients that do not send it as the
+// bearer token.
+const APIKeyHeader = "X-API-Key"
+
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key, IPAddress string) (*utils.Claims, error)
+}
+
+// AccessToken authenticates the user with the access token from the
+// Authorization header and stores their id for UserID and the token claims
+// for Claims. Tokens issued to a client on its own behalf are rejected, since
+// there is no user, and so are tokens exchanged for another audience.
+func AccessToken(tokenManager utils.TokenManager) gin.HandlerFunc {
+	return Authenticate(tokenManager, nil, nil)
+}
+
+// Authenticate is AccessToken that also accepts api keys, as the bearer
+// token or in the APIKeyHeader header. A request made with an api key gets
+// claims of the owner of the key with the scopes of the key.
+func Authenticate(tokenManager utils.TokenManager, apiKeys APIKeyAuthenticator, log logger.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		token, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+
+		if apiKeys != nil {
+			key := ctx.GetHeader(APIKeyHeader)
+			if ok && strings.HasPrefix(token, apikey.Prefix) {
+				key = token
+			}
+
+			if key != "" && (!ok || key == token) {
+				authenticateAPIKey(ctx, apiKeys, key, log)
+
+				return
+			}
+		}
+
+		if !ok || token == "" {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized."})
+
+			return
+		}
+
+		claims, err := tokenManager.ParseJWT(token)
+		if err != nil {
+			if errors.Is(err, models.ErrTokenExpired) {
+				ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Access token has expired."})
+
+				return
+			}
+
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized."})
+
+			return
+		}
+
+		if claims.IsClient() {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "A user access token is required."})
+
+			return
+		}
+
+		if claims.Audience != "" {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "The access token is meant for another service."})
+
+			return
+		}
+
+		ctx.Set(userIDKey, claims.UserID)
+		ctx.Set(claimsKey, claims)
+
+		ctx.Next()
+	}
+}
+
+func authenticateAPIKey(ctx *gin.Context, apiKeys APIKeyAuthenticator, key string, log logger.Logger) {
+	claims, err := apiKeys.AuthenticateAPIKey(ctx.Request.Context(), key, ctx.ClientIP())
+	if err != nil {
+		if errors.Is(err, models.ErrAPIKeyExpired) {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "API key has expired."})
+
+			return
+		}
+
+		if errors.Is(err, models.ErrInvalidAPIKey) {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized."})
+
+			return
+		}
+
+		log.Error(ctx, "failed to authenticate api key", zap.Error(err))
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "An unexpected error occurred."})
+
+		return
+	}
+
+	ctx.Set(userIDKey, claims.UserID)
+	ctx.Set(claimsKey, claims)
+
+	ctx.Next()
+}
+
+// DenyLegacyTokens rejects tokens of the legacy login and api keys, for
+// endpoints that set or change how the user signs in. The legacy login asks
+// for nothing but a user id, so its tokens must not be able to give anyone a
+// credential to the account. It must run after Authenticate.
+func DenyLegacyTokens() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if claims := Claims(ctx); claims != nil && (claims.IsLegacy() || claims.IsAPIKey()) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "A token of a signed in client is required."})
+
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// RequireScope lets through requests whose access token was granted the
+// scope. It must run after AccessToken or Authenticate.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		claims := Claims(ctx)
+		if claims == nil || !claims.HasScope(scope) {
+			ctx.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+scope+`"`)
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "The access token lacks the " + scope + " scope."})
+
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// RequireRole lets through requests of users with the role. It must run
+// after AccessToken or Authenticate.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		claims := Claims(ctx)
+		if claims == nil || !claims.HasRole(role) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "The " + role + " role is required."})
+
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// Claims returns the claims of the access token checked by AccessToken.
+func Claims(ctx *gin.Context) *utils.Claims {
+	value, _ := ctx.Get(claimsKey)
+	claims, _ := value.(*utils.Claims)
+
+	return claims
+}
+
+// UserID returns the id of the user authenticated by AccessToken.
+func UserID(ctx *gin.Context) uuid.UUID {
+	userID, _ := ctx.Get(userIDKey)
+	id, _ := userID.(uuid.UUID)
+
+	return id
+}
@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"context"
+	"medods-test-task/internal/models"
+	"medods-test-task/pkg/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// testAPIKeys accepts every key and grants it scope.
+type testAPIKeys struct {
+	scope string
+}
+
+func (k testAPIKeys) AuthenticateAPIKey(ctx context.Context, key, IPAddress string) (*utils.Claims, error) {
+	return &utils.Claims{
+		UserID:  uuid.New(),
+		Subject: utils.APIKeySubject,
+		Roles:   []string{models.RoleUser},
+		Scope:   k.scope,
+	}, nil
+}
+
+func TestRequireScope_APIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		scope          string
+		expectedStatus int
+	}{
+		{
+			name:           "Scope granted",
+			scope:          "sessions:read profile",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Scope missing",
+			scope:          "sessions:read",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "No scopes",
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/me/notifications",
+				Authenticate(nil, testAPIKeys{scope: tt.scope}, nil),
+				RequireScope(models.ScopeProfile),
+				func(ctx *gin.Context) { ctx.Status(http.StatusOK) },
+			)
+
+			req := httptest.NewRequest(http.MethodGet, "/me/notifications", nil)
+			req.Header.Set(APIKeyHeader, "mdk_key")
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("status = %d, expected %d", rec.Code, tt.expectedStatus)
+			}
+
+			if tt.expectedStatus == http.StatusForbidden && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("expected a WWW-Authenticate header naming the missing scope")
+			}
+		})
+	}
+}
+
+type testTokenConfig struct{}
+
+func (testTokenConfig) GetAuthJWTSecret() string                 { return "secret" }
+func (testTokenConfig) GetAccessTokenExpiration() time.Duration  { return time.Minute }
+func (testTokenConfig) GetRefreshTokenExpiration() time.Duration { return time.Hour }
+func (testTokenConfig) GetRevokeTokenExpiration() time.Duration  { return time.Hour }
+
+func TestDenyLegacyTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	manager := utils.NewManager(testTokenConfig{})
+
+	tests := []struct {
+		name           string
+		clientID       string
+		apiKey         bool
+		expectedStatus int
+	}{
+		{
+			name:           "Client session",
+			clientID:       "spa",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Legacy login",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "API key",
+			apiKey:         true,
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.PUT("/me/password",
+				Authenticate(manager, testAPIKeys{scope: models.ScopeProfile}, nil),
+				DenyLegacyTokens(),
+				func(ctx *gin.Context) { ctx.Status(http.StatusOK) },
+			)
+
+			req := httptest.NewRequest(http.MethodPut, "/me/password", nil)
+
+			if tt.apiKey {
+				req.Header.Set(APIKeyHeader, "mdk_key")
+			} else {
+				access, _, err := manager.NewTokenPair(uuid.New(), tt.clientID, "127.0.0.1", 0, []string{models.RoleUser}, []string{models.ScopeProfile})
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				req.Header.Set("Authorization", "Bearer "+access)
+			}
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("status = %d, expected %d", rec.Code, tt.expectedStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminToken guards operator endpoints with the static admin API token.
+// Every request is rejected when no token is configured.
+func AdminToken(token string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		provided, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+
+		if token == "" || !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized."})
+
+			return
+		}
+
+		ctx.Next()
+	}
+}
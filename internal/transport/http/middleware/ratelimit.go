@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"medods-test-task/pkg/logger"
+	"medods-test-task/pkg/ratelimit"
+	"medods-test-task/pkg/utils"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type KeyFunc func(ctx *gin.Context) string
+
+type limitKey struct {
+	key  string
+	rule ratelimit.Rule
+}
+
+type RouteLimits struct {
+	PerIP   ratelimit.Rule
+	PerUser ratelimit.Rule
+	Global  ratelimit.Rule
+}
+
+func RateLimit(limiter ratelimit.Limiter, route string, limits RouteLimits, userKey KeyFunc, log logger.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		keys := []limitKey{
+			{key: route + ":ip:" + ctx.ClientIP(), rule: limits.PerIP},
+		}
+
+		if limits.PerUser.Enabled() && userKey != nil {
+			if userID := userKey(ctx); userID != "" {
+				keys = append(keys, limitKey{key: route + ":user:" + userID, rule: limits.PerUser})
+			}
+		}
+
+		// The global bucket is checked last, so that a client already over its
+		// own limit cannot drain it and lock everyone else out.
+		keys = append(keys, limitKey{key: route + ":global", rule: limits.Global})
+
+		for _, k := range keys {
+			res, err := limiter.Allow(ctx, k.key, k.rule)
+			if err != nil {
+				log.Error(ctx, "failed to check rate limit", zap.String("key", k.key), zap.Error(err))
+
+				continue
+			}
+
+			if !res.Allowed {
+				AbortWithRetryAfter(ctx, http.StatusTooManyRequests, res.RetryAfter, "Too many requests.")
+
+				return
+			}
+		}
+
+		ctx.Next()
+	}
+}
+
+func AbortWithRetryAfter(ctx *gin.Context, code int, retryAfter time.Duration, msg string) {
+	SetRetryAfter(ctx, retryAfter)
+	ctx.AbortWithStatusJSON(code, gin.H{"error": msg})
+}
+
+// SetRetryAfter sets the Retry-After header in whole seconds, at least one.
+func SetRetryAfter(ctx *gin.Context, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	ctx.Header("Retry-After", strconv.Itoa(seconds))
+}
+
+// UserIDFromClaims keys the limit on the user authenticated by AccessToken.
+func UserIDFromClaims() KeyFunc {
+	return func(ctx *gin.Context) string {
+		if userID := UserID(ctx); userID != uuid.Nil {
+			return userID.String()
+		}
+
+		return ""
+	}
+}
+
+func UserIDFromQuery(param string) KeyFunc {
+	return func(ctx *gin.Context) string {
+		return ctx.Query(param)
+	}
+}
+
+// UserIDFromRefreshToken reads the user id out of the refresh token in a form
+// or json request body and puts a json body back for the handler.
+func UserIDFromRefreshToken(tokenManager utils.TokenManager) KeyFunc {
+	return func(ctx *gin.Context) string {
+		var req struct {
+			RefreshToken string `json:"refresh_token"`
+		}
+
+		if ctx.ContentType() == gin.MIMEPOSTForm {
+			req.RefreshToken = ctx.PostForm("refresh_token")
+		} else {
+			body, err := io.ReadAll(ctx.Request.Body)
+			if err != nil {
+				return ""
+			}
+			ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+			if err := json.Unmarshal(body, &req); err != nil {
+				return ""
+			}
+		}
+
+		if req.RefreshToken == "" {
+			return ""
+		}
+
+		userID, err := tokenManager.ParseRefreshToken(req.RefreshToken)
+		if err != nil {
+			return ""
+		}
+
+		return userID.String()
+	}
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"medods-test-task/pkg/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimit_IPOverLimitKeepsGlobalBucket(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	limits := RouteLimits{
+		PerIP:  ratelimit.Rule{Limit: 1, Period: time.Minute},
+		Global: ratelimit.Rule{Limit: 3, Period: time.Minute},
+	}
+
+	router := gin.New()
+	router.POST("/login", RateLimit(ratelimit.NewMemoryLimiter(), "login", limits, nil, nil), func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	login := func(ip string) int {
+		req := httptest.NewRequest(http.MethodPost, "/login", nil)
+		req.RemoteAddr = ip + ":1234"
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	if code := login("10.0.0.1"); code != http.StatusOK {
+		t.Fatalf("first request: status = %d, expected %d", code, http.StatusOK)
+	}
+
+	for i := 0; i < 5; i++ {
+		if code := login("10.0.0.1"); code != http.StatusTooManyRequests {
+			t.Fatalf("request over the ip limit: status = %d, expected %d", code, http.StatusTooManyRequests)
+		}
+	}
+
+	for _, ip := range []string{"10.0.0.2", "10.0.0.3"} {
+		if code := login(ip); code != http.StatusOK {
+			t.Errorf("request from %s: status = %d, expected %d", ip, code, http.StatusOK)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"context"
+	"medods-test-task/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	RequestIDHeader    = "X-Request-ID"
+	maxRequestIDLength = 64
+)
+
+// RequestID propagates the request id from the X-Request-ID header or
+// generates a new one and stores it in the request context.
+func RequestID() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		requestID := ctx.GetHeader(RequestIDHeader)
+		if requestID == "" || len(requestID) > maxRequestIDLength {
+			requestID = uuid.NewString()
+		}
+
+		ctx.Header(RequestIDHeader, requestID)
+		ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), logger.RequestIDKey{}, requestID))
+
+		ctx.Next()
+	}
+}
@@ -0,0 +1,512 @@
+package http
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"html/template"
+	"medods-test-task/internal/models"
+	"medods-test-task/internal/transport/http/middleware"
+	"medods-test-task/pkg/logger"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const tokenTypeBearer = "Bearer"
+
+//go:embed pages/*.html
+var pagesFS embed.FS
+
+var pages = template.Must(template.ParseFS(pagesFS, "pages/*.html"))
+
+type OAuthService interface {
+	ValidateAuthorizeRequest(ctx context.Context, req models.AuthorizeRequest) (*models.OAuthClient, error)
+	Authorize(ctx context.Context, req models.AuthorizeRequest, email, password, IPAddress string) (string, error)
+	ExchangeCode(ctx context.Context, creds models.ClientCredentials, code, redirectURI, codeVerifier, IPAddress, userAgent string) (*models.TokenSet, error)
+	RefreshToken(ctx context.Context, creds models.ClientCredentials, refreshToken, scope, IPAddress, userAgent string) (*models.TokenSet, error)
+	ClientCredentials(ctx context.Context, creds models.ClientCredentials, scope string) (*models.TokenSet, error)
+	RequestDeviceCode(ctx context.Context, creds models.ClientCredentials, scope string) (*models.DeviceAuthorization, error)
+	DeviceRequest(ctx context.Context, userCode string) (*models.DeviceRequest, error)
+	ConfirmDeviceWithPassword(ctx context.Context, userCode, email, password, IPAddress string, approved bool) error
+	ExchangeDeviceCode(ctx context.Context, creds models.ClientCredentials, deviceCode, IPAddress, userAgent string) (*models.TokenSet, error)
+	ExchangeToken(ctx context.Context, creds models.ClientCredentials, req models.TokenExchangeRequest, IPAddress, userAgent string) (*models.TokenSet, error)
+}
+
+type authorizePage struct {
+	ClientName string
+	Request    models.AuthorizeRequest
+	Email      string
+	Error      string
+}
+
+type devicePage struct {
+	ClientName string
+	// Scope the device asked for, shown before the user approves it
+	Scope    []string
+	UserCode string
+	Email    string
+	Error    string
+}
+
+type devicePageResult struct {
+	ClientName string
+	Approved   bool
+}
+
+// OAuthController serves the authorization server endpoints. They follow
+// RFC 6749 rather than the /v1 API, so they live under /oauth and are left
+// out of the API docs.
+type OAuthController struct {
+	serv   OAuthService
+	logger logger.Logger
+}
+
+func NewOAuthController(serv OAuthService, logger logger.Logger) *OAuthController {
+	return &OAuthController{
+		serv:   serv,
+		logger: logger,
+	}
+}
+
+// Authorize shows the hosted sign in and consent page for an authorization
+// code request with PKCE.
+func (c *OAuthController) Authorize(ctx *gin.Context) {
+	req := models.AuthorizeRequest{
+		ResponseType:        ctx.Query("response_type"),
+		ClientID:            ctx.Query("client_id"),
+		RedirectURI:         ctx.Query("redirect_uri"),
+		Scope:               ctx.Query("scope"),
+		State:               ctx.Query("state"),
+		CodeChallenge:       ctx.Query("code_challenge"),
+		CodeChallengeMethod: ctx.Query("code_challenge_method"),
+	}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), RequestTimeout)
+	defer cancel()
+
+	client, err := c.serv.ValidateAuthorizeRequest(ctxWithTimeout, req)
+	if err != nil {
+		c.authorizeError(ctx, req, err)
+
+		return
+	}
+
+	renderPage(ctx, http.StatusOK, "authorize", authorizePage{ClientName: client.Name, Request: req})
+}
+
+// SubmitAuthorize handles the sign in form. On success the user is
+// redirected back to the client with an authorization code.
+func (c *OAuthController) SubmitAuthorize(ctx *gin.Context) {
+	req := models.AuthorizeRequest{
+		ResponseType:        ctx.PostForm("response_type"),
+		ClientID:            ctx.PostForm("client_id"),
+		RedirectURI:         ctx.PostForm("redirect_uri"),
+		Scope:               ctx.PostForm("scope"),
+		State:               ctx.PostForm("state"),
+		CodeChallenge:       ctx.PostForm("code_challenge"),
+		CodeChallengeMethod: ctx.PostForm("code_challenge_method"),
+	}
+	email := ctx.PostForm("email")
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), RequestTimeout)
+	defer cancel()
+
+	client, err := c.serv.ValidateAuthorizeRequest(ctxWithTimeout, req)
+	if err != nil {
+		c.authorizeError(ctx, req, err)
+
+		return
+	}
+
+	if ctx.PostForm("action") != "allow" {
+		redirectWithError(ctx, req, "access_denied", "The user denied the request.")
+
+		return
+	}
+
+	code, err := c.serv.Authorize(ctxWithTimeout, req, email, ctx.PostForm("password"), ctx.ClientIP())
+	if err != nil {
+		page := authorizePage{ClientName: client.Name, Request: req, Email: email}
+
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			page.Error = "Invalid email or password."
+			renderPage(ctx, http.StatusUnauthorized, "authorize", page)
+
+			return
+		}
+
+		var retryErr *models.RetryError
+		if errors.As(err, &retryErr) {
+			page.Error = "Too many failed attempts. Please try again later."
+			renderPage(ctx, http.StatusTooManyRequests, "authorize", page)
+
+			return
+		}
+
+		c.authorizeError(ctx, req, err)
+
+		return
+	}
+
+	redirectTo(ctx, req.RedirectURI, url.Values{"code": {code}, "state": {req.State}})
+}
+
+// DeviceCode is the RFC 8628 device authorization endpoint. It takes the
+// same client authentication as Token.
+func (c *OAuthController) DeviceCode(ctx *gin.Context) {
+	if !checkAssertionType(ctx) {
+		return
+	}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), RequestTimeout)
+	defer cancel()
+
+	auth, err := c.serv.RequestDeviceCode(ctxWithTimeout, readClientCredentials(ctx), ctx.PostForm("scope"))
+	if err != nil {
+		c.tokenError(ctx, err, "Failed to issue a device code")
+
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(http.StatusOK, DeviceAuthorizationResponse{
+		DeviceCode:              auth.DeviceCode,
+		UserCode:                auth.UserCode,
+		VerificationURI:         auth.VerificationURI,
+		VerificationURIComplete: auth.VerificationURIComplete,
+		ExpiresIn:               int64(auth.ExpiresIn / time.Second),
+		Interval:                int64(auth.Interval / time.Second),
+	})
+}
+
+// Device shows the verification page where the user enters the code shown
+// by their device. The code is filled in when it comes from
+// verification_uri_complete.
+func (c *OAuthController) Device(ctx *gin.Context) {
+	page := devicePage{UserCode: ctx.Query("user_code")}
+
+	if page.UserCode != "" {
+		ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), RequestTimeout)
+		defer cancel()
+
+		request, err := c.serv.DeviceRequest(ctxWithTimeout, page.UserCode)
+		if err != nil {
+			c.deviceError(ctx, page, err)
+
+			return
+		}
+
+		page.ClientName, page.Scope = request.Client.Name, request.Scope
+	}
+
+	renderPage(ctx, http.StatusOK, "device", page)
+}
+
+// SubmitDevice handles the verification form. The user signs in and approves
+// or denies the device, which gets the answer on its next poll. An approval
+// of a code whose scope the page has not shown yet shows it first.
+func (c *OAuthController) SubmitDevice(ctx *gin.Context) {
+	page := devicePage{UserCode: ctx.PostForm("user_code"), Email: ctx.PostForm("email")}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), RequestTimeout)
+	defer cancel()
+
+	request, err := c.serv.DeviceRequest(ctxWithTimeout, page.UserCode)
+	if err != nil {
+		c.deviceError(ctx, page, err)
+
+		return
+	}
+
+	page.ClientName, page.Scope = request.Client.Name, request.Scope
+	approved := ctx.PostForm("action") == "allow"
+
+	// A code typed in by hand was not shown with its client and scope yet,
+	// the user approves only once they have seen them.
+	if approved && ctx.PostForm("shown_code") != page.UserCode {
+		page.Error = "Check what the device asks for and allow it again."
+		renderPage(ctx, http.StatusOK, "device", page)
+
+		return
+	}
+
+	err = c.serv.ConfirmDeviceWithPassword(ctxWithTimeout, page.UserCode, page.Email, ctx.PostForm("password"), ctx.ClientIP(), approved)
+	if err != nil {
+		c.deviceError(ctx, page, err)
+
+		return
+	}
+
+	renderPage(ctx, http.StatusOK, "device_done", devicePageResult{ClientName: request.Client.Name, Approved: approved})
+}
+
+// Token is the RFC 6749 token endpoint for the authorization_code,
+// refresh_token and client_credentials grants, the RFC 8628 device_code grant
+// and RFC 8693 token exchange. Confidential clients authenticate with
+// client_secret_basic, client_secret_post or private_key_jwt.
+func (c *OAuthController) Token(ctx *gin.Context) {
+	if !checkAssertionType(ctx) {
+		return
+	}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), RequestTimeout)
+	defer cancel()
+
+	var (
+		tokens *models.TokenSet
+		err    error
+	)
+
+	grantType := ctx.PostForm("grant_type")
+
+	switch grantType {
+	case models.GrantTypeAuthorizationCode:
+		tokens, err = c.serv.ExchangeCode(ctxWithTimeout, readClientCredentials(ctx), ctx.PostForm("code"),
+			ctx.PostForm("redirect_uri"), ctx.PostForm("code_verifier"), ctx.ClientIP(), ctx.Request.UserAgent())
+	case models.GrantTypeRefreshToken:
+		refreshToken := ctx.PostForm("refresh_token")
+		if refreshToken == "" {
+			oauthError(ctx, http.StatusBadRequest, "invalid_request", "The refresh_token parameter is missing.")
+
+			return
+		}
+
+		tokens, err = c.serv.RefreshToken(ctxWithTimeout, readClientCredentials(ctx), refreshToken, ctx.PostForm("scope"), ctx.ClientIP(), ctx.Request.UserAgent())
+	case models.GrantTypeClientCredentials:
+		tokens, err = c.serv.ClientCredentials(ctxWithTimeout, readClientCredentials(ctx), ctx.PostForm("scope"))
+	case models.GrantTypeDeviceCode:
+		deviceCode := ctx.PostForm("device_code")
+		if deviceCode == "" {
+			oauthError(ctx, http.StatusBadRequest, "invalid_request", "The device_code parameter is missing.")
+
+			return
+		}
+
+		tokens, err = c.serv.ExchangeDeviceCode(ctxWithTimeout, readClientCredentials(ctx), deviceCode, ctx.ClientIP(), ctx.Request.UserAgent())
+	case models.GrantTypeTokenExchange:
+		req := models.TokenExchangeRequest{
+			SubjectToken:       ctx.PostForm("subject_token"),
+			SubjectTokenType:   ctx.PostForm("subject_token_type"),
+			RequestedSubject:   ctx.PostForm("requested_subject"),
+			RequestedTokenType: ctx.PostForm("requested_token_type"),
+			Audience:           ctx.PostForm("audience"),
+			Scope:              ctx.PostForm("scope"),
+		}
+		if req.SubjectToken == "" || req.SubjectTokenType == "" || req.Audience == "" {
+			oauthError(ctx, http.StatusBadRequest, "invalid_request", "The subject_token, subject_token_type and audience parameters are required.")
+
+			return
+		}
+
+		tokens, err = c.serv.ExchangeToken(ctxWithTimeout, readClientCredentials(ctx), req, ctx.ClientIP(), ctx.Request.UserAgent())
+	case "":
+		oauthError(ctx, http.StatusBadRequest, "invalid_request", "The grant_type parameter is missing.")
+
+		return
+	default:
+		oauthError(ctx, http.StatusBadRequest, "unsupported_grant_type", models.ErrUnsupportedGrantType.Error())
+
+		return
+	}
+
+	if err != nil {
+		c.tokenError(ctx, err, "Failed to issue tokens for the "+grantType+" grant")
+
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("Pragma", "no-cache")
+	ctx.JSON(http.StatusOK, newTokenResponse(tokens))
+}
+
+// tokenError reports a failed token request with an RFC 6749 error code.
+func (c *OAuthController) tokenError(ctx *gin.Context, err error, msg string) {
+	var retryErr *models.RetryError
+
+	switch {
+	case errors.Is(err, models.ErrInvalidClient):
+		if _, _, ok := ctx.Request.BasicAuth(); ok {
+			ctx.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+
+		oauthError(ctx, http.StatusUnauthorized, "invalid_client", err.Error())
+	case errors.Is(err, models.ErrInvalidGrant), errors.Is(err, models.ErrInvalidToken),
+		errors.Is(err, models.ErrTokenExpired), errors.Is(err, models.ErrMismatchedHashAndToken),
+		errors.Is(err, models.ErrSessionNotFound), errors.Is(err, models.ErrInvalidSession),
+		errors.Is(err, models.ErrClientMismatch), errors.Is(err, models.ErrImpersonationForbidden),
+		errors.Is(err, models.ErrUnknownSubject):
+		oauthError(ctx, http.StatusBadRequest, "invalid_grant", err.Error())
+	case errors.Is(err, models.ErrUnsupportedTokenType):
+		oauthError(ctx, http.StatusBadRequest, "invalid_request", err.Error())
+	case errors.Is(err, models.ErrInvalidTarget):
+		oauthError(ctx, http.StatusBadRequest, "invalid_target", err.Error())
+	case errors.Is(err, models.ErrUnauthorizedClient):
+		oauthError(ctx, http.StatusBadRequest, "unauthorized_client", err.Error())
+	case errors.Is(err, models.ErrScopeNotAllowed), errors.Is(err, models.ErrScopeNotGranted):
+		oauthError(ctx, http.StatusBadRequest, "invalid_scope", err.Error())
+	case errors.Is(err, models.ErrAuthorizationPending):
+		oauthError(ctx, http.StatusBadRequest, "authorization_pending", err.Error())
+	case errors.Is(err, models.ErrSlowDown):
+		oauthError(ctx, http.StatusBadRequest, "slow_down", err.Error())
+	case errors.Is(err, models.ErrAccessDenied):
+		oauthError(ctx, http.StatusBadRequest, "access_denied", err.Error())
+	case errors.Is(err, models.ErrExpiredToken):
+		oauthError(ctx, http.StatusBadRequest, "expired_token", err.Error())
+	case errors.As(err, &retryErr):
+		middleware.SetRetryAfter(ctx, retryErr.RetryAfter)
+
+		status := http.StatusTooManyRequests
+		if errors.Is(err, models.ErrAccountLocked) {
+			status = http.StatusLocked
+		}
+
+		oauthError(ctx, status, "temporarily_unavailable", err.Error())
+	default:
+		c.logger.Error(ctx, msg, zap.Error(err))
+		oauthError(ctx, http.StatusInternalServerError, "server_error", "An unexpected error occurred.")
+	}
+}
+
+// authorizeError reports a failed authorization request. Errors about the
+// client or redirect uri are shown on the page, since redirecting to an
+// unverified uri would make the server an open redirector.
+func (c *OAuthController) authorizeError(ctx *gin.Context, req models.AuthorizeRequest, err error) {
+	switch {
+	case errors.Is(err, models.ErrInvalidClient), errors.Is(err, models.ErrInvalidRedirectURI):
+		renderPage(ctx, http.StatusBadRequest, "error", "The application sent an invalid request: "+err.Error()+".")
+	case errors.Is(err, models.ErrUnsupportedResponseType):
+		redirectWithError(ctx, req, "unsupported_response_type", err.Error())
+	case errors.Is(err, models.ErrUnauthorizedClient):
+		redirectWithError(ctx, req, "unauthorized_client", err.Error())
+	case errors.Is(err, models.ErrScopeNotAllowed):
+		redirectWithError(ctx, req, "invalid_scope", err.Error())
+	case errors.Is(err, models.ErrInvalidCodeChallenge):
+		redirectWithError(ctx, req, "invalid_request", err.Error())
+	default:
+		c.logger.Error(ctx, "Failed to authorize", zap.Error(err))
+		redirectWithError(ctx, req, "server_error", "An unexpected error occurred.")
+	}
+}
+
+// deviceError shows a failed verification on the device page, so that the
+// user can correct the code or their credentials.
+func (c *OAuthController) deviceError(ctx *gin.Context, page devicePage, err error) {
+	var retryErr *models.RetryError
+
+	switch {
+	case errors.Is(err, models.ErrInvalidUserCode):
+		page.ClientName, page.Scope = "", nil
+		page.Error = "The code is invalid or has expired."
+		renderPage(ctx, http.StatusBadRequest, "device", page)
+	case errors.Is(err, models.ErrInvalidCredentials):
+		page.Error = "Invalid email or password."
+		renderPage(ctx, http.StatusUnauthorized, "device", page)
+	case errors.As(err, &retryErr):
+		page.Error = "Too many failed attempts. Please try again later."
+		renderPage(ctx, http.StatusTooManyRequests, "device", page)
+	default:
+		c.logger.Error(ctx, "Failed to verify device", zap.Error(err))
+		renderPage(ctx, http.StatusInternalServerError, "error", "An unexpected error occurred.")
+	}
+}
+
+// checkAssertionType rejects client assertions other than private_key_jwt.
+func checkAssertionType(ctx *gin.Context) bool {
+	assertionType := ctx.PostForm("client_assertion_type")
+	if assertionType != "" && assertionType != models.ClientAssertionTypeJWT {
+		oauthError(ctx, http.StatusBadRequest, "invalid_request", "Unsupported client assertion type.")
+
+		return false
+	}
+
+	return true
+}
+
+// readClientCredentials reads client_secret_basic credentials, falling back
+// to the client_id, client_secret and client_assertion form fields.
+func readClientCredentials(ctx *gin.Context) models.ClientCredentials {
+	id, secret, ok := ctx.Request.BasicAuth()
+	if !ok {
+		return models.ClientCredentials{
+			ID:        ctx.PostForm("client_id"),
+			Secret:    ctx.PostForm("client_secret"),
+			Assertion: ctx.PostForm("client_assertion"),
+		}
+	}
+
+	// Both parts are form encoded before they are joined, see RFC 6749 2.3.1.
+	if unescaped, err := url.QueryUnescape(id); err == nil {
+		id = unescaped
+	}
+
+	if unescaped, err := url.QueryUnescape(secret); err == nil {
+		secret = unescaped
+	}
+
+	return models.ClientCredentials{ID: id, Secret: secret}
+}
+
+func newTokenResponse(tokens *models.TokenSet) TokenResponse {
+	return TokenResponse{
+		AccessToken:      tokens.AccessToken,
+		TokenType:        tokenTypeBearer,
+		ExpiresIn:        int64(tokens.AccessTTL / time.Second),
+		RefreshToken:     tokens.RefreshToken,
+		RefreshExpiresIn: int64(tokens.RefreshTTL / time.Second),
+		Scope:            strings.Join(tokens.Scope, " "),
+		IssuedTokenType:  tokens.IssuedTokenType,
+	}
+}
+
+func oauthError(ctx *gin.Context, status int, code, description string) {
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(status, OAuthErrorResponse{Error: code, ErrorDescription: description})
+}
+
+func redirectWithError(ctx *gin.Context, req models.AuthorizeRequest, code, description string) {
+	redirectTo(ctx, req.RedirectURI, url.Values{
+		"error":             {code},
+		"error_description": {description},
+		"state":             {req.State},
+	})
+}
+
+// redirectTo adds params to the query of a registered redirect uri.
+func redirectTo(ctx *gin.Context, redirectURI string, params url.Values) {
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		renderPage(ctx, http.StatusBadRequest, "error", "The application has an invalid redirect uri.")
+
+		return
+	}
+
+	query := target.Query()
+	for key, values := range params {
+		if values[0] != "" {
+			query.Set(key, values[0])
+		}
+	}
+
+	target.RawQuery = query.Encode()
+
+	ctx.Redirect(http.StatusFound, target.String())
+}
+
+// renderPage writes an html page that must not be framed or cached.
+func renderPage(ctx *gin.Context, status int, name string, data any) {
+	ctx.Header("Content-Type", "text/html; charset=utf-8")
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("X-Frame-Options", "DENY")
+	ctx.Header("Content-Security-Policy", "frame-ancestors 'none'")
+	ctx.Status(status)
+
+	if err := pages.ExecuteTemplate(ctx.Writer, name, data); err != nil {
+		ctx.Error(err)
+	}
+}
@@ -1,5 +1,7 @@
 package http
 
+import "time"
+
 // swagger:model ErrorResponse
 type ErrorResponse struct {
 	// Error message
@@ -11,6 +13,251 @@ type TokenResponse struct {
 	// Access token
 	AccessToken string `json:"access_token"`
 
-	// Refresh token
-	RefreshToken string `json:"refresh_token"`
+	// Always Bearer
+	TokenType string `json:"token_type"`
+
+	// Access token lifetime in seconds
+	ExpiresIn int64 `json:"expires_in"`
+
+	// Refresh token, not issued for the client credentials grant
+	RefreshToken string `json:"refresh_token,omitempty"`
+
+	// Refresh token lifetime in seconds
+	RefreshExpiresIn int64 `json:"refresh_expires_in,omitempty"`
+
+	// Granted scopes separated by spaces
+	Scope string `json:"scope,omitempty"`
+
+	// Type of the issued token, set only for token exchange
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
+}
+
+// OAuthErrorResponse is the error of the oauth token endpoint, see RFC 6749
+// section 5.2.
+type OAuthErrorResponse struct {
+	// Error code such as invalid_request, invalid_client or invalid_grant
+	Error string `json:"error"`
+
+	// Human readable explanation
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// DeviceAuthorizationResponse starts the device authorization grant, see
+// RFC 8628 section 3.2.
+type DeviceAuthorizationResponse struct {
+	// Code the device polls the token endpoint with
+	DeviceCode string `json:"device_code"`
+
+	// Code the user enters on the verification page
+	UserCode string `json:"user_code"`
+
+	// Page where the user enters the code
+	VerificationURI string `json:"verification_uri"`
+
+	// Verification page with the code filled in, for QR codes
+	VerificationURIComplete string `json:"verification_uri_complete"`
+
+	// Device code lifetime in seconds
+	ExpiresIn int64 `json:"expires_in"`
+
+	// Seconds the device waits between polls
+	Interval int64 `json:"interval"`
+}
+
+// DeviceRequestResponse is what a device waiting for the user code asks for.
+type DeviceRequestResponse struct {
+	// Client of the device
+	ClientID   string `json:"client_id"`
+	ClientName string `json:"client_name"`
+
+	// Scopes the device gets when approved
+	Scope []string `json:"scope"`
+}
+
+// swagger:model PasswordErrorResponse
+type PasswordErrorResponse struct {
+	// Error message
+	Error string `json:"error"`
+
+	// Failed rule: min_length, max_length, banned_word, matches_email or breached
+	Rule string `json:"rule"`
+}
+
+// swagger:model MessageResponse
+type MessageResponse struct {
+	// Human readable result
+	Message string `json:"message"`
+}
+
+// swagger:model WebhookEndpointResponse
+type WebhookEndpointResponse struct {
+	// Endpoint id
+	ID string `json:"id"`
+
+	// Url receiving the events
+	URL string `json:"url"`
+
+	// Subscribed events
+	Events []string `json:"events"`
+
+	// Signing secret, returned only on registration
+	Secret string `json:"secret,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// swagger:model UserRolesResponse
+type UserRolesResponse struct {
+	// User id
+	UserID string `json:"user_id"`
+
+	// Roles of the user including the implied user role
+	Roles []string `json:"roles"`
+}
+
+// swagger:model ClientResponse
+type ClientResponse struct {
+	// Client id
+	ID string `json:"id"`
+
+	// Name shown on the consent page
+	Name string `json:"name"`
+
+	// Whether the client authenticates with a secret or a key
+	Confidential bool `json:"confidential"`
+
+	// Allowed redirect uris
+	RedirectURIs []string `json:"redirect_uris"`
+
+	// Allowed grant types
+	GrantTypes []string `json:"grant_types"`
+
+	// Scopes the client may request
+	Scopes []string `json:"scopes"`
+
+	// PEM encoded key verifying private_key_jwt assertions
+	PublicKey string `json:"public_key,omitempty"`
+
+	// Access token ttl in seconds, 0 for the server default
+	AccessTokenTTL int64 `json:"access_token_ttl"`
+
+	// Refresh token ttl in seconds, 0 for the server default
+	RefreshTokenTTL int64 `json:"refresh_token_ttl"`
+
+	// Client secret, returned only on creation and rotation
+	Secret string `json:"secret,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// swagger:model APIKeyResponse
+type APIKeyResponse struct {
+	// Key id
+	ID string `json:"id"`
+
+	// Name given by the owner
+	Name string `json:"name"`
+
+	// Start of the key, identifies it without revealing it
+	Prefix string `json:"prefix"`
+
+	// Scopes granted to the key
+	Scopes []string `json:"scopes"`
+
+	// The key, returned only on creation
+	Key string `json:"key,omitempty"`
+
+	// Absent when the key does not expire
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+
+	// Ip of the last use
+	LastUsedIP string `json:"last_used_ip,omitempty"`
+
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// swagger:model NotificationSettingsResponse
+type NotificationSettingsResponse struct {
+	// Channels of every event
+	Preferences []NotificationPreferenceResponse `json:"preferences"`
+
+	// Phone for the sms channel
+	Phone string `json:"phone,omitempty"`
+
+	// New phone waiting for its code, returned only when the phone changes
+	PendingPhone string `json:"pending_phone,omitempty"`
+
+	// Url for the webhook channel
+	WebhookURL string `json:"webhook_url,omitempty"`
+
+	// Signing secret, returned only when the webhook url changes
+	WebhookSecret string `json:"webhook_secret,omitempty"`
+}
+
+type NotificationPreferenceResponse struct {
+	// Event name
+	Event string `json:"event"`
+
+	// Channels, empty when the event is off
+	Channels []string `json:"channels"`
+}
+
+type MailMessageResponse struct {
+	// Message id
+	ID string `json:"id"`
+
+	// Sender address
+	From string `json:"from"`
+
+	// Recipient address
+	To string `json:"to"`
+
+	// Email subject
+	Subject string `json:"subject"`
+
+	// Html body
+	Body string `json:"body"`
+
+	// Plain text body
+	Text string `json:"text"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type HealthStatusResponse struct {
+	// ok, degraded or unavailable
+	Status string `json:"status"`
+}
+
+type HealthResponse struct {
+	// ok, degraded or unavailable
+	Status string `json:"status"`
+
+	// Database status
+	Database string `json:"database"`
+
+	// Smtp relays in priority order
+	Relays []RelayHealthResponse `json:"relays,omitempty"`
+}
+
+type RelayHealthResponse struct {
+	// Relay address
+	Name string `json:"name"`
+
+	// Circuit breaker state: closed, open or half_open
+	State string `json:"state"`
+
+	// Consecutive failures
+	Failures int `json:"failures"`
+
+	// Last delivery error
+	LastError string `json:"last_error,omitempty"`
+
+	LastFailureAt *time.Time `json:"last_failure_at,omitempty"`
 }
@@ -0,0 +1,75 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"medods-test-task/internal/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type SetUserRolesRequest struct {
+	// Assigned roles, the user role is implied
+	Roles []string `json:"roles"`
+}
+
+// SetUserRoles godoc
+// @Summary      SetUserRoles
+// @Description  Replaces the roles of a user. The new roles are put into access tokens issued from now on, existing sessions get them on their next refresh.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param id path string true "User id"
+// @Param roles body SetUserRolesRequest true "Roles"
+// @Success      200 {object} UserRolesResponse "All roles of the user"
+// @Failure      400 {object} ErrorResponse "User id must be a valid UUID"
+// @Failure      400 {object} ErrorResponse "Unknown role"
+// @Failure      401 {object} ErrorResponse "Unauthorized"
+// @Failure      404 {object} ErrorResponse "User was not found"
+// @Failure      500 {object} ErrorResponse "An unexpected error occurred"
+// @Router /admin/users/{id}/roles [put]
+func (c *AppController) SetUserRoles(ctx *gin.Context) {
+	userID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{Error: "User id must be a valid UUID."})
+
+		return
+	}
+
+	var req SetUserRolesRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body."})
+
+		return
+	}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), RequestTimeout)
+	defer cancel()
+
+	roles, err := c.roles.SetUserRoles(ctxWithTimeout, userID, req.Roles)
+	if err != nil {
+		if errors.Is(err, models.ErrUnknownRole) {
+			ctx.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+
+			return
+		}
+
+		if errors.Is(err, models.ErrUserNotFound) {
+			ctx.JSON(http.StatusNotFound, ErrorResponse{Error: "User was not found."})
+
+			return
+		}
+
+		c.logger.Error(ctx, "Failed to set user roles", zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError, ErrorResponse{Error: "An unexpected error occurred."})
+
+		return
+	}
+
+	ctx.JSON(http.StatusOK, UserRolesResponse{UserID: userID.String(), Roles: roles})
+}
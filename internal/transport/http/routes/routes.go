@@ -1,6 +1,11 @@
 package routes
 
 import (
+	"medods-test-task/config"
+	"medods-test-task/internal/models"
+	"medods-test-task/internal/transport/http/middleware"
+	"medods-test-task/pkg/logger"
+	"medods-test-task/pkg/ratelimit"
 	"medods-test-task/pkg/utils"
 
 	_ "medods-test-task/docs"
@@ -14,20 +19,129 @@ import (
 type Controller interface {
 	Login(ctx *gin.Context)
 	RefreshToken(ctx *gin.Context)
+	RevokeSessionsPage(ctx *gin.Context)
+	RevokeSessions(ctx *gin.Context)
+	ConfirmEmailChangePage(ctx *gin.Context)
+	ConfirmEmailChange(ctx *gin.Context)
+	RegisterWebhook(ctx *gin.Context)
+	ListWebhooks(ctx *gin.Context)
+	DeleteWebhook(ctx *gin.Context)
+	GetNotificationSettings(ctx *gin.Context)
+	UpdateNotificationSettings(ctx *gin.Context)
+	ConfirmPhone(ctx *gin.Context)
+	ChangeEmail(ctx *gin.Context)
+	SetPassword(ctx *gin.Context)
+	CreateClient(ctx *gin.Context)
+	ListClients(ctx *gin.Context)
+	GetClient(ctx *gin.Context)
+	UpdateClient(ctx *gin.Context)
+	RotateClientSecret(ctx *gin.Context)
+	DeleteClient(ctx *gin.Context)
+	SetUserRoles(ctx *gin.Context)
+	GetDeviceRequest(ctx *gin.Context)
+	ConfirmDevice(ctx *gin.Context)
+	CreateAPIKey(ctx *gin.Context)
+	ListAPIKeys(ctx *gin.Context)
+	RevokeAPIKey(ctx *gin.Context)
+	CreateUserAPIKey(ctx *gin.Context)
+	ListUserAPIKeys(ctx *gin.Context)
+	RevokeUserAPIKey(ctx *gin.Context)
 }
 
-func RegistrationRoutes(app *gin.Engine, tokenManager utils.TokenManager, c Controller) {
+type DevMailController interface {
+	ListMail(ctx *gin.Context)
+	GetMail(ctx *gin.Context)
+	GetRawMail(ctx *gin.Context)
+	GetMailHTML(ctx *gin.Context)
+	ClearMail(ctx *gin.Context)
+}
+
+type OAuthController interface {
+	Authorize(ctx *gin.Context)
+	SubmitAuthorize(ctx *gin.Context)
+	Token(ctx *gin.Context)
+	DeviceCode(ctx *gin.Context)
+	Device(ctx *gin.Context)
+	SubmitDevice(ctx *gin.Context)
+}
+
+type HealthController interface {
+	Health(ctx *gin.Context)
+	HealthDetails(ctx *gin.Context)
+}
+
+func RegistrationRoutes(app *gin.Engine, cfg *config.Config, tokenManager utils.TokenManager, apiKeys middleware.APIKeyAuthenticator, limiter ratelimit.Limiter, logs logger.Logger, c Controller) {
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: []string{"*"},
-		AllowMethods: []string{"GET", "POST", "PUT", "DELETE"},
-		AllowHeaders: []string{"Content-Type", "Authorization"},
+		AllowOrigins:  []string{"*"},
+		AllowMethods:  []string{"GET", "POST", "PUT", "DELETE"},
+		AllowHeaders:  []string{"Content-Type", "Authorization", middleware.APIKeyHeader, middleware.RequestIDHeader},
+		ExposeHeaders: []string{middleware.RequestIDHeader},
 	}))
+	app.Use(middleware.RequestID())
 	v1 := app.Group("/v1")
 
 	auth := v1.Group("/auth")
 	{
-		auth.POST("/login", c.Login)
-		auth.POST("/refresh", c.RefreshToken)
+		auth.POST("/login",
+			middleware.RateLimit(limiter, "login", routeLimits(cfg.RateLimit.Login), middleware.UserIDFromQuery("user_id"), logs),
+			c.Login,
+		)
+		auth.POST("/refresh",
+			middleware.RateLimit(limiter, "refresh", routeLimits(cfg.RateLimit.Refresh), middleware.UserIDFromRefreshToken(tokenManager), logs),
+			c.RefreshToken,
+		)
+		auth.GET("/revoke", c.RevokeSessionsPage)
+		auth.POST("/revoke", c.RevokeSessions)
+		auth.GET("/email/confirm", c.ConfirmEmailChangePage)
+		auth.POST("/email/confirm", c.ConfirmEmailChange)
+	}
+
+	me := v1.Group("/me", middleware.Authenticate(tokenManager, apiKeys, logs))
+
+	// Routes open to api keys check the scope, so that a key works only for
+	// what it was created for.
+	profile := me.Group("", middleware.RequireScope(models.ScopeProfile))
+	{
+		profile.GET("/notifications", c.GetNotificationSettings)
+		profile.PUT("/notifications", c.UpdateNotificationSettings)
+		profile.POST("/notifications/phone", c.ConfirmPhone)
+	}
+
+	// A leaked api key must not be able to take over the account or mint
+	// more keys. Neither may a token of the legacy login, which asks only for
+	// a user id.
+	credentials := me.Group("", middleware.DenyLegacyTokens())
+	{
+		credentials.PUT("/email",
+			middleware.RateLimit(limiter, "email_change", routeLimits(cfg.RateLimit.EmailChange), middleware.UserIDFromClaims(), logs),
+			c.ChangeEmail,
+		)
+		credentials.PUT("/password", c.SetPassword)
+		credentials.GET("/devices/:userCode", c.GetDeviceRequest)
+		credentials.POST("/devices", c.ConfirmDevice)
+		credentials.POST("/api-keys", c.CreateAPIKey)
+		credentials.GET("/api-keys", c.ListAPIKeys)
+		credentials.DELETE("/api-keys/:id", c.RevokeAPIKey)
+	}
+
+	// The operator endpoints are served only when a token is configured.
+	if cfg.Admin.Enabled() {
+		admin := v1.Group("/admin", middleware.AdminToken(cfg.Admin.Token))
+		{
+			admin.POST("/webhooks", c.RegisterWebhook)
+			admin.GET("/webhooks", c.ListWebhooks)
+			admin.DELETE("/webhooks/:id", c.DeleteWebhook)
+			admin.POST("/clients", c.CreateClient)
+			admin.GET("/clients", c.ListClients)
+			admin.GET("/clients/:id", c.GetClient)
+			admin.PUT("/clients/:id", c.UpdateClient)
+			admin.DELETE("/clients/:id", c.DeleteClient)
+			admin.POST("/clients/:id/secret", c.RotateClientSecret)
+			admin.PUT("/users/:id/roles", c.SetUserRoles)
+			admin.POST("/users/:id/api-keys", c.CreateUserAPIKey)
+			admin.GET("/users/:id/api-keys", c.ListUserAPIKeys)
+			admin.DELETE("/users/:id/api-keys/:keyID", c.RevokeUserAPIKey)
+		}
 	}
 
 	app.GET("/docs/*any", func(c *gin.Context) {
@@ -36,3 +150,53 @@ func RegistrationRoutes(app *gin.Engine, tokenManager utils.TokenManager, c Cont
 
 	app.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 }
+
+func RegistrationOAuthRoutes(app *gin.Engine, cfg *config.Config, tokenManager utils.TokenManager, limiter ratelimit.Limiter, logs logger.Logger, c OAuthController) {
+	oauth := app.Group("/oauth")
+	{
+		oauth.GET("/authorize", c.Authorize)
+		oauth.POST("/authorize", c.SubmitAuthorize)
+		oauth.POST("/token",
+			middleware.RateLimit(limiter, "token", routeLimits(cfg.RateLimit.Refresh), middleware.UserIDFromRefreshToken(tokenManager), logs),
+			c.Token,
+		)
+		oauth.POST("/device/code",
+			middleware.RateLimit(limiter, "device_code", routeLimits(cfg.RateLimit.Refresh), nil, logs),
+			c.DeviceCode,
+		)
+		oauth.GET("/device", c.Device)
+		oauth.POST("/device",
+			middleware.RateLimit(limiter, "device", routeLimits(cfg.RateLimit.Login), nil, logs),
+			c.SubmitDevice,
+		)
+	}
+}
+
+func RegistrationHealthRoutes(app *gin.Engine, cfg *config.Config, c HealthController) {
+	app.GET("/health", c.Health)
+
+	if cfg.Admin.Enabled() {
+		app.GET("/health/details", middleware.AdminToken(cfg.Admin.Token), c.HealthDetails)
+	}
+}
+
+// RegistrationDevMailRoutes exposes the development mail catcher. It must only
+// be called in development mode.
+func RegistrationDevMailRoutes(app *gin.Engine, c DevMailController) {
+	mail := app.Group("/dev/mail")
+	{
+		mail.GET("", c.ListMail)
+		mail.DELETE("", c.ClearMail)
+		mail.GET("/:id", c.GetMail)
+		mail.GET("/:id/raw", c.GetRawMail)
+		mail.GET("/:id/html", c.GetMailHTML)
+	}
+}
+
+func routeLimits(cfg config.RouteRateLimitConfig) middleware.RouteLimits {
+	return middleware.RouteLimits{
+		PerIP:   ratelimit.Rule{Limit: cfg.PerIP.Limit, Period: cfg.PerIP.Period},
+		PerUser: ratelimit.Rule{Limit: cfg.PerUser.Limit, Period: cfg.PerUser.Period},
+		Global:  ratelimit.Rule{Limit: cfg.Global.Limit, Period: cfg.Global.Period},
+	}
+}
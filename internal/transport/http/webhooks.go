@@ -0,0 +1,142 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"medods-test-task/internal/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type RegisterWebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+// RegisterWebhook godoc
+// @Summary      RegisterWebhook
+// @Description  Registers an endpoint that receives signed security events. The signing secret is returned only once.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param endpoint body RegisterWebhookRequest true "Endpoint url and events, all events when empty"
+// @Success      201 {object} WebhookEndpointResponse "Registered endpoint with its secret"
+// @Failure      400 {object} ErrorResponse "Invalid url or unknown event"
+// @Failure      401 {object} ErrorResponse "Unauthorized"
+// @Failure      500 {object} ErrorResponse "An unexpected error occurred"
+// @Router /admin/webhooks [post]
+func (c *AppController) RegisterWebhook(ctx *gin.Context) {
+	var req RegisterWebhookRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body."})
+
+		return
+	}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), RequestTimeout)
+	defer cancel()
+
+	endpoint, err := c.webhooks.RegisterEndpoint(ctxWithTimeout, req.URL, req.Events)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidWebhookURL) || errors.Is(err, models.ErrUnknownWebhookEvent) {
+			ctx.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+
+			return
+		}
+
+		c.logger.Error(ctx, "Failed to register webhook", zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError, ErrorResponse{Error: "An unexpected error occurred."})
+
+		return
+	}
+
+	resp := newWebhookEndpointResponse(endpoint)
+	resp.Secret = endpoint.Secret
+
+	ctx.JSON(http.StatusCreated, resp)
+}
+
+// ListWebhooks godoc
+// @Summary      ListWebhooks
+// @Description  Lists registered webhook endpoints
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {array} WebhookEndpointResponse "Registered endpoints"
+// @Failure      401 {object} ErrorResponse "Unauthorized"
+// @Failure      500 {object} ErrorResponse "An unexpected error occurred"
+// @Router /admin/webhooks [get]
+func (c *AppController) ListWebhooks(ctx *gin.Context) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), RequestTimeout)
+	defer cancel()
+
+	endpoints, err := c.webhooks.ListEndpoints(ctxWithTimeout)
+	if err != nil {
+		c.logger.Error(ctx, "Failed to list webhooks", zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError, ErrorResponse{Error: "An unexpected error occurred."})
+
+		return
+	}
+
+	resp := make([]WebhookEndpointResponse, 0, len(endpoints))
+	for i := range endpoints {
+		resp = append(resp, newWebhookEndpointResponse(&endpoints[i]))
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// DeleteWebhook godoc
+// @Summary      DeleteWebhook
+// @Description  Deletes a webhook endpoint together with its pending deliveries
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param id path string true "Endpoint id"
+// @Success      204 "Deleted"
+// @Failure      400 {object} ErrorResponse "Endpoint id must be a valid UUID"
+// @Failure      401 {object} ErrorResponse "Unauthorized"
+// @Failure      404 {object} ErrorResponse "Endpoint was not found"
+// @Failure      500 {object} ErrorResponse "An unexpected error occurred"
+// @Router /admin/webhooks/{id} [delete]
+func (c *AppController) DeleteWebhook(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{Error: "Endpoint id must be a valid UUID."})
+
+		return
+	}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx.Request.Context(), RequestTimeout)
+	defer cancel()
+
+	err = c.webhooks.DeleteEndpoint(ctxWithTimeout, id)
+	if err != nil {
+		if errors.Is(err, models.ErrWebhookEndpointNotFound) {
+			ctx.JSON(http.StatusNotFound, ErrorResponse{Error: "Endpoint was not found."})
+
+			return
+		}
+
+		c.logger.Error(ctx, "Failed to delete webhook", zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError, ErrorResponse{Error: "An unexpected error occurred."})
+
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func newWebhookEndpointResponse(endpoint *models.WebhookEndpoint) WebhookEndpointResponse {
+	return WebhookEndpointResponse{
+		ID:        endpoint.ID.String(),
+		URL:       endpoint.URL,
+		Events:    endpoint.Events,
+		CreatedAt: endpoint.CreatedAt,
+	}
+}
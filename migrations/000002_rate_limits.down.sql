@@ -0,0 +1 @@
+DROP TABLE IF EXISTS rateLimits;
\ No newline at end of file
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS rateLimits (
+    key VARCHAR(255) PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    updatedAt TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
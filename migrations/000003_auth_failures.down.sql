@@ -0,0 +1 @@
+DROP TABLE IF EXISTS authFailures;
\ No newline at end of file
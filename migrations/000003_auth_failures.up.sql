@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS authFailures (
+    key VARCHAR(255) PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    lastFailureAt TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    lockedUntil TIMESTAMP WITH TIME ZONE
+);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS auth_events (
+    id BIGSERIAL PRIMARY KEY,
+    type VARCHAR(32) NOT NULL,
+    outcome VARCHAR(16) NOT NULL,
+    reason VARCHAR(64) NOT NULL DEFAULT '',
+    userId UUID,
+    sessionId INTEGER,
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    userAgent TEXT NOT NULL DEFAULT '',
+    requestId VARCHAR(64) NOT NULL DEFAULT '',
+    createdAt TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_auth_events_userId_createdAt ON auth_events(userId, createdAt);
+CREATE INDEX idx_auth_events_createdAt ON auth_events(createdAt);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS audit_checkpoints;
+
+ALTER TABLE auth_events
+    DROP COLUMN IF EXISTS prevHash,
+    DROP COLUMN IF EXISTS hash;
\ No newline at end of file
@@ -0,0 +1,11 @@
+ALTER TABLE auth_events
+    ADD COLUMN IF NOT EXISTS prevHash VARCHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS hash VARCHAR(64) NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS audit_checkpoints (
+    day DATE PRIMARY KEY,
+    lastEventId BIGINT NOT NULL,
+    hash VARCHAR(64) NOT NULL,
+    signature VARCHAR(64) NOT NULL,
+    createdAt TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
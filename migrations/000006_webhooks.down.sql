@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS webhookDeadLetters;
+DROP TABLE IF EXISTS webhookDeliveries;
+DROP TABLE IF EXISTS webhookEndpoints;
\ No newline at end of file
@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS webhookEndpoints (
+    id UUID PRIMARY KEY,
+    url TEXT NOT NULL,
+    secret VARCHAR(100) NOT NULL,
+    events TEXT[] NOT NULL,
+    createdAt TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS webhookDeliveries (
+    id BIGSERIAL PRIMARY KEY,
+    endpointId UUID NOT NULL REFERENCES webhookEndpoints(id) ON DELETE CASCADE,
+    eventType VARCHAR(64) NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    lastError TEXT NOT NULL DEFAULT '',
+    nextAttemptAt TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    deliveredAt TIMESTAMP WITH TIME ZONE,
+    createdAt TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_webhook_deliveries_pending ON webhookDeliveries(nextAttemptAt) WHERE deliveredAt IS NULL;
+
+CREATE TABLE IF NOT EXISTS webhookDeadLetters (
+    id BIGSERIAL PRIMARY KEY,
+    deliveryId BIGINT NOT NULL,
+    endpointId UUID NOT NULL,
+    eventType VARCHAR(64) NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INTEGER NOT NULL,
+    lastError TEXT NOT NULL,
+    createdAt TIMESTAMP WITH TIME ZONE NOT NULL,
+    failedAt TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS emailOutbox;
\ No newline at end of file
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS emailOutbox (
+    id BIGSERIAL PRIMARY KEY,
+    recipient VARCHAR(255) NOT NULL,
+    subject TEXT NOT NULL,
+    body TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    lastError TEXT NOT NULL DEFAULT '',
+    nextAttemptAt TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    sentAt TIMESTAMP WITH TIME ZONE,
+    failedAt TIMESTAMP WITH TIME ZONE,
+    createdAt TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_email_outbox_pending ON emailOutbox(nextAttemptAt) WHERE sentAt IS NULL AND failedAt IS NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE emailOutbox DROP COLUMN IF EXISTS textBody;
+
+ALTER TABLE users DROP COLUMN IF EXISTS locale;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locale VARCHAR(16);
+
+ALTER TABLE emailOutbox ADD COLUMN IF NOT EXISTS textBody TEXT NOT NULL DEFAULT '';
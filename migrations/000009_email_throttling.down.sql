@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS emailSuppressions;
+
+DROP INDEX IF EXISTS idx_email_outbox_recipient;
+DROP INDEX IF EXISTS idx_email_outbox_user_kind;
+
+ALTER TABLE emailOutbox DROP COLUMN IF EXISTS kind;
+ALTER TABLE emailOutbox DROP COLUMN IF EXISTS userID;
\ No newline at end of file
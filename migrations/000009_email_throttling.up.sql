@@ -0,0 +1,18 @@
+ALTER TABLE emailOutbox ADD COLUMN IF NOT EXISTS userID UUID;
+ALTER TABLE emailOutbox ADD COLUMN IF NOT EXISTS kind VARCHAR(64) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_email_outbox_user_kind ON emailOutbox(userID, kind, createdAt);
+CREATE INDEX IF NOT EXISTS idx_email_outbox_recipient ON emailOutbox(recipient, createdAt);
+
+CREATE TABLE IF NOT EXISTS emailSuppressions (
+    id BIGSERIAL PRIMARY KEY,
+    userID UUID,
+    recipient VARCHAR(255) NOT NULL,
+    locale VARCHAR(16) NOT NULL DEFAULT '',
+    kind VARCHAR(64) NOT NULL,
+    reason VARCHAR(32) NOT NULL,
+    createdAt TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    digestedAt TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_suppressions_pending ON emailSuppressions(createdAt) WHERE digestedAt IS NULL;
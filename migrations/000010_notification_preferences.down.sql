@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS smsOutbox;
+
+DROP INDEX IF EXISTS idx_webhook_endpoints_user;
+DELETE FROM webhookEndpoints WHERE userID IS NOT NULL;
+ALTER TABLE webhookEndpoints DROP COLUMN IF EXISTS userID;
+
+DROP TABLE IF EXISTS userDevices;
+DROP TABLE IF EXISTS notificationPreferences;
+
+ALTER TABLE users DROP COLUMN IF EXISTS phone;
\ No newline at end of file
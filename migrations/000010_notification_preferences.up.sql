@@ -0,0 +1,37 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS phone VARCHAR(32);
+
+CREATE TABLE IF NOT EXISTS notificationPreferences (
+    userID UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    event VARCHAR(64) NOT NULL,
+    channels TEXT[] NOT NULL,
+    updatedAt TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    PRIMARY KEY (userID, event)
+);
+
+CREATE TABLE IF NOT EXISTS userDevices (
+    userID UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    fingerprint VARCHAR(64) NOT NULL,
+    firstSeenAt TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    lastSeenAt TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    PRIMARY KEY (userID, fingerprint)
+);
+
+ALTER TABLE webhookEndpoints ADD COLUMN IF NOT EXISTS userID UUID;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_endpoints_user ON webhookEndpoints(userID) WHERE userID IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS smsOutbox (
+    id BIGSERIAL PRIMARY KEY,
+    userID UUID,
+    kind VARCHAR(64) NOT NULL DEFAULT '',
+    recipient VARCHAR(32) NOT NULL,
+    body TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    lastError TEXT NOT NULL DEFAULT '',
+    nextAttemptAt TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    sentAt TIMESTAMP WITH TIME ZONE,
+    failedAt TIMESTAMP WITH TIME ZONE,
+    createdAt TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_sms_outbox_pending ON smsOutbox(nextAttemptAt) WHERE sentAt IS NULL AND failedAt IS NULL;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS emailChanges;
+
+DROP INDEX IF EXISTS idx_users_email;
\ No newline at end of file
@@ -0,0 +1,11 @@
+ALTER TABLE users ALTER COLUMN email TYPE VARCHAR(255);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users(lower(email)) WHERE email IS NOT NULL AND email <> '';
+
+CREATE TABLE IF NOT EXISTS emailChanges (
+    userID UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    newEmail VARCHAR(255) NOT NULL,
+    tokenHash VARCHAR(64) NOT NULL UNIQUE,
+    expiresAt TIMESTAMP WITH TIME ZONE NOT NULL,
+    createdAt TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
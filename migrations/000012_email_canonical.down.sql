@@ -0,0 +1,6 @@
+ALTER TABLE emailChanges DROP COLUMN IF EXISTS newEmailCanonical;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users(lower(email)) WHERE email IS NOT NULL AND email <> '';
+
+DROP INDEX IF EXISTS idx_users_email_canonical;
+ALTER TABLE users DROP COLUMN IF EXISTS emailCanonical;
\ No newline at end of file
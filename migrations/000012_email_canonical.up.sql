@@ -0,0 +1,13 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS emailCanonical VARCHAR(255);
+
+UPDATE users SET emailCanonical = lower(email) WHERE email IS NOT NULL AND email <> '';
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_canonical ON users(emailCanonical);
+
+DROP INDEX IF EXISTS idx_users_email;
+
+ALTER TABLE emailChanges ADD COLUMN IF NOT EXISTS newEmailCanonical VARCHAR(255);
+
+UPDATE emailChanges SET newEmailCanonical = lower(newEmail) WHERE newEmailCanonical IS NULL;
+
+ALTER TABLE emailChanges ALTER COLUMN newEmailCanonical SET NOT NULL;
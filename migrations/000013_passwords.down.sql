@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN IF EXISTS passwordChangedAt;
+ALTER TABLE users DROP COLUMN IF EXISTS passwordHash;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS passwordHash VARCHAR(255);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS passwordChangedAt TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS authorizationCodes;
\ No newline at end of file
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS authorizationCodes (
+    codeHash VARCHAR(64) PRIMARY KEY,
+    clientID VARCHAR(128) NOT NULL,
+    userID UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    redirectURI TEXT NOT NULL,
+    scope TEXT NOT NULL DEFAULT '',
+    codeChallenge VARCHAR(128) NOT NULL,
+    expiresAt TIMESTAMP WITH TIME ZONE NOT NULL,
+    createdAt TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_authorization_codes_expires_at ON authorizationCodes(expiresAt);
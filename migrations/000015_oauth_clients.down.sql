@@ -0,0 +1,5 @@
+ALTER TABLE refreshSessions DROP COLUMN IF EXISTS clientID;
+
+ALTER TABLE authorizationCodes DROP CONSTRAINT IF EXISTS fk_authorization_codes_client;
+
+DROP TABLE IF EXISTS clients;
\ No newline at end of file
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS clients (
+    id VARCHAR(64) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    secretHash VARCHAR(64),
+    redirectURIs TEXT[] NOT NULL DEFAULT '{}',
+    grantTypes TEXT[] NOT NULL DEFAULT '{}',
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    accessTokenTTL BIGINT NOT NULL DEFAULT 0,
+    refreshTokenTTL BIGINT NOT NULL DEFAULT 0,
+    createdAt TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updatedAt TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- The codes were issued to clients from the OAUTH_CLIENTS setting, which are
+-- registered only at the next startup, so they would break the foreign key.
+-- Codes live a minute, a user caught in between just signs in again.
+DELETE FROM authorizationCodes;
+
+ALTER TABLE authorizationCodes
+    ADD CONSTRAINT fk_authorization_codes_client FOREIGN KEY (clientID) REFERENCES clients(id) ON DELETE CASCADE;
+
+ALTER TABLE refreshSessions
+    ADD COLUMN IF NOT EXISTS clientID VARCHAR(64) REFERENCES clients(id) ON DELETE CASCADE;
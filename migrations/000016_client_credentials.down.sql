@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS clientAssertions;
+
+ALTER TABLE clients DROP COLUMN IF EXISTS publicKey;
\ No newline at end of file
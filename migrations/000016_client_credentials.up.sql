@@ -0,0 +1,10 @@
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS publicKey TEXT;
+
+CREATE TABLE IF NOT EXISTS clientAssertions (
+    clientID VARCHAR(64) NOT NULL REFERENCES clients(id) ON DELETE CASCADE,
+    jti VARCHAR(255) NOT NULL,
+    expiresAt TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (clientID, jti)
+);
+
+CREATE INDEX IF NOT EXISTS idx_client_assertions_expires_at ON clientAssertions(expiresAt);
@@ -0,0 +1,3 @@
+ALTER TABLE refreshSessions DROP COLUMN IF EXISTS scopes;
+
+ALTER TABLE users DROP COLUMN IF EXISTS roles;
\ No newline at end of file
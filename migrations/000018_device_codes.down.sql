@@ -0,0 +1 @@
+DROP TABLE IF EXISTS deviceCodes;
\ No newline at end of file
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS deviceCodes (
+    deviceCodeHash VARCHAR(64) PRIMARY KEY,
+    userCode VARCHAR(16) NOT NULL UNIQUE,
+    clientID VARCHAR(64) NOT NULL REFERENCES clients(id) ON DELETE CASCADE,
+    scope TEXT NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    userID UUID REFERENCES users(id) ON DELETE CASCADE,
+    pollInterval INTEGER NOT NULL,
+    lastPolledAt TIMESTAMP WITH TIME ZONE,
+    expiresAt TIMESTAMP WITH TIME ZONE NOT NULL,
+    createdAt TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_device_codes_expires_at ON deviceCodes(expiresAt);
@@ -0,0 +1 @@
+ALTER TABLE auth_events DROP COLUMN IF EXISTS actorId;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE auth_events ADD COLUMN IF NOT EXISTS actorId UUID;
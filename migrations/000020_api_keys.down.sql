@@ -0,0 +1 @@
+DROP TABLE IF EXISTS apiKeys;
\ No newline at end of file
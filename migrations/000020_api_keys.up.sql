@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS apiKeys (
+    id UUID PRIMARY KEY,
+    userId UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    keyPrefix VARCHAR(16) NOT NULL,
+    keyHash VARCHAR(64) NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    expiresAt TIMESTAMP WITH TIME ZONE,
+    lastUsedAt TIMESTAMP WITH TIME ZONE,
+    lastUsedIP VARCHAR(45),
+    revokedAt TIMESTAMP WITH TIME ZONE,
+    createdAt TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_userId ON apiKeys(userId);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS usedRevokeTokens;
\ No newline at end of file
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS usedRevokeTokens (
+    tokenHash VARCHAR(64) PRIMARY KEY,
+    userId UUID REFERENCES users(id) ON DELETE CASCADE,
+    usedAt TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
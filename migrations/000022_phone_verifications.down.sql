@@ -0,0 +1 @@
+DROP TABLE IF EXISTS phoneVerifications;
\ No newline at end of file
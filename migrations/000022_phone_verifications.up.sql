@@ -0,0 +1,13 @@
+-- Phones set before are kept, new ones are used only after the code is confirmed.
+CREATE TABLE IF NOT EXISTS phoneVerifications (
+    id BIGSERIAL PRIMARY KEY,
+    userID UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    phone VARCHAR(32) NOT NULL,
+    codeHash VARCHAR(64) NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expiresAt TIMESTAMP WITH TIME ZONE NOT NULL,
+    usedAt TIMESTAMP WITH TIME ZONE,
+    createdAt TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_phone_verifications_user ON phoneVerifications(userID, createdAt);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_rate_limits_full_at;
+
+ALTER TABLE rateLimits DROP COLUMN IF EXISTS fullAt;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE rateLimits ADD COLUMN IF NOT EXISTS fullAt TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS idx_rate_limits_full_at ON rateLimits(fullAt);
@@ -0,0 +1,3 @@
+-- Keys created before roles were stored keep acting with every role of
+-- their owner.
+ALTER TABLE apiKeys ADD COLUMN IF NOT EXISTS roles TEXT[];
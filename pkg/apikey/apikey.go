@@ -0,0 +1,82 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"fmt"
+	"hash/crc32"
+	"strings"
+)
+
+const (
+	// Prefix starts every key, so that leaked keys are easy to recognise by
+	// secret scanners and are not mistaken for access tokens.
+	Prefix = "mdk_"
+
+	secretBytes    = 20
+	secretLength   = 32
+	checksumLength = 8
+	keyLength      = len(Prefix) + secretLength + checksumLength
+
+	// displayLength is the part of a key that is safe to show in listings.
+	displayLength = len(Prefix) + 8
+
+	secretAlphabet   = "abcdefghijklmnopqrstuvwxyz234567"
+	checksumAlphabet = "0123456789abcdef"
+)
+
+var encoding = base32.NewEncoding(secretAlphabet).WithPadding(base32.NoPadding)
+
+// New returns a key made of Prefix, a random secret and a CRC32 checksum of
+// both. The checksum lets Valid reject mistyped or made up keys without a
+// database lookup.
+func New() (string, error) {
+	secret := make([]byte, secretBytes)
+
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to create api key: %w", err)
+	}
+
+	key := Prefix + encoding.EncodeToString(secret)
+
+	return key + checksum(key), nil
+}
+
+// Valid reports whether the key has the format of New and a matching
+// checksum. It does not tell whether the key was ever issued.
+func Valid(key string) bool {
+	if len(key) != keyLength || !strings.HasPrefix(key, Prefix) {
+		return false
+	}
+
+	body := key[:keyLength-checksumLength]
+	if !only(body[len(Prefix):], secretAlphabet) || !only(key[len(body):], checksumAlphabet) {
+		return false
+	}
+
+	return checksum(body) == key[len(body):]
+}
+
+// Display returns the start of the key, which identifies it to its owner
+// without revealing it.
+func Display(key string) string {
+	if len(key) < displayLength {
+		return key
+	}
+
+	return key[:displayLength]
+}
+
+func checksum(body string) string {
+	return fmt.Sprintf("%08x", crc32.ChecksumIEEE([]byte(body)))
+}
+
+func only(s, alphabet string) bool {
+	for _, r := range s {
+		if !strings.ContainsRune(alphabet, r) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,78 @@
+package apikey
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	first, err := New()
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	second, err := New()
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if first == second {
+		t.Errorf("keys should be random")
+	}
+
+	if !strings.HasPrefix(first, Prefix) || len(first) != keyLength {
+		t.Errorf("key = %q, expected %d characters starting with %q", first, keyLength, Prefix)
+	}
+
+	if !Valid(first) {
+		t.Errorf("new key %q should be valid", first)
+	}
+}
+
+func TestValid(t *testing.T) {
+	key, err := New()
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	// Change one character of the secret, keeping it in the alphabet.
+	typo := []byte(key)
+	if typo[len(Prefix)] == 'a' {
+		typo[len(Prefix)] = 'b'
+	} else {
+		typo[len(Prefix)] = 'a'
+	}
+
+	tests := []struct {
+		name  string
+		key   string
+		valid bool
+	}{
+		{name: "OK", key: key, valid: true},
+		{name: "Typo", key: string(typo), valid: false},
+		{name: "Uppercase", key: strings.ToUpper(key), valid: false},
+		{name: "Other prefix", key: "mdx_" + key[len(Prefix):], valid: false},
+		{name: "Truncated", key: key[:len(key)-1], valid: false},
+		{name: "Access token", key: "eyJhbGciOiJIUzUxMiIsInR5cCI6IkpXVCJ9", valid: false},
+		{name: "Empty", key: "", valid: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Valid(tt.key); got != tt.valid {
+				t.Errorf("Valid(%q) = %v, expected %v", tt.key, got, tt.valid)
+			}
+		})
+	}
+}
+
+func TestDisplay(t *testing.T) {
+	key, err := New()
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	display := Display(key)
+	if !strings.HasPrefix(key, display) || len(display) != displayLength {
+		t.Errorf("Display(%q) = %q, expected the first %d characters", key, display, displayLength)
+	}
+}
@@ -0,0 +1,131 @@
+// Package circuitbreaker stops calls to a dependency that keeps failing and
+// probes it again after a cool-down.
+package circuitbreaker
+
+import (
+	"sync"
+	"time"
+)
+
+type State string
+
+const (
+	StateClosed   State = "closed"
+	StateOpen     State = "open"
+	StateHalfOpen State = "half_open"
+)
+
+type Snapshot struct {
+	State         State
+	Failures      int
+	LastError     string
+	LastFailureAt time.Time
+	OpenedAt      time.Time
+}
+
+// Breaker opens after threshold consecutive failures. Once the open timeout
+// passes a single probe is let through: success closes the breaker, failure
+// opens it again.
+type Breaker struct {
+	mu        sync.Mutex
+	threshold int
+	timeout   time.Duration
+	now       func() time.Time
+
+	state         State
+	failures      int
+	probing       bool
+	lastError     string
+	lastFailureAt time.Time
+	openedAt      time.Time
+}
+
+func New(threshold int, openTimeout time.Duration) *Breaker {
+	return &Breaker{
+		threshold: max(threshold, 1),
+		timeout:   openTimeout,
+		now:       time.Now,
+		state:     StateClosed,
+	}
+}
+
+// Allow reports whether a call may be made now.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateClosed:
+		return true
+	case StateOpen:
+		if b.now().Sub(b.openedAt) < b.timeout {
+			return false
+		}
+
+		b.state = StateHalfOpen
+		b.probing = true
+
+		return true
+	default:
+		if b.probing {
+			return false
+		}
+
+		b.probing = true
+
+		return true
+	}
+}
+
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = StateClosed
+	b.failures = 0
+	b.probing = false
+}
+
+func (b *Breaker) Failure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	b.lastFailureAt = b.now()
+	if err != nil {
+		b.lastError = err.Error()
+	}
+
+	if b.state == StateHalfOpen || b.failures >= b.threshold {
+		b.state = StateOpen
+		b.openedAt = b.now()
+	}
+}
+
+// Cancel gives back a call allowed by Allow without recording its outcome,
+// for errors that say nothing about the health of the dependency.
+func (b *Breaker) Cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+func (b *Breaker) Snapshot() Snapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := b.state
+	if state == StateOpen && b.now().Sub(b.openedAt) >= b.timeout {
+		state = StateHalfOpen
+	}
+
+	return Snapshot{
+		State:         state,
+		Failures:      b.failures,
+		LastError:     b.lastError,
+		LastFailureAt: b.lastFailureAt,
+		OpenedAt:      b.openedAt,
+	}
+}
@@ -0,0 +1,52 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	errRelay := errors.New("relay is down")
+
+	b := New(2, time.Minute)
+	b.now = func() time.Time { return now }
+
+	steps := []struct {
+		name          string
+		action        func()
+		advance       time.Duration
+		expectedAllow bool
+		expectedState State
+	}{
+		{name: "Closed", expectedAllow: true, expectedState: StateClosed},
+		{name: "Below threshold", action: func() { b.Failure(errRelay) }, expectedAllow: true, expectedState: StateClosed},
+		{name: "Opened", action: func() { b.Failure(errRelay) }, expectedAllow: false, expectedState: StateOpen},
+		{name: "Still open", advance: 30 * time.Second, expectedAllow: false, expectedState: StateOpen},
+		{name: "Probe allowed", advance: 31 * time.Second, expectedAllow: true, expectedState: StateHalfOpen},
+		{name: "Single probe", expectedAllow: false, expectedState: StateHalfOpen},
+		{name: "Probe failed", action: func() { b.Failure(errRelay) }, expectedAllow: false, expectedState: StateOpen},
+		{name: "Second probe", advance: time.Minute, expectedAllow: true, expectedState: StateHalfOpen},
+		{name: "Probe succeeded", action: b.Success, expectedAllow: true, expectedState: StateClosed},
+	}
+	for _, step := range steps {
+		now = now.Add(step.advance)
+
+		if step.action != nil {
+			step.action()
+		}
+
+		if allow := b.Allow(); allow != step.expectedAllow {
+			t.Fatalf("%s: allow = %v, expected %v", step.name, allow, step.expectedAllow)
+		}
+
+		if state := b.Snapshot().State; state != step.expectedState {
+			t.Fatalf("%s: state = %s, expected %s", step.name, state, step.expectedState)
+		}
+	}
+
+	if snap := b.Snapshot(); snap.LastError != errRelay.Error() {
+		t.Errorf("last error = %q, expected %q", snap.LastError, errRelay.Error())
+	}
+}
@@ -0,0 +1,108 @@
+package email
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// disposableDomains is the bundled list of throwaway inbox providers. Update
+// it from https://github.com/disposable-email-domains/disposable-email-domains
+// or extend it at run time with LoadFile.
+//
+//go:embed disposable_domains.txt
+var disposableDomains string
+
+// Blocklist holds domains that are not accepted for sign up. A domain also
+// blocks all of its subdomains.
+type Blocklist struct {
+	domains map[string]struct{}
+}
+
+func NewBlocklist(domains ...string) *Blocklist {
+	b := &Blocklist{domains: make(map[string]struct{}, len(domains))}
+
+	for _, domain := range domains {
+		b.add(domain)
+	}
+
+	return b
+}
+
+// DefaultBlocklist returns the bundled list of disposable domains.
+func DefaultBlocklist() *Blocklist {
+	b := NewBlocklist()
+
+	// The embedded list is plain text, reading it cannot fail.
+	_ = b.Load(strings.NewReader(disposableDomains))
+
+	return b
+}
+
+// Load adds one domain per line from r. Empty lines and lines starting with
+// "#" are skipped.
+func (b *Blocklist) Load(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		b.add(line)
+	}
+
+	return scanner.Err()
+}
+
+func (b *Blocklist) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open blocklist: %w", err)
+	}
+	defer f.Close()
+
+	if err := b.Load(f); err != nil {
+		return fmt.Errorf("failed to read blocklist %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Contains reports whether the domain or one of its parents is blocked.
+func (b *Blocklist) Contains(domain string) bool {
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+
+	for {
+		if _, ok := b.domains[domain]; ok {
+			return true
+		}
+
+		dot := strings.IndexByte(domain, '.')
+		if dot < 0 {
+			return false
+		}
+
+		domain = domain[dot+1:]
+	}
+}
+
+func (b *Blocklist) Len() int {
+	return len(b.domains)
+}
+
+func (b *Blocklist) add(domain string) {
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+
+	if ascii, err := idna.Lookup.ToASCII(domain); err == nil {
+		domain = ascii
+	}
+
+	b.domains[domain] = struct{}{}
+}
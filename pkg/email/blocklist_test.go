@@ -0,0 +1,47 @@
+package email
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBlocklist_Contains(t *testing.T) {
+	b := NewBlocklist("Mailinator.com", "пример.рф")
+
+	if err := b.Load(strings.NewReader("# extra\n\ntrash.example\n")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		domain   string
+		expected bool
+	}{
+		{domain: "mailinator.com", expected: true},
+		{domain: "inbox.mailinator.com", expected: true},
+		{domain: "MAILINATOR.COM.", expected: true},
+		{domain: "notmailinator.com", expected: false},
+		{domain: "xn--e1afmkfd.xn--p1ai", expected: true},
+		{domain: "trash.example", expected: true},
+		{domain: "example", expected: false},
+		{domain: "gmail.com", expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			if got := b.Contains(tt.domain); got != tt.expected {
+				t.Errorf("Contains(%q) = %v, expected %v", tt.domain, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDefaultBlocklist(t *testing.T) {
+	b := DefaultBlocklist()
+
+	if b.Len() == 0 {
+		t.Fatal("bundled blocklist is empty")
+	}
+
+	if !b.Contains("yopmail.com") || b.Contains("gmail.com") {
+		t.Error("bundled blocklist does not match the expected domains")
+	}
+}
@@ -0,0 +1,108 @@
+package catcher
+
+import (
+	"bytes"
+	"medods-test-task/pkg/email/smtp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Message struct {
+	ID        string
+	From      string
+	To        string
+	Subject   string
+	Body      string
+	Text      string
+	Raw       []byte
+	CreatedAt time.Time
+}
+
+// Catcher keeps outgoing emails in memory for development and end-to-end
+// tests. Only the newest capacity messages are kept.
+type Catcher struct {
+	mu       sync.RWMutex
+	messages []Message
+	capacity int
+	from     string
+	domain   string
+}
+
+func New(from, domain string, capacity int) *Catcher {
+	return &Catcher{
+		capacity: capacity,
+		from:     from,
+		domain:   domain,
+	}
+}
+
+func (c *Catcher) Send(input smtp.SendEmailInput) error {
+	if err := input.Validate(); err != nil {
+		return err
+	}
+
+	var raw bytes.Buffer
+	if _, err := smtp.NewMessage(c.from, c.domain, input).WriteTo(&raw); err != nil {
+		return err
+	}
+
+	msg := Message{
+		ID:        uuid.NewString(),
+		From:      c.from,
+		To:        input.To,
+		Subject:   input.Subject,
+		Body:      input.Body,
+		Text:      input.Text,
+		Raw:       raw.Bytes(),
+		CreatedAt: time.Now(),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.messages = append(c.messages, msg)
+	if len(c.messages) > c.capacity {
+		c.messages = slices.Clone(c.messages[len(c.messages)-c.capacity:])
+	}
+
+	return nil
+}
+
+// List returns the caught messages, newest first. An empty to matches every
+// recipient.
+func (c *Catcher) List(to string) []Message {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	messages := make([]Message, 0, len(c.messages))
+	for i := len(c.messages) - 1; i >= 0; i-- {
+		if to == "" || c.messages[i].To == to {
+			messages = append(messages, c.messages[i])
+		}
+	}
+
+	return messages
+}
+
+func (c *Catcher) Get(id string) (Message, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, msg := range c.messages {
+		if msg.ID == id {
+			return msg, true
+		}
+	}
+
+	return Message{}, false
+}
+
+func (c *Catcher) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.messages = nil
+}
@@ -0,0 +1,70 @@
+package catcher
+
+import (
+	"medods-test-task/pkg/email/smtp"
+	"strings"
+	"testing"
+)
+
+func TestCatcher_Send(t *testing.T) {
+	c := New("no-reply@example.com", "example.com", 2)
+
+	inputs := []smtp.SendEmailInput{
+		{To: "first@example.com", Subject: "First", Body: "<p>1</p>"},
+		{To: "second@example.com", Subject: "Second", Body: "<p>2</p>"},
+		{To: "second@example.com", Subject: "Third", Body: "<p>3</p>"},
+	}
+
+	for _, input := range inputs {
+		if err := c.Send(input); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		to       string
+		subjects []string
+	}{
+		{
+			name:     "All recipients, oldest evicted",
+			to:       "",
+			subjects: []string{"Third", "Second"},
+		},
+		{
+			name:     "Filtered by recipient",
+			to:       "second@example.com",
+			subjects: []string{"Third", "Second"},
+		},
+		{
+			name:     "Evicted recipient",
+			to:       "first@example.com",
+			subjects: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages := c.List(tt.to)
+			if len(messages) != len(tt.subjects) {
+				t.Fatalf("messages = %d, expected %d", len(messages), len(tt.subjects))
+			}
+
+			for i, msg := range messages {
+				if msg.Subject != tt.subjects[i] {
+					t.Errorf("subject = %q, expected %q", msg.Subject, tt.subjects[i])
+				}
+
+				got, ok := c.Get(msg.ID)
+				if !ok || !strings.Contains(string(got.Raw), "Subject: "+msg.Subject) {
+					t.Errorf("raw message for %s is missing", msg.ID)
+				}
+			}
+		})
+	}
+
+	c.Clear()
+
+	if messages := c.List(""); len(messages) != 0 {
+		t.Errorf("messages after clear = %d, expected 0", len(messages))
+	}
+}
@@ -0,0 +1,217 @@
+// Package dkim signs outgoing messages with rsa-sha256 using relaxed header
+// and body canonicalization as described in RFC 6376.
+package dkim
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidKey     = errors.New("dkim private key should be an rsa key in pem format")
+	ErrInvalidMessage = errors.New("message has no header section")
+)
+
+// DefaultHeaders are signed when they are present in the message.
+var DefaultHeaders = []string{"From", "To", "Subject", "Date", "Message-ID", "MIME-Version", "Content-Type"}
+
+type Signer struct {
+	domain   string
+	selector string
+	key      *rsa.PrivateKey
+	headers  []string
+	now      func() time.Time
+}
+
+func NewSigner(domain, selector string, key *rsa.PrivateKey) *Signer {
+	return &Signer{
+		domain:   domain,
+		selector: selector,
+		key:      key,
+		headers:  DefaultHeaders,
+		now:      time.Now,
+	}
+}
+
+// LoadPrivateKey reads a PKCS #1 or PKCS #8 rsa key from a pem file.
+func LoadPrivateKey(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dkim private key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidKey
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+
+	return rsaKey, nil
+}
+
+// Sign returns the message with a DKIM-Signature header prepended. The
+// message must use CRLF line endings.
+func (s *Signer) Sign(message []byte) ([]byte, error) {
+	header, body, ok := bytes.Cut(message, []byte("\r\n\r\n"))
+	if !ok {
+		return nil, ErrInvalidMessage
+	}
+
+	bodyHash := sha256.Sum256(RelaxedBody(body))
+	fields := parseHeader(header)
+
+	var (
+		signed []string
+		hashed bytes.Buffer
+	)
+
+	for _, name := range s.headers {
+		field, ok := lastField(fields, name)
+		if !ok {
+			continue
+		}
+
+		signed = append(signed, strings.ToLower(name))
+		hashed.WriteString(RelaxedHeader(field))
+		hashed.WriteString("\r\n")
+	}
+
+	value := fmt.Sprintf("v=1; a=rsa-sha256; c=relaxed/relaxed; d=%s; s=%s; t=%s; h=%s; bh=%s; b=",
+		s.domain,
+		s.selector,
+		strconv.FormatInt(s.now().Unix(), 10),
+		strings.Join(signed, ":"),
+		base64.StdEncoding.EncodeToString(bodyHash[:]),
+	)
+
+	hashed.WriteString(RelaxedHeader("DKIM-Signature: " + value))
+
+	digest := sha256.Sum256(hashed.Bytes())
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign message: %w", err)
+	}
+
+	var out bytes.Buffer
+	out.Grow(len(message) + 512)
+	out.WriteString("DKIM-Signature: ")
+	out.WriteString(value)
+	out.WriteString(base64.StdEncoding.EncodeToString(signature))
+	out.WriteString("\r\n")
+	out.Write(message)
+
+	return out.Bytes(), nil
+}
+
+// RelaxedHeader canonicalizes a single, possibly folded, header field.
+func RelaxedHeader(field string) string {
+	name, value, _ := strings.Cut(field, ":")
+
+	value = strings.ReplaceAll(value, "\r\n", "")
+	value = strings.Join(strings.FieldsFunc(value, isWSP), " ")
+
+	return strings.ToLower(strings.TrimRight(name, " \t")) + ":" + value
+}
+
+// RelaxedBody canonicalizes the message body.
+func RelaxedBody(body []byte) []byte {
+	lines := strings.Split(string(body), "\r\n")
+
+	for i, line := range lines {
+		line = strings.TrimRight(line, " \t")
+		lines[i] = collapseWSP(line)
+	}
+
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	if len(lines) == 0 {
+		return nil
+	}
+
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+func collapseWSP(line string) string {
+	var b strings.Builder
+	b.Grow(len(line))
+
+	inWSP := false
+
+	for _, r := range line {
+		if isWSP(r) {
+			if !inWSP {
+				b.WriteByte(' ')
+			}
+
+			inWSP = true
+
+			continue
+		}
+
+		inWSP = false
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+func isWSP(r rune) bool {
+	return r == ' ' || r == '\t'
+}
+
+// parseHeader splits the header section into fields, keeping folded lines
+// together with their field.
+func parseHeader(header []byte) []string {
+	var fields []string
+
+	for _, line := range strings.Split(string(header), "\r\n") {
+		if len(fields) > 0 && line != "" && isWSP(rune(line[0])) {
+			fields[len(fields)-1] += "\r\n" + line
+
+			continue
+		}
+
+		fields = append(fields, line)
+	}
+
+	return fields
+}
+
+func lastField(fields []string, name string) (string, bool) {
+	for i := len(fields) - 1; i >= 0; i-- {
+		fieldName, _, ok := strings.Cut(fields[i], ":")
+		if ok && strings.EqualFold(strings.TrimSpace(fieldName), name) {
+			return fields[i], true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,112 @@
+package dkim
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRelaxed(t *testing.T) {
+	// Example from RFC 6376, section 3.4.5.
+	headers := []struct {
+		field    string
+		expected string
+	}{
+		{field: "A: X", expected: "a:X"},
+		{field: "B : Y\t\r\n\tZ  ", expected: "b:Y Z"},
+	}
+
+	for _, h := range headers {
+		if got := RelaxedHeader(h.field); got != h.expected {
+			t.Errorf("header = %q, expected %q", got, h.expected)
+		}
+	}
+
+	body := RelaxedBody([]byte(" C \r\nD \t E\r\n\r\n\r\n"))
+	if string(body) != " C\r\nD E\r\n" {
+		t.Errorf("body = %q, expected %q", body, " C\r\nD E\r\n")
+	}
+
+	if body := RelaxedBody([]byte("\r\n\r\n")); len(body) != 0 {
+		t.Errorf("empty body = %q, expected empty", body)
+	}
+}
+
+func TestSigner_Sign(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	s := NewSigner("example.com", "mail", key)
+	s.now = func() time.Time { return time.Unix(1700000000, 0) }
+
+	message := []byte("From: no-reply@example.com\r\n" +
+		"To: user@example.com\r\n" +
+		"Subject: Hello\r\n" +
+		" world\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"\r\n" +
+		"Hi  there \r\n\r\n")
+
+	signed, err := s.Sign(message)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !bytes.HasSuffix(signed, message) {
+		t.Fatalf("original message was changed")
+	}
+
+	header, _, _ := strings.Cut(string(signed), "\r\n")
+	value := strings.TrimPrefix(header, "DKIM-Signature: ")
+
+	tags := map[string]string{}
+	for _, tag := range strings.Split(value, "; ") {
+		k, v, _ := strings.Cut(tag, "=")
+		tags[k] = v
+	}
+
+	expectedTags := map[string]string{
+		"a": "rsa-sha256",
+		"c": "relaxed/relaxed",
+		"d": "example.com",
+		"s": "mail",
+		"t": "1700000000",
+		"h": "from:to:subject:content-type",
+	}
+	for k, v := range expectedTags {
+		if tags[k] != v {
+			t.Errorf("tag %s = %q, expected %q", k, tags[k], v)
+		}
+	}
+
+	bodyHash := sha256.Sum256([]byte("Hi there\r\n"))
+	if tags["bh"] != base64.StdEncoding.EncodeToString(bodyHash[:]) {
+		t.Errorf("unexpected body hash %s", tags["bh"])
+	}
+
+	// Verify the way a receiver does: signed headers followed by the
+	// signature header with an empty b= tag.
+	hashed := "from:no-reply@example.com\r\n" +
+		"to:user@example.com\r\n" +
+		"subject:Hello world\r\n" +
+		"content-type:text/plain\r\n" +
+		RelaxedHeader("DKIM-Signature: "+strings.TrimSuffix(value, tags["b"]))
+	digest := sha256.Sum256([]byte(hashed))
+
+	signature, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		t.Errorf("signature does not verify: %v", err)
+	}
+}
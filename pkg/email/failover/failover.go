@@ -0,0 +1,118 @@
+// Package failover sends mail through several relays in priority order and
+// skips relays whose circuit breaker is open.
+package failover
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"medods-test-task/internal/models"
+	"medods-test-task/pkg/circuitbreaker"
+	"medods-test-task/pkg/email/smtp"
+	"time"
+)
+
+type Transport interface {
+	Send(input smtp.SendEmailInput) error
+}
+
+type Relay struct {
+	Name      string
+	Transport Transport
+	Breaker   *circuitbreaker.Breaker
+}
+
+type RelayStatus struct {
+	Name          string
+	State         circuitbreaker.State
+	Failures      int
+	LastError     string
+	LastFailureAt time.Time
+}
+
+type Sender struct {
+	relays []Relay
+}
+
+// New takes relays ordered by priority, the primary first.
+func New(relays ...Relay) *Sender {
+	return &Sender{
+		relays: relays,
+	}
+}
+
+// Send tries every relay whose breaker allows a call until one accepts the
+// message. A full queue is local backpressure, so it moves on to the next
+// relay without counting against the busy one. A rejected recipient is
+// returned at once, as the relay works and the others would refuse it too.
+func (s *Sender) Send(input smtp.SendEmailInput) error {
+	if err := input.Validate(); err != nil {
+		return err
+	}
+
+	var errs []error
+
+	for _, relay := range s.relays {
+		if !relay.Breaker.Allow() {
+			continue
+		}
+
+		err := relay.Transport.Send(input)
+		if err == nil {
+			relay.Breaker.Success()
+
+			return nil
+		}
+
+		if errors.Is(err, models.ErrSMTPRecipient) {
+			relay.Breaker.Success()
+
+			return err
+		}
+
+		if errors.Is(err, models.ErrSMTPQueueFull) || errors.Is(err, models.ErrSMTPClosed) {
+			relay.Breaker.Cancel()
+		} else {
+			relay.Breaker.Failure(err)
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", relay.Name, err))
+	}
+
+	if len(errs) == 0 {
+		return models.ErrNoRelayAvailable
+	}
+
+	return fmt.Errorf("%w: %w", models.ErrNoRelayAvailable, errors.Join(errs...))
+}
+
+// Health reports the breaker state of every relay in priority order.
+func (s *Sender) Health() []RelayStatus {
+	statuses := make([]RelayStatus, 0, len(s.relays))
+
+	for _, relay := range s.relays {
+		snap := relay.Breaker.Snapshot()
+
+		statuses = append(statuses, RelayStatus{
+			Name:          relay.Name,
+			State:         snap.State,
+			Failures:      snap.Failures,
+			LastError:     snap.LastError,
+			LastFailureAt: snap.LastFailureAt,
+		})
+	}
+
+	return statuses
+}
+
+func (s *Sender) Close() error {
+	var errs []error
+
+	for _, relay := range s.relays {
+		if closer, ok := relay.Transport.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,133 @@
+package failover
+
+import (
+	"errors"
+	"medods-test-task/internal/models"
+	"medods-test-task/pkg/circuitbreaker"
+	"medods-test-task/pkg/email/smtp"
+	"testing"
+	"time"
+)
+
+type fakeTransport struct {
+	err   error
+	calls int
+}
+
+func (f *fakeTransport) Send(smtp.SendEmailInput) error {
+	f.calls++
+
+	return f.err
+}
+
+func TestSender_Send(t *testing.T) {
+	input := smtp.SendEmailInput{To: "user@medods.ru", Subject: "Subject", Body: "<p>Body</p>"}
+	errDown := errors.New("connection refused")
+
+	tests := []struct {
+		name            string
+		primaryErr      error
+		backupErr       error
+		sends           int
+		expectedErr     error
+		expectedPrimary int
+		expectedBackup  int
+		expectedState   circuitbreaker.State
+	}{
+		{
+			name:            "Primary",
+			sends:           3,
+			expectedPrimary: 3,
+			expectedBackup:  0,
+			expectedState:   circuitbreaker.StateClosed,
+		},
+		{
+			name:            "Failover",
+			primaryErr:      errDown,
+			sends:           1,
+			expectedPrimary: 1,
+			expectedBackup:  1,
+			expectedState:   circuitbreaker.StateClosed,
+		},
+		{
+			name:            "Primary circuit open",
+			primaryErr:      errDown,
+			sends:           4,
+			expectedPrimary: 2,
+			expectedBackup:  4,
+			expectedState:   circuitbreaker.StateOpen,
+		},
+		{
+			name:            "Queue full does not open circuit",
+			primaryErr:      models.ErrSMTPQueueFull,
+			sends:           4,
+			expectedPrimary: 4,
+			expectedBackup:  4,
+			expectedState:   circuitbreaker.StateClosed,
+		},
+		{
+			name:            "Rejected recipient does not fail over",
+			primaryErr:      models.ErrSMTPRecipient,
+			sends:           3,
+			expectedErr:     models.ErrSMTPRecipient,
+			expectedPrimary: 3,
+			expectedBackup:  0,
+			expectedState:   circuitbreaker.StateClosed,
+		},
+		{
+			name:            "All relays down",
+			primaryErr:      errDown,
+			backupErr:       errDown,
+			sends:           1,
+			expectedErr:     models.ErrNoRelayAvailable,
+			expectedPrimary: 1,
+			expectedBackup:  1,
+			expectedState:   circuitbreaker.StateClosed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := &fakeTransport{err: tt.primaryErr}
+			backup := &fakeTransport{err: tt.backupErr}
+
+			s := New(
+				Relay{Name: "primary", Transport: primary, Breaker: circuitbreaker.New(2, time.Minute)},
+				Relay{Name: "backup", Transport: backup, Breaker: circuitbreaker.New(2, time.Minute)},
+			)
+
+			var err error
+			for range tt.sends {
+				err = s.Send(input)
+			}
+
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("err = %v, expected %v", err, tt.expectedErr)
+			}
+
+			if primary.calls != tt.expectedPrimary || backup.calls != tt.expectedBackup {
+				t.Errorf("calls = %d/%d, expected %d/%d", primary.calls, backup.calls, tt.expectedPrimary, tt.expectedBackup)
+			}
+
+			if state := s.Health()[0].State; state != tt.expectedState {
+				t.Errorf("primary state = %s, expected %s", state, tt.expectedState)
+			}
+		})
+	}
+}
+
+func TestSender_SendAllOpen(t *testing.T) {
+	primary := &fakeTransport{err: errors.New("connection refused")}
+
+	s := New(Relay{Name: "primary", Transport: primary, Breaker: circuitbreaker.New(1, time.Minute)})
+
+	_ = s.Send(smtp.SendEmailInput{To: "user@medods.ru", Subject: "Subject", Body: "Body"})
+
+	err := s.Send(smtp.SendEmailInput{To: "user@medods.ru", Subject: "Subject", Body: "Body"})
+	if !errors.Is(err, models.ErrNoRelayAvailable) {
+		t.Fatalf("err = %v, expected %v", err, models.ErrNoRelayAvailable)
+	}
+
+	if primary.calls != 1 {
+		t.Errorf("calls = %d, expected 1", primary.calls)
+	}
+}
@@ -0,0 +1,60 @@
+package maildir
+
+import (
+	"fmt"
+	"medods-test-task/pkg/email/smtp"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Sender writes every email as an .eml file into a maildir, so local setups
+// and tests can inspect outgoing mail without a relay.
+type Sender struct {
+	dir    string
+	from   string
+	domain string
+}
+
+func NewSender(dir, from, domain string) (*Sender, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create maildir: %w", err)
+		}
+	}
+
+	return &Sender{dir: dir, from: from, domain: domain}, nil
+}
+
+// Send writes the message to tmp first and moves it to new, so readers never
+// see a partially written file.
+func (s *Sender) Send(input smtp.SendEmailInput) error {
+	if err := input.Validate(); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d.%s.eml", time.Now().UnixNano(), uuid.NewString())
+	tmpPath := filepath.Join(s.dir, "tmp", name)
+
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create email file: %w", err)
+	}
+
+	if _, err := smtp.NewMessage(s.from, s.domain, input).WriteTo(f); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+
+		return fmt.Errorf("failed to write email file: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+
+		return fmt.Errorf("failed to write email file: %w", err)
+	}
+
+	return os.Rename(tmpPath, filepath.Join(s.dir, "new", name))
+}
@@ -0,0 +1,74 @@
+package maildir
+
+import (
+	"medods-test-task/internal/models"
+	"medods-test-task/pkg/email/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSender_Send(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       smtp.SendEmailInput
+		expectedErr error
+		files       int
+	}{
+		{
+			name:        "OK",
+			input:       smtp.SendEmailInput{To: "user@example.com", Subject: "Warning", Body: "<p>hello</p>"},
+			expectedErr: nil,
+			files:       1,
+		},
+		{
+			name:        "Invalid recipient",
+			input:       smtp.SendEmailInput{To: "not-an-email", Subject: "Warning", Body: "<p>hello</p>"},
+			expectedErr: models.ErrSMTPInvalidToEmail,
+			files:       0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			s, err := NewSender(dir, "no-reply@example.com", "example.com")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if err := s.Send(tt.input); err != tt.expectedErr {
+				t.Fatalf("error = %v, expectedError %v", err, tt.expectedErr)
+			}
+
+			entries, err := os.ReadDir(filepath.Join(dir, "new"))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(entries) != tt.files {
+				t.Fatalf("files = %d, expected %d", len(entries), tt.files)
+			}
+
+			if tt.files == 0 {
+				return
+			}
+
+			raw, err := os.ReadFile(filepath.Join(dir, "new", entries[0].Name()))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for _, header := range []string{"To: " + tt.input.To, "Subject: " + tt.input.Subject} {
+				if !strings.Contains(string(raw), header) {
+					t.Errorf("message does not contain %q", header)
+				}
+			}
+
+			if tmp, _ := os.ReadDir(filepath.Join(dir, "tmp")); len(tmp) != 0 {
+				t.Errorf("tmp is not empty: %d files", len(tmp))
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package nop
+
+import "medods-test-task/pkg/email/smtp"
+
+// Sender drops every email.
+type Sender struct{}
+
+func NewSender() *Sender {
+	return &Sender{}
+}
+
+func (s *Sender) Send(input smtp.SendEmailInput) error {
+	return nil
+}
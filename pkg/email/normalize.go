@@ -0,0 +1,107 @@
+package email
+
+import (
+	"medods-test-task/internal/models"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// provider describes how a mailbox provider delivers different spellings of
+// an address to the same inbox.
+type provider struct {
+	// domain is the primary domain of the provider's aliases.
+	domain string
+	// ignoreDots is set when dots in the local part are not significant.
+	ignoreDots bool
+	// tagSeparator starts a subaddress tag, such as "+" in user+tag@.
+	tagSeparator string
+}
+
+var (
+	gmail   = provider{domain: "gmail.com", ignoreDots: true, tagSeparator: "+"}
+	yandex  = provider{domain: "yandex.ru", tagSeparator: "+"}
+	plusTag = provider{tagSeparator: "+"}
+)
+
+var providers = map[string]provider{
+	"gmail.com":      gmail,
+	"googlemail.com": gmail,
+
+	"yandex.ru":  yandex,
+	"yandex.com": yandex,
+	"yandex.by":  yandex,
+	"yandex.kz":  yandex,
+	"yandex.ua":  yandex,
+	"ya.ru":      yandex,
+
+	"outlook.com":    plusTag,
+	"hotmail.com":    plusTag,
+	"live.com":       plusTag,
+	"icloud.com":     plusTag,
+	"me.com":         plusTag,
+	"fastmail.com":   plusTag,
+	"proton.me":      plusTag,
+	"protonmail.com": plusTag,
+}
+
+// Normalize trims the address, lowercases its domain and converts an
+// internationalized domain to punycode. The local part is kept as is.
+func Normalize(address string) (string, error) {
+	address = strings.TrimSpace(address)
+
+	at := strings.LastIndex(address, "@")
+	if at <= 0 || at == len(address)-1 {
+		return "", models.ErrEmailFormat
+	}
+
+	domain, err := idna.Lookup.ToASCII(strings.TrimSuffix(strings.ToLower(address[at+1:]), "."))
+	if err != nil {
+		return "", models.ErrEmailFormat
+	}
+
+	address = address[:at] + "@" + domain
+	if !IsValid(address) {
+		return "", models.ErrEmailFormat
+	}
+
+	return address, nil
+}
+
+// Canonicalize maps a normalized address to the key its inbox is known by:
+// the local part is lowercased, and for known providers dots and subaddress
+// tags are dropped and domain aliases are merged.
+func Canonicalize(address string) string {
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return strings.ToLower(address)
+	}
+
+	local, domain := strings.ToLower(address[:at]), address[at+1:]
+
+	p, ok := providers[domain]
+	if !ok {
+		return local + "@" + domain
+	}
+
+	if p.tagSeparator != "" {
+		if i := strings.Index(local, p.tagSeparator); i > 0 {
+			local = local[:i]
+		}
+	}
+
+	if p.ignoreDots {
+		local = strings.ReplaceAll(local, ".", "")
+	}
+
+	if p.domain != "" {
+		domain = p.domain
+	}
+
+	return local + "@" + domain
+}
+
+// Domain returns the domain of a normalized address.
+func Domain(address string) string {
+	return address[strings.LastIndex(address, "@")+1:]
+}
@@ -0,0 +1,81 @@
+package email
+
+import (
+	"errors"
+	"medods-test-task/internal/models"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name        string
+		address     string
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:     "Domain lowercased",
+			address:  " John.Smith@Example.COM ",
+			expected: "John.Smith@example.com",
+		},
+		{
+			name:     "Trailing dot",
+			address:  "user@example.com.",
+			expected: "user@example.com",
+		},
+		{
+			name:     "Internationalized domain",
+			address:  "user@Пример.РФ",
+			expected: "user@xn--e1afmkfd.xn--p1ai",
+		},
+		{
+			name:        "No domain",
+			address:     "user@",
+			expectedErr: models.ErrEmailFormat,
+		},
+		{
+			name:        "No at sign",
+			address:     "user.example.com",
+			expectedErr: models.ErrEmailFormat,
+		},
+		{
+			name:        "Invalid domain",
+			address:     "user@exa mple.com",
+			expectedErr: models.ErrEmailFormat,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Normalize(tt.address)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("error = %v, expectedError %v", err, tt.expectedErr)
+			}
+
+			if got != tt.expected {
+				t.Errorf("Normalize(%q) = %q, expected %q", tt.address, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		address  string
+		expected string
+	}{
+		{address: "John.Smith@example.com", expected: "john.smith@example.com"},
+		{address: "John.Smith+news@example.com", expected: "john.smith+news@example.com"},
+		{address: "John.Smith+news@gmail.com", expected: "johnsmith@gmail.com"},
+		{address: "j.o.h.n.smith@googlemail.com", expected: "johnsmith@gmail.com"},
+		{address: "ivan.petrov+shop@ya.ru", expected: "ivan.petrov@yandex.ru"},
+		{address: "jane+work@outlook.com", expected: "jane@outlook.com"},
+		{address: "+tag@gmail.com", expected: "+tag@gmail.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			if got := Canonicalize(tt.address); got != tt.expected {
+				t.Errorf("Canonicalize(%q) = %q, expected %q", tt.address, got, tt.expected)
+			}
+		})
+	}
+}
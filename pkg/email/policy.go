@@ -0,0 +1,52 @@
+package email
+
+import (
+	"medods-test-task/internal/models"
+	"strings"
+)
+
+type Address struct {
+	// Email is the normalized address mail is sent to.
+	Email string
+	// Canonical identifies the inbox and is used to find duplicate users.
+	Canonical string
+}
+
+// Policy decides which addresses users may register.
+type Policy struct {
+	blocklist    *Blocklist
+	canonicalize bool
+}
+
+// NewPolicy creates a policy rejecting domains of the blocklist. Without
+// canonicalize addresses are only compared case-insensitively.
+func NewPolicy(blocklist *Blocklist, canonicalize bool) *Policy {
+	if blocklist == nil {
+		blocklist = NewBlocklist()
+	}
+
+	return &Policy{
+		blocklist:    blocklist,
+		canonicalize: canonicalize,
+	}
+}
+
+// Check normalizes the address and fails with models.ErrEmailFormat or
+// models.ErrDisposableEmail when it cannot be used.
+func (p *Policy) Check(address string) (Address, error) {
+	normalized, err := Normalize(address)
+	if err != nil {
+		return Address{}, err
+	}
+
+	if p.blocklist.Contains(Domain(normalized)) {
+		return Address{}, models.ErrDisposableEmail
+	}
+
+	canonical := Canonicalize(normalized)
+	if !p.canonicalize {
+		canonical = strings.ToLower(normalized)
+	}
+
+	return Address{Email: normalized, Canonical: canonical}, nil
+}
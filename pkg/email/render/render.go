@@ -0,0 +1,164 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+)
+
+const (
+	htmlLayout  = "layout.html.tmpl"
+	textLayout  = "layout.txt.tmpl"
+	partialsDir = "partials"
+
+	subjectSuffix = ".subject.tmpl"
+	htmlSuffix    = ".html.tmpl"
+	textSuffix    = ".txt.tmpl"
+)
+
+// View is passed to every template.
+type View struct {
+	Locale string
+	Data   any
+}
+
+type Email struct {
+	Subject string
+	HTML    string
+	Text    string
+}
+
+type email struct {
+	subject *texttemplate.Template
+	html    *htmltemplate.Template
+	text    *texttemplate.Template
+}
+
+// Renderer renders localized emails from templates parsed once at start up.
+type Renderer struct {
+	emails   map[string]map[string]*email
+	fallback string
+}
+
+// New parses the layouts, partials and every locale directory of fsys.
+// Every email must have a subject, an html and a text template.
+func New(fsys fs.FS, fallback string) (*Renderer, error) {
+	htmlBase, err := htmltemplate.ParseFS(fsys, htmlLayout, path.Join(partialsDir, "*.html.tmpl"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse html layout: %w", err)
+	}
+
+	textBase, err := texttemplate.ParseFS(fsys, textLayout, path.Join(partialsDir, "*.txt.tmpl"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse text layout: %w", err)
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Renderer{
+		emails:   make(map[string]map[string]*email),
+		fallback: fallback,
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == partialsDir {
+			continue
+		}
+
+		emails, err := parseLocale(fsys, entry.Name(), htmlBase, textBase)
+		if err != nil {
+			return nil, err
+		}
+
+		r.emails[entry.Name()] = emails
+	}
+
+	if _, ok := r.emails[fallback]; !ok {
+		return nil, fmt.Errorf("no templates for fallback locale %q", fallback)
+	}
+
+	return r, nil
+}
+
+func parseLocale(fsys fs.FS, locale string, htmlBase *htmltemplate.Template, textBase *texttemplate.Template) (map[string]*email, error) {
+	subjects, err := fs.Glob(fsys, path.Join(locale, "*"+subjectSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	emails := make(map[string]*email, len(subjects))
+
+	for _, subjectFile := range subjects {
+		name := strings.TrimSuffix(path.Base(subjectFile), subjectSuffix)
+
+		subject, err := texttemplate.ParseFS(fsys, subjectFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", subjectFile, err)
+		}
+
+		html, err := htmltemplate.Must(htmlBase.Clone()).ParseFS(fsys, path.Join(locale, name+htmlSuffix))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s html: %w", path.Join(locale, name), err)
+		}
+
+		text, err := texttemplate.Must(textBase.Clone()).ParseFS(fsys, path.Join(locale, name+textSuffix))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s text: %w", path.Join(locale, name), err)
+		}
+
+		emails[name] = &email{subject: subject, html: html, text: text}
+	}
+
+	return emails, nil
+}
+
+// Render renders the email in the user's locale. A regional locale such as
+// en-US falls back to its language and then to the fallback locale.
+func (r *Renderer) Render(name, locale string, data any) (*Email, error) {
+	locale, tmpl, ok := r.lookup(name, locale)
+	if !ok {
+		return nil, fmt.Errorf("email template %q not found", name)
+	}
+
+	view := View{Locale: locale, Data: data}
+
+	var subject, html, text bytes.Buffer
+
+	if err := tmpl.subject.Execute(&subject, view); err != nil {
+		return nil, fmt.Errorf("failed to render %s subject: %w", name, err)
+	}
+
+	if err := tmpl.html.ExecuteTemplate(&html, "layout", view); err != nil {
+		return nil, fmt.Errorf("failed to render %s html: %w", name, err)
+	}
+
+	if err := tmpl.text.ExecuteTemplate(&text, "layout", view); err != nil {
+		return nil, fmt.Errorf("failed to render %s text: %w", name, err)
+	}
+
+	return &Email{
+		Subject: strings.TrimSpace(subject.String()),
+		HTML:    html.String(),
+		Text:    text.String(),
+	}, nil
+}
+
+func (r *Renderer) lookup(name, locale string) (string, *email, bool) {
+	locale = strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+	lang, _, _ := strings.Cut(locale, "-")
+
+	for _, candidate := range []string{locale, lang, r.fallback} {
+		if tmpl, ok := r.emails[candidate][name]; ok {
+			return candidate, tmpl, true
+		}
+	}
+
+	return "", nil, false
+}
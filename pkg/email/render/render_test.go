@@ -0,0 +1,98 @@
+package render
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestRenderer_Render(t *testing.T) {
+	fsys := fstest.MapFS{
+		"layout.html.tmpl":        {Data: []byte(`{{define "layout"}}<html lang="{{.Locale}}">{{template "content" .}}{{template "sign.html"}}</html>{{end}}`)},
+		"layout.txt.tmpl":         {Data: []byte(`{{define "layout"}}{{template "content" .}}{{template "sign.txt"}}{{end}}`)},
+		"partials/sign.html.tmpl": {Data: []byte(`{{define "sign.html"}}<p>team</p>{{end}}`)},
+		"partials/sign.txt.tmpl":  {Data: []byte(`{{define "sign.txt"}} team{{end}}`)},
+		"en/hello.subject.tmpl":   {Data: []byte("Hello {{.Data}}\n")},
+		"en/hello.html.tmpl":      {Data: []byte(`{{define "content"}}<b>{{.Data}}</b>{{end}}`)},
+		"en/hello.txt.tmpl":       {Data: []byte(`{{define "content"}}{{.Data}}{{end}}`)},
+		"ru/hello.subject.tmpl":   {Data: []byte("Привет {{.Data}}")},
+		"ru/hello.html.tmpl":      {Data: []byte(`{{define "content"}}<i>{{.Data}}</i>{{end}}`)},
+		"ru/hello.txt.tmpl":       {Data: []byte(`{{define "content"}}{{.Data}}!{{end}}`)},
+		"ru/ru_only.subject.tmpl": {Data: []byte("Только")},
+		"ru/ru_only.html.tmpl":    {Data: []byte(`{{define "content"}}ru{{end}}`)},
+		"ru/ru_only.txt.tmpl":     {Data: []byte(`{{define "content"}}ru{{end}}`)},
+	}
+
+	r, err := New(fsys, "ru")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		email    string
+		locale   string
+		data     any
+		expected Email
+	}{
+		{
+			name:   "Exact locale",
+			email:  "hello",
+			locale: "en",
+			data:   "<Bob>",
+			expected: Email{
+				Subject: "Hello <Bob>",
+				HTML:    `<html lang="en"><b>&lt;Bob&gt;</b><p>team</p></html>`,
+				Text:    "<Bob> team",
+			},
+		},
+		{
+			name:   "Regional locale",
+			email:  "hello",
+			locale: "en_US",
+			data:   "Bob",
+			expected: Email{
+				Subject: "Hello Bob",
+				HTML:    `<html lang="en"><b>Bob</b><p>team</p></html>`,
+				Text:    "Bob team",
+			},
+		},
+		{
+			name:   "Fallback locale",
+			email:  "ru_only",
+			locale: "en",
+			expected: Email{
+				Subject: "Только",
+				HTML:    `<html lang="ru">ru<p>team</p></html>`,
+				Text:    "ru team",
+			},
+		},
+		{
+			name:   "Unknown locale",
+			email:  "hello",
+			locale: "",
+			data:   "Боб",
+			expected: Email{
+				Subject: "Привет Боб",
+				HTML:    `<html lang="ru"><i>Боб</i><p>team</p></html>`,
+				Text:    "Боб! team",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.Render(tt.email, tt.locale, tt.data)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if *got != tt.expected {
+				t.Errorf("email = %+v, expected %+v", *got, tt.expected)
+			}
+		})
+	}
+
+	if _, err := r.Render("missing", "en", nil); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
@@ -1,33 +1,20 @@
 package smtp
 
 import (
-	"bytes"
 	"fmt"
-	"html/template"
 	"medods-test-task/internal/models"
 	"medods-test-task/pkg/email"
+	"time"
+
+	"github.com/go-gomail/gomail"
+	"github.com/google/uuid"
 )
 
 type SendEmailInput struct {
 	To      string
 	Subject string
 	Body    string
-}
-
-func (e *SendEmailInput) GenerateBodyFromHTML(templateFileName string, data interface{}) error {
-	t, err := template.ParseFiles(templateFileName)
-	if err != nil {
-		return fmt.Errorf("failed to parse file %s:%w", templateFileName, err)
-	}
-
-	buf := new(bytes.Buffer)
-	if err = t.Execute(buf, data); err != nil {
-		return fmt.Errorf("failed to parse template: %w", err)
-	}
-
-	e.Body = buf.String()
-
-	return nil
+	Text    string
 }
 
 func (e *SendEmailInput) Validate() error {
@@ -45,3 +32,27 @@ func (e *SendEmailInput) Validate() error {
 
 	return nil
 }
+
+// NewMessage builds the MIME message shared by all mail transports. When a
+// text body is set the message is multipart/alternative with the html part
+// preferred.
+func NewMessage(from, domain string, input SendEmailInput) *gomail.Message {
+	msg := gomail.NewMessage()
+	msg.SetHeader("From", from)
+	msg.SetHeader("To", input.To)
+	msg.SetHeader("Subject", input.Subject)
+	msg.SetHeader("Date", time.Now().Format(time.RFC1123Z))
+	msg.SetHeader("MIME-Version", "1.0")
+	msg.SetHeader("Message-ID", fmt.Sprintf("<%s@%s>", uuid.NewString(), domain))
+
+	if input.Text == "" {
+		msg.SetBody("text/html", input.Body)
+
+		return msg
+	}
+
+	msg.SetBody("text/plain", input.Text)
+	msg.AddAlternative("text/html", input.Body)
+
+	return msg
+}
@@ -1,30 +1,89 @@
 package smtp
 
 import (
+	"bytes"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"medods-test-task/internal/models"
 	"medods-test-task/pkg/email"
+	"net/textproto"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-gomail/gomail"
-	"github.com/google/uuid"
 )
 
+const defaultIdleTimeout = 30 * time.Second
+
+// Signer adds a signature, such as DKIM, to a raw message.
+type Signer interface {
+	Sign(message []byte) ([]byte, error)
+}
+
+type PoolConfig struct {
+	// Size is the number of connections kept open to the relay.
+	Size int
+	// QueueSize bounds the number of messages waiting for a connection.
+	QueueSize int
+	// IdleTimeout closes a connection that has not been used for a while.
+	IdleTimeout time.Duration
+}
+
+type job struct {
+	input SendEmailInput
+	done  chan error
+}
+
+// SMTPSender sends messages over a pool of keep-alive connections. Send
+// blocks until the relay accepts the message and fails fast when the queue is
+// full.
 type SMTPSender struct {
 	from   string
-	pass   string
-	host   string
-	port   int
 	domain string
+	dialer *gomail.Dialer
+	signer Signer
+	pool   PoolConfig
+
+	queue     chan job
+	closing   chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
 }
 
-func NewSMTPSender(from, pass, host, domain string, port int) (*SMTPSender, error) {
+func NewSMTPSender(from, pass, host, domain string, port int, pool PoolConfig, signer Signer) (*SMTPSender, error) {
 	if !email.IsValid(from) {
 		return nil, fmt.Errorf("failed to create smtp sender: %w", models.ErrEmailFormat)
 	}
 
-	return &SMTPSender{from: from, pass: pass, host: host, port: port}, nil
+	pool.Size = max(pool.Size, 1)
+	pool.QueueSize = max(pool.QueueSize, 0)
+	if pool.IdleTimeout <= 0 {
+		pool.IdleTimeout = defaultIdleTimeout
+	}
+
+	dialer := gomail.NewDialer(host, port, from, pass)
+	dialer.TLSConfig = &tls.Config{ServerName: host}
+
+	s := &SMTPSender{
+		from:    from,
+		domain:  domain,
+		dialer:  dialer,
+		signer:  signer,
+		pool:    pool,
+		queue:   make(chan job, pool.QueueSize),
+		closing: make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	s.wg.Add(pool.Size)
+	for range pool.Size {
+		go s.worker()
+	}
+
+	return s, nil
 }
 
 func (s *SMTPSender) Send(input SendEmailInput) error {
@@ -32,21 +91,142 @@ func (s *SMTPSender) Send(input SendEmailInput) error {
 		return err
 	}
 
-	msg := gomail.NewMessage()
-	msg.SetHeader("From", s.from)
-	msg.SetHeader("To", input.To)
-	msg.SetHeader("Subject", input.Subject)
-	msg.SetHeader("Date", time.Now().Format(time.RFC1123Z))
-	msg.SetHeader("MIME-Version", "1.0")
-	msg.SetHeader("Message-ID", fmt.Sprintf("<%s@%s>", uuid.NewString(), s.domain))
-	msg.SetHeader("Content-Type", "text/html; charset=UTF-8")
-	msg.SetBody("text/html", input.Body)
+	j := job{input: input, done: make(chan error, 1)}
 
-	dialer := gomail.NewDialer(s.host, s.port, s.from, s.pass)
-	dialer.TLSConfig = &tls.Config{ServerName: s.host}
-	if err := dialer.DialAndSend(msg); err != nil {
-		return fmt.Errorf("failed to sent email: %w", err)
+	select {
+	case <-s.closing:
+		return models.ErrSMTPClosed
+	case s.queue <- j:
+	default:
+		return models.ErrSMTPQueueFull
 	}
 
+	select {
+	case err := <-j.done:
+		return err
+	case <-s.stopped:
+		select {
+		case err := <-j.done:
+			return err
+		default:
+			return models.ErrSMTPClosed
+		}
+	}
+}
+
+// Close waits for in-flight messages and closes every connection. Messages
+// still waiting in the queue fail with models.ErrSMTPClosed.
+func (s *SMTPSender) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closing)
+		s.wg.Wait()
+		close(s.stopped)
+	})
+
 	return nil
 }
+
+func (s *SMTPSender) worker() {
+	defer s.wg.Done()
+
+	var conn gomail.SendCloser
+
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	idle := time.NewTimer(s.pool.IdleTimeout)
+	defer idle.Stop()
+
+	for {
+		select {
+		case <-s.closing:
+			return
+		case <-idle.C:
+			if conn != nil {
+				conn.Close()
+				conn = nil
+			}
+		case j := <-s.queue:
+			j.done <- s.send(&conn, j.input)
+			idle.Reset(s.pool.IdleTimeout)
+		}
+	}
+}
+
+// send delivers the message over conn, dialing when needed. A reused
+// connection may have been dropped by the relay, so the message is retried
+// once over a fresh one.
+func (s *SMTPSender) send(conn *gomail.SendCloser, input SendEmailInput) error {
+	raw, err := s.message(input)
+	if err != nil {
+		return err
+	}
+
+	reused := *conn != nil
+
+	for {
+		if *conn == nil {
+			c, err := s.dialer.Dial()
+			if err != nil {
+				return fmt.Errorf("failed to dial smtp relay: %w", err)
+			}
+
+			*conn = c
+		}
+
+		err := (*conn).Send(s.from, []string{input.To}, bytes.NewReader(raw))
+		if err == nil {
+			return nil
+		}
+
+		(*conn).Close()
+		*conn = nil
+
+		if recipientRejected(err) {
+			return fmt.Errorf("%w: %w", models.ErrSMTPRecipient, err)
+		}
+
+		if !reused {
+			return fmt.Errorf("failed to sent email: %w", err)
+		}
+
+		reused = false
+	}
+}
+
+// recipientRejected tells a permanent refusal of the recipient, such as an
+// unknown or full mailbox, from a failure of the relay itself. Enhanced status
+// codes (RFC 3463) of policy and sender problems point at the relay and are
+// left out.
+func recipientRejected(err error) bool {
+	var reply *textproto.Error
+	if !errors.As(err, &reply) {
+		return false
+	}
+
+	switch reply.Code {
+	case 550, 551, 552, 553:
+	default:
+		return false
+	}
+
+	status, _, _ := strings.Cut(reply.Msg, " ")
+
+	return !strings.HasPrefix(status, "5.7.") && status != "5.1.7" && status != "5.1.8"
+}
+
+func (s *SMTPSender) message(input SendEmailInput) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := NewMessage(s.from, s.domain, input).WriteTo(&buf); err != nil {
+		return nil, fmt.Errorf("failed to build email: %w", err)
+	}
+
+	if s.signer == nil {
+		return buf.Bytes(), nil
+	}
+
+	return s.signer.Sign(buf.Bytes())
+}
@@ -0,0 +1,229 @@
+package smtp
+
+import (
+	"errors"
+	"medods-test-task/internal/models"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// relay is a minimal SMTP server that records connections and messages.
+type relay struct {
+	listener net.Listener
+
+	mu          sync.Mutex
+	connections int
+	messages    []string
+}
+
+func newRelay(t *testing.T) *relay {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	r := &relay{listener: l}
+	go r.serve()
+
+	t.Cleanup(func() { l.Close() })
+
+	return r
+}
+
+func (r *relay) port() int {
+	return r.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (r *relay) serve() {
+	for {
+		conn, err := r.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		r.mu.Lock()
+		r.connections++
+		r.mu.Unlock()
+
+		go r.handle(conn)
+	}
+}
+
+func (r *relay) handle(conn net.Conn) {
+	defer conn.Close()
+
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost ESMTP")
+
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250 localhost")
+		case "RCPT":
+			if strings.Contains(line, "unknown@") {
+				tp.PrintfLine("550 5.1.1 mailbox unavailable")
+
+				continue
+			}
+
+			tp.PrintfLine("250 ok")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+
+			data, err := tp.ReadDotLines()
+			if err != nil {
+				return
+			}
+
+			r.mu.Lock()
+			r.messages = append(r.messages, strings.Join(data, "\r\n"))
+			r.mu.Unlock()
+
+			tp.PrintfLine("250 queued")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+
+			return
+		default:
+			tp.PrintfLine("250 ok")
+		}
+	}
+}
+
+type prefixSigner struct{}
+
+func (prefixSigner) Sign(message []byte) ([]byte, error) {
+	return append([]byte("DKIM-Signature: test\r\n"), message...), nil
+}
+
+func TestSMTPSender_Send(t *testing.T) {
+	r := newRelay(t)
+
+	s, err := NewSMTPSender("no-reply@example.com", "", "127.0.0.1", "example.com", r.port(), PoolConfig{Size: 1, QueueSize: 10, IdleTimeout: time.Minute}, prefixSigner{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i := range 3 {
+		err := s.Send(SendEmailInput{To: "user@example.com", Subject: "Message " + strconv.Itoa(i), Body: "<p>hi</p>", Text: "hi"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.connections != 1 {
+		t.Errorf("connections = %d, expected 1", r.connections)
+	}
+
+	if len(r.messages) != 3 {
+		t.Fatalf("messages = %d, expected 3", len(r.messages))
+	}
+
+	msg := r.messages[0]
+	for _, expected := range []string{"DKIM-Signature: test", "@example.com>", "multipart/alternative"} {
+		if !strings.Contains(msg, expected) {
+			t.Errorf("message does not contain %q", expected)
+		}
+	}
+
+	if err := s.Send(SendEmailInput{To: "user@example.com", Subject: "Late", Body: "<p>hi</p>"}); err == nil {
+		t.Errorf("expected error after close")
+	}
+}
+
+func TestSMTPSender_Reconnect(t *testing.T) {
+	r := newRelay(t)
+
+	s, err := NewSMTPSender("no-reply@example.com", "", "127.0.0.1", "example.com", r.port(), PoolConfig{Size: 1, QueueSize: 10, IdleTimeout: 50 * time.Millisecond}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer s.Close()
+
+	input := SendEmailInput{To: "user@example.com", Subject: "Hello", Body: "<p>hi</p>"}
+
+	if err := s.Send(input); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	if err := s.Send(input); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.connections != 2 {
+		t.Errorf("connections = %d, expected 2 after idle timeout", r.connections)
+	}
+}
+
+func TestSMTPSender_RecipientRejected(t *testing.T) {
+	r := newRelay(t)
+
+	s, err := NewSMTPSender("no-reply@example.com", "", "127.0.0.1", "example.com", r.port(), PoolConfig{Size: 1, QueueSize: 10, IdleTimeout: time.Minute}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer s.Close()
+
+	if err := s.Send(SendEmailInput{To: "user@example.com", Subject: "Hello", Body: "<p>hi</p>"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = s.Send(SendEmailInput{To: "unknown@example.com", Subject: "Hello", Body: "<p>hi</p>"})
+	if !errors.Is(err, models.ErrSMTPRecipient) {
+		t.Fatalf("err = %v, expected %v", err, models.ErrSMTPRecipient)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.connections != 1 {
+		t.Errorf("connections = %d, expected 1 without a retry", r.connections)
+	}
+}
+
+func TestRecipientRejected(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		rejected bool
+	}{
+		{name: "Unknown mailbox", err: &textproto.Error{Code: 550, Msg: "5.1.1 user unknown"}, rejected: true},
+		{name: "Mailbox full", err: &textproto.Error{Code: 552, Msg: "5.2.2 mailbox full"}, rejected: true},
+		{name: "Without enhanced status", err: &textproto.Error{Code: 553, Msg: "mailbox name not allowed"}, rejected: true},
+		{name: "Relaying denied", err: &textproto.Error{Code: 550, Msg: "5.7.1 relaying denied"}, rejected: false},
+		{name: "Sender rejected", err: &textproto.Error{Code: 553, Msg: "5.1.8 bad sender address"}, rejected: false},
+		{name: "Temporary", err: &textproto.Error{Code: 450, Msg: "4.2.1 try again later"}, rejected: false},
+		{name: "Connection", err: errors.New("connection reset by peer"), rejected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := recipientRejected(tt.err); got != tt.rejected {
+				t.Errorf("recipientRejected(%v) = %v, expected %v", tt.err, got, tt.rejected)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package geoip
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+type entry struct {
+	prefix   netip.Prefix
+	location string
+}
+
+// Locator resolves IP addresses to an approximate location using a CSV file
+// of "cidr,location" rows. The most specific matching network wins.
+type Locator struct {
+	entries []entry
+}
+
+func Load(path string) (*Locator, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open geoip database: %w", err)
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
+
+func Parse(r io.Reader) (*Locator, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 2
+	reader.Comment = '#'
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read geoip database: %w", err)
+	}
+
+	l := &Locator{entries: make([]entry, 0, len(records))}
+
+	for _, record := range records {
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(record[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %w", record[0], err)
+		}
+
+		l.entries = append(l.entries, entry{prefix: prefix.Masked(), location: strings.TrimSpace(record[1])})
+	}
+
+	return l, nil
+}
+
+// Locate returns an empty string when the address is unknown.
+func (l *Locator) Locate(ip string) string {
+	if l == nil {
+		return ""
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+
+	addr = addr.Unmap()
+
+	best := -1
+	location := ""
+
+	for _, e := range l.entries {
+		if e.prefix.Bits() > best && e.prefix.Contains(addr) {
+			best = e.prefix.Bits()
+			location = e.location
+		}
+	}
+
+	return location
+}
@@ -0,0 +1,37 @@
+package geoip
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLocator_Locate(t *testing.T) {
+	l, err := Parse(strings.NewReader(`# network,location
+10.0.0.0/8,Private network
+10.1.0.0/16,"Moscow, Russia"
+2001:db8::/32,Documentation
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		ip       string
+		expected string
+	}{
+		{name: "Most specific network", ip: "10.1.2.3", expected: "Moscow, Russia"},
+		{name: "Wider network", ip: "10.2.0.1", expected: "Private network"},
+		{name: "IPv4-mapped IPv6", ip: "::ffff:10.1.0.1", expected: "Moscow, Russia"},
+		{name: "IPv6", ip: "2001:db8::1", expected: "Documentation"},
+		{name: "Unknown", ip: "192.0.2.1", expected: ""},
+		{name: "Invalid", ip: "not-an-ip", expected: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := l.Locate(tt.ip); got != tt.expected {
+				t.Errorf("location = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
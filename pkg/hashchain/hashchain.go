@@ -0,0 +1,29 @@
+package hashchain
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Link returns the hash of a record chained to the hash of the previous one.
+func Link(prevHash string, record []byte) string {
+	h := sha256.New()
+	h.Write([]byte(prevHash))
+	h.Write([]byte{0})
+	h.Write(record)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func Sign(secret []byte, parts ...string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strings.Join(parts, "|")))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func VerifySignature(secret []byte, signature string, parts ...string) bool {
+	return hmac.Equal([]byte(signature), []byte(Sign(secret, parts...)))
+}
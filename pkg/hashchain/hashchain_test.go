@@ -0,0 +1,43 @@
+package hashchain
+
+import "testing"
+
+func TestLink(t *testing.T) {
+	first := Link("", []byte("first"))
+	second := Link(first, []byte("second"))
+
+	if first == second {
+		t.Fatalf("different records should have different hashes")
+	}
+
+	if Link(first, []byte("second")) != second {
+		t.Errorf("hash should be deterministic")
+	}
+
+	if Link("", []byte("second")) == second {
+		t.Errorf("hash should depend on the previous hash")
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	secret := []byte("secret")
+	signature := Sign(secret, "2026-01-01", "42", "hash")
+
+	tests := []struct {
+		name   string
+		secret []byte
+		parts  []string
+		valid  bool
+	}{
+		{name: "OK", secret: secret, parts: []string{"2026-01-01", "42", "hash"}, valid: true},
+		{name: "Wrong secret", secret: []byte("other"), parts: []string{"2026-01-01", "42", "hash"}, valid: false},
+		{name: "Altered data", secret: secret, parts: []string{"2026-01-01", "43", "hash"}, valid: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifySignature(tt.secret, signature, tt.parts...); got != tt.valid {
+				t.Errorf("valid = %v, expected %v", got, tt.valid)
+			}
+		})
+	}
+}
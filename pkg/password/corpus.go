@@ -0,0 +1,199 @@
+package password
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// prefixLength is the number of hash characters of a range query, as in
+	// the k-anonymity API of Have I Been Pwned.
+	prefixLength = 5
+
+	probeSize = 128
+)
+
+// Corpus looks passwords up in a file of breached SHA-1 hashes in the "ordered
+// by hash" format of Have I Been Pwned: one "HASH:COUNT" line per password,
+// sorted by hash. The file is binary searched, so it is never loaded into
+// memory.
+type Corpus struct {
+	r    io.ReaderAt
+	size int64
+	c    io.Closer
+}
+
+func NewCorpus(r io.ReaderAt, size int64) *Corpus {
+	return &Corpus{r: r, size: size}
+}
+
+func OpenCorpus(path string) (*Corpus, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breach corpus: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+
+		return nil, fmt.Errorf("failed to stat breach corpus: %w", err)
+	}
+
+	return &Corpus{r: f, size: info.Size(), c: f}, nil
+}
+
+func (c *Corpus) Close() error {
+	if c.c == nil {
+		return nil
+	}
+
+	return c.c.Close()
+}
+
+// Count returns how many times the password appeared in breaches. Only the
+// hash prefix is used to find the range, the same way a remote range query
+// would.
+func (c *Corpus) Count(password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	suffixes, err := c.Range(hash[:prefixLength])
+	if err != nil {
+		return 0, err
+	}
+
+	return suffixes[hash[prefixLength:]], nil
+}
+
+// Range returns the hash suffixes starting with prefix and their counts.
+func (c *Corpus) Range(prefix string) (map[string]int, error) {
+	prefix = strings.ToUpper(prefix)
+
+	start, err := c.search(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	suffixes := make(map[string]int)
+	scanner := bufio.NewScanner(io.NewSectionReader(c.r, start, c.size-start))
+
+	for scanner.Scan() {
+		hash, count, ok := parseLine(scanner.Text())
+		if !ok {
+			continue
+		}
+
+		if !strings.HasPrefix(hash, prefix) {
+			break
+		}
+
+		suffixes[hash[len(prefix):]] = count
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read breach corpus: %w", err)
+	}
+
+	return suffixes, nil
+}
+
+// search returns the offset of the first line whose hash is not less than
+// prefix.
+func (c *Corpus) search(prefix string) (int64, error) {
+	lo, hi := int64(0), c.size
+
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+
+		start, line, err := c.lineAt(mid)
+		if err != nil {
+			return 0, err
+		}
+
+		if start >= c.size || strings.ToUpper(line) >= prefix {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+
+	start, _, err := c.lineAt(lo)
+
+	return start, err
+}
+
+// lineAt returns the first line starting at or after offset.
+func (c *Corpus) lineAt(offset int64) (int64, string, error) {
+	start := offset
+	if offset > 0 {
+		next, err := c.indexNewline(offset - 1)
+		if err != nil {
+			return 0, "", err
+		}
+
+		start = next + 1
+	}
+
+	if start >= c.size {
+		return c.size, "", nil
+	}
+
+	end, err := c.indexNewline(start)
+	if err != nil {
+		return 0, "", err
+	}
+
+	buf := make([]byte, end-start)
+	if _, err := c.r.ReadAt(buf, start); err != nil && err != io.EOF {
+		return 0, "", fmt.Errorf("failed to read breach corpus: %w", err)
+	}
+
+	return start, strings.TrimRight(string(buf), "\r"), nil
+}
+
+// indexNewline returns the offset of the first newline at or after offset, or
+// the size of the corpus when there is none.
+func (c *Corpus) indexNewline(offset int64) (int64, error) {
+	buf := make([]byte, probeSize)
+
+	for offset < c.size {
+		n, err := c.r.ReadAt(buf, offset)
+		if i := bytes.IndexByte(buf[:n], '\n'); i >= 0 {
+			return offset + int64(i), nil
+		}
+
+		if err != nil && err != io.EOF {
+			return 0, fmt.Errorf("failed to read breach corpus: %w", err)
+		}
+
+		if n == 0 {
+			break
+		}
+
+		offset += int64(n)
+	}
+
+	return c.size, nil
+}
+
+func parseLine(line string) (string, int, bool) {
+	hash, count, ok := strings.Cut(strings.TrimSpace(line), ":")
+	if !ok {
+		return "", 0, false
+	}
+
+	n, err := strconv.Atoi(count)
+	if err != nil {
+		return "", 0, false
+	}
+
+	return strings.ToUpper(hash), n, true
+}
@@ -0,0 +1,96 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// newTestCorpus builds an ordered-by-hash corpus of the given passwords and
+// some filler, with a breach count of 10 for each password.
+func newTestCorpus(t *testing.T, lineEnding string, passwords ...string) *Corpus {
+	t.Helper()
+
+	var lines []string
+	for _, p := range passwords {
+		lines = append(lines, sha1Hex(p)+":10")
+	}
+
+	for i := range 500 {
+		lines = append(lines, fmt.Sprintf("%s:%d", sha1Hex(fmt.Sprintf("filler-%d", i)), i+1))
+	}
+
+	sort.Strings(lines)
+
+	data := strings.Join(lines, lineEnding) + lineEnding
+
+	return NewCorpus(strings.NewReader(data), int64(len(data)))
+}
+
+func TestCorpus_Count(t *testing.T) {
+	for name, lineEnding := range map[string]string{"LF": "\n", "CRLF": "\r\n"} {
+		t.Run(name, func(t *testing.T) {
+			c := newTestCorpus(t, lineEnding, "password123", "qwerty", "letmein")
+
+			tests := []struct {
+				password string
+				expected int
+			}{
+				{password: "password123", expected: 10},
+				{password: "qwerty", expected: 10},
+				{password: "letmein", expected: 10},
+				{password: "filler-0", expected: 1},
+				{password: "filler-499", expected: 500},
+				{password: "correct horse battery staple", expected: 0},
+			}
+			for _, tt := range tests {
+				count, err := c.Count(tt.password)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				if count != tt.expected {
+					t.Errorf("Count(%q) = %d, expected %d", tt.password, count, tt.expected)
+				}
+			}
+		})
+	}
+}
+
+func TestCorpus_Range(t *testing.T) {
+	c := newTestCorpus(t, "\n", "password123")
+	hash := sha1Hex("password123")
+
+	suffixes, err := c.Range(strings.ToLower(hash[:prefixLength]))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if suffixes[hash[prefixLength:]] != 10 {
+		t.Errorf("range of %s = %v, expected it to contain the password", hash[:prefixLength], suffixes)
+	}
+
+	for suffix := range suffixes {
+		if len(suffix) != len(hash)-prefixLength {
+			t.Errorf("unexpected suffix %q", suffix)
+		}
+	}
+}
+
+func TestCorpus_Empty(t *testing.T) {
+	c := NewCorpus(strings.NewReader(""), 0)
+
+	count, err := c.Count("password")
+	if err != nil || count != 0 {
+		t.Errorf("Count() = %d, %v, expected 0, nil", count, err)
+	}
+}
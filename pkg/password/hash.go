@@ -0,0 +1,29 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+	"medods-test-task/internal/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func Hash(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	return string(hashed), nil
+}
+
+// Compare fails with models.ErrWrongPassword when the password does not
+// match the hash.
+func Compare(hashed, password string) error {
+	err := bcrypt.CompareHashAndPassword([]byte(hashed), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return models.ErrWrongPassword
+	}
+
+	return err
+}
@@ -0,0 +1,88 @@
+package password
+
+import (
+	"fmt"
+	"medods-test-task/internal/models"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxBytes is the longest password bcrypt can hash.
+const maxBytes = 72
+
+// BreachCounter reports how many times a password appeared in breaches.
+type BreachCounter interface {
+	Count(password string) (int, error)
+}
+
+type Policy struct {
+	MinLength   int
+	MaxLength   int
+	BannedWords []string
+	// Breaches is checked after the other rules. A nil counter skips the
+	// check.
+	Breaches BreachCounter
+	// BreachThreshold is the number of breaches that rejects a password.
+	BreachThreshold int
+}
+
+// Validate checks the password of the user with the given email. A failed
+// rule is reported as *models.PasswordPolicyError.
+func (p *Policy) Validate(password, email string) error {
+	length := utf8.RuneCountInString(password)
+
+	if length < p.MinLength {
+		return violation(models.PasswordRuleMinLength,
+			fmt.Errorf("%w: use at least %d characters", models.ErrPasswordTooShort, p.MinLength))
+	}
+
+	if (p.MaxLength > 0 && length > p.MaxLength) || len(password) > maxBytes {
+		return violation(models.PasswordRuleMaxLength,
+			fmt.Errorf("%w: use at most %d characters", models.ErrPasswordTooLong, p.maxLength()))
+	}
+
+	lower := strings.ToLower(password)
+
+	if email != "" {
+		email = strings.ToLower(email)
+		local, _, _ := strings.Cut(email, "@")
+
+		if lower == email || lower == local {
+			return violation(models.PasswordRuleMatchesEmail, models.ErrPasswordMatchesEmail)
+		}
+	}
+
+	for _, word := range p.BannedWords {
+		if word != "" && strings.Contains(lower, strings.ToLower(word)) {
+			return violation(models.PasswordRuleBannedWord,
+				fmt.Errorf("%w: %q", models.ErrPasswordBannedWord, word))
+		}
+	}
+
+	if p.Breaches == nil {
+		return nil
+	}
+
+	count, err := p.Breaches.Count(password)
+	if err != nil {
+		return err
+	}
+
+	if count >= max(p.BreachThreshold, 1) {
+		return violation(models.PasswordRuleBreached, models.ErrPasswordBreached)
+	}
+
+	return nil
+}
+
+func (p *Policy) maxLength() int {
+	if p.MaxLength > 0 && p.MaxLength < maxBytes {
+		return p.MaxLength
+	}
+
+	return maxBytes
+}
+
+func violation(rule string, err error) error {
+	return &models.PasswordPolicyError{Err: err, Rule: rule}
+}
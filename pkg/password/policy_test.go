@@ -0,0 +1,86 @@
+package password
+
+import (
+	"errors"
+	"medods-test-task/internal/models"
+	"strings"
+	"testing"
+)
+
+func TestPolicy_Validate(t *testing.T) {
+	p := &Policy{
+		MinLength:       12,
+		MaxLength:       64,
+		BannedWords:     []string{"medods", "qwerty"},
+		Breaches:        newTestCorpus(t, "\n", "hunter2hunter2"),
+		BreachThreshold: 1,
+	}
+
+	tests := []struct {
+		name         string
+		password     string
+		email        string
+		expectedRule string
+	}{
+		{
+			name:     "OK",
+			password: "correct horse battery",
+			email:    "user@email.com",
+		},
+		{
+			name:         "Too short",
+			password:     "short",
+			expectedRule: models.PasswordRuleMinLength,
+		},
+		{
+			name:         "Too long",
+			password:     strings.Repeat("a", 65),
+			expectedRule: models.PasswordRuleMaxLength,
+		},
+		{
+			name:         "Too long for bcrypt",
+			password:     strings.Repeat("я", 40),
+			expectedRule: models.PasswordRuleMaxLength,
+		},
+		{
+			name:         "Matches email",
+			password:     "Long.User@Email.com",
+			email:        "long.user@email.com",
+			expectedRule: models.PasswordRuleMatchesEmail,
+		},
+		{
+			name:         "Matches email local part",
+			password:     "long.username",
+			email:        "long.username@email.com",
+			expectedRule: models.PasswordRuleMatchesEmail,
+		},
+		{
+			name:         "Banned word",
+			password:     "MyMedodsAccount!",
+			expectedRule: models.PasswordRuleBannedWord,
+		},
+		{
+			name:         "Breached",
+			password:     "hunter2hunter2",
+			expectedRule: models.PasswordRuleBreached,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.Validate(tt.password, tt.email)
+
+			var policyErr *models.PasswordPolicyError
+			if !errors.As(err, &policyErr) {
+				if tt.expectedRule != "" || err != nil {
+					t.Fatalf("error = %v, expected rule %q", err, tt.expectedRule)
+				}
+
+				return
+			}
+
+			if policyErr.Rule != tt.expectedRule {
+				t.Errorf("rule = %q, expected %q", policyErr.Rule, tt.expectedRule)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryLimiter_Allow(t *testing.T) {
+	rule := Rule{Limit: 2, Period: time.Minute}
+
+	tests := []struct {
+		name          string
+		advance       []time.Duration
+		expectAllowed []bool
+	}{
+		{
+			name:          "Burst within limit",
+			advance:       []time.Duration{0, 0},
+			expectAllowed: []bool{true, true},
+		},
+		{
+			name:          "Burst over limit",
+			advance:       []time.Duration{0, 0, 0},
+			expectAllowed: []bool{true, true, false},
+		},
+		{
+			name:          "Refilled after period",
+			advance:       []time.Duration{0, 0, 0, 30 * time.Second},
+			expectAllowed: []bool{true, true, false, true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			l := NewMemoryLimiter()
+			l.now = func() time.Time { return now }
+
+			for i, d := range tt.advance {
+				now = now.Add(d)
+
+				res, err := l.Allow(context.Background(), "key", rule)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				if res.Allowed != tt.expectAllowed[i] {
+					t.Errorf("attempt %d: allowed = %v, expected %v", i, res.Allowed, tt.expectAllowed[i])
+				}
+
+				if !res.Allowed && res.RetryAfter <= 0 {
+					t.Errorf("attempt %d: retry after should be positive, got %v", i, res.RetryAfter)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Rule describes a token bucket holding up to Limit tokens that refills
+// with Limit tokens every Period.
+type Rule struct {
+	Limit  int
+	Period time.Duration
+}
+
+type Result struct {
+	Allowed    bool
+	RetryAfter time.Duration
+}
+
+type Limiter interface {
+	Allow(ctx context.Context, key string, rule Rule) (Result, error)
+}
+
+func (r Rule) Enabled() bool {
+	return r.Limit > 0 && r.Period > 0
+}
+
+func (r Rule) rate() float64 {
+	return float64(r.Limit) / r.Period.Seconds()
+}
+
+// FullIn returns how long a bucket left with tokens takes to refill
+// completely. A full bucket behaves like one that was never used, so it can
+// be dropped then.
+func FullIn(tokens float64, rule Rule) time.Duration {
+	return time.Duration((float64(rule.Limit) - tokens) / rule.rate() * float64(time.Second))
+}
+
+// Take refills a bucket that was left with tokens elapsed ago and tries to
+// take a single token from it. It returns the tokens left in the bucket.
+func Take(tokens float64, elapsed time.Duration, rule Rule) (float64, Result) {
+	if elapsed > 0 {
+		tokens = math.Min(float64(rule.Limit), tokens+elapsed.Seconds()*rule.rate())
+	}
+
+	if tokens >= 1 {
+		return tokens - 1, Result{Allowed: true}
+	}
+
+	retryAfter := time.Duration((1 - tokens) / rule.rate() * float64(time.Second))
+
+	return tokens, Result{Allowed: false, RetryAfter: retryAfter}
+}
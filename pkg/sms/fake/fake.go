@@ -0,0 +1,46 @@
+// Package fake provides an in-memory SMS provider for tests and local runs.
+package fake
+
+import (
+	"context"
+	"sync"
+)
+
+type Message struct {
+	To   string
+	Text string
+}
+
+// Provider records every message instead of sending it. Send fails with Err
+// when it is set.
+type Provider struct {
+	mu       sync.Mutex
+	messages []Message
+
+	Err error
+}
+
+func NewProvider() *Provider {
+	return &Provider{}
+}
+
+func (p *Provider) Send(_ context.Context, to, text string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.Err != nil {
+		return p.Err
+	}
+
+	p.messages = append(p.messages, Message{To: to, Text: text})
+
+	return nil
+}
+
+// Messages returns the recorded messages in the order they were sent.
+func (p *Provider) Messages() []Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]Message(nil), p.messages...)
+}
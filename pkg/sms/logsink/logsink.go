@@ -0,0 +1,24 @@
+package logsink
+
+import (
+	"context"
+	"medods-test-task/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// Provider only logs outgoing messages. The text is left out of the log
+// because it may carry links that grant access to the account.
+type Provider struct {
+	logger logger.Logger
+}
+
+func NewProvider(logger logger.Logger) *Provider {
+	return &Provider{logger: logger}
+}
+
+func (p *Provider) Send(ctx context.Context, to, text string) error {
+	p.logger.Info(ctx, "sms sent to log sink", zap.String("to", to), zap.Int("text_length", len([]rune(text))))
+
+	return nil
+}
@@ -0,0 +1,11 @@
+// Package sms holds helpers shared by the SMS providers.
+package sms
+
+import "regexp"
+
+var phoneRegexp = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+
+// IsValidPhone reports whether phone is an E.164 number such as +79991234567.
+func IsValidPhone(phone string) bool {
+	return phoneRegexp.MatchString(phone)
+}
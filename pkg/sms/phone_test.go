@@ -0,0 +1,25 @@
+package sms
+
+import "testing"
+
+func TestIsValidPhone(t *testing.T) {
+	tests := []struct {
+		phone    string
+		expected bool
+	}{
+		{phone: "+79991234567", expected: true},
+		{phone: "+14155552671", expected: true},
+		{phone: "79991234567", expected: false},
+		{phone: "+0991234567", expected: false},
+		{phone: "+7 999 123 45 67", expected: false},
+		{phone: "+7999", expected: false},
+		{phone: "", expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.phone, func(t *testing.T) {
+			if got := IsValidPhone(tt.phone); got != tt.expected {
+				t.Errorf("IsValidPhone(%q) = %v, expected %v", tt.phone, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"medods-test-task/internal/models"
+	"slices"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// maxAssertionLifetime limits how far in the future a client assertion may
+// expire, which bounds how long its id has to be remembered.
+const maxAssertionLifetime = 10 * time.Minute
+
+// audience accepts the aud claim both as a string and as an array.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+
+	*a = list
+
+	return nil
+}
+
+// assertionClaims are the claims of a private_key_jwt assertion, see
+// RFC 7523 section 3.
+type assertionClaims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	ID        string   `json:"jti"`
+}
+
+func (c *assertionClaims) Valid() error {
+	now := time.Now()
+
+	if c.ExpiresAt == 0 || now.Unix() >= c.ExpiresAt {
+		return models.ErrTokenExpired
+	}
+
+	if time.Unix(c.ExpiresAt, 0).Sub(now) > maxAssertionLifetime {
+		return models.ErrInvalidToken
+	}
+
+	if c.NotBefore != 0 && now.Unix() < c.NotBefore {
+		return models.ErrInvalidToken
+	}
+
+	return nil
+}
+
+// ParseClientAssertion verifies a private_key_jwt assertion of the client
+// signed with the key matching publicKey. The assertion must be issued by the
+// client about itself to one of the audiences and have an id.
+func (m *Manager) ParseClientAssertion(assertion, publicKey, clientID string, audiences []string) (*models.ClientAssertion, error) {
+	key, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims assertionClaims
+
+	_, err = jwt.ParseWithClaims(assertion, &claims, func(token *jwt.Token) (interface{}, error) {
+		switch key.(type) {
+		case *rsa.PublicKey:
+			if _, ok := token.Method.(*jwt.SigningMethodRSA); ok {
+				return key, nil
+			}
+
+			if _, ok := token.Method.(*jwt.SigningMethodRSAPSS); ok {
+				return key, nil
+			}
+		case *ecdsa.PublicKey:
+			if _, ok := token.Method.(*jwt.SigningMethodECDSA); ok {
+				return key, nil
+			}
+		}
+
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	})
+	if err != nil {
+		return nil, models.ErrInvalidToken
+	}
+
+	if claims.Issuer != clientID || claims.Subject != clientID || claims.ID == "" {
+		return nil, models.ErrInvalidToken
+	}
+
+	if !slices.ContainsFunc(claims.Audience, func(aud string) bool { return slices.Contains(audiences, aud) }) {
+		return nil, models.ErrInvalidToken
+	}
+
+	return &models.ClientAssertion{
+		ID:        claims.ID,
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+	}, nil
+}
+
+// ParsePublicKey decodes a PEM encoded RSA or EC public key.
+func ParsePublicKey(pemKey string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, models.ErrInvalidClientKey
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", models.ErrInvalidClientKey, err)
+	}
+
+	switch key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+		return key, nil
+	default:
+		return nil, models.ErrInvalidClientKey
+	}
+}
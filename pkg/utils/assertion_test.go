@@ -0,0 +1,166 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"medods-test-task/internal/models"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const testAudience = "https://auth.medods.ru/oauth/token"
+
+func encodePublicKey(t *testing.T, key interface{}) string {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func TestManager_ParseClientAssertion(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss": "worker",
+			"sub": "worker",
+			"aud": testAudience,
+			"exp": time.Now().Add(time.Minute).Unix(),
+			"jti": "assertion-id",
+		}
+	}
+
+	tests := []struct {
+		name        string
+		method      jwt.SigningMethod
+		signKey     interface{}
+		publicKey   string
+		modify      func(claims jwt.MapClaims)
+		expectedErr error
+	}{
+		{
+			name:      "RSA",
+			method:    jwt.SigningMethodRS256,
+			signKey:   rsaKey,
+			publicKey: encodePublicKey(t, &rsaKey.PublicKey),
+		},
+		{
+			name:      "EC",
+			method:    jwt.SigningMethodES256,
+			signKey:   ecKey,
+			publicKey: encodePublicKey(t, &ecKey.PublicKey),
+		},
+		{
+			name:      "Audience array",
+			method:    jwt.SigningMethodES256,
+			signKey:   ecKey,
+			publicKey: encodePublicKey(t, &ecKey.PublicKey),
+			modify:    func(claims jwt.MapClaims) { claims["aud"] = []string{"other", testAudience} },
+		},
+		{
+			name:        "Other key",
+			method:      jwt.SigningMethodES256,
+			signKey:     otherKey,
+			publicKey:   encodePublicKey(t, &ecKey.PublicKey),
+			expectedErr: models.ErrInvalidToken,
+		},
+		{
+			name:        "Other audience",
+			method:      jwt.SigningMethodES256,
+			signKey:     ecKey,
+			publicKey:   encodePublicKey(t, &ecKey.PublicKey),
+			modify:      func(claims jwt.MapClaims) { claims["aud"] = "https://evil.example.com" },
+			expectedErr: models.ErrInvalidToken,
+		},
+		{
+			name:        "Other issuer",
+			method:      jwt.SigningMethodES256,
+			signKey:     ecKey,
+			publicKey:   encodePublicKey(t, &ecKey.PublicKey),
+			modify:      func(claims jwt.MapClaims) { claims["iss"] = "backend" },
+			expectedErr: models.ErrInvalidToken,
+		},
+		{
+			name:        "Missing id",
+			method:      jwt.SigningMethodES256,
+			signKey:     ecKey,
+			publicKey:   encodePublicKey(t, &ecKey.PublicKey),
+			modify:      func(claims jwt.MapClaims) { delete(claims, "jti") },
+			expectedErr: models.ErrInvalidToken,
+		},
+		{
+			name:        "Expired",
+			method:      jwt.SigningMethodES256,
+			signKey:     ecKey,
+			publicKey:   encodePublicKey(t, &ecKey.PublicKey),
+			modify:      func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Minute).Unix() },
+			expectedErr: models.ErrInvalidToken,
+		},
+		{
+			name:        "Too long lifetime",
+			method:      jwt.SigningMethodES256,
+			signKey:     ecKey,
+			publicKey:   encodePublicKey(t, &ecKey.PublicKey),
+			modify:      func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(time.Hour).Unix() },
+			expectedErr: models.ErrInvalidToken,
+		},
+		{
+			name:        "HMAC with public key",
+			method:      jwt.SigningMethodHS256,
+			signKey:     []byte(encodePublicKey(t, &ecKey.PublicKey)),
+			publicKey:   encodePublicKey(t, &ecKey.PublicKey),
+			expectedErr: models.ErrInvalidToken,
+		},
+		{
+			name:        "Invalid public key",
+			method:      jwt.SigningMethodES256,
+			signKey:     ecKey,
+			publicKey:   "not a key",
+			expectedErr: models.ErrInvalidClientKey,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := valid()
+			if tt.modify != nil {
+				tt.modify(claims)
+			}
+
+			assertion, err := jwt.NewWithClaims(tt.method, claims).SignedString(tt.signKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			parsed, err := (&Manager{}).ParseClientAssertion(assertion, tt.publicKey, "worker", []string{testAudience})
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("error = %v, expectedError %v", err, tt.expectedErr)
+			}
+
+			if err == nil && parsed.ID != "assertion-id" {
+				t.Errorf("id = %q, expected %q", parsed.ID, "assertion-id")
+			}
+		})
+	}
+}
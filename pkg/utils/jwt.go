@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"medods-test-task/internal/models"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
@@ -15,36 +17,103 @@ import (
 
 const (
 	refreshTokenLength = 16
+
+	accessTokenSubject = "access"
+	revokeTokenSubject = "revoke"
+
+	// APIKeySubject is the Subject of claims describing a request made with an
+	// api key rather than a token. Such claims are never signed.
+	APIKeySubject = "api_key"
 )
 
 type Config interface {
 	GetAuthJWTSecret() string
 	GetAccessTokenExpiration() time.Duration
 	GetRefreshTokenExpiration() time.Duration
+	GetRevokeTokenExpiration() time.Duration
 }
 
 type TokenManager interface {
-	NewTokenPair(userID uuid.UUID, IPAddress string) (string, string, error)
+	NewTokenPair(userID uuid.UUID, clientID, IPAddress string, accessTTL time.Duration, roles, scope []string) (string, string, error)
+	NewClientToken(clientID string, scope []string, ttl time.Duration) (string, error)
+	ParseClientAssertion(assertion, publicKey, clientID string, audiences []string) (*models.ClientAssertion, error)
 	SignToken(claims Claims) (string, error)
 	ParseJWT(token string) (*Claims, error)
 	ParseRefreshToken(refreshToken string) (uuid.UUID, error)
+	NewRevokeToken(userID uuid.UUID) (string, error)
+	ParseRevokeToken(token string) (uuid.UUID, error)
 	HashToken(password string) (string, error)
 	ValidateToken(token, hashedToken string) error
 	GetAccessTTL() time.Duration
 	GetRefreshTTL() time.Duration
 }
 
+// Claims of the tokens signed by the Manager. Access tokens issued to a user
+// carry UserID and the roles of the user, tokens of the client credentials
+// grant carry ClientID instead and have it as the standard sub claim. Scope
+// holds the granted scopes separated by spaces.
+//
+// Tokens issued by token exchange name the audience they are meant for and
+// the Actor acting on behalf of the user. Impersonated is set when an admin
+// acts as the user.
+//
+// Legacy is set on tokens of the legacy login, which asks for nothing but a
+// user id, and is kept on tokens exchanged from them.
 type Claims struct {
-	UserID    uuid.UUID
-	IPAddress string
-	Subject   string
+	UserID       uuid.UUID
+	IPAddress    string
+	Subject      string
+	ClientID     string   `json:"client_id,omitempty"`
+	Roles        []string `json:"roles,omitempty"`
+	Scope        string   `json:"scope,omitempty"`
+	Actor        *Actor   `json:"act,omitempty"`
+	Impersonated bool     `json:"impersonated,omitempty"`
+	Legacy       bool     `json:"legacy,omitempty"`
 	jwt.StandardClaims
 }
 
+// Actor is the act claim of RFC 8693. Subject is a client id when ClientID is
+// set and a user id otherwise, Actor is the previous actor of a delegation
+// chain.
+type Actor struct {
+	Subject  string `json:"sub"`
+	ClientID string `json:"client_id,omitempty"`
+	Actor    *Actor `json:"act,omitempty"`
+}
+
+// HasScope reports whether the scope was granted to the token.
+func (c *Claims) HasScope(scope string) bool {
+	return slices.Contains(strings.Fields(c.Scope), scope)
+}
+
+// HasRole reports whether the user of the token has the role.
+func (c *Claims) HasRole(role string) bool {
+	return slices.Contains(c.Roles, role)
+}
+
+// IsAPIKey reports whether the claims describe an api key rather than an
+// access token.
+func (c *Claims) IsAPIKey() bool {
+	return c.Subject == APIKeySubject
+}
+
+// IsLegacy reports whether the token comes from the legacy login, which
+// does not prove who the user is.
+func (c *Claims) IsLegacy() bool {
+	return c.Legacy
+}
+
+// IsClient reports whether the token was issued to a client acting on its
+// own behalf.
+func (c *Claims) IsClient() bool {
+	return c.UserID == uuid.Nil
+}
+
 type Manager struct {
 	secret     string
 	accessTTL  time.Duration
 	refreshTTL time.Duration
+	revokeTTL  time.Duration
 }
 
 func NewManager(cfg Config) *Manager {
@@ -52,16 +121,27 @@ func NewManager(cfg Config) *Manager {
 		secret:     cfg.GetAuthJWTSecret(),
 		accessTTL:  cfg.GetAccessTokenExpiration(),
 		refreshTTL: cfg.GetRefreshTokenExpiration(),
+		revokeTTL:  cfg.GetRevokeTokenExpiration(),
 	}
 }
 
-func (m *Manager) NewTokenPair(userID uuid.UUID, IPAddress string) (string, string, error) {
+// NewTokenPair issues an access token with the roles and scope of the user
+// that lives for accessTTL, or for the configured ttl when accessTTL is zero.
+// An empty clientID marks the token as one of the legacy login.
+func (m *Manager) NewTokenPair(userID uuid.UUID, clientID, IPAddress string, accessTTL time.Duration, roles, scope []string) (string, string, error) {
+	if accessTTL == 0 {
+		accessTTL = m.accessTTL
+	}
+
 	accessClaims := Claims{
 		UserID:    userID,
 		IPAddress: IPAddress,
-		Subject:   "access",
+		Subject:   accessTokenSubject,
+		Roles:     roles,
+		Scope:     strings.Join(scope, " "),
+		Legacy:    clientID == "",
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(m.accessTTL).Unix(),
+			ExpiresAt: time.Now().Add(accessTTL).Unix(),
 			IssuedAt:  time.Now().Unix(),
 		},
 	}
@@ -82,6 +162,28 @@ func (m *Manager) NewTokenPair(userID uuid.UUID, IPAddress string) (string, stri
 	return accessToken, base64.URLEncoding.EncodeToString(refreshToken), nil
 }
 
+// NewClientToken issues an access token to a client acting on its own behalf.
+// There is no refresh token, the client authenticates again instead.
+func (m *Manager) NewClientToken(clientID string, scope []string, ttl time.Duration) (string, error) {
+	if ttl == 0 {
+		ttl = m.accessTTL
+	}
+
+	claims := Claims{
+		Subject:  accessTokenSubject,
+		ClientID: clientID,
+		Scope:    strings.Join(scope, " "),
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.NewString(),
+			Subject:   clientID,
+			ExpiresAt: time.Now().Add(ttl).Unix(),
+			IssuedAt:  time.Now().Unix(),
+		},
+	}
+
+	return m.SignToken(claims)
+}
+
 func (m *Manager) SignToken(claims Claims) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
 
@@ -89,23 +191,36 @@ func (m *Manager) SignToken(claims Claims) (string, error) {
 }
 
 func (m *Manager) ParseJWT(accessToken string) (*Claims, error) {
-	token, err := jwt.Parse(accessToken, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+	var claims Claims
+
+	token, err := jwt.ParseWithClaims(accessToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodHS512 {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return []byte(m.secret), nil
 	})
-
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse jwt token: %w", err)
+		var validationErr *jwt.ValidationError
+		if errors.As(err, &validationErr) && validationErr.Errors&jwt.ValidationErrorExpired != 0 {
+			return nil, models.ErrTokenExpired
+		}
+
+		return nil, models.ErrInvalidToken
 	}
 
-	claims, ok := token.Claims.(*Claims)
-	if !ok || !token.Valid {
+	if !token.Valid || claims.Subject != accessTokenSubject {
 		return nil, models.ErrInvalidToken
 	}
 
-	return claims, nil
+	if claims.IsClient() && (claims.ClientID == "" || claims.StandardClaims.Subject != claims.ClientID) {
+		return nil, models.ErrInvalidToken
+	}
+
+	if claims.Impersonated && claims.Actor == nil {
+		return nil, models.ErrInvalidToken
+	}
+
+	return &claims, nil
 }
 
 func (m *Manager) ParseRefreshToken(refreshToken string) (uuid.UUID, error) {
@@ -126,6 +241,47 @@ func (m *Manager) ParseRefreshToken(refreshToken string) (uuid.UUID, error) {
 	return userID, nil
 }
 
+// NewRevokeToken signs the token of the "This wasn't me" link sent in
+// security alerts.
+func (m *Manager) NewRevokeToken(userID uuid.UUID) (string, error) {
+	claims := Claims{
+		UserID:  userID,
+		Subject: revokeTokenSubject,
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.NewString(),
+			ExpiresAt: time.Now().Add(m.revokeTTL).Unix(),
+			IssuedAt:  time.Now().Unix(),
+		},
+	}
+
+	return m.SignToken(claims)
+}
+
+func (m *Manager) ParseRevokeToken(token string) (uuid.UUID, error) {
+	var claims Claims
+
+	_, err := jwt.ParseWithClaims(token, &claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodHS512 {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(m.secret), nil
+	})
+	if err != nil {
+		var validationErr *jwt.ValidationError
+		if errors.As(err, &validationErr) && validationErr.Errors&jwt.ValidationErrorExpired != 0 {
+			return uuid.UUID{}, models.ErrTokenExpired
+		}
+
+		return uuid.UUID{}, models.ErrInvalidToken
+	}
+
+	if claims.Subject != revokeTokenSubject || claims.UserID == uuid.Nil {
+		return uuid.UUID{}, models.ErrInvalidToken
+	}
+
+	return claims.UserID, nil
+}
+
 func (m *Manager) HashToken(token string) (string, error) {
 	hashed, err := bcrypt.GenerateFromPassword([]byte(token), bcrypt.DefaultCost)
 	if err != nil {
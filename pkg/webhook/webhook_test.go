@@ -0,0 +1,153 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSender_Send(t *testing.T) {
+	secret := "whsec"
+	body := []byte(`{"type":"session.created"}`)
+
+	tests := []struct {
+		name          string
+		receiverCode  int
+		receiverKey   string
+		expectSendErr bool
+		expectVerify  error
+	}{
+		{
+			name:         "OK",
+			receiverCode: http.StatusOK,
+			receiverKey:  secret,
+			expectVerify: nil,
+		},
+		{
+			name:          "Receiver failure",
+			receiverCode:  http.StatusInternalServerError,
+			receiverKey:   secret,
+			expectSendErr: true,
+			expectVerify:  nil,
+		},
+		{
+			name:         "Wrong secret",
+			receiverCode: http.StatusOK,
+			receiverKey:  "other",
+			expectVerify: ErrInvalidSignature,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var verifyErr error
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received, _ := io.ReadAll(r.Body)
+				verifyErr = Verify(tt.receiverKey, r.Header, received, 5*time.Minute, time.Now())
+
+				if r.Header.Get(IDHeader) != "delivery-1" {
+					t.Errorf("unexpected delivery id %q", r.Header.Get(IDHeader))
+				}
+
+				w.WriteHeader(tt.receiverCode)
+			}))
+			defer srv.Close()
+
+			err := NewSender(time.Second).Send(context.Background(), srv.URL, secret, "delivery-1", body)
+			if (err != nil) != tt.expectSendErr {
+				t.Errorf("send error = %v, expected error %v", err, tt.expectSendErr)
+			}
+
+			if !errors.Is(verifyErr, tt.expectVerify) {
+				t.Errorf("verify error = %v, expected %v", verifyErr, tt.expectVerify)
+			}
+		})
+	}
+}
+
+func TestSender_SendNonPublic(t *testing.T) {
+	called := false
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	err := NewPublicSender(time.Second).Send(context.Background(), srv.URL, "whsec", "delivery-1", []byte(`{}`))
+	if !errors.Is(err, ErrNonPublicAddress) {
+		t.Errorf("error = %v, expected %v", err, ErrNonPublicAddress)
+	}
+
+	if called {
+		t.Errorf("receiver on a loopback address should not be called")
+	}
+}
+
+func TestSender_SendRedirect(t *testing.T) {
+	redirected := false
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirected = true
+	}))
+	defer target.Close()
+
+	srv := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer srv.Close()
+
+	err := newSender(time.Second, nil).Send(context.Background(), srv.URL, "whsec", "delivery-1", []byte(`{}`))
+	if err == nil {
+		t.Errorf("expected error on redirect")
+	}
+
+	if redirected {
+		t.Errorf("redirect should not be followed")
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	tests := []struct {
+		addr   string
+		public bool
+	}{
+		{addr: "93.184.216.34", public: true},
+		{addr: "2606:2800:220:1:248:1893:25c8:1946", public: true},
+		{addr: "127.0.0.1", public: false},
+		{addr: "10.0.0.1", public: false},
+		{addr: "172.16.0.1", public: false},
+		{addr: "192.168.1.1", public: false},
+		{addr: "169.254.169.254", public: false},
+		{addr: "100.64.0.1", public: false},
+		{addr: "0.0.0.0", public: false},
+		{addr: "::1", public: false},
+		{addr: "fe80::1", public: false},
+		{addr: "fd00::1", public: false},
+		{addr: "::ffff:127.0.0.1", public: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := IsPublic(netip.MustParseAddr(tt.addr)); got != tt.public {
+				t.Errorf("IsPublic(%s) = %v, expected %v", tt.addr, got, tt.public)
+			}
+		})
+	}
+}
+
+func TestVerify_Replay(t *testing.T) {
+	secret := "whsec"
+	body := []byte(`{}`)
+	sent := time.Now().Add(-time.Hour)
+
+	header := http.Header{}
+	header.Set(TimestampHeader, strconv.FormatInt(sent.Unix(), 10))
+	header.Set(SignatureHeader, Sign(secret, sent.Unix(), body))
+
+	if err := Verify(secret, header, body, 5*time.Minute, time.Now()); !errors.Is(err, ErrStaleTimestamp) {
+		t.Errorf("error = %v, expected %v", err, ErrStaleTimestamp)
+	}
+}
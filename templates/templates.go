@@ -0,0 +1,12 @@
+// Package templates embeds the email templates.
+//
+// Every locale has its own directory with <name>.subject.tmpl,
+// <name>.html.tmpl and <name>.txt.tmpl files. Bodies define a "content"
+// block that is rendered inside layout.html.tmpl or layout.txt.tmpl. Shared
+// blocks live in partials.
+package templates
+
+import "embed"
+
+//go:embed *.tmpl */*.tmpl
+var FS embed.FS
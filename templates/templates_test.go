@@ -0,0 +1,48 @@
+package templates
+
+import (
+	"medods-test-task/pkg/email/render"
+	"testing"
+)
+
+func TestTemplates(t *testing.T) {
+	r, err := render.New(FS, "ru")
+	if err != nil {
+		t.Fatalf("failed to parse templates: %v", err)
+	}
+
+	data := map[string]any{
+		"Until":      "Mon, 02 Jan 2006 15:04:05 MST",
+		"OldIP":      "10.0.0.1",
+		"NewIP":      "10.1.0.1",
+		"IP":         "10.1.0.1",
+		"UserAgent":  "test-agent",
+		"Location":   "Moscow, Russia",
+		"Time":       "Mon, 02 Jan 2006 15:04:05 MST",
+		"RevokeURL":  "http://localhost:8080/v1/auth/revoke?token=token",
+		"NewEmail":   "new@email.com",
+		"ConfirmURL": "http://localhost:8080/v1/auth/email/confirm?token=token",
+		"ExpiresAt":  "Mon, 02 Jan 2006 15:04:05 MST",
+		"Since":      "Mon, 02 Jan 2006 15:04:05 MST",
+		"Total":      3,
+		"Items": []map[string]any{
+			{"Kind": "ip_warning", "Count": 2},
+			{"Kind": "account_locked", "Count": 1},
+		},
+	}
+
+	for _, locale := range []string{"ru", "en"} {
+		for _, name := range []string{"ip_warning", "account_locked", "new_device", "password_change", "session_revoked", "email_change_confirm", "email_change_notice", "digest"} {
+			t.Run(locale+"/"+name, func(t *testing.T) {
+				email, err := r.Render(name, locale, data)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				if email.Subject == "" || email.HTML == "" || email.Text == "" {
+					t.Errorf("empty part in %+v", email)
+				}
+			})
+		}
+	}
+}